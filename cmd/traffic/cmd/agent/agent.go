@@ -32,19 +32,24 @@ type Config struct {
 	ManagerHost string `env:"_TEL_AGENT_MANAGER_HOST,default=traffic-manager"`
 	ManagerPort int32  `env:"_TEL_AGENT_MANAGER_PORT,default=8081"`
 	APIPort     int32  `env:"TELEPRESENCE_API_PORT,default="`
+
+	// TunnelFaults are network faults, e.g. "latency=100ms,drop=1%", that are injected into the tunnels
+	// in order to simulate a poor connection. Intended for testing only.
+	TunnelFaults string `env:"_TEL_AGENT_TUNNEL_FAULTS,default="`
 }
 
 var skipKeys = map[string]bool{
 	// Keys found in the Config
-	"_TEL_AGENT_NAME":         true,
-	"_TEL_AGENT_NAMESPACE":    true,
-	"_TEL_AGENT_POD_IP":       true,
-	"_TEL_AGENT_PORT":         true,
-	"_TEL_AGENT_APP_MOUNTS":   true,
-	"_TEL_AGENT_APP_PORT":     true,
-	"_TEL_AGENT_MANAGER_HOST": true,
-	"_TEL_AGENT_MANAGER_PORT": true,
-	"_TEL_AGENT_LOG_LEVEL":    true,
+	"_TEL_AGENT_NAME":          true,
+	"_TEL_AGENT_NAMESPACE":     true,
+	"_TEL_AGENT_POD_IP":        true,
+	"_TEL_AGENT_PORT":          true,
+	"_TEL_AGENT_APP_MOUNTS":    true,
+	"_TEL_AGENT_APP_PORT":      true,
+	"_TEL_AGENT_MANAGER_HOST":  true,
+	"_TEL_AGENT_MANAGER_PORT":  true,
+	"_TEL_AGENT_LOG_LEVEL":     true,
+	"_TEL_AGENT_TUNNEL_FAULTS": true,
 
 	// Keys that aren't useful when running on the local machine
 	"HOME":     true,
@@ -73,6 +78,11 @@ func AppEnvironment() map[string]string {
 	for k, v := range appEnv {
 		fullEnv[k] = v
 	}
+
+	// The HOSTNAME is skipped, but a local container may want to assume the hostname of the pod.
+	if hostname, err := os.Hostname(); err == nil {
+		fullEnv["TELEPRESENCE_HOSTNAME"] = hostname
+	}
 	return fullEnv
 }
 
@@ -209,6 +219,14 @@ func Main(ctx context.Context, args ...string) error {
 		return err
 	}
 	dlog.Infof(ctx, "%+v", config)
+	faults, err := tunnel.ParseFaults(config.TunnelFaults)
+	if err != nil {
+		return fmt.Errorf("invalid _TEL_AGENT_TUNNEL_FAULTS: %w", err)
+	}
+	if faults.Enabled() {
+		dlog.Warnf(ctx, "Injecting faults into tunnels: %s", faults)
+		ctx = tunnel.WithFaults(ctx, faults)
+	}
 
 	info := &rpc.AgentInfo{
 		Name:        config.Name,
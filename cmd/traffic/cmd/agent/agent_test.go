@@ -0,0 +1,29 @@
+package agent_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/agent"
+)
+
+func TestAppEnvironment(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("_TEL_AGENT_NAME", "echo")
+	t.Setenv("HOSTNAME", "echo-7f6c9")
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	env := agent.AppEnvironment()
+	assert.Equal(t, "debug", env["LOG_LEVEL"])
+	assert.NotContains(t, env, "_TEL_AGENT_NAME")
+	assert.NotContains(t, env, "HOME")
+	assert.NotContains(t, env, "PATH")
+
+	// The HOSTNAME is skipped, but the hostname of the pod is passed on using another name.
+	assert.NotContains(t, env, "HOSTNAME")
+	assert.Equal(t, hostname, env["TELEPRESENCE_HOSTNAME"])
+}
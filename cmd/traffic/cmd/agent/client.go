@@ -41,11 +41,34 @@ func GetAmbassadorCloudConnectionInfo(ctx context.Context, address string) (*rpc
 	return cloudConnectInfo, nil
 }
 
+// serviceAccountTokenFile is where Kubernetes mounts the token of the pod's service account.
+const serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// agentToken presents the agent's service account token on each call, so that a traffic-manager that
+// authenticates its clients can verify the agent. The file is read on each call because Kubernetes
+// rotates the token.
+type agentToken string
+
+func (t agentToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	token, err := os.ReadFile(string(t))
+	if err != nil {
+		// The pod doesn't mount its token. That's fine unless the manager requires it.
+		return nil, nil
+	}
+	return map[string]string{install.AgentTokenMetadataKey: strings.TrimSpace(string(token))}, nil
+}
+
+// RequireTransportSecurity returns false. Agents talk to the traffic-manager over the cluster network.
+func (agentToken) RequireTransportSecurity() bool {
+	return false
+}
+
 func TalkToManager(ctx context.Context, address string, info *rpc.AgentInfo, state State) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, address, grpc.WithInsecure(), grpc.WithBlock())
+	conn, err := grpc.DialContext(ctx, address, grpc.WithInsecure(), grpc.WithBlock(),
+		grpc.WithPerRPCCredentials(agentToken(serviceAccountTokenFile)))
 	if err != nil {
 		return err
 	}
@@ -78,11 +78,13 @@ func (s *state) HandleIntercepts(ctx context.Context, cepts []*manager.Intercept
 				activeIntercept = chosenIntercept
 			}
 		} else {
-			// The chosen intercept was deleted by the user
+			// The chosen intercept was deleted by the user, or it was transferred to another
+			// client, in which case it's still ACTIVE but re-keyed under that client's session.
 			dlog.Info(ctx, "The previously-active intercept has been deleted")
 			s.chosenID = ""
 		}
-	} else {
+	}
+	if s.chosenID == "" {
 		// Attach to already ACTIVE intercept if there is one.
 		for _, cept := range cepts {
 			if cept.Disposition == manager.InterceptDispositionType_ACTIVE {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/datawire/dlib/dlog"
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
@@ -131,6 +132,19 @@ func TestState_HandleIntercepts(t *testing.T) {
 	a.Equal(rpc.InterceptDispositionType_AGENT_ERROR, reviews[0].Disposition)
 	a.Equal("Conflicts with the currently-served intercept \"intercept-01\"", reviews[0].Message)
 
+	// Handle keeps intercepting when the active intercept is transferred to another client
+
+	transferred := proto.Clone(cepts[0]).(*rpc.InterceptInfo)
+	transferred.Id = "intercept-03"
+	transferred.Spec.Client = "user@host3"
+	transferred.ClientSession = &rpc.SessionInfo{SessionId: "session-03"}
+	cepts = []*rpc.InterceptInfo{transferred, cepts[1]}
+
+	reviews = s.HandleIntercepts(ctx, cepts)
+	a.Len(reviews, 1)
+	a.True(f.Intercepting())
+	a.Equal("Conflicts with the currently-served intercept \"intercept-03\"", reviews[0].Message)
+
 	// Handle resets state on an empty intercept list again
 
 	reviews = s.HandleIntercepts(ctx, nil)
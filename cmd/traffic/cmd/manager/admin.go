@@ -0,0 +1,401 @@
+package manager
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed" // embed needs to be imported for the go:embed directive to work
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// The JSON types of the admin API. They deliberately omit API keys and agent environments.
+
+type adminClient struct {
+	SessionID string `json:"session_id"`
+	Name      string `json:"name"`
+	InstallID string `json:"install_id"`
+	Product   string `json:"product"`
+	Version   string `json:"version"`
+	Identity  string `json:"identity,omitempty"`
+}
+
+type adminAgent struct {
+	SessionID  string   `json:"session_id"`
+	Name       string   `json:"name"`
+	Namespace  string   `json:"namespace"`
+	PodIP      string   `json:"pod_ip"`
+	Product    string   `json:"product"`
+	Version    string   `json:"version"`
+	Mechanisms []string `json:"mechanisms"`
+}
+
+type adminIntercept struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	SessionID     string `json:"session_id"`
+	Client        string `json:"client"`
+	Agent         string `json:"agent"`
+	Namespace     string `json:"namespace"`
+	Mechanism     string `json:"mechanism"`
+	Disposition   string `json:"disposition"`
+	Message       string `json:"message,omitempty"`
+	PreviewDomain string `json:"preview_domain,omitempty"`
+}
+
+type adminClusterInfo struct {
+	ClusterID      string   `json:"cluster_id"`
+	ClusterDomain  string   `json:"cluster_domain"`
+	KubeDNSIPs     []string `json:"kube_dns_ips"`
+	ServiceSubnets []string `json:"service_subnets"`
+	PodSubnets     []string `json:"pod_subnets"`
+}
+
+type adminErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// adminHandler returns the handler of the traffic-manager's admin API and dashboard. The API is
+// read-only, except for the actions that remove an intercept or expire a session. All API requests
+// require the ADMIN_TOKEN, because the listed session IDs grant access to the sessions. Only the
+// dashboard itself is served without it.
+func (m *Manager) adminHandler(ctx context.Context) http.Handler {
+	adminToken := managerutil.GetEnv(ctx).AdminToken
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/clients", adminGet(adminToken, m.adminClients))
+	mux.HandleFunc("/api/agents", adminGet(adminToken, m.adminAgents))
+	mux.HandleFunc("/api/intercepts", adminGet(adminToken, m.adminIntercepts))
+	mux.HandleFunc("/api/cluster-info", adminGet(adminToken, m.adminClusterInfo))
+	mux.HandleFunc("/api/clients/", adminAction(adminToken, "/api/clients/", m.adminExpireSession))
+	mux.HandleFunc("/api/intercepts/", adminAction(adminToken, "/api/intercepts/", m.adminRemoveIntercept))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			writeAdminError(w, http.StatusNotFound, "%s not found", r.URL.Path)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(dashboardHTML)
+	})
+	return mux
+}
+
+// adminGet returns a handler that responds to GET requests that present the admin token with the
+// JSON encoding of the value returned by the given function.
+func adminGet(adminToken string, get func(*http.Request) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeAdminError(w, http.StatusMethodNotAllowed, "method %s is not allowed", r.Method)
+			return
+		}
+		if !checkAdminToken(w, r, adminToken) {
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, get(r))
+	}
+}
+
+// adminAction returns a handler that responds to DELETE requests that present the admin token by
+// calling the given function with the ID that follows the prefix in the path.
+func adminAction(adminToken, prefix string, action func(context.Context, string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			writeAdminError(w, http.StatusMethodNotAllowed, "method %s is not allowed", r.Method)
+			return
+		}
+		if !checkAdminToken(w, r, adminToken) {
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, prefix)
+		if id == "" || strings.Contains(id, "/") {
+			writeAdminError(w, http.StatusNotFound, "%s not found", r.URL.Path)
+			return
+		}
+		if err := action(r.Context(), id); err != nil {
+			code := http.StatusInternalServerError
+			if status.Code(err) == codes.NotFound {
+				code = http.StatusNotFound
+			}
+			writeAdminError(w, code, "%s", status.Convert(err).Message())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// checkAdminToken writes an error response and returns false unless the given request presents the
+// admin token in its Authorization header.
+func checkAdminToken(w http.ResponseWriter, r *http.Request, adminToken string) bool {
+	if adminToken == "" {
+		writeAdminError(w, http.StatusForbidden, "the admin API is disabled because no admin token has been configured")
+		return false
+	}
+	if !validAdminToken(adminToken, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+		writeAdminError(w, http.StatusUnauthorized, "invalid admin token")
+		return false
+	}
+	return true
+}
+
+// validAdminToken returns true if an admin token is configured and the given token is equal to it.
+func validAdminToken(adminToken, token string) bool {
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	writeAdminJSON(w, code, &adminErrorResponse{Error: fmt.Sprintf(format, args...)})
+}
+
+func (m *Manager) adminClients(*http.Request) interface{} {
+	clients := m.state.GetAllClients()
+	result := make([]*adminClient, 0, len(clients))
+	for sessionID, client := range clients {
+		result = append(result, &adminClient{
+			SessionID: sessionID,
+			Name:      client.Name,
+			InstallID: client.InstallId,
+			Product:   client.Product,
+			Version:   client.Version,
+			Identity:  client.Identity,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name == result[j].Name {
+			return result[i].SessionID < result[j].SessionID
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (m *Manager) adminAgents(*http.Request) interface{} {
+	agents := m.state.GetAllAgents()
+	result := make([]*adminAgent, 0, len(agents))
+	for sessionID, agent := range agents {
+		mechanisms := make([]string, len(agent.Mechanisms))
+		for i, mech := range agent.Mechanisms {
+			mechanisms[i] = mech.Name
+		}
+		result = append(result, &adminAgent{
+			SessionID:  sessionID,
+			Name:       agent.Name,
+			Namespace:  agent.Namespace,
+			PodIP:      agent.PodIp,
+			Product:    agent.Product,
+			Version:    agent.Version,
+			Mechanisms: mechanisms,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].SessionID < result[j].SessionID
+	})
+	return result
+}
+
+// currentIntercepts returns all current intercepts.
+func (m *Manager) currentIntercepts(ctx context.Context) map[string]*rpc.InterceptInfo {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The first snapshot contains all current intercepts
+	snapshot := <-m.state.WatchIntercepts(ctx, nil)
+	return snapshot.State
+}
+
+func (m *Manager) adminIntercepts(r *http.Request) interface{} {
+	intercepts := m.currentIntercepts(r.Context())
+	result := make([]*adminIntercept, 0, len(intercepts))
+	for _, ii := range intercepts {
+		spec := ii.Spec
+		result = append(result, &adminIntercept{
+			ID:            ii.Id,
+			Name:          spec.Name,
+			SessionID:     ii.ClientSession.GetSessionId(),
+			Client:        spec.Client,
+			Agent:         spec.Agent,
+			Namespace:     spec.Namespace,
+			Mechanism:     spec.Mechanism,
+			Disposition:   ii.Disposition.String(),
+			Message:       ii.Message,
+			PreviewDomain: ii.PreviewDomain,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func (m *Manager) adminClusterInfo(*http.Request) interface{} {
+	ci := m.clusterInfo.GetClusterInfo()
+	result := &adminClusterInfo{
+		ClusterID:      m.clusterInfo.GetClusterID(),
+		ClusterDomain:  ci.ClusterDomain,
+		KubeDNSIPs:     make([]string, len(ci.KubeDnsIps)),
+		ServiceSubnets: make([]string, len(ci.ServiceSubnets)),
+		PodSubnets:     make([]string, len(ci.PodSubnets)),
+	}
+	for i, ip := range ci.KubeDnsIps {
+		result.KubeDNSIPs[i] = net.IP(ip).String()
+	}
+	for i, sn := range ci.ServiceSubnets {
+		result.ServiceSubnets[i] = iputil.IPNetFromRPC(sn).String()
+	}
+	for i, sn := range ci.PodSubnets {
+		result.PodSubnets[i] = iputil.IPNetFromRPC(sn).String()
+	}
+	return result
+}
+
+// adminRemoveIntercept removes the given intercept regardless of what client session it belongs to,
+// and notifies the client.
+func (m *Manager) adminRemoveIntercept(ctx context.Context, interceptID string) error {
+	ii, ok := m.state.GetIntercept(interceptID)
+	if !ok || !m.state.RemoveIntercept(interceptID) {
+		return status.Errorf(codes.NotFound, "Intercept %q not found", interceptID)
+	}
+	dlog.Infof(ctx, "Intercept %s removed by admin", interceptID)
+	m.state.NotifyClient(ii.ClientSession.GetSessionId(),
+		fmt.Sprintf("Intercept %q was removed by an administrator of the traffic-manager", ii.Spec.Name))
+	return nil
+}
+
+// adminExpireSession removes the given client or agent session, along with its intercepts. A client
+// is notified before its session ends.
+func (m *Manager) adminExpireSession(ctx context.Context, sessionID string) error {
+	if m.state.GetClient(sessionID) == nil && m.state.GetAgent(sessionID) == nil {
+		return status.Errorf(codes.NotFound, "Session %q not found", sessionID)
+	}
+	m.state.NotifyClient(sessionID, "Your session was expired by an administrator of the traffic-manager. "+
+		"Its intercepts have been removed")
+	m.state.RemoveSession(ctx, sessionID)
+	dlog.Infof(ctx, "Session %s expired by admin", sessionID)
+	return nil
+}
+
+// checkAdmin returns a PermissionDenied error unless the caller's verified identity is one of the
+// ADMIN_IDENTITIES, or the given token is the ADMIN_TOKEN.
+func checkAdmin(ctx context.Context, token string) error {
+	env := managerutil.GetEnv(ctx)
+	if identity, ok := callerIdentity(ctx); ok && identity != "" {
+		for _, admin := range strings.Split(env.AdminIdentities, ",") {
+			if strings.TrimSpace(admin) == identity {
+				return nil
+			}
+		}
+	}
+	if validAdminToken(env.AdminToken, token) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "admin permission is required; use a valid admin token or an admin identity")
+}
+
+// AdminListSessions returns all client and agent sessions.
+func (m *Manager) AdminListSessions(ctx context.Context, req *rpc.AdminRequest) (*rpc.AdminSessionList, error) {
+	dlog.Debug(ctx, "AdminListSessions called")
+	if err := checkAdmin(ctx, req.AdminToken); err != nil {
+		return nil, err
+	}
+
+	interceptIDs := make(map[string][]string)
+	for id, ii := range m.currentIntercepts(ctx) {
+		sessionID := ii.ClientSession.GetSessionId()
+		interceptIDs[sessionID] = append(interceptIDs[sessionID], id)
+	}
+
+	var sessions []*rpc.AdminSession
+	for sessionID, client := range m.state.GetAllClients() {
+		client = proto.Clone(client).(*rpc.ClientInfo)
+		client.ApiKey = ""
+		ids := interceptIDs[sessionID]
+		sort.Strings(ids)
+		sessions = append(sessions, &rpc.AdminSession{SessionId: sessionID, Client: client, InterceptIds: ids})
+	}
+	for sessionID, agent := range m.state.GetAllAgents() {
+		agent = proto.Clone(agent).(*rpc.AgentInfo)
+		agent.Environment = nil
+		sessions = append(sessions, &rpc.AdminSession{SessionId: sessionID, Agent: agent})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		si, sj := sessions[i], sessions[j]
+		if (si.Client == nil) != (sj.Client == nil) {
+			return si.Client != nil // clients first
+		}
+		if ni, nj := adminSessionName(si), adminSessionName(sj); ni != nj {
+			return ni < nj
+		}
+		return si.SessionId < sj.SessionId
+	})
+	return &rpc.AdminSessionList{Sessions: sessions}, nil
+}
+
+// adminSessionName returns the name of a client, or the name.namespace of an agent.
+func adminSessionName(s *rpc.AdminSession) string {
+	if s.Client != nil {
+		return s.Client.Name
+	}
+	return s.Agent.Name + "." + s.Agent.Namespace
+}
+
+// AdminRemoveIntercept removes an intercept regardless of what client session it belongs to.
+func (m *Manager) AdminRemoveIntercept(ctx context.Context, req *rpc.AdminRequest) (*empty.Empty, error) {
+	dlog.Debugf(ctx, "AdminRemoveIntercept called: %s", req.Id)
+	if err := checkAdmin(ctx, req.AdminToken); err != nil {
+		return nil, err
+	}
+	if err := m.adminRemoveIntercept(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	return &empty.Empty{}, nil
+}
+
+// AdminExpireSession removes a client or agent session along with its intercepts.
+func (m *Manager) AdminExpireSession(ctx context.Context, req *rpc.AdminRequest) (*empty.Empty, error) {
+	dlog.Debugf(ctx, "AdminExpireSession called: %s", req.Id)
+	if err := checkAdmin(ctx, req.AdminToken); err != nil {
+		return nil, err
+	}
+	if err := m.adminExpireSession(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	return &empty.Empty{}, nil
+}
+
+// WatchNotifications streams the messages that are intended for the user of a client session.
+func (m *Manager) WatchNotifications(session *rpc.SessionInfo, stream rpc.Manager_WatchNotificationsServer) error {
+	ctx := managerutil.WithSessionInfo(stream.Context(), session)
+	dlog.Debug(ctx, "WatchNotifications called")
+	if err := m.checkSessionOwner(ctx, session.GetSessionId()); err != nil {
+		return err
+	}
+	return m.state.WatchClientNotifications(ctx, session.GetSessionId(), func(msg string) error {
+		return stream.Send(&rpc.Notification{Message: msg})
+	})
+}
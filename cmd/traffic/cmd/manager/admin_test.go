@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+)
+
+func TestAdminAPI(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	env := *managerutil.GetEnv(ctx)
+	env.ClientAuth = ""
+	env.AdminToken = "secret"
+	ctx = managerutil.WithEnv(ctx, &env)
+
+	m := NewManager(ctx)
+	sessionID := m.state.AddClient(&rpc.ClientInfo{
+		Name:      "alice@host",
+		InstallId: "alice-install",
+		Product:   "telepresence",
+		Version:   "2.4.9",
+		ApiKey:    "alice-api-key",
+	}, m.clock.Now())
+	h := m.adminHandler(ctx)
+
+	call := func(method, path, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil).WithContext(ctx)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// Reads require the admin token, because they list session IDs
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/clients", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/agents", "guess").Code)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/intercepts", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/cluster-info", "").Code)
+
+	w := call(http.MethodGet, "/api/clients", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "alice-api-key")
+	var clients []*adminClient
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &clients))
+	require.Len(t, clients, 1)
+	assert.Equal(t, sessionID, clients[0].SessionID)
+	assert.Equal(t, "alice@host", clients[0].Name)
+
+	w = call(http.MethodGet, "/api/intercepts", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	w = call(http.MethodGet, "/api/cluster-info", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	var ci adminClusterInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ci))
+	assert.Equal(t, []string{"192.168.0.0/16"}, ci.PodSubnets)
+
+	w = call(http.MethodGet, "/", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Telepresence Traffic Manager")
+	assert.Equal(t, http.StatusNotFound, call(http.MethodGet, "/hello", "").Code)
+
+	// Actions require the admin token
+	assert.Equal(t, http.StatusMethodNotAllowed, call(http.MethodPost, "/api/clients", "secret").Code)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodDelete, "/api/clients/"+sessionID, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodDelete, "/api/clients/"+sessionID, "guess").Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodDelete, "/api/intercepts/"+sessionID+":echo", "secret").Code)
+	assert.Equal(t, http.StatusNoContent, call(http.MethodDelete, "/api/clients/"+sessionID, "secret").Code)
+	assert.Nil(t, m.state.GetClient(sessionID))
+	assert.Equal(t, http.StatusNotFound, call(http.MethodDelete, "/api/clients/"+sessionID, "secret").Code)
+
+	// The API is disabled when no admin token is configured
+	env.AdminToken = ""
+	h = m.adminHandler(managerutil.WithEnv(ctx, &env))
+	assert.Equal(t, http.StatusForbidden, call(http.MethodDelete, "/api/clients/"+sessionID, "").Code)
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/api/clients", "").Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/", "").Code)
+}
+
+func TestAdminRPCs(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	env := *managerutil.GetEnv(ctx)
+	env.AdminToken = "secret"
+	env.AdminIdentities = "root, ops"
+	ctx = managerutil.WithEnv(ctx, &env)
+
+	m := NewManager(ctx)
+	aliceCtx := withCallerIdentity(ctx, "alice")
+	si, err := m.ArriveAsClient(aliceCtx, &rpc.ClientInfo{
+		Name:      "alice@host",
+		InstallId: "alice-install",
+		Product:   "telepresence",
+		Version:   "2.4.9",
+		ApiKey:    "alice-api-key",
+	})
+	require.NoError(t, err)
+
+	// Admin permission is granted by the admin token or an admin identity
+	_, err = m.AdminListSessions(aliceCtx, &rpc.AdminRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = m.AdminListSessions(aliceCtx, &rpc.AdminRequest{AdminToken: "guess"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = m.AdminListSessions(aliceCtx, &rpc.AdminRequest{AdminToken: "secret"})
+	assert.NoError(t, err)
+	opsCtx := withCallerIdentity(ctx, "ops")
+	sessions, err := m.AdminListSessions(opsCtx, &rpc.AdminRequest{})
+	require.NoError(t, err)
+	require.Len(t, sessions.Sessions, 1)
+	assert.Equal(t, si.SessionId, sessions.Sessions[0].SessionId)
+	assert.Equal(t, "alice", sessions.Sessions[0].Client.Identity)
+	assert.Empty(t, sessions.Sessions[0].Client.ApiKey)
+
+	_, err = m.AdminRemoveIntercept(opsCtx, &rpc.AdminRequest{Id: si.SessionId + ":echo"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// The client is notified before its session is expired
+	require.True(t, m.state.NotifyClient(si.SessionId, "hello"))
+	var msgs []string
+	err = m.state.WatchClientNotifications(ctx, si.SessionId, func(msg string) error {
+		msgs = append(msgs, msg)
+		if msg == "hello" {
+			_, err := m.AdminExpireSession(opsCtx, &rpc.AdminRequest{Id: si.SessionId})
+			return err
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Contains(t, msgs[1], "expired by an administrator")
+	assert.Nil(t, m.state.GetClient(si.SessionId))
+	_, err = m.AdminExpireSession(opsCtx, &rpc.AdminRequest{Id: si.SessionId})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
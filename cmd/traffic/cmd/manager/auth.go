@@ -0,0 +1,305 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+const (
+	// authMTLS authenticates clients using the common name of a client certificate that has been
+	// verified using the client CA. It requires the TLS protected direct port.
+	authMTLS = "mtls"
+
+	// authToken authenticates clients using a bearer token that is verified with a TokenReview.
+	authToken = "token"
+
+	// serviceAccountPrefix is the prefix of the identity of a Kubernetes service account.
+	serviceAccountPrefix = "system:serviceaccount:"
+
+	// tokenReviewTTL is the time that the result of a successful TokenReview is cached.
+	tokenReviewTTL = time.Minute
+)
+
+// authenticator verifies the identity of the clients that call the traffic-manager's API.
+type authenticator struct {
+	mtls      bool
+	token     bool
+	clientset kubernetes.Interface
+
+	reviewsLock sync.Mutex
+	reviews     map[[sha256.Size]byte]*tokenReview
+}
+
+type tokenReview struct {
+	identity string
+	expires  time.Time
+}
+
+// newAuthenticator returns an authenticator for the methods declared in the CLIENT_AUTH
+// environment, or nil when client authentication isn't enabled.
+func newAuthenticator(ctx context.Context) (*authenticator, error) {
+	env := managerutil.GetEnv(ctx)
+	if env.ClientAuth == "" {
+		return nil, nil
+	}
+	// Traffic-agents are always authenticated using their service account token, regardless of the
+	// methods used for clients.
+	a := &authenticator{reviews: make(map[[sha256.Size]byte]*tokenReview)}
+	if a.clientset = managerutil.GetK8sClientset(ctx); a.clientset == nil {
+		return nil, errors.New("client authentication requires a Kubernetes clientset")
+	}
+	for _, method := range strings.Split(env.ClientAuth, ",") {
+		switch method = strings.TrimSpace(method); method {
+		case authMTLS:
+			if env.DirectPort == "" {
+				return nil, fmt.Errorf("client authentication using %s requires DIRECT_PORT", authMTLS)
+			}
+			a.mtls = true
+		case authToken:
+			a.token = true
+		case "":
+		default:
+			return nil, fmt.Errorf("invalid client authentication method %q", method)
+		}
+	}
+	dlog.Infof(ctx, "Client authentication enabled using %s", env.ClientAuth)
+	return a, nil
+}
+
+// tlsConfig returns the configuration of the direct port's TLS server. Client certificates are
+// verified when given, but not required, so that clients can authenticate using a token instead.
+func (a *authenticator) tlsConfig(ctx context.Context) (*tls.Config, error) {
+	if !a.mtls {
+		return nil, nil
+	}
+	caFile := managerutil.GetEnv(ctx).ClientCAFile
+	caData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the client certificate authority: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no valid PEM encoded certificates found in %s", caFile)
+	}
+	return &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// unaryInterceptor makes the verified identities of the caller available to the called method.
+func (a *authenticator) unaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.identify(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor makes the verified identities of the caller available to the called streaming method.
+func (a *authenticator) streamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.identify(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identifiedStream{ServerStream: ss, ctx: ctx})
+}
+
+// identify returns the given context with the verified client and agent identities of the caller.
+func (a *authenticator) identify(ctx context.Context) (context.Context, error) {
+	identity, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	agentIdentity, err := a.authenticateAgent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withAgentIdentity(withCallerIdentity(ctx, identity), agentIdentity), nil
+}
+
+// identifiedStream is a grpc.ServerStream with a context that holds the verified identity of the caller.
+type identifiedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identifiedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate returns the verified identity of the caller, or an empty string if the caller
+// didn't present any credentials. An error is returned when the presented credentials are invalid.
+func (a *authenticator) authenticate(ctx context.Context) (string, error) {
+	if a.mtls {
+		if p, ok := peer.FromContext(ctx); ok {
+			if ti, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(ti.State.VerifiedChains) > 0 {
+				return ti.State.VerifiedChains[0][0].Subject.CommonName, nil
+			}
+		}
+	}
+	if a.token {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, auth := range md.Get("authorization") {
+			if token := strings.TrimPrefix(auth, "Bearer "); token != auth {
+				if plaintextNetworkPeer(ctx) {
+					return "", status.Error(codes.Unauthenticated, "a token must be sent using TLS or a port-forward")
+				}
+				return a.reviewToken(ctx, token)
+			}
+		}
+	}
+	return "", nil
+}
+
+// authenticateAgent returns the verified service account of a traffic-agent, or an empty string if
+// the caller didn't present an agent token. Agents talk to the traffic-manager over the cluster network,
+// so unlike client tokens, their token is accepted without TLS.
+func (a *authenticator) authenticateAgent(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, token := range md.Get(install.AgentTokenMetadataKey) {
+		if token != "" {
+			identity, err := a.reviewToken(ctx, token)
+			if err == nil && !strings.HasPrefix(identity, serviceAccountPrefix) {
+				err = status.Errorf(codes.Unauthenticated, "agent token of %q is not a service account token", identity)
+			}
+			return identity, err
+		}
+	}
+	return "", nil
+}
+
+// plaintextNetworkPeer returns true when the caller is connected over the network without TLS. A
+// port-forward ends at the loopback interface of the traffic-manager's pod, and is protected by the
+// TLS of the API server, so a caller using a port-forward is not a plaintext network peer.
+func plaintextNetworkPeer(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return false
+	}
+	if _, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		return false
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && !ip.IsLoopback()
+}
+
+// reviewToken returns the name of the user that the given token belongs to. Successful reviews
+// are cached for a short while, so that the API server isn't called on each request.
+func (a *authenticator) reviewToken(ctx context.Context, token string) (string, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	a.reviewsLock.Lock()
+	if tr, ok := a.reviews[key]; ok && now.Before(tr.expires) {
+		a.reviewsLock.Unlock()
+		return tr.identity, nil
+	}
+	a.reviewsLock.Unlock()
+
+	review, err := a.clientset.AuthenticationV1().TokenReviews().Create(ctx,
+		&authv1.TokenReview{Spec: authv1.TokenReviewSpec{Token: token}}, metav1.CreateOptions{})
+	if err != nil {
+		return "", status.Errorf(codes.Unavailable, "unable to review token: %v", err)
+	}
+	if !review.Status.Authenticated {
+		return "", status.Errorf(codes.Unauthenticated, "invalid token: %s", review.Status.Error)
+	}
+	identity := review.Status.User.Username
+
+	a.reviewsLock.Lock()
+	for k, tr := range a.reviews {
+		if now.After(tr.expires) {
+			delete(a.reviews, k)
+		}
+	}
+	a.reviews[key] = &tokenReview{identity: identity, expires: now.Add(tokenReviewTTL)}
+	a.reviewsLock.Unlock()
+	return identity, nil
+}
+
+type callerIdentityKey struct{}
+
+func withCallerIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, callerIdentityKey{}, identity)
+}
+
+// callerIdentity returns the verified identity of the caller, and true if the caller is subject to
+// client authentication. Calls that arrive from System A are not.
+func callerIdentity(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(callerIdentityKey{}).(string)
+	return identity, ok
+}
+
+type agentIdentityKey struct{}
+
+func withAgentIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, agentIdentityKey{}, identity)
+}
+
+// agentIdentity returns the verified service account of the traffic-agent that made the call, or an
+// empty string if the caller didn't present an agent token.
+func agentIdentity(ctx context.Context) string {
+	identity, _ := ctx.Value(agentIdentityKey{}).(string)
+	return identity
+}
+
+// checkSessionOwner returns a PermissionDenied error unless the caller is the owner of the given
+// session. A client session is owned by the identity that created it, and an agent session is owned
+// by the service account of the agent that created it. Sessions that don't exist are left for the
+// caller to report.
+func (m *Manager) checkSessionOwner(ctx context.Context, sessionID string) error {
+	identity, ok := callerIdentity(ctx)
+	if !ok {
+		return nil
+	}
+	if client := m.state.GetClient(sessionID); client != nil {
+		if identity == "" || client.Identity != identity {
+			return status.Errorf(codes.PermissionDenied, "client session %q is not owned by %q", sessionID, identity)
+		}
+	} else if agent := m.state.GetAgent(sessionID); agent != nil {
+		if aid := agentIdentity(ctx); aid == "" || agent.Identity != aid {
+			return status.Errorf(codes.PermissionDenied, "agent session %q is not owned by %q", sessionID, aid)
+		}
+	}
+	return nil
+}
+
+// checkInterceptOwner returns a PermissionDenied error unless the caller is the owner of the
+// client session that created the given intercept.
+func (m *Manager) checkInterceptOwner(ctx context.Context, interceptID string) error {
+	if _, ok := callerIdentity(ctx); !ok {
+		return nil
+	}
+	if intercept, ok := m.state.GetIntercept(interceptID); ok {
+		return m.checkSessionOwner(ctx, intercept.ClientSession.GetSessionId())
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sVersion "k8s.io/apimachinery/pkg/version"
+	fakeDiscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+func testAuthContext(t *testing.T) (context.Context, *int) {
+	fakeClient := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	fakeClient.Discovery().(*fakeDiscovery.FakeDiscovery).FakedServerVersion = &k8sVersion.Info{GitVersion: "v1.17.0"}
+	reviews := 0
+	fakeClient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		tr := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview)
+		switch tr.Spec.Token {
+		case "alice-token":
+			tr.Status = authv1.TokenReviewStatus{Authenticated: true, User: authv1.UserInfo{Username: "alice"}}
+		case "bob-token":
+			tr.Status = authv1.TokenReviewStatus{Authenticated: true, User: authv1.UserInfo{Username: "bob"}}
+		case "echo-sa-token":
+			tr.Status = authv1.TokenReviewStatus{Authenticated: true, User: authv1.UserInfo{Username: "system:serviceaccount:default:echo"}}
+		default:
+			tr.Status = authv1.TokenReviewStatus{Error: "unknown token"}
+		}
+		return true, tr, nil
+	})
+	ctx := dlog.NewTestContext(t, true)
+	ctx = managerutil.WithK8SClientset(ctx, fakeClient)
+	ctx = managerutil.WithEnv(ctx, &managerutil.Env{
+		ClientAuth:      authToken,
+		PodCIDRStrategy: "environment",
+		PodCIDRs:        "192.168.0.0/16",
+	})
+	return ctx, &reviews
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	ctx, reviews := testAuthContext(t)
+	a, err := newAuthenticator(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, a)
+
+	identity, err := a.authenticate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "", identity)
+
+	identity, err = a.authenticate(withToken(ctx, "alice-token"))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", identity)
+
+	// The review is cached
+	_, err = a.authenticate(withToken(ctx, "alice-token"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, *reviews)
+
+	_, err = a.authenticate(withToken(ctx, "mallory-token"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Tokens are only accepted using TLS or a port-forward, which ends at the loopback interface
+	fromPeer := func(ip string, authInfo credentials.AuthInfo) context.Context {
+		return peer.NewContext(withToken(ctx, "alice-token"), &peer.Peer{
+			Addr:     &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000},
+			AuthInfo: authInfo,
+		})
+	}
+	_, err = a.authenticate(fromPeer("10.1.0.5", nil))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	identity, err = a.authenticate(fromPeer("10.1.0.5", credentials.TLSInfo{}))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", identity)
+	identity, err = a.authenticate(fromPeer("127.0.0.1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", identity)
+}
+
+func TestAuthenticateAgent(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	a, err := newAuthenticator(ctx)
+	require.NoError(t, err)
+
+	withAgentToken := func(token string) context.Context {
+		return peer.NewContext(metadata.NewIncomingContext(ctx, metadata.Pairs(install.AgentTokenMetadataKey, token)), &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP("10.1.0.5"), Port: 40000},
+		})
+	}
+	identity, err := a.authenticateAgent(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "", identity)
+
+	// Agent tokens are accepted from the cluster network without TLS
+	identity, err = a.authenticateAgent(withAgentToken("echo-sa-token"))
+	require.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:default:echo", identity)
+
+	// Only service account tokens are agent tokens
+	_, err = a.authenticateAgent(withAgentToken("alice-token"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = a.authenticateAgent(withAgentToken("mallory-token"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	env := *managerutil.GetEnv(ctx)
+
+	env.ClientAuth = ""
+	a, err := newAuthenticator(managerutil.WithEnv(ctx, &env))
+	require.NoError(t, err)
+	assert.Nil(t, a)
+
+	env.ClientAuth = "password"
+	_, err = newAuthenticator(managerutil.WithEnv(ctx, &env))
+	assert.Error(t, err)
+
+	// mTLS requires the TLS protected direct port
+	env.ClientAuth = "mtls, token"
+	_, err = newAuthenticator(managerutil.WithEnv(ctx, &env))
+	assert.Error(t, err)
+
+	env.DirectPort = "8082"
+	a, err = newAuthenticator(managerutil.WithEnv(ctx, &env))
+	require.NoError(t, err)
+	assert.True(t, a.mtls)
+	assert.True(t, a.token)
+}
+
+func TestInterceptOwnership(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	m := NewManager(ctx)
+	aliceCtx := withCallerIdentity(ctx, "alice")
+	bobCtx := withCallerIdentity(ctx, "bob")
+
+	// Clients that didn't present credentials can't arrive
+	_, err := m.ArriveAsClient(withCallerIdentity(ctx, ""), &rpc.ClientInfo{Name: "alice@host", InstallId: "alice-install", Product: "telepresence", Version: "2.4.9"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// The identity claimed by the client is replaced with the verified identity
+	si, err := m.ArriveAsClient(aliceCtx, &rpc.ClientInfo{Name: "alice@host", InstallId: "alice-install", Product: "telepresence", Version: "2.4.9", Identity: "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", m.state.GetClient(si.SessionId).Identity)
+
+	_, err = m.RemoveIntercept(bobCtx, &rpc.RemoveInterceptRequest2{Session: si, Name: "echo"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = m.UpdateIntercept(bobCtx, &rpc.UpdateInterceptRequest{Session: si, Name: "echo"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = m.TransferIntercept(bobCtx, &rpc.TransferInterceptRequest{Session: si, Name: "echo", To: "bob@host"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = m.RemoveIntercept(aliceCtx, &rpc.RemoveInterceptRequest2{Session: si, Name: "echo"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = m.TransferIntercept(aliceCtx, &rpc.TransferInterceptRequest{Session: si, Name: "echo", To: "bob@host"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Calls that are not subject to client authentication, i.e. calls from System A, are trusted.
+	_, err = m.RemoveIntercept(ctx, &rpc.RemoveInterceptRequest2{Session: si, Name: "echo"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAgentOwnership(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	m := NewManager(ctx)
+	agentInfo := func() *rpc.AgentInfo {
+		return &rpc.AgentInfo{Name: "echo", Namespace: "default", PodIp: "10.1.0.5", Product: "telepresence", Version: "2.4.9",
+			Mechanisms: []*rpc.AgentInfo_Mechanism{{Name: "tcp", Product: "telepresence", Version: "2.4.9"}}}
+	}
+	asAgent := func(identity string) context.Context {
+		return withAgentIdentity(withCallerIdentity(ctx, ""), identity)
+	}
+
+	// Agents that didn't present a service account token can't arrive
+	_, err := m.ArriveAsAgent(asAgent(""), agentInfo())
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// An agent can only arrive using a service account of its own namespace
+	_, err = m.ArriveAsAgent(asAgent("system:serviceaccount:other:echo"), agentInfo())
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// The identity claimed by the agent is replaced with the verified identity
+	info := agentInfo()
+	info.Identity = "system:serviceaccount:default:admin"
+	si, err := m.ArriveAsAgent(asAgent("system:serviceaccount:default:echo"), info)
+	require.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:default:echo", m.state.GetAgent(si.SessionId).Identity)
+
+	// Only the agent can use its session
+	for _, c := range []context.Context{withCallerIdentity(ctx, ""), withCallerIdentity(ctx, "alice"), asAgent("system:serviceaccount:default:other")} {
+		_, err = m.Remain(c, &rpc.RemainRequest{Session: si})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	}
+	_, err = m.Remain(asAgent("system:serviceaccount:default:echo"), &rpc.RemainRequest{Session: si})
+	assert.NoError(t, err)
+}
+
+func TestStreamAuthentication(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	env := *managerutil.GetEnv(ctx)
+	env.AdminIdentities = "bob"
+	ctx = managerutil.WithEnv(ctx, &env)
+	ctx, cancel := context.WithCancel(ctx)
+	a, err := newAuthenticator(ctx)
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(64 * 1024)
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	s := grpc.NewServer(serverOptions(ctx, a)...)
+	rpc.RegisterManagerServer(s, NewManager(ctx))
+	errCh := make(chan error)
+	go func() {
+		errCh <- (&dhttp.ServerConfig{Handler: s}).Serve(ctx, lis)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-errCh
+		_ = conn.Close()
+	})
+
+	client := rpc.NewManagerClient(conn)
+	asUser := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	si, err := client.ArriveAsClient(asUser("alice-token"), &rpc.ClientInfo{Name: "alice@host", InstallId: "alice-install", Product: "telepresence", Version: "2.4.9"})
+	require.NoError(t, err)
+
+	// watchErr returns the error of the first message received on a WatchIntercepts stream for the given session
+	watchErr := func(ctx context.Context, si *rpc.SessionInfo) error {
+		stream, err := client.WatchIntercepts(ctx, si)
+		if err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+	recvErr := func(ctx context.Context) error {
+		return watchErr(ctx, si)
+	}
+
+	// Streams are authenticated, and only the owner of a session can use it
+	assert.NoError(t, recvErr(asUser("alice-token")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(recvErr(asUser("mallory-token"))))
+	assert.Equal(t, codes.PermissionDenied, status.Code(recvErr(asUser("bob-token"))))
+	assert.Equal(t, codes.PermissionDenied, status.Code(recvErr(ctx)))
+
+	// Only admins can watch the intercepts of all sessions
+	assert.Equal(t, codes.PermissionDenied, status.Code(watchErr(asUser("alice-token"), &rpc.SessionInfo{})))
+	assert.Equal(t, codes.PermissionDenied, status.Code(watchErr(ctx, &rpc.SessionInfo{})))
+	assert.NoError(t, watchErr(asUser("bob-token"), &rpc.SessionInfo{}))
+
+	_, err = client.Remain(asUser("bob-token"), &rpc.RemainRequest{Session: si})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.Depart(asUser("bob-token"), si)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.Remain(asUser("alice-token"), &rpc.RemainRequest{Session: si})
+	assert.NoError(t, err)
+	_, err = client.Depart(asUser("alice-token"), si)
+	assert.NoError(t, err)
+}
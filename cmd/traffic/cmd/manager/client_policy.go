@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"sigs.k8s.io/yaml"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+// clientPolicy is the YAML representation of the client policy, as given in the "clientPolicy" value
+// of the Helm chart.
+type clientPolicy struct {
+	AgentImages struct {
+		Preferred string   `json:"preferred,omitempty"`
+		Allowed   []string `json:"allowed,omitempty"`
+	} `json:"agentImages,omitempty"`
+	Extensions       map[string]interface{} `json:"extensions,omitempty"`
+	DefaultMechanism string                 `json:"defaultMechanism,omitempty"`
+	Timeouts         map[string]string      `json:"timeouts,omitempty"`
+}
+
+// loadClientPolicy reads the client policy from the given file. The file is read on each call, so
+// that changes to the ConfigMap that it's mounted from take effect without a restart. An empty policy
+// is returned when the file doesn't exist.
+func loadClientPolicy(filename string) (*rpc.ClientPolicy, error) {
+	policy := &rpc.ClientPolicy{}
+	if filename == "" {
+		return policy, nil
+	}
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return policy, nil
+		}
+		return nil, err
+	}
+	var cp clientPolicy
+	if err = yaml.UnmarshalStrict(bs, &cp); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	policy.PreferredAgentImage = cp.AgentImages.Preferred
+	policy.AllowedAgentImages = cp.AgentImages.Allowed
+	for _, pattern := range policy.AllowedAgentImages {
+		if _, err = path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: allowed agent image %q: %w", filename, pattern, err)
+		}
+	}
+	if policy.PreferredAgentImage != "" && !install.AgentImageAllowed(policy, policy.PreferredAgentImage) {
+		return nil, fmt.Errorf("%s: the preferred agent image %q is not allowed", filename, policy.PreferredAgentImage)
+	}
+	if len(cp.Extensions) > 0 {
+		policy.Extensions = make(map[string]string, len(cp.Extensions))
+		for name, ext := range cp.Extensions {
+			extYAML, err := yaml.Marshal(ext)
+			if err != nil {
+				return nil, fmt.Errorf("%s: extension %q: %w", filename, name, err)
+			}
+			policy.Extensions[name] = string(extYAML)
+		}
+	}
+	policy.DefaultMechanism = cp.DefaultMechanism
+	if len(cp.Timeouts) > 0 {
+		policy.Timeouts = make(map[string]*durationpb.Duration, len(cp.Timeouts))
+		for name, value := range cp.Timeouts {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: timeout %q: %w", filename, name, err)
+			}
+			policy.Timeouts[name] = durationpb.New(d)
+		}
+	}
+	return policy, nil
+}
+
+// GetClientPolicy returns the cluster-wide client policy.
+func (m *Manager) GetClientPolicy(ctx context.Context, _ *empty.Empty) (*rpc.ClientPolicy, error) {
+	policy, err := loadClientPolicy(managerutil.GetEnv(ctx).ClientPolicyFile)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid client policy: %v", err)
+	}
+	return policy, nil
+}
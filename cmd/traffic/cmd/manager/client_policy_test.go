@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+func TestGetClientPolicy(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	policyFile := filepath.Join(t.TempDir(), "client-policy.yml")
+	env := *managerutil.GetEnv(ctx)
+	env.ClientPolicyFile = policyFile
+	ctx = managerutil.WithEnv(ctx, &env)
+	m := NewManager(ctx)
+
+	// No policy is published when the file doesn't exist
+	policy, err := m.GetClientPolicy(ctx, &empty.Empty{})
+	require.NoError(t, err)
+	assert.Empty(t, policy.PreferredAgentImage)
+	assert.Empty(t, policy.Extensions)
+
+	require.NoError(t, os.WriteFile(policyFile, []byte(`
+agentImages:
+  preferred: docker.io/datawire/tel2:2.4.9
+  allowed:
+  - docker.io/datawire/tel2:*
+extensions:
+  color:
+    image: example.com/color-agent:1.0
+    mechanisms:
+      color:
+        preference: 200
+defaultMechanism: color
+timeouts:
+  agentInstall: 3m
+`), 0o644))
+	policy, err = m.GetClientPolicy(ctx, &empty.Empty{})
+	require.NoError(t, err)
+	assert.Equal(t, "docker.io/datawire/tel2:2.4.9", policy.PreferredAgentImage)
+	assert.True(t, install.AgentImageAllowed(policy, "docker.io/datawire/tel2:2.4.10"))
+	assert.False(t, install.AgentImageAllowed(policy, "example.com/tel2:2.4.9"))
+	assert.Equal(t, "color", policy.DefaultMechanism)
+	assert.YAMLEq(t, "image: example.com/color-agent:1.0\nmechanisms:\n  color:\n    preference: 200\n", policy.Extensions["color"])
+	assert.Equal(t, 3*time.Minute, policy.Timeouts["agentInstall"].AsDuration())
+
+	// A preferred image that isn't allowed makes the policy invalid
+	require.NoError(t, os.WriteFile(policyFile, []byte(`
+agentImages:
+  preferred: example.com/tel2:2.4.9
+  allowed:
+  - docker.io/datawire/tel2:*
+`), 0o644))
+	_, err = m.GetClientPolicy(ctx, &empty.Empty{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	require.NoError(t, os.WriteFile(policyFile, []byte("timeouts:\n  agentInstall: forever\n"), 0o644))
+	_, err = m.GetClientPolicy(ctx, &empty.Empty{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/egress"
 )
 
 func validateClient(client *rpc.ClientInfo) string {
@@ -18,6 +19,12 @@ func validateClient(client *rpc.ClientInfo) string {
 		return "version must not be empty"
 	}
 
+	for _, rule := range client.EgressRules {
+		if err := egress.Validate(rule); err != nil {
+			return fmt.Sprintf("invalid egress rule %q: %v", egress.String(rule), err)
+		}
+	}
+
 	return ""
 }
 
@@ -32,6 +32,9 @@ type Info interface {
 	// GetClusterID returns the ClusterID
 	GetClusterID() string
 
+	// GetClusterInfo returns a snapshot of the current ClusterInfo
+	GetClusterInfo() *rpc.ClusterInfo
+
 	// GetTrafficManagerPods acquires all pods that have `traffic-manager` in
 	// their name
 	GetTrafficManagerPods(context.Context) ([]*corev1.Pod, error)
@@ -112,7 +115,18 @@ func NewInfo(ctx context.Context) Info {
 	for _, dnsService := range dnsServices {
 		if svc, err := client.Services(dnsService.Namespace).Get(ctx, dnsService.Name, metav1.GetOptions{}); err == nil {
 			dlog.Infof(ctx, "Using DNS IP from %s.%s", svc.Name, svc.Namespace)
-			oi.KubeDnsIp = iputil.Parse(svc.Spec.ClusterIP)
+			clusterIPs := svc.Spec.ClusterIPs
+			if len(clusterIPs) == 0 {
+				clusterIPs = []string{svc.Spec.ClusterIP}
+			}
+			for _, cip := range clusterIPs {
+				if ip := iputil.Parse(cip); ip != nil {
+					oi.KubeDnsIps = append(oi.KubeDnsIps, ip)
+				}
+			}
+			if len(oi.KubeDnsIps) > 0 {
+				oi.KubeDnsIp = oi.KubeDnsIps[0]
+			}
 			break
 		}
 	}
@@ -126,50 +140,27 @@ func NewInfo(ctx context.Context) Info {
 	}
 	dlog.Infof(ctx, "Using cluster domain %q", oi.ClusterDomain)
 
-	// make an attempt to create a service with ClusterIP that is out of range and then
-	// check the error message for the correct range as suggested tin the second answer here:
-	//   https://stackoverflow.com/questions/44190607/how-do-you-find-the-cluster-service-cidr-of-a-kubernetes-cluster
-	// This requires an additional permission to create a service, which the traffic-manager
-	// should have.
+	// Find the service subnet of each IP family that the cluster's DNS service uses. A cluster that
+	// has no known DNS service is assumed to be IPv4 only.
 	env := managerutil.GetEnv(ctx)
-	svc := corev1.Service{
-		TypeMeta: metav1.TypeMeta{
-			Kind: "Service",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: env.ManagerNamespace,
-			Name:      "t2-tst-dummy",
-		},
-		Spec: corev1.ServiceSpec{
-			Ports:     []corev1.ServicePort{{Port: 443}},
-			ClusterIP: "1.1.1.1",
-		},
+	families := []corev1.IPFamily{corev1.IPv4Protocol}
+	if len(oi.KubeDnsIps) > 0 {
+		families = families[:0]
+		for _, ip := range oi.KubeDnsIps {
+			families = append(families, ipFamily(ip))
+		}
 	}
-	if _, err = client.Services(env.ManagerNamespace).Create(ctx, &svc, metav1.CreateOptions{}); err != nil {
-		svcCIDRrx := regexp.MustCompile(`range of valid IPs is (.*)$`)
-		if match := svcCIDRrx.FindStringSubmatch(err.Error()); match != nil {
-			var cidr *net.IPNet
-			if _, cidr, err = net.ParseCIDR(match[1]); err != nil {
-				dlog.Errorf(ctx, "unable to parse service CIDR %q", match[1])
-			} else {
-				dlog.Infof(ctx, "Extracting service subnet %v from create service error message", cidr)
-				oi.ServiceSubnet = iputil.IPNetToRPC(cidr)
-			}
-		} else {
-			dlog.Errorf(ctx, "unable to extract service subnet from error message %q", err.Error())
+	for _, family := range families {
+		cidr := serviceSubnetFromCreateError(ctx, env.ManagerNamespace, family)
+		if cidr == nil {
+			cidr = oi.serviceSubnetFromDNSIP(ctx, family)
+		}
+		if cidr != nil {
+			oi.ServiceSubnets = append(oi.ServiceSubnets, cidr)
 		}
 	}
-
-	if oi.ServiceSubnet == nil && oi.KubeDnsIp != nil {
-		// Using a "kubectl cluster-info dump" or scanning all services generates a lot of unwanted traffic
-		// and would quite possibly also require elevated permissions, so instead, we derive the service subnet
-		// from the kubeDNS IP. This is cheating but a cluster may only have one service subnet and the mask is
-		// unlikely to cover less than half the bits.
-		dlog.Infof(ctx, "Deriving serviceSubnet from %s (the IP of kube-dns.kube-system)", net.IP(oi.KubeDnsIp))
-		bits := len(oi.KubeDnsIp) * 8
-		ones := bits / 2
-		mask := net.CIDRMask(ones, bits) // will yield a 16 bit mask on IPv4 and 64 bit mask on IPv6.
-		oi.ServiceSubnet = &rpc.IPNet{Ip: net.IP(oi.KubeDnsIp).Mask(mask), Mask: int32(ones)}
+	if len(oi.ServiceSubnets) > 0 {
+		oi.ServiceSubnet = oi.ServiceSubnets[0]
 	}
 
 	podCIDRStrategy := env.PodCIDRStrategy
@@ -194,6 +185,82 @@ func NewInfo(ctx context.Context) Info {
 	return &oi
 }
 
+func ipFamily(ip net.IP) corev1.IPFamily {
+	if ip.To4() != nil {
+		return corev1.IPv4Protocol
+	}
+	return corev1.IPv6Protocol
+}
+
+// serviceSubnetFromCreateError makes an attempt to create a service with a ClusterIP of the given IP family
+// that is out of range, and then checks the error message for the correct range, as suggested in the second
+// answer here:
+//   https://stackoverflow.com/questions/44190607/how-do-you-find-the-cluster-service-cidr-of-a-kubernetes-cluster
+// This requires an additional permission to create a service, which the traffic-manager
+// should have.
+func serviceSubnetFromCreateError(ctx context.Context, namespace string, family corev1.IPFamily) *rpc.IPNet {
+	clusterIP := "1.1.1.1"
+	if family == corev1.IPv6Protocol {
+		clusterIP = "1::1"
+	}
+	singleStack := corev1.IPFamilyPolicySingleStack
+	svc := corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "t2-tst-dummy",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports:          []corev1.ServicePort{{Port: 443}},
+			ClusterIP:      clusterIP,
+			IPFamilies:     []corev1.IPFamily{family},
+			IPFamilyPolicy: &singleStack,
+		},
+	}
+	services := managerutil.GetK8sClientset(ctx).CoreV1().Services(namespace)
+	_, err := services.Create(ctx, &svc, metav1.CreateOptions{})
+	if err == nil {
+		// Should never happen, but if it does, we don't want to leave the service behind.
+		_ = services.Delete(ctx, svc.Name, metav1.DeleteOptions{})
+		dlog.Warnf(ctx, "unable to extract %s service subnet: dummy service with ClusterIP %s was accepted", family, clusterIP)
+		return nil
+	}
+	svcCIDRrx := regexp.MustCompile(`range of valid IPs is (.*)$`)
+	match := svcCIDRrx.FindStringSubmatch(err.Error())
+	if match == nil {
+		dlog.Errorf(ctx, "unable to extract %s service subnet from error message %q", family, err.Error())
+		return nil
+	}
+	_, cidr, err := net.ParseCIDR(match[1])
+	if err != nil {
+		dlog.Errorf(ctx, "unable to parse service CIDR %q", match[1])
+		return nil
+	}
+	dlog.Infof(ctx, "Extracting service subnet %v from create service error message", cidr)
+	return iputil.IPNetToRPC(cidr)
+}
+
+// serviceSubnetFromDNSIP derives the service subnet of the given family from the kube-dns IP of that family.
+func (oi *info) serviceSubnetFromDNSIP(ctx context.Context, family corev1.IPFamily) *rpc.IPNet {
+	// Using a "kubectl cluster-info dump" or scanning all services generates a lot of unwanted traffic
+	// and would quite possibly also require elevated permissions, so instead, we derive the service subnet
+	// from the kubeDNS IP. This is cheating but a cluster may only have one service subnet per IP family
+	// and the mask is unlikely to cover less than half the bits.
+	for _, ip := range oi.KubeDnsIps {
+		if ipFamily(ip) != family {
+			continue
+		}
+		dlog.Infof(ctx, "Deriving serviceSubnet from %s (the IP of kube-dns.kube-system)", net.IP(ip))
+		bits := len(ip) * 8
+		ones := bits / 2
+		mask := net.CIDRMask(ones, bits) // will yield a 16 bit mask on IPv4 and 64 bit mask on IPv6.
+		return &rpc.IPNet{Ip: net.IP(ip).Mask(mask), Mask: int32(ones)}
+	}
+	return nil
+}
+
 func (oi *info) watchNodeSubnets(ctx context.Context) bool {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -290,13 +357,21 @@ func (oi *info) GetClusterID() string {
 	return oi.clusterID
 }
 
+func (oi *info) GetClusterInfo() *rpc.ClusterInfo {
+	oi.accLock.Lock()
+	defer oi.accLock.Unlock()
+	return oi.clusterInfo()
+}
+
 // clusterInfo must be called with accLock locked
 func (oi *info) clusterInfo() *rpc.ClusterInfo {
 	ci := &rpc.ClusterInfo{
-		KubeDnsIp:     oi.KubeDnsIp,
-		ServiceSubnet: oi.ServiceSubnet,
-		PodSubnets:    make([]*rpc.IPNet, len(oi.PodSubnets)),
-		ClusterDomain: oi.ClusterDomain,
+		KubeDnsIp:      oi.KubeDnsIp,
+		ServiceSubnet:  oi.ServiceSubnet,
+		PodSubnets:     make([]*rpc.IPNet, len(oi.PodSubnets)),
+		ClusterDomain:  oi.ClusterDomain,
+		KubeDnsIps:     oi.KubeDnsIps,
+		ServiceSubnets: oi.ServiceSubnets,
 	}
 	copy(ci.PodSubnets, oi.PodSubnets)
 	return ci
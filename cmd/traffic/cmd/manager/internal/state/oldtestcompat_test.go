@@ -37,3 +37,7 @@ func (s *State) Mark(sessionID string, now time.Time) bool {
 	}
 	return s.MarkSession(req, now)
 }
+
+func (s *State) TunnelInterceptID(agentSessionID, clientSessionID string, targetPort uint16) string {
+	return s.getTunnelInterceptID(agentSessionID, clientSessionID, targetPort)
+}
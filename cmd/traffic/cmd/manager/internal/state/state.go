@@ -3,6 +3,7 @@ package state
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"sync"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/watchable"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/connpool"
+	"github.com/telepresenceio/telepresence/v2/pkg/egress"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
 	"github.com/telepresenceio/telepresence/v2/pkg/log"
 	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
@@ -29,7 +31,7 @@ type SessionState interface {
 	LastMarked() time.Time
 	SetLastMarked(lastMarked time.Time)
 	Dials() <-chan *rpc.DialRequest
-	EstablishBidiPipe(context.Context, tunnel.Stream) (tunnel.Endpoint, error)
+	EstablishBidiPipe(ctx context.Context, stream tunnel.Stream, interceptID string) (tunnel.Endpoint, error)
 	OnConnect(context.Context, tunnel.Stream) (tunnel.Endpoint, error)
 }
 
@@ -49,8 +51,9 @@ type sessionState struct {
 
 // EstablishBidiPipe registers the given stream as waiting for a matching stream to arrive in a call
 // to Tunnel, sends a DialRequest to the owner of this sessionState, and then waits. When the call
-// arrives, a BidiPipe connecting the two streams is returned.
-func (ss *sessionState) EstablishBidiPipe(ctx context.Context, stream tunnel.Stream) (tunnel.Endpoint, error) {
+// arrives, a BidiPipe connecting the two streams is returned. The interceptID is passed on in the
+// DialRequest so that the receiving client can tell which intercept the connection belongs to.
+func (ss *sessionState) EstablishBidiPipe(ctx context.Context, stream tunnel.Stream, interceptID string) (tunnel.Endpoint, error) {
 	// Dispatch directly to agent and let the dial happen there
 	bidiPipeCh := make(chan tunnel.Endpoint)
 	id := stream.ID()
@@ -68,7 +71,12 @@ func (ss *sessionState) EstablishBidiPipe(ctx context.Context, stream tunnel.Str
 	select {
 	case <-ss.done:
 		return nil, status.Error(codes.Canceled, "session cancelled")
-	case ss.dials <- &rpc.DialRequest{ConnId: []byte(id), RoundtripLatency: int64(stream.RoundtripLatency()), DialTimeout: int64(stream.DialTimeout())}:
+	case ss.dials <- &rpc.DialRequest{
+		ConnId:           []byte(id),
+		RoundtripLatency: int64(stream.RoundtripLatency()),
+		DialTimeout:      int64(stream.DialTimeout()),
+		InterceptId:      interceptID,
+	}:
 	}
 
 	// Wait for the client/agent to connect. Allow extra time for the call
@@ -145,6 +153,92 @@ type clientSessionState struct {
 	pool         *tunnel.Pool
 	muxTunnel    connpool.MuxTunnel
 	agentTunnels map[string]*agentTunnel
+
+	// notifications are the most recent messages for the user of the client, and dropped is the
+	// number of older messages that have been discarded. notified is closed and replaced each time a
+	// notification is added.
+	notifications []string
+	dropped       int
+	notified      chan struct{}
+}
+
+// maxNotifications is the number of notifications that a client session retains for watchers that
+// haven't received them yet.
+const maxNotifications = 64
+
+func (cs *clientSessionState) notify(message string) {
+	cs.Lock()
+	cs.notifications = append(cs.notifications, message)
+	if excess := len(cs.notifications) - maxNotifications; excess > 0 {
+		cs.notifications = append(cs.notifications[:0:0], cs.notifications[excess:]...)
+		cs.dropped += excess
+	}
+	if cs.notified != nil {
+		close(cs.notified)
+		cs.notified = nil
+	}
+	cs.Unlock()
+}
+
+// unlockedNotificationsFrom returns the retained notifications with sequence number next or higher,
+// and the sequence number that follows them. The caller must hold the lock.
+func (cs *clientSessionState) unlockedNotificationsFrom(next int) ([]string, int) {
+	if next < cs.dropped {
+		next = cs.dropped
+	}
+	return cs.notifications[next-cs.dropped:], cs.dropped + len(cs.notifications)
+}
+
+// watchNotifications calls the given function for each notification until the context is done,
+// the session ends, or the function returns an error. The retained notifications that were added
+// before the call, or before the session ended, are delivered too. A watcher that falls behind by
+// more than maxNotifications misses the oldest ones.
+func (cs *clientSessionState) watchNotifications(ctx context.Context, f func(string) error) error {
+	next := 0
+	for {
+		cs.Lock()
+		var msgs []string
+		msgs, next = cs.unlockedNotificationsFrom(next)
+		if cs.notified == nil {
+			cs.notified = make(chan struct{})
+		}
+		notified := cs.notified
+		cs.Unlock()
+
+		for _, msg := range msgs {
+			if err := f(msg); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-cs.done:
+			cs.Lock()
+			msgs, _ = cs.unlockedNotificationsFrom(next)
+			cs.Unlock()
+			for _, msg := range msgs {
+				if err := f(msg); err != nil {
+					return err
+				}
+			}
+			return nil
+		case <-notified:
+		}
+	}
+}
+
+func (cs *clientSessionState) setMuxTunnel(muxTunnel connpool.MuxTunnel) {
+	cs.Lock()
+	cs.muxTunnel = muxTunnel
+	cs.Unlock()
+}
+
+func (cs *clientSessionState) getMuxTunnel() connpool.MuxTunnel {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.muxTunnel
 }
 
 func (cs *clientSessionState) addAgentTunnel(agentSessionID, name, namespace string, muxTunnel connpool.MuxTunnel) {
@@ -447,6 +541,30 @@ func (s *State) GetClient(sessionID string) *rpc.ClientInfo {
 	return ret
 }
 
+// NotifyClient adds a message for the user of the given client session. It returns false if there's
+// no such session.
+func (s *State) NotifyClient(sessionID, message string) bool {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID].(*clientSessionState)
+	s.mu.Unlock()
+	if ok {
+		sess.notify(message)
+	}
+	return ok
+}
+
+// WatchClientNotifications calls the given function for each message added for the user of the
+// given client session until the context is done, the session ends, or the function returns an error.
+func (s *State) WatchClientNotifications(ctx context.Context, sessionID string, f func(string) error) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID].(*clientSessionState)
+	s.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
+	}
+	return sess.watchNotifications(ctx, f)
+}
+
 func (s *State) GetAllClients() map[string]*rpc.ClientInfo {
 	return s.clients.LoadAll()
 }
@@ -580,6 +698,48 @@ func (s *State) AddIntercept(sessionID, apiKey string, spec *rpc.InterceptSpec)
 	return cept, nil
 }
 
+// TransferIntercept hands the intercept with the given ID over to the client with the given session ID.
+// The intercept keeps its disposition and agent so that the agent can switch to the new client without
+// interrupting the intercepted traffic. It is re-keyed under the new client's session, so that the
+// previous client can create a new intercept with the same name. The re-keyed intercept is stored
+// before the original one is deleted, so that no snapshot lacks both.
+func (s *State) TransferIntercept(interceptID, toSessionID string) (*rpc.InterceptInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toClient, ok := s.clients.Load(toSessionID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Client session %q not found", toSessionID)
+	}
+	cur, ok := s.intercepts.Load(interceptID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Intercept %q not found", interceptID)
+	}
+	if cur.ClientSession.SessionId == toSessionID {
+		return nil, status.Errorf(codes.InvalidArgument, "Intercept %q is already owned by client %q", cur.Spec.Name, toClient.Name)
+	}
+
+	// Sessions are removed while holding s.mu, so neither the intercept nor the sessions
+	// can vanish between the checks above and the store below.
+	newInfo := proto.Clone(cur).(*rpc.InterceptInfo)
+	newInfo.Id = fmt.Sprintf("%s:%s", toSessionID, cur.Spec.Name)
+	newInfo.Spec.Client = toClient.Name
+	newInfo.ClientSession = &rpc.SessionInfo{SessionId: toSessionID}
+	if _, hasConflict := s.intercepts.LoadOrStore(newInfo.Id, newInfo); hasConflict {
+		return nil, status.Errorf(codes.AlreadyExists, "Client %q already has an intercept named %q", toClient.Name, cur.Spec.Name)
+	}
+	s.intercepts.Delete(interceptID)
+	if apiKey, ok := s.interceptAPIKeys[interceptID]; ok {
+		s.interceptAPIKeys[newInfo.Id] = apiKey
+		delete(s.interceptAPIKeys, interceptID)
+	}
+	if l, ok := s.listeners[interceptID]; ok {
+		s.listeners[newInfo.Id] = l
+		delete(s.listeners, interceptID)
+	}
+	return newInfo, nil
+}
+
 // getAgentsInterceptedByClient returns the session IDs for each agent that are currently
 // intercepted by the client with the given client session ID.
 func (s *State) getAgentsInterceptedByClient(clientSessionID string) []string {
@@ -610,6 +770,25 @@ func (s *State) getAgentsInterceptedByClient(clientSessionID string) []string {
 	return agentIDs
 }
 
+// getTunnelInterceptID returns the ID of the intercept that the client with the given session ID has
+// on the traffic-agent with the given session ID for the given target port, or an empty string when no
+// such intercept exists.
+func (s *State) getTunnelInterceptID(agentSessionID, clientSessionID string, targetPort uint16) string {
+	ai, ok := s.agents.Load(agentSessionID)
+	if !ok {
+		return ""
+	}
+	intercepts := s.intercepts.LoadAllMatching(func(_ string, ii *rpc.InterceptInfo) bool {
+		spec := ii.Spec
+		return ii.ClientSession.SessionId == clientSessionID &&
+			spec.Agent == ai.Name && spec.Namespace == ai.Namespace && uint16(spec.TargetPort) == targetPort
+	})
+	for id := range intercepts {
+		return id
+	}
+	return ""
+}
+
 // UpdateIntercept applies a given mutator function to the stored intercept with interceptID;
 // storing and returning the result.  If the given intercept does not exist, then the mutator
 // function is not run, and nil is returned.
@@ -706,7 +885,7 @@ func (s *State) ClientTunnel(ctx context.Context, muxTunnel connpool.MuxTunnel)
 	}
 	dlog.Debug(ctx, "Established TCP tunnel")
 	pool := cs.pool // must have one pool per client
-	cs.muxTunnel = muxTunnel
+	cs.setMuxTunnel(muxTunnel)
 	defer pool.CloseAll(ctx)
 	msgCh, errCh := muxTunnel.ReadLoop(ctx)
 	for {
@@ -741,7 +920,7 @@ func (s *State) ClientTunnel(ctx context.Context, muxTunnel connpool.MuxTunnel)
 						dlog.Debugf(ctx, "|| FRWD %s forwarding client connection to agent %s.%s", id, agentTunnel.name, agentTunnel.namespace)
 						return newMuxTunnelForward(release, agentTunnel.muxTunnel), nil
 					}
-					return connpool.NewDialer(id, cs.muxTunnel, release), nil
+					return connpool.NewDialer(id, muxTunnel, release), nil
 				})
 				if err != nil {
 					return fmt.Errorf("failed to get connection handler: %w", err)
@@ -774,12 +953,14 @@ func (s *State) Tunnel(ctx context.Context, stream tunnel.Stream) error {
 
 	// The session is either the telepresence client or a traffic-agent.
 	//
-	// A client will want to extend the tunnel to a dialer in an intercepted traffic-agent or, if no
-	// intercept is active, to a dialer here in the traffic-agent.
+	// A client will want to extend the tunnel to a dialer in the traffic-agent that an egress rule of
+	// the client selects, to a dialer in an intercepted traffic-agent or, if no intercept is active, to
+	// a dialer here in the traffic-agent.
 	//
 	// A traffic-agent must always extend the tunnel to the client that it is currently intercepted
 	// by, and hence, start by sending the sessionID of that client on the tunnel.
 	var peerSession SessionState
+	var interceptID string
 	if _, ok := ss.(*agentSessionState); ok {
 		// traffic-agent, so obtain the desired client session
 		m, err := stream.Receive(ctx)
@@ -793,14 +974,20 @@ func (s *State) Tunnel(ctx context.Context, stream tunnel.Stream) error {
 		s.mu.Lock()
 		peerSession = s.sessions[peerID]
 		s.mu.Unlock()
+		interceptID = s.getTunnelInterceptID(sessionID, peerID, stream.ID().DestinationPort())
 	} else {
-		peerSession = s.getRandomAgentSession(sessionID)
+		if peerSession, err = s.getEgressAgentSession(sessionID, stream.ID().Destination()); err != nil {
+			return err
+		}
+		if peerSession == nil {
+			peerSession = s.getRandomAgentSession(sessionID)
+		}
 	}
 
 	var endPoint tunnel.Endpoint
 	if peerSession != nil {
 		var err error
-		if endPoint, err = peerSession.EstablishBidiPipe(ctx, stream); err != nil {
+		if endPoint, err = peerSession.EstablishBidiPipe(ctx, stream, interceptID); err != nil {
 			return err
 		}
 	} else {
@@ -820,6 +1007,65 @@ func (s *State) getRandomAgentSession(clientSessionID string) (agent SessionStat
 	return
 }
 
+// getEgressAgentSession returns the session of the agent that the egress rules of the given client
+// select for connections to the given destination, or nil when no rule applies to the destination.
+// It's an error when a rule applies but no agent of its workload is available, because the connection
+// must not originate from somewhere else.
+func (s *State) getEgressAgentSession(clientSessionID string, destination net.IP) (SessionState, error) {
+	client, ok := s.clients.Load(clientSessionID)
+	if !ok {
+		return nil, nil
+	}
+	rule := egress.RuleFor(client.EgressRules, destination)
+	if rule == nil {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if agentID, _ := s.unlockedEgressAgent(rule); agentID != "" {
+		return s.sessions[agentID], nil
+	}
+	return nil, status.Errorf(codes.Unavailable, "no traffic-agent of %s.%s is available for egress to %s",
+		rule.Agent, rule.Namespace, destination)
+}
+
+// EgressStatus returns the egress rules of the given client together with the pod IPs of the agents that
+// the rules currently select. The second return value is false when the client session doesn't exist.
+func (s *State) EgressStatus(clientSessionID string) ([]*rpc.EgressRuleStatus, bool) {
+	client, ok := s.clients.Load(clientSessionID)
+	if !ok {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := make([]*rpc.EgressRuleStatus, len(client.EgressRules))
+	for i, rule := range client.EgressRules {
+		rs[i] = &rpc.EgressRuleStatus{Rule: rule}
+		if _, agent := s.unlockedEgressAgent(rule); agent != nil {
+			rs[i].PodIp = agent.PodIp
+		}
+	}
+	return rs, true
+}
+
+// unlockedEgressAgent returns the session ID and info of the agent that the connections that the given
+// rule applies to originate from, or an empty ID and nil when no agent of the rule's workload is
+// available. Must be called with s.mu locked.
+func (s *State) unlockedEgressAgent(rule *rpc.EgressRule) (string, *rpc.AgentInfo) {
+	agentSet := s.agentsByName[rule.Agent]
+	agents := make([]*rpc.AgentInfo, 0, len(agentSet))
+	agentIDs := make(map[*rpc.AgentInfo]string, len(agentSet))
+	for agentID, agent := range agentSet {
+		agents = append(agents, agent)
+		agentIDs[agent] = agentID
+	}
+	if agent := egress.AgentFor(rule, agents); agent != nil {
+		return agentIDs[agent], agent
+	}
+	return "", nil
+}
+
 func (s *State) WatchDial(sessionID string) <-chan *rpc.DialRequest {
 	s.mu.Lock()
 	ss, ok := s.sessions[sessionID]
@@ -920,7 +1166,11 @@ func (s *State) AgentTunnel(ctx context.Context, clientSessionInfo *rpc.SessionI
 				}
 			}
 			dlog.Debugf(ctx, ">> FRWD %s to client", msg.ID())
-			if err = cs.muxTunnel.Send(ctx, msg); err != nil {
+			clientTunnel := cs.getMuxTunnel()
+			if clientTunnel == nil {
+				return status.Errorf(codes.FailedPrecondition, "client %q has no tunnel", cs.name)
+			}
+			if err = clientTunnel.Send(ctx, msg); err != nil {
 				dlog.Errorf(ctx, "Send to client failed: %v", err)
 				return err
 			}
@@ -2,9 +2,12 @@ package state_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
 	manager "github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/state"
 	testdata "github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/test"
 )
@@ -122,4 +125,127 @@ func TestStateInternal(topT *testing.T) {
 		a.False(state.Mark(c2, clock.Now()))
 		a.False(state.Mark(c3, clock.Now()))
 	})
+
+	topT.Run("intercept-transfer", func(t *testing.T) {
+		a := assertNew(t)
+
+		clock := &FakeClock{}
+		state := manager.NewState(ctx)
+
+		alice := state.AddClient(testClients["alice"], clock.Now())
+		bob := state.AddClient(testClients["bob"], clock.Now())
+		state.AddAgent(testAgents["hello"], clock.Now())
+
+		spec := &rpc.InterceptSpec{
+			Name:      "hello",
+			Client:    testClients["alice"].Name,
+			Agent:     "hello",
+			Namespace: "default",
+		}
+		cept, err := state.AddIntercept(alice, "", spec)
+		a.NoError(err)
+
+		_, err = state.TransferIntercept(cept.Id, alice)
+		a.Error(err)
+		_, err = state.TransferIntercept(cept.Id, "asdf")
+		a.Error(err)
+
+		// The intercept is re-keyed under bob's session
+		transferred, err := state.TransferIntercept(cept.Id, bob)
+		a.NoError(err)
+		a.Equal(bob+":hello", transferred.Id)
+		a.Equal(bob, transferred.ClientSession.SessionId)
+		a.Equal(testClients["bob"].Name, transferred.Spec.Client)
+		a.Equal(cept.Disposition, transferred.Disposition)
+		_, ok := state.GetIntercept(cept.Id)
+		a.False(ok)
+		_, ok = state.GetIntercept(transferred.Id)
+		a.True(ok)
+
+		// Bob can't create another intercept with the same name, but alice can
+		_, err = state.AddIntercept(bob, "", spec)
+		a.Error(err)
+		again, err := state.AddIntercept(alice, "", spec)
+		a.NoError(err)
+		a.Equal(cept.Id, again.Id)
+
+		// Alice's new intercept can't be transferred to bob, who already has one with the same name
+		_, err = state.TransferIntercept(again.Id, bob)
+		a.Error(err)
+		_, ok = state.GetIntercept(again.Id)
+		a.True(ok)
+
+		// The intercept is removed with bob's session, not with alice's
+		state.RemoveSession(ctx, alice)
+		_, ok = state.GetIntercept(transferred.Id)
+		a.True(ok)
+		_, ok = state.GetIntercept(again.Id)
+		a.False(ok)
+		state.RemoveSession(ctx, bob)
+		_, ok = state.GetIntercept(transferred.Id)
+		a.False(ok)
+	})
+
+	topT.Run("tunnel intercept ID", func(t *testing.T) {
+		a := assertNew(t)
+
+		clock := &FakeClock{}
+		state := manager.NewState(ctx)
+
+		alice := state.AddClient(testClients["alice"], clock.Now())
+		bob := state.AddClient(testClients["bob"], clock.Now())
+		hello := state.AddAgent(testAgents["hello"], clock.Now())
+		helloPro := state.AddAgent(testAgents["helloPro"], clock.Now())
+
+		spec := func(name string, port int32) *rpc.InterceptSpec {
+			return &rpc.InterceptSpec{
+				Name:       name,
+				Client:     testClients["alice"].Name,
+				Agent:      "hello",
+				Namespace:  "default",
+				TargetPort: port,
+			}
+		}
+		http, err := state.AddIntercept(alice, "", spec("http", 8080))
+		a.NoError(err)
+		grpc, err := state.AddIntercept(alice, "", spec("grpc", 8081))
+		a.NoError(err)
+
+		// The intercept is found by the agent, the client, and the port that the agent forwards to
+		a.Equal(http.Id, state.TunnelInterceptID(hello, alice, 8080))
+		a.Equal(grpc.Id, state.TunnelInterceptID(hello, alice, 8081))
+		a.Empty(state.TunnelInterceptID(hello, alice, 9999))
+		a.Empty(state.TunnelInterceptID(hello, bob, 8080))
+		a.Empty(state.TunnelInterceptID(helloPro, alice, 8080))
+		a.Empty(state.TunnelInterceptID("unknown", alice, 8080))
+	})
+
+	topT.Run("notifications", func(t *testing.T) {
+		a := assertNew(t)
+
+		clock := &FakeClock{}
+		state := manager.NewState(ctx)
+		alice := state.AddClient(testClients["alice"], clock.Now())
+		for i := 0; i < 100; i++ {
+			a.True(state.NotifyClient(alice, fmt.Sprintf("message %d", i)))
+		}
+		a.False(state.NotifyClient("unknown", "message"))
+
+		// Only the most recent notifications are retained for a watcher that starts late.
+		errDone := errors.New("done")
+		var msgs []string
+		err := state.WatchClientNotifications(ctx, alice, func(msg string) error {
+			msgs = append(msgs, msg)
+			if msg == "message 100" {
+				return errDone
+			}
+			if msg == "message 99" {
+				go state.NotifyClient(alice, "message 100")
+			}
+			return nil
+		})
+		a.Equal(errDone, err)
+		a.Len(msgs, 65)
+		a.Equal("message 36", msgs[0])
+	})
 }
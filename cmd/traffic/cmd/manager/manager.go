@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -21,6 +22,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/mutator"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/watchable"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 	"github.com/telepresenceio/telepresence/v2/pkg/version"
 )
 
@@ -32,6 +34,19 @@ func Main(ctx context.Context, args ...string) error {
 	if err != nil {
 		return fmt.Errorf("failed to LoadEnv: %w", err)
 	}
+	faults, err := tunnel.ParseFaults(managerutil.GetEnv(ctx).TunnelFaults)
+	if err != nil {
+		return fmt.Errorf("invalid TELEPRESENCE_TUNNEL_FAULTS: %w", err)
+	}
+	if faults.Enabled() {
+		dlog.Warnf(ctx, "Injecting faults into tunnels: %s", faults)
+		ctx = tunnel.WithFaults(ctx, faults)
+	}
+	compressions, err := tunnel.ParseCompressions(managerutil.GetEnv(ctx).TunnelCompression)
+	if err != nil {
+		return fmt.Errorf("invalid TELEPRESENCE_TUNNEL_COMPRESSION: %w", err)
+	}
+	ctx = tunnel.WithCompressions(ctx, compressions)
 
 	cfg, err := rest.InClusterConfig()
 	if err != nil {
@@ -61,6 +76,11 @@ func Main(ctx context.Context, args ...string) error {
 	// exist.
 	g.Go("systema-gc", mgr.runSystemAGCLoop)
 
+	// The traffic-manager serves the preview URLs itself when it has a preview domain.
+	if managerutil.GetEnv(ctx).PreviewDomain != "" {
+		g.Go("preview-gateway", mgr.servePreviewGateway)
+	}
+
 	// Wait for exit
 	return g.Wait()
 }
@@ -69,15 +89,13 @@ func (m *Manager) serveHTTP(ctx context.Context) error {
 	env := managerutil.GetEnv(ctx)
 	host := env.ServerHost
 	port := env.ServerPort
-	opts := []grpc.ServerOption{}
-	if mz, ok := env.MaxReceiveSize.AsInt64(); ok {
-		opts = append(opts, grpc.MaxRecvMsgSize(int(mz)))
+	auth, err := newAuthenticator(ctx)
+	if err != nil {
+		return err
 	}
 
-	grpcHandler := grpc.NewServer(opts...)
-	httpHandler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "Hello World from: %s\n", r.URL.Path)
-	}))
+	grpcHandler := grpc.NewServer(serverOptions(ctx, auth)...)
+	httpHandler := m.adminHandler(ctx)
 	sc := &dhttp.ServerConfig{
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
@@ -91,7 +109,50 @@ func (m *Manager) serveHTTP(ctx context.Context) error {
 	rpc.RegisterManagerServer(grpcHandler, m)
 	grpc_health_v1.RegisterHealthServer(grpcHandler, &HealthChecker{})
 
-	return sc.ListenAndServe(ctx, host+":"+port)
+	if env.DirectPort == "" {
+		return sc.ListenAndServe(ctx, host+":"+port)
+	}
+
+	// Serve the same API using TLS on the direct port, so that clients can connect using a
+	// LoadBalancer, Ingress, or NodePort instead of a port-forward through the API server.
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{})
+	g.Go("plaintext", func(ctx context.Context) error {
+		return sc.ListenAndServe(ctx, host+":"+port)
+	})
+	g.Go("tls", func(ctx context.Context) error {
+		tsc := &dhttp.ServerConfig{Handler: sc.Handler}
+		if auth != nil {
+			var err error
+			if tsc.TLSConfig, err = auth.tlsConfig(ctx); err != nil {
+				return err
+			}
+		}
+		return tsc.ListenAndServeTLS(ctx, host+":"+env.DirectPort,
+			filepath.Join(env.DirectTLSDir, "crt.pem"),
+			filepath.Join(env.DirectTLSDir, "key.pem"))
+	})
+	return g.Wait()
+}
+
+// ServerOptions returns the options of the gRPC server that serves the traffic-manager's API. They
+// include the interceptors that authenticate the callers when client authentication is enabled.
+func ServerOptions(ctx context.Context) ([]grpc.ServerOption, error) {
+	auth, err := newAuthenticator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return serverOptions(ctx, auth), nil
+}
+
+func serverOptions(ctx context.Context, auth *authenticator) []grpc.ServerOption {
+	opts := []grpc.ServerOption{}
+	if mz, ok := managerutil.GetEnv(ctx).MaxReceiveSize.AsInt64(); ok && mz > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(mz)))
+	}
+	if auth != nil {
+		opts = append(opts, grpc.UnaryInterceptor(auth.unaryInterceptor), grpc.StreamInterceptor(auth.streamInterceptor))
+	}
+	return opts
 }
 
 func (m *Manager) runInterceptGCLoop(ctx context.Context) error {
@@ -120,7 +181,7 @@ func (m *Manager) runSystemAGCLoop(ctx context.Context) error {
 					dlog.Errorln(ctx, "systema: acquire connection:", err)
 				} else {
 					// First we remove the PreviewDomain if it exists
-					if update.Value.PreviewDomain != "" {
+					if update.Value.PreviewDomain != "" && !isSelfHostedPreviewDomain(ctx, update.Value.PreviewDomain) {
 						err = m.reapDomain(ctx, sa, update)
 						if err != nil {
 							dlog.Errorln(ctx, "systema: remove domain:", err)
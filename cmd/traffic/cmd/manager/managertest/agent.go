@@ -0,0 +1,117 @@
+package managertest
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/connpool"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+// Handler serves a connection that the manager routes to a fake agent. The connection is closed when
+// the handler returns.
+type Handler func(ctx context.Context, id tunnel.ConnID, conn net.Conn)
+
+// Agent is a fake traffic-agent with a session in the traffic-manager.
+type Agent struct {
+	Session
+	AgentInfo *rpc.AgentInfo
+}
+
+// Serve makes the agent behave like a traffic-agent until the test ends. It activates the intercepts
+// that clients create for it, and serves the connections that the manager routes to it with the given
+// handler. The agent dials the destination of each connection itself when the handler is nil.
+func (a *Agent) Serve(handler Handler) {
+	ctx := a.h.ctx
+	wi, err := a.h.Client.WatchIntercepts(a.context(ctx), a.Info)
+	if err != nil {
+		a.h.t.Fatalf("WatchIntercepts(%s): %v", a.AgentInfo.Name, err)
+	}
+	wd, err := a.h.Client.WatchDial(a.context(ctx), a.Info)
+	if err != nil {
+		a.h.t.Fatalf("WatchDial(%s): %v", a.AgentInfo.Name, err)
+	}
+	a.h.goWorker(func() { a.activateIntercepts(ctx, wi) })
+	a.h.goWorker(func() {
+		for {
+			dr, err := wd.Recv()
+			if err != nil {
+				return
+			}
+			a.h.goWorker(func() { a.serveDial(ctx, dr, handler) })
+		}
+	})
+}
+
+// activateIntercepts reviews each waiting intercept of the agent as active.
+func (a *Agent) activateIntercepts(ctx context.Context, wi rpc.Manager_WatchInterceptsClient) {
+	for {
+		snapshot, err := wi.Recv()
+		if err != nil {
+			return
+		}
+		for _, ii := range snapshot.Intercepts {
+			if ii.Disposition != rpc.InterceptDispositionType_WAITING {
+				continue
+			}
+			_, err := a.h.Client.ReviewIntercept(a.context(ctx), &rpc.ReviewInterceptRequest{
+				Session:     a.Info,
+				Id:          ii.Id,
+				Disposition: rpc.InterceptDispositionType_ACTIVE,
+				PodIp:       a.AgentInfo.PodIp,
+			})
+			if err != nil && ctx.Err() == nil {
+				dlog.Errorf(ctx, "ReviewIntercept(%s): %v", ii.Id, err)
+			}
+		}
+	}
+}
+
+// serveDial connects to the tunnel that the dial request is for, and serves the connection.
+func (a *Agent) serveDial(ctx context.Context, dr *rpc.DialRequest, handler Handler) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	id := tunnel.ConnID(dr.ConnId)
+	ms, err := a.h.Client.Tunnel(a.context(ctx))
+	if err != nil {
+		dlog.Errorf(ctx, "Tunnel(%s): %v", id, err)
+		return
+	}
+	s, err := tunnel.NewClientStream(ctx, ms, id, a.Info.SessionId, time.Duration(dr.RoundtripLatency), time.Duration(dr.DialTimeout))
+	if err != nil {
+		dlog.Errorf(ctx, "stream %s: %v", id, err)
+		return
+	}
+	s = tunnel.NewFaultyStream(s, a.Faults)
+	if handler == nil {
+		d := tunnel.NewDialer(s)
+		d.Start(ctx)
+		<-d.Done()
+		return
+	}
+	if err = s.Send(ctx, tunnel.NewMessage(tunnel.DialOK, nil)); err != nil {
+		dlog.Errorf(ctx, "stream %s: %v", id, err)
+		return
+	}
+	conn, peer := net.Pipe()
+	ep := tunnel.NewConnEndpoint(s, peer)
+	ep.Start(ctx)
+	a.h.goWorker(func() {
+		defer conn.Close()
+		handler(ctx, id, conn)
+	})
+	<-ep.Done()
+}
+
+// AgentTunnel opens the agent's tunnel of the older multiplexing protocol to the given client and
+// performs its handshake, after which connpool messages can be exchanged.
+func (a *Agent) AgentTunnel(ctx context.Context, client *Client) (connpool.MuxTunnel, error) {
+	at, err := a.h.Client.AgentTunnel(a.context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return muxHandshake(ctx, connpool.NewFaultyMuxTunnel(ctx, at, a.Faults), a.Info, client.Info)
+}
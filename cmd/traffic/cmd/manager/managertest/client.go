@@ -0,0 +1,182 @@
+package managertest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/connpool"
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+const (
+	roundtripLatency = time.Second
+	dialTimeout      = 5 * time.Second
+)
+
+// nextSourcePort is the source port of the next connection that a fake client dials. Each connection
+// needs its own port, because the ConnID that identifies the connection includes it.
+var nextSourcePort = uint32(40000)
+
+// Client is a fake client with a session in the traffic-manager.
+type Client struct {
+	Session
+	ClientInfo *rpc.ClientInfo
+}
+
+// CreateIntercept creates an intercept for the client. The name, namespace, and agent of the spec
+// default to those of the given agent, the mechanism defaults to "tcp", and the target host to
+// "127.0.0.1".
+func (c *Client) CreateIntercept(ctx context.Context, agent *Agent, spec *rpc.InterceptSpec) (*rpc.InterceptInfo, error) {
+	if spec.Agent == "" {
+		spec.Agent = agent.AgentInfo.Name
+	}
+	if spec.Name == "" {
+		spec.Name = spec.Agent
+	}
+	if spec.Namespace == "" {
+		spec.Namespace = agent.AgentInfo.Namespace
+	}
+	if spec.Mechanism == "" {
+		spec.Mechanism = "tcp"
+	}
+	if spec.TargetHost == "" {
+		spec.TargetHost = "127.0.0.1"
+	}
+	spec.Client = c.ClientInfo.Name
+	return c.h.Client.CreateIntercept(c.context(ctx), &rpc.CreateInterceptRequest{Session: c.Info, InterceptSpec: spec})
+}
+
+// RemoveIntercept removes an intercept of the client.
+func (c *Client) RemoveIntercept(ctx context.Context, name string) error {
+	_, err := c.h.Client.RemoveIntercept(c.context(ctx), &rpc.RemoveInterceptRequest2{Session: c.Info, Name: name})
+	return err
+}
+
+// Dial opens a TCP connection to the given "ip:port" address through the manager's Tunnel RPC, just
+// like the root daemon does for the connections that it routes to the cluster. The manager sends the
+// connection to the agent that an egress rule of the client selects, to an agent that the client
+// intercepts, or else dials the address itself.
+func (c *Client) Dial(ctx context.Context, address string) (net.Conn, error) {
+	return c.dial(ctx, address, func(sctx context.Context, id tunnel.ConnID) (tunnel.Stream, error) {
+		ms, err := c.h.Client.Tunnel(c.context(sctx))
+		if err != nil {
+			return nil, err
+		}
+		return tunnel.NewClientStream(sctx, ms, id, c.Info.SessionId, roundtripLatency, dialTimeout)
+	})
+}
+
+// OpenMuxSession opens a session that multiplexes the streams of many connections over one Tunnel RPC,
+// just like the root daemon does when the manager supports it. The session ends when the given context
+// is cancelled.
+func (c *Client) OpenMuxSession(ctx context.Context) (*tunnel.MuxSession, error) {
+	mt, err := c.h.Client.Tunnel(c.context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return tunnel.NewMuxSession(ctx, mt)
+}
+
+// DialMux is like Dial, but the connection is carried by the given multiplexed session.
+func (c *Client) DialMux(ctx context.Context, ms *tunnel.MuxSession, address string) (net.Conn, error) {
+	return c.dial(ctx, address, func(sctx context.Context, id tunnel.ConnID) (tunnel.Stream, error) {
+		return ms.Open(sctx, id, c.Info.SessionId, roundtripLatency, dialTimeout)
+	})
+}
+
+func (c *Client) dial(ctx context.Context, address string, open func(context.Context, tunnel.ConnID) (tunnel.Stream, error)) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ip := iputil.Parse(host)
+	if ip == nil {
+		return nil, fmt.Errorf("%q is not an IP address", host)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+	srcPort := uint16(atomic.AddUint32(&nextSourcePort, 1))
+	id := tunnel.NewConnID(ipproto.TCP, iputil.Parse("127.0.0.1"), ip, srcPort, uint16(port))
+
+	// The stream must outlive the given context, which is only used when dialing.
+	sctx, cancel := context.WithCancel(c.h.ctx)
+	s, err := open(sctx, id)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s = tunnel.NewFaultyStream(s, c.Faults)
+
+	type result struct {
+		m   tunnel.Message
+		err error
+	}
+	rc := make(chan result, 1)
+	go func() {
+		m, err := s.Receive(sctx)
+		rc <- result{m, err}
+	}()
+	var r result
+	select {
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	case r = <-rc:
+	}
+	switch {
+	case r.err != nil:
+		cancel()
+		return nil, fmt.Errorf("dial %s: %w", address, r.err)
+	case r.m.Code() != tunnel.DialOK:
+		cancel()
+		return nil, fmt.Errorf("dial %s: %s", address, r.m.Code())
+	}
+
+	conn, peer := net.Pipe()
+	ep := tunnel.NewConnEndpoint(s, peer)
+	ep.Start(sctx)
+	c.h.goWorker(func() {
+		<-ep.Done()
+		cancel()
+	})
+	return conn, nil
+}
+
+// ClientTunnel opens the client's tunnel of the older multiplexing protocol and performs its
+// handshake, after which connpool messages can be exchanged.
+func (c *Client) ClientTunnel(ctx context.Context) (connpool.MuxTunnel, error) {
+	ct, err := c.h.Client.ClientTunnel(c.context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return muxHandshake(ctx, connpool.NewFaultyMuxTunnel(ctx, ct, c.Faults), c.Info)
+}
+
+// muxHandshake sends the given sessions and the version of the multiplexing protocol, and then reads
+// the version of the manager.
+func muxHandshake(ctx context.Context, mt connpool.MuxTunnel, sessions ...*rpc.SessionInfo) (connpool.MuxTunnel, error) {
+	for _, si := range sessions {
+		if err := mt.Send(ctx, connpool.SessionInfoControl(si)); err != nil {
+			_ = mt.CloseSend()
+			return nil, err
+		}
+	}
+	if err := mt.Send(ctx, connpool.VersionControl()); err != nil {
+		_ = mt.CloseSend()
+		return nil, err
+	}
+	if _, err := mt.ReadPeerVersion(ctx); err != nil {
+		_ = mt.CloseSend()
+		return nil, err
+	}
+	return mt, nil
+}
@@ -0,0 +1,250 @@
+// Package managertest provides a hermetic, in-process traffic-manager for tests. The manager serves its
+// gRPC API on an in-memory bufconn listener and is backed by a fake Kubernetes clientset, so tests that
+// use it need neither a cluster nor a network.
+//
+// Fake clients and agents arrive at the manager just like real ones do. A fake agent serves the
+// connections that are routed to it with a Handler, and a fake client pushes TCP traffic through the
+// manager's Tunnel RPC using Dial, or through the older ClientTunnel and AgentTunnel RPCs.
+//
+// Faults can be injected into the tunnels of the manager and of each session in order to test how
+// the code behaves on a poor connection.
+//
+// The manager authenticates its callers when the environment enables client authentication. A fake
+// client that arrives with a token presents it on each call that it makes for its session.
+package managertest
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sVersion "k8s.io/apimachinery/pkg/version"
+	fakeDiscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+const bufSize = 64 * 1024
+
+// Harness is a traffic-manager that runs in-process for the duration of a test.
+type Harness struct {
+	// Clientset is the fake Kubernetes clientset that the manager uses.
+	Clientset *fake.Clientset
+
+	// Conn is a connection to the manager's gRPC API.
+	Conn *grpc.ClientConn
+
+	// Client is a client of the manager's gRPC API.
+	Client rpc.ManagerClient
+
+	t   testing.TB
+	ctx context.Context
+	wg  sync.WaitGroup // goroutines of fake clients and agents
+}
+
+// DefaultEnv returns the environment that New uses when it's given a nil env. The pod CIDRs are
+// given explicitly, because the fake clientset has no nodes to derive them from.
+func DefaultEnv() *managerutil.Env {
+	return &managerutil.Env{
+		PodCIDRStrategy: "environment",
+		PodCIDRs:        "192.168.0.0/16",
+	}
+}
+
+// New starts a traffic-manager with the given environment, and a fake clientset that contains a
+// "default" namespace and the given objects. The manager injects the TunnelFaults of the environment
+// into its tunnels. The manager is stopped when the test ends. Errors are
+// logged to the test without failing it, because connections that are still open when the test ends
+// log errors when they're cancelled.
+func New(t testing.TB, env *managerutil.Env, objects ...runtime.Object) *Harness {
+	if env == nil {
+		env = DefaultEnv()
+	}
+	ctx, cancel := context.WithCancel(dlog.NewTestContext(t, false))
+
+	fakeClient := fake.NewSimpleClientset(append([]runtime.Object{&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}}, objects...)...)
+	fakeClient.Discovery().(*fakeDiscovery.FakeDiscovery).FakedServerVersion = &k8sVersion.Info{
+		GitVersion: "v1.17.0",
+	}
+	ctx = managerutil.WithK8SClientset(ctx, fakeClient)
+	ctx = managerutil.WithEnv(ctx, env)
+	faults, err := tunnel.ParseFaults(env.TunnelFaults)
+	if err != nil {
+		cancel()
+		t.Fatalf("Invalid TunnelFaults: %v", err)
+	}
+	if faults.Enabled() {
+		ctx = tunnel.WithFaults(ctx, faults)
+	}
+
+	lis := bufconn.Listen(bufSize)
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		cancel()
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+
+	opts, err := manager.ServerOptions(ctx)
+	if err != nil {
+		cancel()
+		t.Fatalf("Invalid server options: %v", err)
+	}
+	s := grpc.NewServer(opts...)
+	rpc.RegisterManagerServer(s, manager.NewManager(ctx))
+
+	errCh := make(chan error)
+	go func() {
+		sc := &dhttp.ServerConfig{
+			Handler: s,
+		}
+		errCh <- sc.Serve(ctx, lis)
+		close(errCh)
+	}()
+	h := &Harness{
+		Clientset: fakeClient,
+		Conn:      conn,
+		Client:    rpc.NewManagerClient(conn),
+		t:         t,
+		ctx:       ctx,
+	}
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errCh; err != nil && err != ctx.Err() {
+			t.Error(err)
+		}
+		// Nothing may log to the test once it has ended
+		h.wg.Wait()
+		_ = conn.Close()
+	})
+	return h
+}
+
+// goWorker runs the given function in a goroutine that the harness waits for when the test ends.
+func (h *Harness) goWorker(f func()) {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		f()
+	}()
+}
+
+// Context returns a context that logs to the test and is cancelled when the test ends.
+func (h *Harness) Context() context.Context {
+	return h.ctx
+}
+
+// ArriveAsClient creates a client session for the given client. Fields that are empty in the given
+// info are filled in with defaults.
+func (h *Harness) ArriveAsClient(info *rpc.ClientInfo) *Client {
+	return h.ArriveAsClientWithToken(info, "")
+}
+
+// ArriveAsClientWithToken is like ArriveAsClient, but the client presents the given bearer token when
+// it arrives, and on each call that it makes for its session.
+func (h *Harness) ArriveAsClientWithToken(info *rpc.ClientInfo, token string) *Client {
+	if info.Product == "" {
+		info.Product = "telepresence"
+	}
+	if info.Version == "" {
+		info.Version = "v2.4.5"
+	}
+	if info.InstallId == "" {
+		info.InstallId = info.Name + "-install-id"
+	}
+	ss := Session{Token: token, h: h}
+	si, err := h.Client.ArriveAsClient(ss.context(h.ctx), info)
+	if err != nil {
+		h.t.Fatalf("ArriveAsClient(%s): %v", info.Name, err)
+	}
+	ss.Info = si
+	return &Client{Session: ss, ClientInfo: info}
+}
+
+// ArriveAsAgent creates an agent session for the given agent. Fields that are empty in the given
+// info are filled in with defaults.
+func (h *Harness) ArriveAsAgent(info *rpc.AgentInfo) *Agent {
+	return h.ArriveAsAgentWithToken(info, "")
+}
+
+// ArriveAsAgentWithToken is like ArriveAsAgent, but the agent presents the given service account token
+// when it arrives, and on each call that it makes for its session.
+func (h *Harness) ArriveAsAgentWithToken(info *rpc.AgentInfo, token string) *Agent {
+	if info.Namespace == "" {
+		info.Namespace = "default"
+	}
+	if info.Product == "" {
+		info.Product = "telepresence"
+	}
+	if info.Version == "" {
+		info.Version = "v2.4.5"
+	}
+	if len(info.Mechanisms) == 0 {
+		info.Mechanisms = []*rpc.AgentInfo_Mechanism{{Name: "tcp", Product: "telepresence", Version: info.Version}}
+	}
+	ss := Session{AgentToken: token, h: h}
+	si, err := h.Client.ArriveAsAgent(ss.context(h.ctx), info)
+	if err != nil {
+		h.t.Fatalf("ArriveAsAgent(%s): %v", info.Name, err)
+	}
+	ss.Info = si
+	return &Agent{Session: ss, AgentInfo: info}
+}
+
+// Session is the session of a fake client or agent.
+type Session struct {
+	Info *rpc.SessionInfo
+
+	// Faults are injected into the tunnels that the session opens after they're set.
+	Faults *tunnel.Faults
+
+	// Token is the bearer token that the session presents on its calls, if any.
+	Token string
+
+	// AgentToken is the service account token that an agent session presents on its calls, if any.
+	AgentToken string
+
+	h *Harness
+}
+
+// context returns the given context with the session's tokens, if any, as outgoing metadata.
+func (s *Session) context(ctx context.Context) context.Context {
+	if s.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+s.Token)
+	}
+	if s.AgentToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, install.AgentTokenMetadataKey, s.AgentToken)
+	}
+	return ctx
+}
+
+// Remain keeps the session alive.
+func (s *Session) Remain(ctx context.Context) error {
+	_, err := s.h.Client.Remain(s.context(ctx), &rpc.RemainRequest{Session: s.Info})
+	return err
+}
+
+// Depart ends the session.
+func (s *Session) Depart(ctx context.Context) error {
+	_, err := s.h.Client.Depart(s.context(ctx), s.Info)
+	return err
+}
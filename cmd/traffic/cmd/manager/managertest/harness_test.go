@@ -0,0 +1,351 @@
+package managertest_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managertest"
+	"github.com/telepresenceio/telepresence/v2/pkg/connpool"
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+// upperCase replies with each line that it reads in upper case.
+func upperCase(_ context.Context, _ tunnel.ConnID, conn net.Conn) {
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		if _, err := io.WriteString(conn, strings.ToUpper(sc.Text())+"\n"); err != nil {
+			return
+		}
+	}
+}
+
+func roundtrip(t *testing.T, conn net.Conn, line string) string {
+	t.Helper()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err := io.WriteString(conn, line+"\n")
+	require.NoError(t, err)
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	return strings.TrimSpace(reply)
+}
+
+func TestInterceptedDial(t *testing.T) {
+	h := managertest.New(t, nil)
+	ctx := h.Context()
+
+	agent := h.ArriveAsAgent(&rpc.AgentInfo{Name: "echo", PodIp: "10.1.0.5"})
+	agent.Serve(upperCase)
+	client := h.ArriveAsClient(&rpc.ClientInfo{Name: "alice@host"})
+
+	_, err := client.CreateIntercept(ctx, agent, &rpc.InterceptSpec{TargetPort: 8080})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		ii, err := h.Client.GetIntercept(ctx, &rpc.GetInterceptRequest{Session: client.Info, Name: "echo"})
+		return err == nil && ii.Disposition == rpc.InterceptDispositionType_ACTIVE
+	}, 5*time.Second, 10*time.Millisecond, "the agent activates the intercept")
+
+	// The connections of a client that intercepts an agent are routed to that agent
+	conn, err := client.Dial(ctx, "10.1.0.5:8080")
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "HELLO", roundtrip(t, conn, "hello"))
+	assert.Equal(t, "AGAIN", roundtrip(t, conn, "again"))
+}
+
+func TestManagerDial(t *testing.T) {
+	h := managertest.New(t, nil)
+	ctx := h.Context()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				upperCase(ctx, "", conn)
+			}()
+		}
+	}()
+
+	// Without intercepts, the manager dials the destination itself
+	client := h.ArriveAsClient(&rpc.ClientInfo{Name: "bob@host"})
+	conn, err := client.Dial(ctx, l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "DIRECT", roundtrip(t, conn, "direct"))
+
+	_, err = client.Dial(ctx, "not-an-ip:80")
+	assert.Error(t, err)
+}
+
+func TestMuxTunnels(t *testing.T) {
+	h := managertest.New(t, nil)
+	ctx := h.Context()
+
+	agent := h.ArriveAsAgent(&rpc.AgentInfo{Name: "echo", PodIp: "10.1.0.5"})
+	client := h.ArriveAsClient(&rpc.ClientInfo{Name: "alice@host"})
+
+	ct, err := client.ClientTunnel(ctx)
+	require.NoError(t, err)
+	defer func() { _ = ct.CloseSend() }()
+	at, err := agent.AgentTunnel(ctx, client)
+	require.NoError(t, err)
+	defer func() { _ = at.CloseSend() }()
+
+	// The manager relays the messages of the agent to the client, and the replies of the client back
+	// to the agent.
+	id := tunnel.NewConnID(ipproto.TCP, iputil.Parse("10.1.0.5"), iputil.Parse("127.0.0.1"), 8080, 40000)
+	require.NoError(t, at.Send(ctx, connpool.NewMessage(id, []byte("hello"))))
+	msg := receive(t, ct)
+	assert.Equal(t, id, msg.ID())
+	assert.Equal(t, "hello", string(msg.Payload()))
+
+	require.NoError(t, ct.Send(ctx, connpool.NewMessage(id, []byte("HELLO"))))
+	msg = receive(t, at)
+	assert.Equal(t, id, msg.ID())
+	assert.Equal(t, "HELLO", string(msg.Payload()))
+}
+
+// receive returns the next message of the given tunnel, or fails the test if none arrives in time.
+func receive(t *testing.T, mt connpool.MuxTunnel) connpool.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	type result struct {
+		msg connpool.Message
+		err error
+	}
+	rc := make(chan result, 1)
+	go func() {
+		msg, err := mt.Receive(ctx)
+		rc <- result{msg, err}
+	}()
+	select {
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for tunnel message")
+		return nil
+	case r := <-rc:
+		require.NoError(t, r.err)
+		return r.msg
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	env := managertest.DefaultEnv()
+	env.ClientAuth = "token"
+	h := managertest.New(t, env)
+	ctx := h.Context()
+
+	// A token "<user>-token" belongs to <user>
+	h.Clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tr := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview)
+		if user := strings.TrimSuffix(tr.Spec.Token, "-token"); user != tr.Spec.Token {
+			tr.Status = authv1.TokenReviewStatus{Authenticated: true, User: authv1.UserInfo{Username: user}}
+		}
+		return true, tr, nil
+	})
+
+	_, err := h.Client.ArriveAsClient(ctx, &rpc.ClientInfo{Name: "anonymous@host", InstallId: "x", Product: "telepresence", Version: "v2.4.5"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	alice := h.ArriveAsClientWithToken(&rpc.ClientInfo{Name: "alice@host"}, "alice-token")
+	require.NoError(t, alice.Remain(ctx))
+	ct, err := alice.ClientTunnel(ctx)
+	require.NoError(t, err)
+	_ = ct.CloseSend()
+
+	// Neither unary nor streaming calls can use alice's session with another identity
+	impostor := *alice
+	impostor.Token = "mallory-token"
+	assert.Equal(t, codes.PermissionDenied, status.Code(impostor.Remain(ctx)))
+	_, err = impostor.ClientTunnel(ctx)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = impostor.Dial(ctx, "127.0.0.1:8080")
+	assert.Equal(t, codes.PermissionDenied, status.Code(errors.Unwrap(err)))
+
+	// Agents present their service account token, and their intercepts work as usual
+	agent := h.ArriveAsAgentWithToken(&rpc.AgentInfo{Name: "echo", PodIp: "10.1.0.5"}, "system:serviceaccount:default:echo-token")
+	agent.Serve(upperCase)
+	_, err = alice.CreateIntercept(ctx, agent, &rpc.InterceptSpec{TargetPort: 8080})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		ii, err := h.Client.GetIntercept(ctx, &rpc.GetInterceptRequest{Session: alice.Info, Name: "echo"})
+		return err == nil && ii.Disposition == rpc.InterceptDispositionType_ACTIVE
+	}, 5*time.Second, 10*time.Millisecond, "the agent activates the intercept")
+
+	// A client cannot use the agent's session
+	agentImpostor := agent.Session
+	agentImpostor.AgentToken = ""
+	agentImpostor.Token = "alice-token"
+	assert.Equal(t, codes.PermissionDenied, status.Code(agentImpostor.Remain(ctx)))
+}
+
+func TestMuxSession(t *testing.T) {
+	h := managertest.New(t, nil)
+	ctx := h.Context()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				upperCase(ctx, "", conn)
+			}()
+		}
+	}()
+
+	agent := h.ArriveAsAgent(&rpc.AgentInfo{Name: "echo", PodIp: "10.1.0.5"})
+	agent.Serve(upperCase)
+	client := h.ArriveAsClient(&rpc.ClientInfo{Name: "alice@host"})
+	_, err = client.CreateIntercept(ctx, agent, &rpc.InterceptSpec{TargetPort: 8080})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		ii, err := h.Client.GetIntercept(ctx, &rpc.GetInterceptRequest{Session: client.Info, Name: "echo"})
+		return err == nil && ii.Disposition == rpc.InterceptDispositionType_ACTIVE
+	}, 5*time.Second, 10*time.Millisecond, "the agent activates the intercept")
+
+	ms, err := client.OpenMuxSession(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, tunnel.Version, ms.PeerVersion())
+
+	// Connections to intercepted agents and connections that the manager dials share the session
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		for _, address := range []string{"10.1.0.5:8080", l.Addr().String()} {
+			conn, err := client.DialMux(ctx, ms, address)
+			require.NoError(t, err)
+			defer conn.Close()
+			conns = append(conns, conn)
+		}
+	}
+	for _, conn := range conns {
+		assert.Equal(t, "SHARED", roundtrip(t, conn, "shared"))
+	}
+	_ = conns[0].Close()
+	assert.Equal(t, "STILL", roundtrip(t, conns[1], "still"))
+}
+
+func TestEgress(t *testing.T) {
+	h := managertest.New(t, nil)
+	ctx := h.Context()
+
+	// Each agent prefixes its replies with its pod IP, so that the origin of a connection is known
+	for _, ai := range []*rpc.AgentInfo{
+		{Name: "db-proxy", PodIp: "10.1.0.12"},
+		{Name: "db-proxy", PodIp: "10.1.0.9"},
+		{Name: "api-proxy", PodIp: "10.1.0.20"},
+	} {
+		podIP := ai.PodIp
+		h.ArriveAsAgent(ai).Serve(func(_ context.Context, _ tunnel.ConnID, conn net.Conn) {
+			sc := bufio.NewScanner(conn)
+			for sc.Scan() {
+				if _, err := io.WriteString(conn, podIP+" "+sc.Text()+"\n"); err != nil {
+					return
+				}
+			}
+		})
+	}
+	client := h.ArriveAsClient(&rpc.ClientInfo{Name: "carol@host", EgressRules: []*rpc.EgressRule{
+		{Subnet: "10.9.0.0/16", Agent: "db-proxy", Namespace: "default"},
+		{Subnet: "10.9.8.0/24", Agent: "gone", Namespace: "default"},
+		{Agent: "api-proxy", Namespace: "default"},
+	}})
+
+	// Connections originate from the agent with the lowest pod IP of the workload that the rule with the
+	// narrowest matching subnet selects
+	conn, err := client.Dial(ctx, "10.9.1.1:5432")
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "10.1.0.9 db", roundtrip(t, conn, "db"))
+
+	conn, err = client.Dial(ctx, "203.0.113.5:443")
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "10.1.0.20 api", roundtrip(t, conn, "api"))
+
+	// Connections are refused rather than dialed elsewhere when the selected workload has no agent
+	_, err = client.Dial(ctx, "10.9.8.1:5432")
+	assert.Error(t, err)
+
+	// The status reports the agents that the manager selects
+	es, err := h.Client.GetEgressStatus(ctx, client.Info)
+	require.NoError(t, err)
+	require.Len(t, es.Rules, 3)
+	assert.Equal(t, "10.1.0.9", es.Rules[0].PodIp)
+	assert.Equal(t, "", es.Rules[1].PodIp)
+	assert.Equal(t, "10.1.0.20", es.Rules[2].PodIp)
+	_, err = h.Client.GetEgressStatus(ctx, &rpc.SessionInfo{SessionId: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = h.Client.ArriveAsClient(ctx, &rpc.ClientInfo{
+		Name:        "dave@host",
+		InstallId:   "dave-install-id",
+		Product:     "telepresence",
+		Version:     "v2.4.5",
+		EgressRules: []*rpc.EgressRule{{Subnet: "10.9.0.0", Agent: "db-proxy", Namespace: "default"}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestFaults(t *testing.T) {
+	env := managertest.DefaultEnv()
+	env.TunnelFaults = "latency=10ms,jitter=5ms,bandwidth=1mbit"
+	h := managertest.New(t, env)
+	ctx := h.Context()
+
+	agent := h.ArriveAsAgent(&rpc.AgentInfo{Name: "echo", PodIp: "10.1.0.5"})
+	agent.Faults = &tunnel.Faults{Latency: 10 * time.Millisecond}
+	agent.Serve(upperCase)
+	client := h.ArriveAsClient(&rpc.ClientInfo{Name: "alice@host"})
+	client.Faults = &tunnel.Faults{Latency: 10 * time.Millisecond, Jitter: 5 * time.Millisecond}
+
+	_, err := client.CreateIntercept(ctx, agent, &rpc.InterceptSpec{TargetPort: 8080})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		ii, err := h.Client.GetIntercept(ctx, &rpc.GetInterceptRequest{Session: client.Info, Name: "echo"})
+		return err == nil && ii.Disposition == rpc.InterceptDispositionType_ACTIVE
+	}, 5*time.Second, 10*time.Millisecond, "the agent activates the intercept")
+
+	// Data arrives intact, but late, through tunnels that are delayed and throttled
+	conn, err := client.Dial(ctx, "10.1.0.5:8080")
+	require.NoError(t, err)
+	defer conn.Close()
+	start := time.Now()
+	assert.Equal(t, "SLOW", roundtrip(t, conn, "slow"))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ct, err := client.ClientTunnel(ctx)
+	require.NoError(t, err)
+	defer func() { _ = ct.CloseSend() }()
+	at, err := agent.AgentTunnel(ctx, client)
+	require.NoError(t, err)
+	defer func() { _ = at.CloseSend() }()
+}
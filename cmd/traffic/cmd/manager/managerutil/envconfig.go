@@ -17,6 +17,31 @@ type Env struct {
 	SystemAHost string `env:"SYSTEMA_HOST,default=app.getambassador.io"`
 	SystemAPort string `env:"SYSTEMA_PORT,default=443"`
 
+	// DirectPort is the port where the gRPC API is served using TLS, so that clients can connect
+	// without a port-forward. It's disabled when empty.
+	DirectPort   string `env:"DIRECT_PORT,default="`
+	DirectTLSDir string `env:"DIRECT_TLS_DIR,default=/var/run/secrets/manager-tls"`
+
+	// ClientAuth is a comma separated list of the methods that clients can use to authenticate,
+	// "mtls" and "token". Clients aren't authenticated when it's empty.
+	ClientAuth   string `env:"CLIENT_AUTH,default="`
+	ClientCAFile string `env:"CLIENT_CA_FILE,default=/var/run/secrets/manager-tls/client-ca.pem"`
+
+	// AdminToken must be presented by callers of the admin API's actions and the admin RPCs. The
+	// actions are disabled when it's empty. AdminIdentities is a comma separated list of verified
+	// client identities that may call the admin RPCs without presenting the token.
+	AdminToken      string `env:"ADMIN_TOKEN,default="`
+	AdminIdentities string `env:"ADMIN_IDENTITIES,default="`
+
+	// PreviewDomain is the domain under which the traffic-manager serves the preview URLs of
+	// intercepts on PreviewPort, instead of Ambassador Cloud. It's disabled when empty.
+	PreviewDomain string `env:"PREVIEW_DOMAIN,default="`
+	PreviewPort   string `env:"PREVIEW_PORT,default=8083"`
+
+	// ClientPolicyFile is the YAML file with the client policy that is published to clients. No
+	// policy is published when the file doesn't exist.
+	ClientPolicyFile string `env:"CLIENT_POLICY_FILE,default=/etc/traffic-manager/client-policy.yml"`
+
 	ManagerNamespace string            `env:"MANAGER_NAMESPACE,default="`
 	AgentRegistry    string            `env:"TELEPRESENCE_REGISTRY,default=docker.io/datawire"`
 	AgentImage       string            `env:"TELEPRESENCE_AGENT_IMAGE,default="`
@@ -26,6 +51,14 @@ type Env struct {
 
 	PodCIDRStrategy string `env:"POD_CIDR_STRATEGY,default=auto"`
 	PodCIDRs        string `env:"POD_CIDRS,default="`
+
+	// TunnelFaults are network faults, e.g. "latency=100ms,drop=1%", that are injected into the tunnels
+	// in order to simulate a poor connection. Intended for testing only.
+	TunnelFaults string `env:"TELEPRESENCE_TUNNEL_FAULTS,default="`
+
+	// TunnelCompression is the compressions, e.g. "deflate" or "none", that the traffic-manager accepts
+	// when its peers establish tunnels. The default is all supported compressions.
+	TunnelCompression string `env:"TELEPRESENCE_TUNNEL_COMPRESSION,default="`
 }
 
 type envKey struct{}
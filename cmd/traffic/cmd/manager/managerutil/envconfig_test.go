@@ -29,16 +29,20 @@ func TestEnvconfig(t *testing.T) {
 	}()
 
 	defaults := managerutil.Env{
-		User:            "",
-		ServerHost:      "",
-		ServerPort:      "8081",
-		SystemAHost:     "app.getambassador.io",
-		SystemAPort:     "443",
-		AgentRegistry:   "docker.io/datawire",
-		AgentImage:      "tel2:" + strings.TrimPrefix(version.Version, "v"),
-		AgentPort:       9900,
-		MaxReceiveSize:  resource.MustParse("4Mi"),
-		PodCIDRStrategy: "auto",
+		User:             "",
+		ServerHost:       "",
+		ServerPort:       "8081",
+		SystemAHost:      "app.getambassador.io",
+		SystemAPort:      "443",
+		DirectTLSDir:     "/var/run/secrets/manager-tls",
+		ClientCAFile:     "/var/run/secrets/manager-tls/client-ca.pem",
+		PreviewPort:      "8083",
+		ClientPolicyFile: "/etc/traffic-manager/client-policy.yml",
+		AgentRegistry:    "docker.io/datawire",
+		AgentImage:       "tel2:" + strings.TrimPrefix(version.Version, "v"),
+		AgentPort:        9900,
+		MaxReceiveSize:   resource.MustParse("4Mi"),
+		PodCIDRStrategy:  "auto",
 	}
 
 	testcases := map[string]struct {
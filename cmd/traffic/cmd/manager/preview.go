@@ -0,0 +1,158 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
+)
+
+// The traffic-manager acts as its own preview gateway when a PREVIEW_DOMAIN is configured. It then
+// assigns the preview domains instead of Ambassador Cloud, and routes the requests for a preview
+// domain to the intercepted service with the x-telepresence-intercept-id header set, so that the
+// requests are intercepted.
+
+// selfHostedPreviewDomain returns the preview domain of the given intercept, or an empty string when
+// preview domains are managed by Ambassador Cloud.
+func selfHostedPreviewDomain(ctx context.Context, intercept *rpc.InterceptInfo) string {
+	domain := managerutil.GetEnv(ctx).PreviewDomain
+	if domain == "" {
+		return ""
+	}
+	return previewLabel(intercept) + "." + domain
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// previewLabel returns a DNS label that is unique for the intercept. It consists of the intercept
+// name followed by the start of its client's session ID.
+func previewLabel(intercept *rpc.InterceptInfo) string {
+	sessionID := intercept.ClientSession.GetSessionId()
+	if len(sessionID) > 8 {
+		sessionID = sessionID[:8]
+	}
+	name := invalidLabelChars.ReplaceAllString(strings.ToLower(intercept.Spec.Name), "-")
+	if maxLen := 63 - len(sessionID) - 1; len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	return strings.Trim(name, "-") + "-" + sessionID
+}
+
+// isSelfHostedPreviewDomain returns true if the given domain was assigned by this traffic-manager.
+func isSelfHostedPreviewDomain(ctx context.Context, domain string) bool {
+	pd := managerutil.GetEnv(ctx).PreviewDomain
+	return pd != "" && strings.HasSuffix(domain, "."+pd)
+}
+
+// GetPreviewGateway returns the domain of the preview gateway, if one is configured.
+func (m *Manager) GetPreviewGateway(ctx context.Context, _ *empty.Empty) (*rpc.PreviewGateway, error) {
+	return &rpc.PreviewGateway{Domain: managerutil.GetEnv(ctx).PreviewDomain}, nil
+}
+
+func (m *Manager) servePreviewGateway(ctx context.Context) error {
+	env := managerutil.GetEnv(ctx)
+	dlog.Infof(ctx, "Serving preview URLs for *.%s", env.PreviewDomain)
+	sc := &dhttp.ServerConfig{Handler: m.previewHandler(ctx)}
+	return sc.ListenAndServe(ctx, env.ServerHost+":"+env.PreviewPort)
+}
+
+// previewHandler returns the handler that routes the requests for a preview domain to the intercepted
+// service.
+func (m *Manager) previewHandler(ctx context.Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		var intercept *rpc.InterceptInfo
+		for _, ii := range m.currentIntercepts(r.Context()) {
+			if ii.PreviewDomain != "" && strings.EqualFold(ii.PreviewDomain, host) {
+				intercept = ii
+				break
+			}
+		}
+		if intercept == nil {
+			http.Error(w, fmt.Sprintf("no intercept has the preview domain %q", host), http.StatusNotFound)
+			return
+		}
+		target, err := m.previewTarget(r.Context(), intercept.Spec)
+		if err != nil {
+			dlog.Errorf(ctx, "preview %s: %v", host, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+			r.Header.Set(restapi.HeaderInterceptID, intercept.Id)
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// previewTarget returns the URL of the service port that the given intercept intercepts.
+func (m *Manager) previewTarget(ctx context.Context, spec *rpc.InterceptSpec) (*url.URL, error) {
+	services := managerutil.GetK8sClientset(ctx).CoreV1().Services(spec.Namespace)
+	var svc *corev1.Service
+	if spec.ServiceName != "" {
+		var err error
+		if svc, err = services.Get(ctx, spec.ServiceName, metav1.GetOptions{}); err != nil {
+			return nil, err
+		}
+	} else {
+		list, err := services.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			if string(list.Items[i].UID) == spec.ServiceUid {
+				svc = &list.Items[i]
+				break
+			}
+		}
+		if svc == nil {
+			return nil, fmt.Errorf("unable to find the service of intercept %s", spec.Name)
+		}
+	}
+
+	port, err := servicePort(svc, spec.ServicePortIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "http", Host: fmt.Sprintf("%s.%s:%d", svc.Name, svc.Namespace, port)}, nil
+}
+
+// servicePort returns the port of the given service that matches the given name or number. The
+// identifier may be empty when the service has only one port.
+func servicePort(svc *corev1.Service, identifier string) (int32, error) {
+	ports := svc.Spec.Ports
+	if identifier == "" {
+		if len(ports) == 1 {
+			return ports[0].Port, nil
+		}
+		return 0, fmt.Errorf("service %s.%s has multiple ports", svc.Name, svc.Namespace)
+	}
+	number, err := strconv.Atoi(identifier)
+	for _, p := range ports {
+		if p.Name == identifier || err == nil && int(p.Port) == number {
+			return p.Port, nil
+		}
+	}
+	return 0, fmt.Errorf("service %s.%s has no port %q", svc.Name, svc.Namespace, identifier)
+}
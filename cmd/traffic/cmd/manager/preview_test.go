@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+)
+
+func TestPreviewDomain(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	ii := &rpc.InterceptInfo{
+		Spec:          &rpc.InterceptSpec{Name: "Echo_Server-default"},
+		ClientSession: &rpc.SessionInfo{SessionId: "0123456789abcdef"},
+	}
+	assert.Empty(t, selfHostedPreviewDomain(ctx, ii))
+	assert.False(t, isSelfHostedPreviewDomain(ctx, "echo-server-default-01234567.preview.example.com"))
+
+	env := *managerutil.GetEnv(ctx)
+	env.PreviewDomain = "preview.example.com"
+	ctx = managerutil.WithEnv(ctx, &env)
+	domain := selfHostedPreviewDomain(ctx, ii)
+	assert.Equal(t, "echo-server-default-01234567.preview.example.com", domain)
+	assert.True(t, isSelfHostedPreviewDomain(ctx, domain))
+	assert.False(t, isSelfHostedPreviewDomain(ctx, "abc123.edgestack.me"))
+
+	// The label is truncated so that it remains a valid DNS label
+	ii.Spec.Name = strings.Repeat("x", 80)
+	assert.Len(t, previewLabel(ii), 63)
+}
+
+func TestPreviewTarget(t *testing.T) {
+	ctx, _ := testAuthContext(t)
+	_, err := managerutil.GetK8sClientset(ctx).CoreV1().Services("default").Create(ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default", UID: "echo-uid"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "http", Port: 80},
+			{Name: "grpc", Port: 9000},
+		}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	m := NewManager(ctx)
+
+	target, err := m.previewTarget(ctx, &rpc.InterceptSpec{Namespace: "default", ServiceName: "echo", ServicePortIdentifier: "http"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://echo.default:80", target.String())
+
+	target, err = m.previewTarget(ctx, &rpc.InterceptSpec{Namespace: "default", ServiceUid: "echo-uid", ServicePortIdentifier: "9000"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://echo.default:9000", target.String())
+
+	_, err = m.previewTarget(ctx, &rpc.InterceptSpec{Namespace: "default", ServiceName: "echo"})
+	assert.Error(t, err, "the port must be identified when the service has several ports")
+	_, err = m.previewTarget(ctx, &rpc.InterceptSpec{Namespace: "default", ServiceUid: "other-uid", ServicePortIdentifier: "http"})
+	assert.Error(t, err)
+
+	// Requests for unknown preview domains are rejected
+	w := httptest.NewRecorder()
+	m.previewHandler(ctx).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://unknown.preview.example.com/", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"net"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -139,6 +141,16 @@ func (m *Manager) ArriveAsClient(ctx context.Context, client *rpc.ClientInfo) (*
 		return nil, status.Errorf(codes.InvalidArgument, val)
 	}
 
+	// The identity is stamped on the session by the manager. It is never taken from the client.
+	client.Identity = ""
+	if identity, ok := callerIdentity(ctx); ok {
+		if identity == "" {
+			return nil, status.Errorf(codes.Unauthenticated, "client %q did not present valid credentials", client.Name)
+		}
+		client.Identity = identity
+		dlog.Infof(ctx, "Client %q authenticated as %q", client.Name, identity)
+	}
+
 	sessionID := m.state.AddClient(client, m.clock.Now())
 
 	return &rpc.SessionInfo{
@@ -154,16 +166,33 @@ func (m *Manager) ArriveAsAgent(ctx context.Context, agent *rpc.AgentInfo) (*rpc
 		return nil, status.Errorf(codes.InvalidArgument, val)
 	}
 
+	// The identity is stamped on the session by the manager. It is never taken from the agent.
+	agent.Identity = ""
+	if _, ok := callerIdentity(ctx); ok {
+		identity := agentIdentity(ctx)
+		if identity == "" {
+			return nil, status.Errorf(codes.Unauthenticated, "agent %s.%s did not present a service account token", agent.Name, agent.Namespace)
+		}
+		if !strings.HasPrefix(identity, serviceAccountPrefix+agent.Namespace+":") {
+			return nil, status.Errorf(codes.PermissionDenied, "agent %s.%s cannot arrive as %q", agent.Name, agent.Namespace, identity)
+		}
+		agent.Identity = identity
+		dlog.Infof(ctx, "Agent %s.%s authenticated as %q", agent.Name, agent.Namespace, identity)
+	}
+
 	sessionID := m.state.AddAgent(agent, m.clock.Now())
 
 	return &rpc.SessionInfo{SessionId: sessionID}, nil
 }
 
 // Remain indicates that the session is still valid.
-func (m *Manager) Remain(_ context.Context, req *rpc.RemainRequest) (*empty.Empty, error) {
+func (m *Manager) Remain(ctx context.Context, req *rpc.RemainRequest) (*empty.Empty, error) {
 	// ctx = WithSessionInfo(ctx, req.GetSession())
 	// dlog.Debug(ctx, "Remain called")
 
+	if err := m.checkSessionOwner(ctx, req.GetSession().GetSessionId()); err != nil {
+		return nil, err
+	}
 	if ok := m.state.MarkSession(req, m.clock.Now()); !ok {
 		return nil, status.Errorf(codes.NotFound, "Session %q not found", req.GetSession().GetSessionId())
 	}
@@ -176,6 +205,9 @@ func (m *Manager) Depart(ctx context.Context, session *rpc.SessionInfo) (*empty.
 	ctx = managerutil.WithSessionInfo(ctx, session)
 	dlog.Debug(ctx, "Depart called")
 
+	if err := m.checkSessionOwner(ctx, session.GetSessionId()); err != nil {
+		return nil, err
+	}
 	m.state.RemoveSession(ctx, session.GetSessionId())
 
 	return &empty.Empty{}, nil
@@ -187,6 +219,9 @@ func (m *Manager) WatchAgents(session *rpc.SessionInfo, stream rpc.Manager_Watch
 
 	dlog.Debug(ctx, "WatchAgents called")
 
+	if err := m.checkSessionOwner(ctx, session.GetSessionId()); err != nil {
+		return err
+	}
 	snapshotCh := m.state.WatchAgents(ctx, nil)
 	sessionDone, err := m.state.SessionDone(session.GetSessionId())
 	if err != nil {
@@ -226,9 +261,17 @@ func (m *Manager) WatchIntercepts(session *rpc.SessionInfo, stream rpc.Manager_W
 
 	dlog.Debug(ctx, "WatchIntercepts called")
 
+	if err := m.checkSessionOwner(ctx, sessionID); err != nil {
+		return err
+	}
 	var filter func(id string, info *rpc.InterceptInfo) bool
 	if sessionID == "" {
-		// No sessonID; watch everything
+		// No sessonID; watch everything. That's only for admins when client authentication is enabled.
+		if _, ok := callerIdentity(ctx); ok {
+			if err := checkAdmin(ctx, ""); err != nil {
+				return err
+			}
+		}
 		filter = func(id string, info *rpc.InterceptInfo) bool {
 			return true
 		}
@@ -314,6 +357,9 @@ func (m *Manager) CreateIntercept(ctx context.Context, ciReq *rpc.CreateIntercep
 	if m.state.GetClient(sessionID) == nil {
 		return nil, status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
 	}
+	if err := m.checkSessionOwner(ctx, sessionID); err != nil {
+		return nil, err
+	}
 
 	if val := validateIntercept(spec); val != "" {
 		return nil, status.Errorf(codes.InvalidArgument, val)
@@ -348,9 +394,30 @@ func (m *Manager) UpdateIntercept(ctx context.Context, req *rpc.UpdateInterceptR
 	}
 
 	dlog.Debugf(ctx, "UpdateIntercept called: %s", interceptID)
+	if sessionID := req.GetSession().GetSessionId(); sessionID != "" {
+		err = m.checkSessionOwner(ctx, sessionID)
+	} else {
+		err = m.checkInterceptOwner(ctx, interceptID)
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	switch action := req.PreviewDomainAction.(type) {
 	case *rpc.UpdateInterceptRequest_AddPreviewDomain:
+		if managerutil.GetEnv(ctx).PreviewDomain != "" {
+			// The preview domain is served by this traffic-manager.
+			intercept := m.state.UpdateIntercept(interceptID, func(intercept *rpc.InterceptInfo) {
+				if intercept.PreviewDomain == "" {
+					intercept.PreviewDomain = selfHostedPreviewDomain(ctx, intercept)
+					intercept.PreviewSpec = action.AddPreviewDomain
+				}
+			})
+			if intercept == nil {
+				return nil, status.Errorf(codes.NotFound, "Intercept with ID %q not found for this session", interceptID)
+			}
+			return intercept, nil
+		}
 		var domain string
 		var sa systema.SystemACRUDClient
 		var err error
@@ -421,7 +488,7 @@ func (m *Manager) UpdateIntercept(ctx context.Context, req *rpc.UpdateInterceptR
 			domain = intercept.PreviewDomain
 			intercept.PreviewDomain = ""
 		})
-		if domain != "" {
+		if domain != "" && !isSelfHostedPreviewDomain(ctx, domain) {
 			if sa, err := m.systema.Get(); err != nil {
 				dlog.Errorln(ctx, "systema: acquire connection:", err)
 			} else {
@@ -456,6 +523,9 @@ func (m *Manager) RemoveIntercept(ctx context.Context, riReq *rpc.RemoveIntercep
 	if m.state.GetClient(sessionID) == nil {
 		return nil, status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
 	}
+	if err := m.checkSessionOwner(ctx, sessionID); err != nil {
+		return nil, err
+	}
 
 	if !m.state.RemoveIntercept(sessionID + ":" + name) {
 		return nil, status.Errorf(codes.NotFound, "Intercept named %q not found", name)
@@ -464,6 +534,63 @@ func (m *Manager) RemoveIntercept(ctx context.Context, riReq *rpc.RemoveIntercep
 	return &empty.Empty{}, nil
 }
 
+// TransferIntercept lets a client hand one of its intercepts over to another client. The
+// recipient is identified by its session ID or by its name. Both clients are notified.
+func (m *Manager) TransferIntercept(ctx context.Context, req *rpc.TransferInterceptRequest) (*rpc.InterceptInfo, error) {
+	ctx = managerutil.WithSessionInfo(ctx, req.GetSession())
+	sessionID := req.GetSession().GetSessionId()
+	name := req.Name
+
+	dlog.Debugf(ctx, "TransferIntercept called: %s to %s", name, req.To)
+
+	from := m.state.GetClient(sessionID)
+	if from == nil {
+		return nil, status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
+	}
+	if err := m.checkSessionOwner(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	toSessionID, err := m.findClientSession(req.To)
+	if err != nil {
+		return nil, err
+	}
+	interceptID := sessionID + ":" + name
+	if _, ok := m.state.GetIntercept(interceptID); !ok {
+		return nil, status.Errorf(codes.NotFound, "Intercept named %q not found", name)
+	}
+	intercept, err := m.state.TransferIntercept(interceptID, toSessionID)
+	if err != nil {
+		return nil, err
+	}
+	dlog.Infof(ctx, "Intercept %s transferred from %s to %s as %s", interceptID, sessionID, toSessionID, intercept.Id)
+	m.state.NotifyClient(sessionID, fmt.Sprintf("Intercept %q was transferred to %s", name, intercept.Spec.Client))
+	m.state.NotifyClient(toSessionID, fmt.Sprintf("Intercept %q was transferred to you by %s", name, from.Name))
+	return intercept, nil
+}
+
+// findClientSession returns the ID of the client session that has the given session ID or name.
+func (m *Manager) findClientSession(sessionIDOrName string) (string, error) {
+	if m.state.GetClient(sessionIDOrName) != nil {
+		return sessionIDOrName, nil
+	}
+	var found []string
+	for id, client := range m.state.GetAllClients() {
+		if client.Name == sessionIDOrName {
+			found = append(found, id)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", status.Errorf(codes.NotFound, "Client %q not found", sessionIDOrName)
+	case 1:
+		return found[0], nil
+	default:
+		sort.Strings(found)
+		return "", status.Errorf(codes.FailedPrecondition,
+			"Client name %q is ambiguous; use one of the session IDs %s", sessionIDOrName, strings.Join(found, ", "))
+	}
+}
+
 // GetIntercept gets an intercept info from intercept name
 func (m *Manager) GetIntercept(ctx context.Context, request *rpc.GetInterceptRequest) (*rpc.InterceptInfo, error) {
 	interceptID, err := m.makeinterceptID(ctx, request.GetSession().GetSessionId(), request.GetName())
@@ -504,6 +631,10 @@ func (m *Manager) ReviewIntercept(ctx context.Context, rIReq *rpc.ReviewIntercep
 			intercept.PodIp = rIReq.PodIp
 			intercept.SftpPort = rIReq.SftpPort
 			intercept.MechanismArgsDesc = rIReq.MechanismArgsDesc
+			if desc := intercept.Spec.MechanismArgsDesc; desc != "" {
+				// Described by the client's mechanism plugin
+				intercept.MechanismArgsDesc = desc
+			}
 			intercept.Headers = rIReq.Headers
 		}
 	})
@@ -517,11 +648,14 @@ func (m *Manager) ReviewIntercept(ctx context.Context, rIReq *rpc.ReviewIntercep
 
 func (m *Manager) ClientTunnel(server rpc.Manager_ClientTunnelServer) error {
 	ctx := server.Context()
-	muxTunnel := connpool.NewMuxTunnel(server)
+	muxTunnel := connpool.NewFaultyMuxTunnel(ctx, server, tunnel.GetFaults(m.ctx))
 	sessionInfo, err := readTunnelSessionID(ctx, muxTunnel)
 	if err != nil {
 		return err
 	}
+	if err = m.checkSessionOwner(ctx, sessionInfo.SessionId); err != nil {
+		return err
+	}
 	_, err = muxTunnel.ReadPeerVersion(ctx)
 	if err != nil {
 		return status.Errorf(codes.FailedPrecondition, "failed to read client tunnel version: %v", err)
@@ -534,11 +668,14 @@ func (m *Manager) ClientTunnel(server rpc.Manager_ClientTunnelServer) error {
 
 func (m *Manager) AgentTunnel(server rpc.Manager_AgentTunnelServer) error {
 	ctx := server.Context()
-	muxTunnel := connpool.NewMuxTunnel(server)
+	muxTunnel := connpool.NewFaultyMuxTunnel(ctx, server, tunnel.GetFaults(m.ctx))
 	agentSessionInfo, err := readTunnelSessionID(ctx, muxTunnel)
 	if err != nil {
 		return err
 	}
+	if err = m.checkSessionOwner(ctx, agentSessionInfo.SessionId); err != nil {
+		return err
+	}
 	clientSessionInfo, err := readTunnelSessionID(ctx, muxTunnel)
 	if err != nil {
 		return err
@@ -570,16 +707,32 @@ func readTunnelSessionID(ctx context.Context, server connpool.MuxTunnel) (*rpc.S
 
 func (m *Manager) Tunnel(server rpc.Manager_TunnelServer) error {
 	ctx := server.Context()
-	stream, err := tunnel.NewServerStream(ctx, server)
+	stream, muxSession, err := tunnel.AcceptTunnel(tunnel.WithCompressions(ctx, tunnel.GetCompressions(m.ctx)), server)
 	if err != nil {
 		return status.Errorf(codes.FailedPrecondition, "failed to connect stream: %v", err)
 	}
-	return m.state.Tunnel(ctx, stream)
+	faults := tunnel.GetFaults(m.ctx)
+	if muxSession != nil {
+		// The client multiplexes the streams of all its connections over this one
+		return muxSession.Serve(ctx, func(ctx context.Context, stream tunnel.Stream) error {
+			if err := m.checkSessionOwner(ctx, stream.SessionID()); err != nil {
+				return err
+			}
+			return m.state.Tunnel(ctx, tunnel.NewFaultyStream(stream, faults))
+		})
+	}
+	if err = m.checkSessionOwner(ctx, stream.SessionID()); err != nil {
+		return err
+	}
+	return m.state.Tunnel(ctx, tunnel.NewFaultyStream(stream, faults))
 }
 
 func (m *Manager) WatchDial(session *rpc.SessionInfo, stream rpc.Manager_WatchDialServer) error {
 	ctx := managerutil.WithSessionInfo(stream.Context(), session)
 	dlog.Debugf(ctx, "WatchDial called")
+	if err := m.checkSessionOwner(ctx, session.GetSessionId()); err != nil {
+		return err
+	}
 	lrCh := m.state.WatchDial(session.SessionId)
 	for {
 		select {
@@ -601,6 +754,9 @@ func (m *Manager) LookupHost(ctx context.Context, request *rpc.LookupHostRequest
 	ctx = managerutil.WithSessionInfo(ctx, request.GetSession())
 	dlog.Debugf(ctx, "LookupHost called %s", request.Host)
 	sessionID := request.GetSession().GetSessionId()
+	if err := m.checkSessionOwner(ctx, sessionID); err != nil {
+		return nil, err
+	}
 
 	ips, count, err := m.state.AgentsLookup(ctx, sessionID, request)
 	if err != nil {
@@ -644,6 +800,9 @@ func (m *Manager) AgentLookupHostResponse(ctx context.Context, response *rpc.Loo
 func (m *Manager) WatchLookupHost(session *rpc.SessionInfo, stream rpc.Manager_WatchLookupHostServer) error {
 	ctx := managerutil.WithSessionInfo(stream.Context(), session)
 	dlog.Debugf(ctx, "WatchLookupHost called")
+	if err := m.checkSessionOwner(ctx, session.GetSessionId()); err != nil {
+		return err
+	}
 	lrCh := m.state.WatchLookupHost(session.SessionId)
 	for {
 		select {
@@ -756,6 +915,126 @@ func (m *Manager) GetLogs(ctx context.Context, request *rpc.GetLogsRequest) (*rp
 	return resp, nil
 }
 
+// WatchLogs streams the logs of the traffic-manager and/or traffic-agents specified by the
+// WatchLogsRequest to the caller, one line at a time. The pods are resolved when the call is
+// made, so pods that are created after that will not be included in the stream.
+func (m *Manager) WatchLogs(request *rpc.WatchLogsRequest, stream rpc.Manager_WatchLogsServer) error {
+	ctx := stream.Context()
+	dlog.Debugf(ctx, "WatchLogs called")
+
+	type podContainer struct {
+		pod       *corev1.Pod
+		container string
+	}
+	var sources []podContainer
+	agentPods, err := m.clusterInfo.GetTrafficAgentPods(ctx, request.Agents)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error getting traffic-agent pods: %v", err)
+	}
+	for _, pod := range agentPods {
+		sources = append(sources, podContainer{pod: pod, container: "traffic-agent"})
+	}
+	if request.TrafficManager {
+		managerPods, err := m.clusterInfo.GetTrafficManagerPods(ctx)
+		if err != nil {
+			return status.Errorf(codes.Internal, "error getting traffic-manager pods: %v", err)
+		}
+		for _, pod := range managerPods {
+			sources = append(sources, podContainer{pod: pod, container: "traffic-manager"})
+		}
+	}
+
+	clientset := managerutil.GetK8sClientset(ctx)
+	lines := make(chan *rpc.LogLine, 100)
+	wg := sync.WaitGroup{}
+	wg.Add(len(sources))
+	for _, src := range sources {
+		go func(src podContainer) {
+			defer wg.Done()
+			podAndNs := fmt.Sprintf("%s.%s", src.pod.Name, src.pod.Namespace)
+			send := func(text string) bool {
+				select {
+				case <-ctx.Done():
+					return false
+				case lines <- &rpc.LogLine{Pod: podAndNs, Container: src.container, Text: text}:
+					return true
+				}
+			}
+
+			plo := &corev1.PodLogOptions{
+				Container: src.container,
+				Follow:    request.Follow,
+			}
+			if request.TailLines > 0 {
+				tailLines := request.TailLines
+				plo.TailLines = &tailLines
+			}
+			podLogs, err := clientset.CoreV1().Pods(src.pod.Namespace).GetLogs(src.pod.Name, plo).Stream(ctx)
+			if err != nil {
+				send(fmt.Sprintf("Failed to get logs: %s", err))
+				return
+			}
+			defer podLogs.Close()
+
+			scanner := bufio.NewScanner(podLogs)
+			for scanner.Scan() {
+				if !send(scanner.Text()) {
+					return
+				}
+			}
+			if err := scanner.Err(); err != nil && ctx.Err() == nil {
+				send(fmt.Sprintf("Failed reading logs: %s", err))
+			}
+		}(src)
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	for line := range lines {
+		if err := stream.Send(line); err != nil {
+			dlog.Errorf(ctx, "WatchLogs.Send() failed: %v", err)
+			return nil
+		}
+	}
+	return nil
+}
+
+// RunDiagnostics performs the traffic-manager's self-checks and returns the results
+func (m *Manager) RunDiagnostics(ctx context.Context, _ *empty.Empty) (*rpc.DiagnosticResults, error) {
+	dlog.Debug(ctx, "RunDiagnostics called")
+	results := &rpc.DiagnosticResults{}
+
+	apiResult := &rpc.DiagnosticResult{Name: "api-server"}
+	if sv, err := managerutil.GetK8sClientset(ctx).Discovery().ServerVersion(); err != nil {
+		apiResult.Status = rpc.DiagnosticResult_FAIL
+		apiResult.Message = fmt.Sprintf("the traffic-manager is unable to reach the Kubernetes API server: %v", err)
+		apiResult.Hint = "ensure that no network policy prevents the traffic-manager from reaching the API server, " +
+			"and that its service account has the required permissions"
+	} else {
+		apiResult.Message = fmt.Sprintf("the traffic-manager can reach the Kubernetes API server (%s)", sv.GitVersion)
+	}
+	results.Results = append(results.Results, apiResult)
+
+	// The agent image is configurable, so it might have a tag that doesn't match the version of
+	// the traffic-manager.
+	imgResult := &rpc.DiagnosticResult{Name: "agent-image"}
+	if env := managerutil.GetEnv(ctx); env != nil {
+		img := env.AgentImage
+		wantTag := strings.TrimPrefix(version.Version, "v")
+		if i := strings.LastIndexByte(img, ':'); i < 0 || img[i+1:] != wantTag {
+			imgResult.Status = rpc.DiagnosticResult_WARN
+			imgResult.Message = fmt.Sprintf("the traffic-agent image %s/%s does not match the traffic-manager version %s", env.AgentRegistry, img, version.Version)
+			imgResult.Hint = "unless a custom agent image is intended, remove the agentImage setting from the Helm values, or reinstall the traffic-manager"
+		} else {
+			imgResult.Message = fmt.Sprintf("the traffic-agent image %s/%s matches the traffic-manager version", env.AgentRegistry, img)
+		}
+		results.Results = append(results.Results, imgResult)
+	}
+	return results, nil
+}
+
 func (m *Manager) SetLogLevel(ctx context.Context, request *rpc.LogLevelRequest) (*empty.Empty, error) {
 	m.state.SetTempLogLevel(ctx, request)
 	return &empty.Empty{}, nil
@@ -783,9 +1062,28 @@ func (m *Manager) WatchLogLevel(_ *empty.Empty, stream rpc.Manager_WatchLogLevel
 func (m *Manager) WatchClusterInfo(session *rpc.SessionInfo, stream rpc.Manager_WatchClusterInfoServer) error {
 	ctx := managerutil.WithSessionInfo(stream.Context(), session)
 	dlog.Debugf(ctx, "WatchClusterInfo called")
+	if err := m.checkSessionOwner(ctx, session.GetSessionId()); err != nil {
+		return err
+	}
 	return m.clusterInfo.Watch(ctx, stream)
 }
 
+// GetEgressStatus returns the egress rules of a client session together with the traffic-agents that the
+// rules currently select.
+func (m *Manager) GetEgressStatus(ctx context.Context, session *rpc.SessionInfo) (*rpc.EgressRuleStatusList, error) {
+	ctx = managerutil.WithSessionInfo(ctx, session)
+	dlog.Debug(ctx, "GetEgressStatus called")
+	sessionID := session.GetSessionId()
+	if err := m.checkSessionOwner(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	rules, ok := m.state.EgressStatus(sessionID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
+	}
+	return &rpc.EgressRuleStatusList{Rules: rules}, nil
+}
+
 // expire removes stale sessions.
 func (m *Manager) expire(ctx context.Context) {
 	m.state.ExpireSessions(ctx, m.clock.Now().Add(-15*time.Second))
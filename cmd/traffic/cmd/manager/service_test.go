@@ -3,10 +3,12 @@ package manager_test
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
@@ -14,6 +16,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	k8sVersion "k8s.io/apimachinery/pkg/version"
 	fakeDiscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes/fake"
@@ -286,7 +289,179 @@ func TestConnect(t *testing.T) {
 	a.NoError(err)
 }
 
-func getTestClientConn(t *testing.T) *grpc.ClientConn {
+func TestWatchLogs(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	conn := getTestClientConn(t,
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "traffic-manager-5d4bc7d9f-xkw7h", Namespace: "ambassador"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "traffic-manager"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "echo-easy-6848967857-tw4jw", Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "echo-easy"}, {Name: "traffic-agent"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello-7c8d5b5c4-8xjpl", Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "hello"}}},
+		},
+	)
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	collect := func(rq *rpc.WatchLogsRequest) []string {
+		stream, err := client.WatchLogs(ctx, rq)
+		require.NoError(t, err)
+		var lines []string
+		for {
+			line, err := stream.Recv()
+			if err == io.EOF {
+				return lines
+			}
+			require.NoError(t, err)
+			lines = append(lines, line.Pod+"/"+line.Container+": "+line.Text)
+		}
+	}
+
+	// The fake clientset streams "fake logs" for every pod
+	assert.ElementsMatch(t, []string{
+		"traffic-manager-5d4bc7d9f-xkw7h.ambassador/traffic-manager: fake logs",
+		"echo-easy-6848967857-tw4jw.default/traffic-agent: fake logs",
+	}, collect(&rpc.WatchLogsRequest{TrafficManager: true, Agents: "all"}))
+
+	assert.Equal(t, []string{
+		"echo-easy-6848967857-tw4jw.default/traffic-agent: fake logs",
+	}, collect(&rpc.WatchLogsRequest{Agents: "echo"}))
+
+	assert.Empty(t, collect(&rpc.WatchLogsRequest{Agents: "None"}))
+}
+
+func TestRunDiagnostics(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	conn := getTestClientConn(t)
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	results, err := client.RunDiagnostics(ctx, &empty.Empty{})
+	require.NoError(t, err)
+	statuses := make(map[string]rpc.DiagnosticResult_Status)
+	for _, r := range results.Results {
+		statuses[r.Name] = r.Status
+	}
+	assert.Equal(t, map[string]rpc.DiagnosticResult_Status{
+		// The fake clientset reports a server version
+		"api-server": rpc.DiagnosticResult_PASS,
+		// The test environment has no agent image configured
+		"agent-image": rpc.DiagnosticResult_WARN,
+	}, statuses)
+}
+
+func TestWatchClusterInfo(t *testing.T) {
+	kubeDNS := func(clusterIPs ...string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kube-dns",
+				Namespace: "kube-system",
+			},
+			Spec: corev1.ServiceSpec{
+				ClusterIP:  clusterIPs[0],
+				ClusterIPs: clusterIPs,
+			},
+		}
+	}
+	cidrs := func(ss ...string) []*rpc.IPNet {
+		ns := make([]*rpc.IPNet, len(ss))
+		for i, s := range ss {
+			_, n, err := net.ParseCIDR(s)
+			require.NoError(t, err)
+			ones, _ := n.Mask.Size()
+			ns[i] = &rpc.IPNet{Ip: n.IP, Mask: int32(ones)}
+		}
+		return ns
+	}
+	ips := func(ss ...string) [][]byte {
+		bs := make([][]byte, len(ss))
+		for i, s := range ss {
+			ip := net.ParseIP(s)
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			bs[i] = ip
+		}
+		return bs
+	}
+
+	tests := []struct {
+		name           string
+		podCIDRs       string
+		dnsService     *corev1.Service
+		dnsIPs         [][]byte
+		serviceSubnets []*rpc.IPNet
+		podSubnets     []*rpc.IPNet
+	}{
+		{
+			name:           "IPv4",
+			podCIDRs:       "10.244.0.0/16",
+			dnsService:     kubeDNS("10.96.0.10"),
+			dnsIPs:         ips("10.96.0.10"),
+			serviceSubnets: cidrs("10.96.0.0/16"),
+			podSubnets:     cidrs("10.244.0.0/16"),
+		},
+		{
+			name:           "IPv6",
+			podCIDRs:       "fd00:10:244::/56",
+			dnsService:     kubeDNS("fd00:10:96::a"),
+			dnsIPs:         ips("fd00:10:96::a"),
+			serviceSubnets: cidrs("fd00:10:96::/64"),
+			podSubnets:     cidrs("fd00:10:244::/56"),
+		},
+		{
+			name:           "dual-stack",
+			podCIDRs:       "10.244.0.0/16 fd00:10:244::/56",
+			dnsService:     kubeDNS("10.96.0.10", "fd00:10:96::a"),
+			dnsIPs:         ips("10.96.0.10", "fd00:10:96::a"),
+			serviceSubnets: cidrs("10.96.0.0/16", "fd00:10:96::/64"),
+			podSubnets:     cidrs("10.244.0.0/16", "fd00:10:244::/56"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := dlog.NewTestContext(t, false)
+			conn := getTestClientConnWithEnv(t, &managerutil.Env{
+				PodCIDRStrategy: "environment",
+				PodCIDRs:        tt.podCIDRs,
+			}, tt.dnsService)
+			defer conn.Close()
+			client := rpc.NewManagerClient(conn)
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			stream, err := client.WatchClusterInfo(ctx, &rpc.SessionInfo{})
+			require.NoError(t, err)
+			ci, err := stream.Recv()
+			require.NoError(t, err)
+
+			// The fake clientset accepts any service, so the service subnets are derived from the kube-dns IPs
+			assert.Equal(t, tt.dnsIPs, ci.KubeDnsIps)
+			assert.Equal(t, tt.dnsIPs[0], ci.KubeDnsIp)
+			assert.True(t, proto.Equal(&rpc.ClusterInfo{ServiceSubnets: tt.serviceSubnets},
+				&rpc.ClusterInfo{ServiceSubnets: ci.ServiceSubnets}), "service subnets %v", ci.ServiceSubnets)
+			assert.True(t, proto.Equal(tt.serviceSubnets[0], ci.ServiceSubnet))
+			assert.True(t, proto.Equal(&rpc.ClusterInfo{PodSubnets: tt.podSubnets},
+				&rpc.ClusterInfo{PodSubnets: ci.PodSubnets}), "pod subnets %v", ci.PodSubnets)
+		})
+	}
+}
+
+func getTestClientConn(t *testing.T, objects ...runtime.Object) *grpc.ClientConn {
+	return getTestClientConnWithEnv(t, &managerutil.Env{
+		MaxReceiveSize:  resource.Quantity{},
+		PodCIDRStrategy: "environment",
+		PodCIDRs:        "192.168.0.0/16",
+	}, objects...)
+}
+
+func getTestClientConnWithEnv(t *testing.T, env *managerutil.Env, objects ...runtime.Object) *grpc.ClientConn {
 	const bufsize = 64 * 1024
 	ctx, cancel := context.WithCancel(dlog.NewTestContext(t, true))
 
@@ -295,20 +470,16 @@ func getTestClientConn(t *testing.T) *grpc.ClientConn {
 		return lis.Dial()
 	}
 
-	fakeClient := fake.NewSimpleClientset(&corev1.Namespace{
+	fakeClient := fake.NewSimpleClientset(append([]runtime.Object{&corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "default",
 		},
-	})
+	}}, objects...)...)
 	fakeClient.Discovery().(*fakeDiscovery.FakeDiscovery).FakedServerVersion = &k8sVersion.Info{
 		GitVersion: "v1.17.0",
 	}
 	ctx = managerutil.WithK8SClientset(ctx, fakeClient)
-	ctx = managerutil.WithEnv(ctx, &managerutil.Env{
-		MaxReceiveSize:  resource.Quantity{},
-		PodCIDRStrategy: "environment",
-		PodCIDRs:        "192.168.0.0/16",
-	})
+	ctx = managerutil.WithEnv(ctx, env)
 
 	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithInsecure())
 	if err != nil {
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"os"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+// clientPolicyFile returns the name of the file that caches the client policy of the traffic-manager
+// of the given connection. An empty name denotes the connection with the default name.
+func clientPolicyFile(connectionName string) string {
+	if connectionName == "" {
+		connectionName = client.DefaultConnectionName
+	}
+	return "client-policy-" + connectionName + ".json"
+}
+
+// SaveClientPolicyToUserCache saves the client policy of the traffic-manager of the given connection
+// to user cache, so that commands can use it before they connect. The cache is removed when the policy
+// is nil.
+func SaveClientPolicyToUserCache(ctx context.Context, connectionName string, policy *manager.ClientPolicy) error {
+	if policy == nil {
+		return DeleteClientPolicyFromUserCache(ctx, connectionName)
+	}
+	return SaveToUserCache(ctx, policy, clientPolicyFile(connectionName))
+}
+
+// LoadClientPolicyFromUserCache gets the client policy of the given connection from cache. Nil is
+// returned if the file does not exist. An error is returned if something goes wrong while loading or
+// unmarshalling.
+func LoadClientPolicyFromUserCache(ctx context.Context, connectionName string) (*manager.ClientPolicy, error) {
+	var policy manager.ClientPolicy
+	if err := LoadFromUserCache(ctx, &policy, clientPolicyFile(connectionName)); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// DeleteClientPolicyFromUserCache removes the client policy cache of the given connection if it exists.
+func DeleteClientPolicyFromUserCache(ctx context.Context, connectionName string) error {
+	return DeleteFromUserCache(ctx, clientPolicyFile(connectionName))
+}
@@ -33,6 +33,8 @@ recommended) which in turn may result in a password prompt.`
 // global options
 var dnsIP string
 var mappedNamespaces []string
+var connectionName string
+var egressVia []string
 var kubeFlags *pflag.FlagSet
 var kubeConfig *kates.ConfigFlags
 
@@ -140,15 +142,15 @@ func Command(ctx context.Context) *cobra.Command {
 		},
 		{
 			Name:     "Traffic Commands",
-			Commands: []*cobra.Command{listCommand(), interceptCommand(ctx), leaveCommand(), previewCommand()},
+			Commands: []*cobra.Command{listCommand(), interceptCommand(ctx), leaveCommand(), previewCommand(), upCommand(), downCommand()},
 		},
 		{
 			Name:     "Debug Commands",
-			Commands: []*cobra.Command{loglevelCommand(), gatherLogsCommand()},
+			Commands: []*cobra.Command{loglevelCommand(), gatherLogsCommand(), logsCommand(), doctorCommand()},
 		},
 		{
 			Name:     "Other Commands",
-			Commands: []*cobra.Command{versionCommand(), uninstallCommand(), dashboardCommand(), ClusterIdCommand(), genYAMLCommand(), vpnDiagCommand()},
+			Commands: []*cobra.Command{versionCommand(), uninstallCommand(), dashboardCommand(), ClusterIdCommand(), genYAMLCommand(), vpnDiagCommand(), adminCommand(), extensionsCommand()},
 		},
 	})
 	initGlobalFlagGroups()
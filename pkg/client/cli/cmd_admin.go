@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+)
+
+type adminArgs struct {
+	token string
+}
+
+func adminCommand() *cobra.Command {
+	aa := &adminArgs{}
+	cmd := &cobra.Command{
+		Use:  "admin",
+		Args: OnlySubcommands,
+
+		Short: "Manage the sessions and intercepts of all users of the traffic-manager",
+		Long: `Manage the sessions and intercepts of all users of the traffic-manager.
+These commands require admin permission. Either pass the traffic-manager's admin
+token using --admin-token, or connect using an identity that the traffic-manager
+declares as an admin identity. Affected users are notified.`,
+		RunE: RunSubcommands,
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&aa.token, "admin-token", "", "The admin token of the traffic-manager")
+	addConnectionFlag(flags)
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "list-sessions",
+			Args:  cobra.NoArgs,
+			Short: "List the client and agent sessions of all users",
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return aa.withManager(cmd, func(ctx context.Context, managerClient manager.ManagerClient) error {
+					sessions, err := managerClient.AdminListSessions(ctx, &manager.AdminRequest{AdminToken: aa.token})
+					if err != nil {
+						return err
+					}
+					printAdminSessions(cmd.OutOrStdout(), sessions.Sessions)
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "remove-intercept <intercept-id>",
+			Args:  cobra.ExactArgs(1),
+			Short: "Remove an intercept that belongs to any user",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return aa.withManager(cmd, func(ctx context.Context, managerClient manager.ManagerClient) error {
+					if _, err := managerClient.AdminRemoveIntercept(ctx, &manager.AdminRequest{Id: args[0], AdminToken: aa.token}); err != nil {
+						return err
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Intercept %s removed\n", args[0])
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "expire-session <session-id>",
+			Args:  cobra.ExactArgs(1),
+			Short: "Expire the session of any user, removing its intercepts",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return aa.withManager(cmd, func(ctx context.Context, managerClient manager.ManagerClient) error {
+					if _, err := managerClient.AdminExpireSession(ctx, &manager.AdminRequest{Id: args[0], AdminToken: aa.token}); err != nil {
+						return err
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Session %s expired\n", args[0])
+					return nil
+				})
+			},
+		},
+	)
+	return cmd
+}
+
+func (aa *adminArgs) withManager(cmd *cobra.Command, f func(context.Context, manager.ManagerClient) error) error {
+	return withConnector(cmd, true, func(ctx context.Context, _ connector.ConnectorClient, _ *connector.ConnectInfo, _ daemon.DaemonClient) error {
+		return cliutil.WithManager(ctx, f)
+	})
+}
+
+// printAdminSessions prints one line for each session, keyed by the session ID.
+func printAdminSessions(out io.Writer, sessions []*manager.AdminSession) {
+	if len(sessions) == 0 {
+		fmt.Fprintln(out, "No sessions")
+		return
+	}
+	idLen := 0
+	for _, s := range sessions {
+		if l := len(s.SessionId); l > idLen {
+			idLen = l
+		}
+	}
+	for _, s := range sessions {
+		var desc string
+		if c := s.Client; c != nil {
+			desc = "client " + c.Name
+			if c.Identity != "" {
+				desc += ", identity " + c.Identity
+			}
+			if len(s.InterceptIds) > 0 {
+				desc += ", intercepts " + strings.Join(s.InterceptIds, ", ")
+			}
+		} else if a := s.Agent; a != nil {
+			desc = fmt.Sprintf("agent %s.%s, pod IP %s", a.Name, a.Namespace, a.PodIp)
+		}
+		fmt.Fprintf(out, "%-*s: %s\n", idLen, s.SessionId, desc)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
 	"github.com/telepresenceio/telepresence/v2/pkg/log"
+	"github.com/telepresenceio/telepresence/v2/pkg/subnet"
 	"github.com/telepresenceio/telepresence/v2/pkg/vif/routing"
 )
 
@@ -48,6 +49,7 @@ func waitForNetwork(ctx context.Context) error {
 
 const (
 	good    = "✅"
+	warning = "⚠️ "
 	bad     = "❌"
 	podType = "pod"
 	svcType = "svc"
@@ -177,12 +179,10 @@ func (di *vpnDiagInfo) run(cmd *cobra.Command, _ []string) (err error) {
 				if _, inVPN := vpnIfaces[rt.Interface.Name]; !inVPN {
 					continue
 				}
-				if rt.Routes(sn.IP) || sn.Contains(rt.RoutedNet.IP) {
+				if overlap := subnet.OverlapOf(sn, rt.RoutedNet); overlap != subnet.Disjoint {
 					ok = false
 					configIssues = true
-					snSz, _ := sn.Mask.Size()
-					rtSz, _ := rt.RoutedNet.Mask.Size()
-					if rtSz > snSz {
+					if overlap == subnet.MaskedBy {
 						vpnMasks = true
 						instructions = append(instructions,
 							fmt.Sprintf("%s %s subnet %s being masked by VPN-routed CIDR %s."+
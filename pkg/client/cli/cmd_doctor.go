@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif/routing"
+)
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCheck is the outcome of one check performed by the doctor command
+type doctorCheck struct {
+	Component string `json:"component"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	Hint      string `json:"hint,omitempty"`
+}
+
+type doctorInfo struct {
+	json   bool
+	checks []*doctorCheck
+}
+
+func doctorCommand() *cobra.Command {
+	di := &doctorInfo{}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Args:  cobra.NoArgs,
+		Short: "Diagnose common problems with the Telepresence installation and connection",
+		Long: `Run a suite of checks in the CLI, the root and user daemons, and the traffic-manager, and
+report the outcome of each check as pass, warn, or fail. Checks that don't pass come with a hint
+on how to remedy the problem. Checks that require a connection to the cluster are only performed
+when the daemons are connected.`,
+		RunE: di.run,
+	}
+	cmd.Flags().BoolVarP(&di.json, "json", "j", false, "output as json array")
+	addConnectionFlag(cmd.Flags())
+	return cmd
+}
+
+func (di *doctorInfo) add(component, name, status, message, hint string) {
+	di.checks = append(di.checks, &doctorCheck{
+		Component: component,
+		Name:      name,
+		Status:    status,
+		Message:   message,
+		Hint:      hint,
+	})
+}
+
+// addResults adds the results of a component's RunDiagnostics call
+func (di *doctorInfo) addResults(component string, results *manager.DiagnosticResults) {
+	for _, r := range results.GetResults() {
+		var st string
+		switch r.Status {
+		case manager.DiagnosticResult_WARN:
+			st = checkWarn
+		case manager.DiagnosticResult_FAIL:
+			st = checkFail
+		default:
+			st = checkPass
+		}
+		di.add(component, r.Name, st, r.Message, r.Hint)
+	}
+}
+
+func (di *doctorInfo) run(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	di.cliChecks(ctx)
+	if err := di.rootDaemonChecks(ctx); err != nil {
+		return err
+	}
+	if err := di.userDaemonChecks(ctx); err != nil {
+		return err
+	}
+	if err := di.write(cmd.OutOrStdout()); err != nil {
+		return err
+	}
+	for _, c := range di.checks {
+		if c.Status == checkFail {
+			return errcat.User.New("one or more checks failed")
+		}
+	}
+	return nil
+}
+
+func (di *doctorInfo) cliChecks(ctx context.Context) {
+	const component = "cli"
+	if err := checkMountCapability(ctx); err != nil {
+		di.add(component, "sshfs", checkWarn, err.Error(),
+			"install sshfs (and macFUSE on macOS) to enable remote volume mounts, or intercept using --mount=false")
+	} else {
+		di.add(component, "sshfs", checkPass, "sshfs is installed", "")
+	}
+
+	publicIP := net.ParseIP("8.8.8.8")
+	tCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if rt, err := routing.GetRoute(tCtx, &net.IPNet{IP: publicIP, Mask: net.CIDRMask(32, 32)}); err != nil {
+		di.add(component, "network", checkFail, fmt.Sprintf("unable to find a route to %s: %v", publicIP, err),
+			"this usually means that your network or VPN client is misconfigured. Try `telepresence test-vpn`")
+	} else {
+		di.add(component, "network", checkPass, fmt.Sprintf("%s is routed via %s", publicIP, rt.Interface.Name), "")
+	}
+}
+
+func (di *doctorInfo) rootDaemonChecks(ctx context.Context) error {
+	const component = "root-daemon"
+	err := cliutil.WithStartedDaemon(ctx, func(ctx context.Context, daemonClient daemon.DaemonClient) error {
+		version, err := daemonClient.Version(ctx, &empty.Empty{})
+		if err != nil {
+			return err
+		}
+		di.versionCheck(component, version.Version)
+		results, err := daemonClient.RunDiagnostics(ctx, &empty.Empty{})
+		if err != nil {
+			return di.unsupported(component, err)
+		}
+		di.addResults(component, results)
+		return nil
+	})
+	if errors.Is(err, cliutil.ErrNoDaemon) {
+		di.add(component, "running", checkWarn, "the root daemon is not running", "run `telepresence connect` to start it")
+		return nil
+	}
+	return err
+}
+
+func (di *doctorInfo) userDaemonChecks(ctx context.Context) error {
+	const component = "user-daemon"
+	err := cliutil.WithStartedConnector(ctx, func(ctx context.Context, connectorClient connector.ConnectorClient) error {
+		// The checks that concern the cluster are made using the selected connection
+		ctx = client.WithConnectionName(ctx, connectionName)
+		version, err := connectorClient.Version(ctx, &empty.Empty{})
+		if err != nil {
+			return err
+		}
+		di.versionCheck(component, version.Version)
+
+		st, err := connectorClient.Status(ctx, &connector.ConnectRequest{KubeFlags: kubeFlagMap(), Name: connectionName})
+		if err != nil {
+			return err
+		}
+		switch st.Error {
+		case connector.ConnectInfo_UNSPECIFIED, connector.ConnectInfo_ALREADY_CONNECTED:
+			di.add(component, "connection", checkPass, fmt.Sprintf("connected to context %s (%s)", st.ClusterContext, st.ClusterServer), "")
+		case connector.ConnectInfo_DISCONNECTED:
+			di.add(component, "connection", checkWarn, "not connected to a cluster; cluster checks were skipped",
+				"run `telepresence connect` and then run this command again")
+			return nil
+		default:
+			di.add(component, "connection", checkFail, fmt.Sprintf("unable to connect to the cluster: %s", st.ErrorText),
+				"run `telepresence quit` and then `telepresence connect`. If the problem persists, run `telepresence gather-logs`")
+			return nil
+		}
+
+		agents, err := connectorClient.List(ctx, &connector.ListRequest{Filter: connector.ListRequest_INSTALLED_AGENTS})
+		if err != nil {
+			return err
+		}
+		di.agentVersionChecks(agents)
+		return cliutil.WithManager(ctx, di.managerChecks)
+	})
+	if errors.Is(err, cliutil.ErrNoConnector) {
+		di.add(component, "running", checkWarn, "the user daemon is not running", "run `telepresence connect` to start it")
+		return nil
+	}
+	return err
+}
+
+func (di *doctorInfo) managerChecks(ctx context.Context, managerClient manager.ManagerClient) error {
+	const component = "traffic-manager"
+	version, err := managerClient.Version(ctx, &empty.Empty{})
+	if err != nil {
+		return err
+	}
+	di.versionCheck(component, version.Version)
+	results, err := managerClient.RunDiagnostics(ctx, &empty.Empty{})
+	if err != nil {
+		return di.unsupported(component, err)
+	}
+	di.addResults(component, results)
+	return nil
+}
+
+// agentVersionChecks verifies that the version of each installed traffic-agent matches the client version
+func (di *doctorInfo) agentVersionChecks(agents *connector.WorkloadInfoSnapshot) {
+	const component = "traffic-agent"
+	for _, wl := range agents.GetWorkloads() {
+		ai := wl.GetAgentInfo()
+		if ai == nil {
+			continue
+		}
+		name := fmt.Sprintf("%s.%s", ai.Name, ai.Namespace)
+		if ai.Version == client.Version() {
+			di.add(component, name, checkPass, fmt.Sprintf("the traffic-agent in %s has version %s", name, ai.Version), "")
+		} else {
+			di.add(component, name, checkWarn,
+				fmt.Sprintf("the traffic-agent in %s has version %s, but the client version is %s", name, ai.Version, client.Version()),
+				fmt.Sprintf("run `telepresence uninstall --agent %s -n %s` so that a new traffic-agent is installed on the next intercept", ai.Name, ai.Namespace))
+		}
+	}
+}
+
+// versionCheck verifies that the version of the given component matches the client version
+func (di *doctorInfo) versionCheck(component, version string) {
+	if version == client.Version() {
+		di.add(component, "version", checkPass, fmt.Sprintf("version %s matches the client", version), "")
+	} else {
+		di.add(component, "version", checkWarn, fmt.Sprintf("version %s does not match the client version %s", version, client.Version()),
+			"run `telepresence quit` and then `telepresence connect` so that outdated components are replaced")
+	}
+}
+
+// unsupported adds a warning when the component predates the RunDiagnostics call, and returns any
+// other error
+func (di *doctorInfo) unsupported(component string, err error) error {
+	if grpcStatus.Code(err) != codes.Unimplemented {
+		return err
+	}
+	di.add(component, "diagnostics", checkWarn, "the "+component+" is too old to perform diagnostics", "upgrade to the same version as the client")
+	return nil
+}
+
+func (di *doctorInfo) write(out io.Writer) error {
+	if di.json {
+		data, err := json.MarshalIndent(di.checks, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	component := ""
+	counts := make(map[string]int, 3)
+	for _, c := range di.checks {
+		if c.Component != component {
+			component = c.Component
+			fmt.Fprintf(out, "%s:\n", component)
+		}
+		var mark string
+		switch c.Status {
+		case checkPass:
+			mark = good
+		case checkWarn:
+			mark = warning
+		default:
+			mark = bad
+		}
+		counts[c.Status]++
+		fmt.Fprintf(out, "  %s %s: %s\n", mark, c.Name, c.Message)
+		if c.Hint != "" {
+			fmt.Fprintf(out, "     hint: %s\n", c.Hint)
+		}
+	}
+	fmt.Fprintf(out, "\n%d passed, %d warnings, %d failed\n", counts[checkPass], counts[checkWarn], counts[checkFail])
+	return nil
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func Test_doctorAddResults(t *testing.T) {
+	di := &doctorInfo{}
+	di.addResults("traffic-manager", &manager.DiagnosticResults{Results: []*manager.DiagnosticResult{
+		{Name: "api-server", Message: "reachable"},
+		{Name: "agent-image", Status: manager.DiagnosticResult_WARN, Message: "mismatch", Hint: "reinstall"},
+		{Name: "other", Status: manager.DiagnosticResult_FAIL, Message: "broken", Hint: "fix it"},
+	}})
+	require.Len(t, di.checks, 3)
+	assert.Equal(t, checkPass, di.checks[0].Status)
+	assert.Equal(t, checkWarn, di.checks[1].Status)
+	assert.Equal(t, checkFail, di.checks[2].Status)
+	for _, c := range di.checks {
+		assert.Equal(t, "traffic-manager", c.Component)
+	}
+}
+
+func Test_doctorWrite(t *testing.T) {
+	newInfo := func(asJSON bool) *doctorInfo {
+		di := &doctorInfo{json: asJSON}
+		di.add("cli", "sshfs", checkPass, "sshfs is installed", "")
+		di.add("cli", "network", checkFail, "no route", "check the VPN")
+		di.add("root-daemon", "running", checkWarn, "not running", "connect")
+		return di
+	}
+
+	t.Run("text", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.NoError(t, newInfo(false).write(out))
+		assert.Equal(t, `cli:
+  `+good+` sshfs: sshfs is installed
+  `+bad+` network: no route
+     hint: check the VPN
+root-daemon:
+  `+warning+` running: not running
+     hint: connect
+
+1 passed, 1 warnings, 1 failed
+`, out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.NoError(t, newInfo(true).write(out))
+		var checks []*doctorCheck
+		require.NoError(t, json.Unmarshal(out.Bytes(), &checks))
+		assert.Equal(t, newInfo(true).checks, checks)
+		assert.NotContains(t, out.String(), `"hint": ""`)
+	})
+}
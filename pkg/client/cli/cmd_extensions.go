@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/extensions"
+)
+
+func extensionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:  "extensions",
+		Args: OnlySubcommands,
+
+		Short: "Add, update, and remove extension files fetched from remote sources",
+		Long: `Add, update, and remove extension files fetched from remote sources.
+Extension files are stored in the user's extensions directory, pinned by the
+digest of their content. When extensions.publicKey is set in the config.yml,
+each file must be accompanied by a "<url>.sig" file with the base64 encoded
+ed25519 signature of its content.`,
+		RunE: RunSubcommands,
+	}
+
+	var name, digest string
+	addCmd := &cobra.Command{
+		Use:   "add <url>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Fetch an extension file and add it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := extensions.AddExtension(cmd.Context(), name, args[0], digest)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Extension %s added, pinned to %s\n", e.Name, e.Digest)
+			return nil
+		},
+	}
+	flags := addCmd.Flags()
+	flags.StringVar(&name, "name", "", "Name of the extension (default is the basename of the URL)")
+	flags.StringVar(&digest, "digest", "", `The "sha256:<hex>" digest that the extension file must match`)
+
+	cmd.AddCommand(
+		addCmd,
+		&cobra.Command{
+			Use:   "list",
+			Args:  cobra.NoArgs,
+			Short: "List the extension files that have been added",
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				entries, err := extensions.ListExtensions(cmd.Context())
+				if err != nil {
+					return err
+				}
+				out := cmd.OutOrStdout()
+				if len(entries) == 0 {
+					fmt.Fprintln(out, "No extensions have been added")
+					return nil
+				}
+				nameLen := 0
+				for _, e := range entries {
+					if l := len(e.Name); l > nameLen {
+						nameLen = l
+					}
+				}
+				for _, e := range entries {
+					desc := e.Digest
+					if e.Signed {
+						desc += ", signed"
+					}
+					if e.Modified {
+						desc += ", MODIFIED"
+					}
+					fmt.Fprintf(out, "%-*s: %s (%s)\n", nameLen, e.Name, e.Source, desc)
+				}
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "update [<name>...]",
+			Short: "Fetch extension files again and pin them to their new digests",
+			Long: `Fetch extension files again and pin them to their new digests. All
+extension files that have been added are updated when no name is given.`,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				ctx := cmd.Context()
+				if len(args) == 0 {
+					entries, err := extensions.ListExtensions(ctx)
+					if err != nil {
+						return err
+					}
+					for _, e := range entries {
+						args = append(args, e.Name)
+					}
+				}
+				for _, name := range args {
+					prev, e, err := extensions.UpdateExtension(ctx, name)
+					if err != nil {
+						return err
+					}
+					if prev == e.Digest {
+						fmt.Fprintf(cmd.OutOrStdout(), "Extension %s is up to date\n", name)
+					} else {
+						fmt.Fprintf(cmd.OutOrStdout(), "Extension %s updated, pinned to %s\n", name, e.Digest)
+					}
+				}
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "remove <name>",
+			Args:  cobra.ExactArgs(1),
+			Short: "Remove an extension file that has been added",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := extensions.RemoveExtension(cmd.Context(), args[0]); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Extension %s removed\n", args[0])
+				return nil
+			},
+		},
+	)
+	return cmd
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"archive/zip"
 	"bufio"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -73,6 +74,7 @@ telepresence gather-logs --daemons=None
 	flags.StringVar(&gl.trafficAgents, "traffic-agents", "all", "Traffic-agents to collect logs from: all, name substring, None")
 	flags.BoolVarP(&gl.anon, "anonymize", "a", false, "To anonymize pod names + namespaces from the logs")
 	flags.BoolVarP(&gl.podYaml, "get-pod-yaml", "y", false, "Get the yaml of any pods you are getting logs for")
+	addConnectionFlag(flags)
 	return cmd
 }
 
@@ -151,13 +153,15 @@ func (gl *gatherLogsArgs) gatherLogs(ctx context.Context, cmd *cobra.Command, st
 		return errcat.User.New(err)
 	}
 	for _, entry := range logFiles {
-		if entry.IsDir() {
+		// Skip directories and files that are in the process of being created
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
 			continue
 		}
 		for _, logType := range daemonLogs {
 			if strings.Contains(entry.Name(), logType) {
 				srcFile := fmt.Sprintf("%s/%s", logDir, entry.Name())
-				dstFile := fmt.Sprintf("%s/%s", exportDir, entry.Name())
+				// Rotated logs might be compressed. They are decompressed by copyFiles
+				dstFile := fmt.Sprintf("%s/%s", exportDir, strings.TrimSuffix(entry.Name(), ".gz"))
 				if err := copyFiles(dstFile, srcFile); err != nil {
 					// We don't want to fail / exit abruptly if we can't copy certain
 					// files, but we do want the user to know we were unsuccessful
@@ -178,7 +182,7 @@ func (gl *gatherLogsArgs) gatherLogs(ctx context.Context, cmd *cobra.Command, st
 			Agents:         gl.trafficAgents,
 			GetPodYaml:     gl.podYaml,
 		}
-		err = withConnector(cmd, false, func(_ context.Context, _ connector.ConnectorClient, _ *connector.ConnectInfo, _ daemon.DaemonClient) error {
+		err = withConnector(cmd, false, func(ctx context.Context, _ connector.ConnectorClient, _ *connector.ConnectInfo, _ daemon.DaemonClient) error {
 			err = cliutil.WithManager(ctx, func(ctx context.Context, managerClient manager.ManagerClient) error {
 				lr, err := managerClient.GetLogs(ctx, rq)
 				if err != nil {
@@ -269,7 +273,9 @@ func writeResponseToFiles(lr *manager.LogsResponse, anonymizer *anonymizer, expo
 	return nil
 }
 
-// copyFiles copies files from one location into another.
+// copyFiles copies files from one location into another. A source file with
+// a ".gz" extension is assumed to be gzip compressed and is decompressed
+// during the copy.
 func copyFiles(dstFile, srcFile string) error {
 	srcWriter, err := os.Open(srcFile)
 	if err != nil {
@@ -277,13 +283,23 @@ func copyFiles(dstFile, srcFile string) error {
 	}
 	defer srcWriter.Close()
 
+	var srcReader io.Reader = srcWriter
+	if strings.HasSuffix(srcFile, ".gz") {
+		zr, err := gzip.NewReader(srcWriter)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		srcReader = zr
+	}
+
 	dstWriter, err := os.Create(dstFile)
 	if err != nil {
 		return err
 	}
 	defer dstWriter.Close()
 
-	if _, err := io.Copy(dstWriter, srcWriter); err != nil {
+	if _, err := io.Copy(dstWriter, srcReader); err != nil {
 		return err
 	}
 	return nil
@@ -2,9 +2,12 @@ package cli
 
 import (
 	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 	"time"
@@ -163,6 +166,29 @@ func Test_gatherLogsCopyFiles(t *testing.T) {
 	}
 }
 
+func Test_gatherLogsCopyCompressedFile(t *testing.T) {
+	srcFile := "testdata/zipDir/file1.log"
+	srcContent, err := os.ReadFile(srcFile)
+	require.NoError(t, err)
+
+	// Create a gzip compressed version of the source file, similar to a rotated log file
+	tmpDir := t.TempDir()
+	gzFile := filepath.Join(tmpDir, "file1.log.gz")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(srcContent)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(gzFile, buf.Bytes(), 0600))
+
+	// Ensure that the file is decompressed when it's copied
+	dstFile := filepath.Join(tmpDir, "copiedFile.log")
+	require.NoError(t, copyFiles(dstFile, gzFile))
+	dstContent, err := os.ReadFile(dstFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(srcContent), string(dstContent))
+}
+
 func Test_gatherLogsNoK8s(t *testing.T) {
 	type testcase struct {
 		name       string
@@ -39,6 +39,7 @@ func listCommand() *cobra.Command {
 	flags.BoolVar(&s.debug, "debug", false, "include debugging information")
 	flags.StringVarP(&s.namespace, "namespace", "n", "", "If present, the namespace scope for this CLI request")
 	flags.BoolVarP(&s.json, "json", "j", false, "output as json array")
+	addConnectionFlag(flags)
 	return cmd
 }
 
@@ -58,6 +58,7 @@ func loglevelCommand() *cobra.Command {
 	flags.DurationVarP(&lls.duration, "duration", "d", defaultDuration, "The time that the log-level will be in effect (0s means indefinitely)")
 	flags.BoolVarP(&lls.localOnly, "local-only", "l", false, "Only affect the user and root daemons")
 	flags.BoolVarP(&lls.remoteOnly, "remote-only", "r", false, "Only affect the traffic-manager and traffic-agents")
+	addConnectionFlag(flags)
 	return cmd
 }
 
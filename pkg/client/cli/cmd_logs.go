@@ -0,0 +1,381 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+// logFilePollInterval is the interval used when polling local log files for new lines
+const logFilePollInterval = 250 * time.Millisecond
+
+type logsArgs struct {
+	manager   bool
+	agents    string
+	daemons   bool
+	follow    bool
+	tailLines int64
+	level     string
+}
+
+// logLine is a line of log output, annotated with the name of the source that produced it
+type logLine struct {
+	source string
+	text   string
+}
+
+func logsCommand() *cobra.Command {
+	la := &logsArgs{}
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Args:  cobra.NoArgs,
+		Short: "Show logs from the traffic-manager, traffic-agents, and user and root daemons",
+		Long: `Show logs from the traffic-manager, traffic-agents, and user and root daemons.
+The logs of all sources are interleaved and each line is prefixed with the name of the
+source that produced it. Logs from all sources are shown unless one or more of the
+--manager, --agent, or --daemons flags are given.`,
+		Example: `Here are a few examples of how you can use this command:
+# Follow the logs of the traffic-manager
+telepresence logs --manager --follow
+
+# Follow the logs of the traffic-agents in pods that have "echo-easy" in the name, and the daemons
+telepresence logs --agent echo-easy --daemons --follow
+
+# Show the last 20 lines from all sources that are logged at level warning or higher
+telepresence logs --tail 20 --level warning
+`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return la.logs(cmd)
+		},
+	}
+	flags := cmd.Flags()
+	flags.BoolVar(&la.manager, "manager", false, "Show logs from the traffic-manager")
+	flags.StringVar(&la.agents, "agent", "", "Show logs from traffic-agents: all, or a pod name substring")
+	flags.BoolVar(&la.daemons, "daemons", false, "Show logs from the user and root daemons")
+	flags.BoolVarP(&la.follow, "follow", "f", false, "Keep streaming new log lines as they are produced")
+	flags.Int64Var(&la.tailLines, "tail", 0, "Number of recent lines to show from each source, 0 means all")
+	flags.StringVar(&la.level, "level", "", "Only show lines logged at this level or more severe (error, warning, info, debug, trace)")
+	addConnectionFlag(flags)
+	return cmd
+}
+
+func (la *logsArgs) logs(cmd *cobra.Command) error {
+	var minLevel logrus.Level
+	if la.level != "" {
+		var err error
+		if minLevel, err = logrus.ParseLevel(la.level); err != nil {
+			return errcat.User.New(err)
+		}
+	}
+	if !(la.manager || la.agents != "" || la.daemons) {
+		la.manager = true
+		la.agents = "all"
+		la.daemons = true
+	}
+
+	var sources []logSource
+	if la.daemons {
+		logDir, err := filelocation.AppUserLogDir(cmd.Context())
+		if err != nil {
+			return errcat.User.New(err)
+		}
+		for _, name := range []string{"connector", "daemon"} {
+			path := filepath.Join(logDir, name+".log")
+			name := name
+			sources = append(sources, func(ctx context.Context, lines chan<- logLine) error {
+				return tailLogFile(ctx, path, name, la.tailLines, la.follow, lines)
+			})
+		}
+	}
+	if la.manager || la.agents != "" {
+		agents := la.agents
+		if agents == "" {
+			agents = "None"
+		}
+		rq := &manager.WatchLogsRequest{
+			TrafficManager: la.manager,
+			Agents:         agents,
+			TailLines:      la.tailLines,
+			Follow:         la.follow,
+		}
+		sources = append(sources, func(ctx context.Context, lines chan<- logLine) error {
+			return withConnector(cmd, false, func(cc context.Context, _ connector.ConnectorClient, _ *connector.ConnectInfo, _ daemon.DaemonClient) error {
+				// The context of the connector carries the connection, but it must also end when
+				// another source fails.
+				cc, cancel := context.WithCancel(cc)
+				defer cancel()
+				go func() {
+					select {
+					case <-ctx.Done():
+						cancel()
+					case <-cc.Done():
+					}
+				}()
+				return cliutil.WithManager(cc, func(cc context.Context, managerClient manager.ManagerClient) error {
+					return watchManagerLogs(cc, managerClient, rq, lines)
+				})
+			})
+		})
+	}
+	return readLogSources(cmd.Context(), sources, func(lines <-chan logLine) {
+		writeLogLines(cmd.OutOrStdout(), lines, la.level != "", minLevel)
+	})
+}
+
+// logSource sends the lines of one source of logs to the given channel until it has no more lines
+// to send or the context is cancelled.
+type logSource func(ctx context.Context, lines chan<- logLine) error
+
+// readLogSources runs the given sources concurrently and passes the channel that receives their
+// lines to write, which must consume it until it's closed. The first source that fails cancels the
+// others, so that its error is returned right away also when the sources follow their logs.
+func readLogSources(ctx context.Context, sources []logSource, write func(<-chan logLine)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines := make(chan logLine, 100)
+	errs := make(chan error, len(sources))
+	wg := sync.WaitGroup{}
+	wg.Add(len(sources))
+	for _, source := range sources {
+		go func(source logSource) {
+			defer wg.Done()
+			if err := source(ctx, lines); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(source)
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+		close(errs)
+	}()
+
+	write(lines)
+	return <-errs
+}
+
+// watchManagerLogs sends the lines received from the traffic-manager's WatchLogs stream to the
+// given channel until the stream ends or the context is cancelled.
+func watchManagerLogs(ctx context.Context, managerClient manager.ManagerClient, rq *manager.WatchLogsRequest, lines chan<- logLine) error {
+	stream, err := managerClient.WatchLogs(ctx, rq)
+	if err != nil {
+		return err
+	}
+	for {
+		ll, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case lines <- logLine{source: ll.Pod, text: ll.Text}:
+		}
+	}
+}
+
+// tailLogFile sends the last tailLines lines of the given file to the given channel, or all
+// lines if tailLines is zero or less. If follow is true, the file is then polled for new lines
+// until the context is cancelled. A file that is rotated while it's being followed is reopened.
+func tailLogFile(ctx context.Context, path, source string, tailLines int64, follow bool, lines chan<- logLine) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if !follow {
+			// Nothing has been logged by this daemon.
+			return nil
+		}
+		// The daemon might not have started yet. Wait for the file to appear.
+		if f, err = waitForFile(ctx, path); f == nil {
+			return err
+		}
+	}
+	defer func() {
+		f.Close()
+	}()
+
+	send := func(text string) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case lines <- logLine{source: source, text: text}:
+			return true
+		}
+	}
+
+	rd := bufio.NewReader(f)
+	var tail []string
+	partial := ""
+	for {
+		text, err := rd.ReadString('\n')
+		if err != nil {
+			partial = text
+			break
+		}
+		text = strings.TrimRight(text, "\r\n")
+		if tailLines > 0 {
+			if int64(len(tail)) == tailLines {
+				tail = tail[1:]
+			}
+			tail = append(tail, text)
+		} else if !send(text) {
+			return nil
+		}
+	}
+	for _, text := range tail {
+		if !send(text) {
+			return nil
+		}
+	}
+	if !follow {
+		if partial != "" {
+			send(partial)
+		}
+		return nil
+	}
+
+	// readToEOF sends all complete lines up to the current end of the file. An incomplete last
+	// line is retained in partial.
+	readToEOF := func() bool {
+		for {
+			text, err := rd.ReadString('\n')
+			partial += text
+			if err != nil {
+				return true
+			}
+			if !send(strings.TrimRight(partial, "\r\n")) {
+				return false
+			}
+			partial = ""
+		}
+	}
+
+	ticker := time.NewTicker(logFilePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if !rotated(f, path) {
+			if !readToEOF() {
+				return nil
+			}
+			continue
+		}
+
+		// The file has been rotated. Read what remains in the old file before moving on to the
+		// new one.
+		nf, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		if !readToEOF() {
+			nf.Close()
+			return nil
+		}
+		if partial != "" {
+			if !send(partial) {
+				nf.Close()
+				return nil
+			}
+			partial = ""
+		}
+		f.Close()
+		f = nf
+		rd = bufio.NewReader(f)
+	}
+}
+
+// rotated returns true when the given path no longer denotes the given open file
+func rotated(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(fi, pi)
+}
+
+func waitForFile(ctx context.Context, path string) (*os.File, error) {
+	ticker := time.NewTicker(logFilePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+		}
+		f, err := os.Open(path)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// writeLogLines writes the lines read from the given channel to out, each prefixed with the name of
+// its source. If filter is true, lines logged at a level that is less severe than minLevel are
+// discarded. Lines that lack a level, such as continuation lines of a multi-line message, share
+// the fate of the preceding line from the same source.
+func writeLogLines(out io.Writer, lines <-chan logLine, filter bool, minLevel logrus.Level) {
+	accepted := make(map[string]bool)
+	for ll := range lines {
+		if filter {
+			if lvl, ok := parseLineLevel(ll.text); ok {
+				accepted[ll.source] = lvl <= minLevel
+			}
+			if ok, seen := accepted[ll.source]; seen && !ok {
+				continue
+			}
+		}
+		fmt.Fprintf(out, "%s | %s\n", ll.source, ll.text)
+	}
+}
+
+// parseLineLevel parses the level from a line produced by Telepresence's log formatter. Such
+// lines start with a date, a time, and the level.
+func parseLineLevel(text string) (logrus.Level, bool) {
+	fields := strings.SplitN(text, " ", 3)
+	if len(fields) < 3 || fields[0] == "" || fields[0][0] < '0' || fields[0][0] > '9' {
+		return 0, false
+	}
+	lvl := strings.Fields(fields[2])
+	if len(lvl) == 0 {
+		return 0, false
+	}
+	level, err := logrus.ParseLevel(lvl[0])
+	if err != nil {
+		return 0, false
+	}
+	return level, true
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+func Test_logsParseLineLevel(t *testing.T) {
+	type testcase struct {
+		name  string
+		text  string
+		level logrus.Level
+		ok    bool
+	}
+	testCases := []testcase{
+		{
+			name:  "info",
+			text:  "2021-12-03 10:21:05.1234 info    connector/session : hello",
+			level: logrus.InfoLevel,
+			ok:    true,
+		},
+		{
+			name:  "warning",
+			text:  "2021-12-03 10:21:05.1234 warning connector/session : hello",
+			level: logrus.WarnLevel,
+			ok:    true,
+		},
+		{
+			name:  "shortTimestamp",
+			text:  "10:21:05.1234 debug   hello",
+			level: 0,
+			ok:    false,
+		},
+		{
+			name: "continuation",
+			text: "goroutine 1 [running]:",
+			ok:   false,
+		},
+		{
+			name: "wordsThatLookLikeALevel",
+			text: "an unexpected error occurred",
+			ok:   false,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			level, ok := parseLineLevel(tc.text)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.level, level)
+			}
+		})
+	}
+}
+
+func Test_logsWriteLogLines(t *testing.T) {
+	lines := make(chan logLine, 10)
+	lines <- logLine{source: "connector", text: "2021-12-03 10:21:05.1234 debug   dropped"}
+	lines <- logLine{source: "daemon", text: "2021-12-03 10:21:05.1234 error   kept"}
+	lines <- logLine{source: "connector", text: "  continuation of dropped"}
+	lines <- logLine{source: "daemon", text: "  continuation of kept"}
+	lines <- logLine{source: "connector", text: "2021-12-03 10:21:05.1234 info    kept"}
+	close(lines)
+
+	out := &bytes.Buffer{}
+	writeLogLines(out, lines, true, logrus.InfoLevel)
+	assert.Equal(t, `daemon | 2021-12-03 10:21:05.1234 error   kept
+daemon |   continuation of kept
+connector | 2021-12-03 10:21:05.1234 info    kept
+`, out.String())
+}
+
+func Test_logsReadLogSources(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	logFile := filepath.Join(t.TempDir(), "connector.log")
+	require.NoError(t, os.WriteFile(logFile, []byte("one\n"), 0600))
+	sources := []logSource{
+		func(ctx context.Context, lines chan<- logLine) error {
+			return tailLogFile(ctx, logFile, "connector", 0, true, lines)
+		},
+		func(ctx context.Context, lines chan<- logLine) error {
+			lines <- logLine{source: "traffic-manager", text: "two"}
+			return errors.New("stream broken")
+		},
+	}
+
+	// A failing source ends the sources that follow their logs, so that its error is returned.
+	out := &bytes.Buffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- readLogSources(ctx, sources, func(lines <-chan logLine) {
+			writeLogLines(out, lines, false, 0)
+		})
+	}()
+	select {
+	case err := <-done:
+		assert.EqualError(t, err, "stream broken")
+	case <-time.After(5 * time.Second):
+		t.Fatal("a failing source didn't end the sources that follow their logs")
+	}
+	assert.Contains(t, out.String(), "traffic-manager | two\n")
+
+	// Sources that don't follow their logs end on their own, without an error.
+	out.Reset()
+	assert.NoError(t, readLogSources(ctx, []logSource{
+		func(ctx context.Context, lines chan<- logLine) error {
+			return tailLogFile(ctx, logFile, "connector", 0, false, lines)
+		},
+	}, func(lines <-chan logLine) {
+		writeLogLines(out, lines, false, 0)
+	}))
+	assert.Equal(t, "connector | one\n", out.String())
+}
+
+func Test_logsTailLogFile(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "connector.log")
+	require.NoError(t, os.WriteFile(logFile, []byte("one\ntwo\nthree\n"), 0600))
+
+	readLines := func(lines <-chan logLine, count int) []string {
+		var texts []string
+		for len(texts) < count {
+			select {
+			case ll := <-lines:
+				assert.Equal(t, "connector", ll.source)
+				texts = append(texts, ll.text)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timeout waiting for line %d", len(texts)+1)
+			}
+		}
+		return texts
+	}
+
+	t.Run("tail", func(t *testing.T) {
+		ctx := dlog.NewTestContext(t, false)
+		lines := make(chan logLine, 10)
+		require.NoError(t, tailLogFile(ctx, logFile, "connector", 2, false, lines))
+		close(lines)
+		assert.Equal(t, []string{"two", "three"}, readLines(lines, 2))
+	})
+
+	t.Run("follow", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(dlog.NewTestContext(t, false))
+		lines := make(chan logLine, 10)
+		done := make(chan error)
+		go func() {
+			done <- tailLogFile(ctx, logFile, "connector", 1, true, lines)
+		}()
+		assert.Equal(t, []string{"three"}, readLines(lines, 1))
+
+		f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0600)
+		require.NoError(t, err)
+		_, err = f.WriteString("four\nfi")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"four"}, readLines(lines, 1))
+
+		// Finish the line and rotate the file. The remainder of the old file must be read before
+		// the new file.
+		_, err = f.WriteString("ve\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.NoError(t, os.Rename(logFile, logFile+".old"))
+		require.NoError(t, os.WriteFile(logFile, []byte("six\n"), 0600))
+		assert.Equal(t, []string{"five", "six"}, readLines(lines, 2))
+
+		cancel()
+		require.NoError(t, <-done)
+	})
+}
@@ -6,6 +6,9 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
@@ -19,6 +22,20 @@ func addPreviewFlags(prefix string, flags *pflag.FlagSet, spec *manager.PreviewS
 	flags.BoolVarP(&spec.DisplayBanner, prefix+"banner", "b", true, "Display banner on preview page")
 }
 
+// previewGatewayDomain returns the domain under which the traffic-manager serves preview URLs itself, or
+// an empty string when the preview URLs are served by Ambassador Cloud.
+func previewGatewayDomain(ctx context.Context, managerClient manager.ManagerClient) (string, error) {
+	gw, err := managerClient.GetPreviewGateway(ctx, &empty.Empty{})
+	if err != nil {
+		if grpcStatus.Code(err) == codes.Unimplemented {
+			// Older traffic-managers can't serve preview URLs.
+			return "", nil
+		}
+		return "", err
+	}
+	return gw.Domain, nil
+}
+
 func previewCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:  "preview",
@@ -35,12 +52,18 @@ func previewCommand() *cobra.Command {
 
 		Short: "Create a preview domain for an existing intercept",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if _, err := cliutil.EnsureLoggedIn(cmd.Context(), ""); err != nil {
-				return err
-			}
 			return withConnector(cmd, true, func(ctx context.Context, _ connector.ConnectorClient, connInfo *connector.ConnectInfo, _ daemon.DaemonClient) error {
 				return cliutil.WithManager(ctx, func(ctx context.Context, managerClient manager.ManagerClient) error {
-					if createSpec.Ingress == nil {
+					gwDomain, err := previewGatewayDomain(ctx, managerClient)
+					if err != nil {
+						return err
+					}
+					if gwDomain == "" {
+						if _, err := cliutil.EnsureLoggedIn(ctx, ""); err != nil {
+							return err
+						}
+					}
+					if gwDomain == "" && createSpec.Ingress == nil {
 						request := manager.GetInterceptRequest{Session: connInfo.SessionInfo, Name: args[0]}
 						// Throws rpc "not found" error if intercept has not yet been created
 						interceptInfo, err := managerClient.GetIntercept(ctx, &request)
@@ -70,6 +93,7 @@ func previewCommand() *cobra.Command {
 		},
 	}
 	addPreviewFlags("", createCmd.Flags(), &createSpec)
+	addConnectionFlag(createCmd.Flags())
 
 	removeCmd := &cobra.Command{
 		Use:  "remove <intercept_name>",
@@ -95,6 +119,7 @@ func previewCommand() *cobra.Command {
 			})
 		},
 	}
+	addConnectionFlag(removeCmd.Flags())
 
 	cmd.AddCommand(createCmd, removeCmd)
 
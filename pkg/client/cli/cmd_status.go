@@ -12,18 +12,22 @@ import (
 
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
 func statusCommand() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:  "status",
 		Args: cobra.NoArgs,
 
 		Short: "Show connectivity status",
 		RunE:  status,
 	}
+	addConnectionFlag(cmd.Flags())
+	return cmd
 }
 
 // status will retrieve connectivity status from the daemon and print it on stdout.
@@ -44,7 +48,7 @@ func daemonStatus(cmd *cobra.Command) error {
 
 	err := cliutil.WithStartedDaemon(cmd.Context(), func(ctx context.Context, daemonClient daemon.DaemonClient) error {
 		var err error
-		status, err := daemonClient.Status(cmd.Context(), &empty.Empty{})
+		status, err := daemonClient.Status(client.WithConnectionName(ctx, connectionName), &empty.Empty{})
 		if err != nil {
 			return err
 		}
@@ -70,6 +74,14 @@ func daemonStatus(cmd *cobra.Command) error {
 		for _, subnet := range status.OutboundConfig.AlsoProxySubnets {
 			fmt.Fprintf(out, "    - %s\n", iputil.IPNetFromRPC(subnet))
 		}
+		if shaping := status.GetNetworkShaping().GetOutbound(); shaping != "" {
+			fmt.Fprintf(out, "  Shaping    : %s\n", shaping)
+		}
+		if ts := status.GetTunnelStats(); ts != nil {
+			stats := tunnel.Stats{Sent: ts.Sent, SentWire: ts.SentWire, Received: ts.Received, ReceivedWire: ts.ReceivedWire}
+			fmt.Fprintf(out, "  Tunnel     : sent %d bytes (compression ratio %.2f), received %d bytes (compression ratio %.2f)\n",
+				stats.Sent, stats.SendRatio(), stats.Received, stats.ReceiveRatio())
+		}
 
 		return nil
 	})
@@ -89,10 +101,6 @@ func connectorStatus(cmd *cobra.Command) error {
 	err := cliutil.WithStartedConnector(cmd.Context(), func(ctx context.Context, connectorClient connector.ConnectorClient) error {
 		fmt.Fprintln(out, "User Daemon: Running")
 
-		type kv struct {
-			Key   string
-			Value string
-		}
 		var fields []kv
 		defer func() {
 			klen := 0
@@ -124,42 +132,29 @@ func connectorStatus(cmd *cobra.Command) error {
 			fields = append(fields, kv{"Ambassador Cloud", "Logged in"})
 		}
 
-		status, err := connectorClient.Status(ctx, &connector.ConnectRequest{
+		if connectionName == "" {
+			// Show all connections when there's more than one
+			cl, err := connectorClient.ListConnections(ctx, &empty.Empty{})
+			if err != nil {
+				return err
+			}
+			if len(cl.Connections) > 1 {
+				for _, status := range cl.Connections {
+					fields = append(fields, kv{"Connection", status.ConnectionName})
+					fields = connectionStatus(fields, status)
+				}
+				return nil
+			}
+		}
+
+		status, err := connectorClient.Status(client.WithConnectionName(ctx, connectionName), &connector.ConnectRequest{
 			KubeFlags: kubeFlagMap(),
+			Name:      connectionName,
 		})
 		if err != nil {
 			return err
 		}
-		switch status.Error {
-		case connector.ConnectInfo_UNSPECIFIED, connector.ConnectInfo_ALREADY_CONNECTED:
-			fields = append(fields, kv{"Status", "Connected"})
-		case connector.ConnectInfo_MUST_RESTART:
-			fields = append(fields, kv{"Status", "Connected, but must restart"})
-		case connector.ConnectInfo_DISCONNECTED:
-			fields = append(fields, kv{"Status", "Not connected"})
-			return nil
-		case connector.ConnectInfo_CLUSTER_FAILED:
-			fields = append(fields, kv{"Status", "Not connected, error talking to cluster"})
-			fields = append(fields, kv{"Error", status.ErrorText})
-			return nil
-		case connector.ConnectInfo_TRAFFIC_MANAGER_FAILED:
-			fields = append(fields, kv{"Status", "Not connected, error talking to in-cluster Telepresence traffic-manager"})
-			fields = append(fields, kv{"Error", status.ErrorText})
-			return nil
-		}
-		fields = append(fields, kv{"Kubernetes server", status.ClusterServer})
-		fields = append(fields, kv{"Kubernetes context", status.ClusterContext})
-		if status.BridgeOk {
-			fields = append(fields, kv{"Telepresence proxy", "ON (networking to the cluster is enabled)"})
-		} else {
-			fields = append(fields, kv{"Telepresence proxy", "OFF (attempting to connect...)"})
-		}
-		intercepts := fmt.Sprintf("%d total\n", len(status.GetIntercepts().GetIntercepts()))
-		for _, icept := range status.GetIntercepts().GetIntercepts() {
-			intercepts += fmt.Sprintf("%s: %s\n", icept.Spec.Name, icept.Spec.Client)
-		}
-		fields = append(fields, kv{"Intercepts", intercepts})
-
+		fields = connectionStatus(fields, status)
 		return nil
 	})
 	if err != nil {
@@ -171,3 +166,58 @@ func connectorStatus(cmd *cobra.Command) error {
 	}
 	return nil
 }
+
+type kv struct {
+	Key   string
+	Value string
+}
+
+// connectionStatus appends the fields that describe the status of a connection.
+func connectionStatus(fields []kv, status *connector.ConnectInfo) []kv {
+	switch status.Error {
+	case connector.ConnectInfo_UNSPECIFIED, connector.ConnectInfo_ALREADY_CONNECTED:
+		fields = append(fields, kv{"Status", "Connected"})
+	case connector.ConnectInfo_MUST_RESTART:
+		fields = append(fields, kv{"Status", "Connected, but must restart"})
+	case connector.ConnectInfo_DISCONNECTED:
+		return append(fields, kv{"Status", "Not connected"})
+	case connector.ConnectInfo_CLUSTER_FAILED:
+		fields = append(fields, kv{"Status", "Not connected, error talking to cluster"})
+		return append(fields, kv{"Error", status.ErrorText})
+	case connector.ConnectInfo_TRAFFIC_MANAGER_FAILED:
+		fields = append(fields, kv{"Status", "Not connected, error talking to in-cluster Telepresence traffic-manager"})
+		return append(fields, kv{"Error", status.ErrorText})
+	}
+	fields = append(fields, kv{"Kubernetes server", status.ClusterServer})
+	fields = append(fields, kv{"Kubernetes context", status.ClusterContext})
+	if status.BridgeOk {
+		fields = append(fields, kv{"Telepresence proxy", "ON (networking to the cluster is enabled)"})
+	} else {
+		fields = append(fields, kv{"Telepresence proxy", "OFF (attempting to connect...)"})
+	}
+	intercepts := fmt.Sprintf("%d total\n", len(status.GetIntercepts().GetIntercepts()))
+	for _, icept := range status.GetIntercepts().GetIntercepts() {
+		intercepts += fmt.Sprintf("%s: %s", icept.Spec.Name, icept.Spec.Client)
+		if shaping, ok := status.NetworkShaping[icept.Spec.Name]; ok {
+			intercepts += fmt.Sprintf(" (shaping: %s)", shaping)
+		}
+		intercepts += "\n"
+	}
+	fields = append(fields, kv{"Intercepts", intercepts})
+	if len(status.Egress) > 0 {
+		rules := fmt.Sprintf("%d rules\n", len(status.Egress))
+		for _, es := range status.Egress {
+			dest := es.Rule.Subnet
+			if dest == "" {
+				dest = "other destinations"
+			}
+			origin := "no agent available"
+			if es.PodIp != "" {
+				origin = "agent in pod " + es.PodIp
+			}
+			rules += fmt.Sprintf("%s via %s.%s: %s\n", dest, es.Rule.Agent, es.Rule.Namespace, origin)
+		}
+		fields = append(fields, kv{"Egress", rules})
+	}
+	return fields
+}
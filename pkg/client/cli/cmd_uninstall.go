@@ -34,6 +34,7 @@ func uninstallCommand() *cobra.Command {
 	flags.BoolVarP(&ui.allAgents, "all-agents", "a", false, "uninstall intercept agent on all deployments")
 	flags.BoolVarP(&ui.everything, "everything", "e", false, "uninstall agents and the traffic manager")
 	flags.StringVarP(&ui.namespace, "namespace", "n", "", "If present, the namespace scope for this CLI request")
+	addConnectionFlag(flags)
 
 	return cmd
 }
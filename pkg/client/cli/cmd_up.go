@@ -0,0 +1,449 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/extensions"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+// defaultWorkspaceFile is the workspace file used by "telepresence up" and "telepresence down" when
+// no file is given.
+const defaultWorkspaceFile = "telepresence.yaml"
+
+// upReconcileInterval is how often "telepresence up" checks that the declared intercepts still exist.
+const upReconcileInterval = 5 * time.Second
+
+// workspace is the content of a workspace file. It declares a connection and the intercepts that
+// "telepresence up" establishes using that connection.
+type workspace struct {
+	Connection workspaceConnection   `json:"connection,omitempty"`
+	Intercepts []*workspaceIntercept `json:"intercepts,omitempty"`
+}
+
+type workspaceConnection struct {
+	// Name of the connection. Defaults to the --connection flag.
+	Name string `json:"name,omitempty"`
+
+	// Context is the kubeconfig context to use. Defaults to the --context flag or the current context.
+	Context string `json:"context,omitempty"`
+
+	// Kubeconfig is the path to the kubeconfig file. Defaults to the --kubeconfig flag or $KUBECONFIG.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+
+	// MappedNamespaces are the namespaces considered by the DNS resolver and NAT for outbound
+	// connections. Defaults to the --mapped-namespaces flag.
+	MappedNamespaces []string `json:"mappedNamespaces,omitempty"`
+
+	// AlsoProxy are additional subnets, in CIDR notation, that are proxied via the cluster.
+	AlsoProxy []string `json:"alsoProxy,omitempty"`
+
+	// EgressVia are rules, on the form [<subnet>=]<workload>[.<namespace>], that route outbound
+	// connections that are routed to the cluster through a traffic-agent of the workload.
+	EgressVia []string `json:"egressVia,omitempty"`
+}
+
+// workspaceIntercept declares an intercept. Its fields correspond to the flags of the
+// "telepresence intercept" command.
+type workspaceIntercept struct {
+	Name          string              `json:"name"`
+	Workload      string              `json:"workload,omitempty"`
+	Namespace     string              `json:"namespace,omitempty"`
+	Service       string              `json:"service,omitempty"`
+	Port          string              `json:"port,omitempty"`
+	Mechanism     string              `json:"mechanism,omitempty"`
+	MechanismArgs []string            `json:"mechanismArgs,omitempty"`
+	EnvFile       string              `json:"envFile,omitempty"`
+	EnvJSON       string              `json:"envJson,omitempty"`
+	Mount         string              `json:"mount,omitempty"`
+	ToPod         []string            `json:"toPod,omitempty"`
+	DockerRun     *workspaceDockerRun `json:"dockerRun,omitempty"`
+}
+
+// workspaceDockerRun declares a container that is started with the intercepted environment and
+// volume mounts once the intercept is active.
+type workspaceDockerRun struct {
+	// Args are the arguments passed to "docker run", including the image.
+	Args []string `json:"args"`
+
+	// Mount is the volume mount point in the container. Defaults to the intercept's mount point.
+	Mount string `json:"mount,omitempty"`
+}
+
+func loadWorkspace(path string) (*workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errcat.User.Newf("unable to read workspace file: %w", err)
+	}
+	ws := &workspace{}
+	if err = yaml.UnmarshalStrict(data, ws); err != nil {
+		return nil, errcat.User.Newf("unable to parse workspace file %q: %w", path, err)
+	}
+	if len(ws.Intercepts) == 0 {
+		return nil, errcat.User.Newf("workspace file %q declares no intercepts", path)
+	}
+	names := make(map[string]struct{}, len(ws.Intercepts))
+	for _, wi := range ws.Intercepts {
+		if wi.Name == "" {
+			return nil, errcat.User.Newf("workspace file %q declares an intercept without a name", path)
+		}
+		if _, dup := names[wi.Name]; dup {
+			return nil, errcat.User.Newf("workspace file %q declares more than one intercept named %q", path, wi.Name)
+		}
+		names[wi.Name] = struct{}{}
+		if wi.DockerRun != nil {
+			if len(wi.DockerRun.Args) == 0 {
+				return nil, errcat.User.Newf("intercept %q: dockerRun must declare the args passed to docker run", wi.Name)
+			}
+			if err = validateDockerArgs(wi.DockerRun.Args); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ws, nil
+}
+
+// connectRequest returns the request used when connecting. Settings that the workspace doesn't
+// declare are taken from the global flags.
+func (ws *workspace) connectRequest() *connector.ConnectRequest {
+	wc := &ws.Connection
+	cr := &connector.ConnectRequest{
+		KubeFlags:        kubeFlagMap(),
+		MappedNamespaces: mappedNamespaces,
+		Name:             connectionName,
+		AlsoProxy:        wc.AlsoProxy,
+		EgressVia:        wc.EgressVia,
+	}
+	if wc.Context != "" {
+		cr.KubeFlags["context"] = wc.Context
+	}
+	if wc.Kubeconfig != "" {
+		cr.KubeFlags["kubeconfig"] = wc.Kubeconfig
+	}
+	if len(wc.MappedNamespaces) > 0 {
+		cr.MappedNamespaces = wc.MappedNamespaces
+	}
+	if wc.Name != "" {
+		cr.Name = wc.Name
+	}
+	return cr
+}
+
+// interceptArgs returns the arguments that the "telepresence intercept" command would have
+// produced for the declared intercept.
+func (wi *workspaceIntercept) interceptArgs(ctx context.Context) (interceptArgs, error) {
+	args := interceptArgs{
+		name:        wi.Name,
+		agentName:   wi.Workload,
+		namespace:   wi.Namespace,
+		port:        wi.Port,
+		serviceName: wi.Service,
+		previewSpec: &manager.PreviewSpec{},
+		envFile:     wi.EnvFile,
+		envJSON:     wi.EnvJSON,
+		mount:       wi.Mount,
+		mountSet:    wi.Mount != "",
+		toPod:       wi.ToPod,
+	}
+	if args.agentName == "" {
+		args.agentName = wi.Name
+	}
+	if args.port == "" {
+		args.port = "8080"
+	}
+	if args.mount == "" {
+		args.mount = "true"
+	}
+	if d := wi.DockerRun; d != nil {
+		args.dockerRun = true
+		args.dockerMount = d.Mount
+		args.cmdline = d.Args
+	}
+
+	// Mechanisms and their arguments are declared by the extensions, so they are parsed as flags
+	flags := pflag.NewFlagSet(wi.Name, pflag.ContinueOnError)
+	extState, err := extensions.LoadExtensions(ctx, connectionName, flags)
+	if err != nil {
+		return args, err
+	}
+	var extArgs []string
+	if wi.Mechanism != "" {
+		extArgs = append(extArgs, "--mechanism="+wi.Mechanism)
+	}
+	if err = flags.Parse(append(extArgs, wi.MechanismArgs...)); err != nil {
+		return args, errcat.User.Newf("intercept %q: %w", wi.Name, err)
+	}
+	args.extState = extState
+	if args.extRequiresLogin, err = extState.RequiresAPIKeyOrLicense(); err != nil {
+		return args, err
+	}
+	return args, nil
+}
+
+func upCommand() *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:  "up [flags]",
+		Args: cobra.NoArgs,
+
+		Short: "Connect and start the intercepts declared in a workspace file",
+		Long: `Connect using the settings declared in a workspace file and start the intercepts that it declares.
+
+The command keeps running and re-creates the intercepts when they are lost, e.g. because the
+session with the traffic-manager expired, and it restarts the containers of intercepts that
+declare dockerRun. It ends when it's interrupted, or when all its intercepts have been removed
+using "telepresence leave" or "telepresence down".`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ws, err := loadWorkspace(file)
+			if err != nil {
+				return err
+			}
+			cr := ws.connectRequest()
+			return withConnectRequest(cmd, true, cr, func(ctx context.Context, connectorClient connector.ConnectorClient, _ *connector.ConnectInfo, _ daemon.DaemonClient) error {
+				return cliutil.WithManager(ctx, func(ctx context.Context, managerClient manager.ManagerClient) error {
+					u := &upState{
+						cmd:             safeCobraCommandImpl{cmd},
+						ws:              ws,
+						cr:              cr,
+						connectorClient: connectorClient,
+						managerClient:   managerClient,
+						containers:      make(map[string]*upContainer),
+					}
+					return u.run(ctx)
+				})
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", defaultWorkspaceFile, "The workspace file")
+	return cmd
+}
+
+func downCommand() *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:  "down [flags]",
+		Args: cobra.NoArgs,
+
+		Short: "Remove the intercepts declared in a workspace file",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ws, err := loadWorkspace(file)
+			if err != nil {
+				return err
+			}
+			ctx := client.WithConnectionName(cmd.Context(), ws.connectRequest().Name)
+			return cliutil.WithStartedConnector(ctx, func(ctx context.Context, connectorClient connector.ConnectorClient) error {
+				for _, wi := range ws.Intercepts {
+					r, err := connectorClient.RemoveIntercept(dcontext.WithoutCancel(ctx), &manager.RemoveInterceptRequest2{Name: wi.Name})
+					if err != nil {
+						return err
+					}
+					switch r.Error {
+					case connector.InterceptError_UNSPECIFIED:
+						fmt.Fprintf(cmd.OutOrStdout(), "Intercept %s removed\n", wi.Name)
+					case connector.InterceptError_NOT_FOUND:
+					default:
+						return interceptMessage(r)
+					}
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", defaultWorkspaceFile, "The workspace file")
+	return cmd
+}
+
+// upState is the state of a running "telepresence up" command.
+type upState struct {
+	cmd             safeCobraCommand
+	ws              *workspace
+	cr              *connector.ConnectRequest
+	connectorClient connector.ConnectorClient
+	managerClient   manager.ManagerClient
+
+	// sessionID is the client session that the intercepts were last created in
+	sessionID string
+
+	// recovering is true during the round that follows a session change. The connector re-creates
+	// the intercepts of a lost session, so the ones that are missing then are created rather than
+	// considered removed.
+	recovering bool
+
+	// intercepts are the states of the intercepts created by this command
+	intercepts map[string]*interceptState
+
+	// removed are the intercepts that were removed by the user
+	removed map[string]struct{}
+
+	mu         sync.Mutex
+	containers map[string]*upContainer
+	wg         sync.WaitGroup
+}
+
+// upContainer is a container started for an intercept that declares dockerRun.
+type upContainer struct {
+	cancel context.CancelFunc
+}
+
+func (u *upState) run(ctx context.Context) error {
+	defer func() {
+		u.mu.Lock()
+		for _, c := range u.containers {
+			c.cancel()
+		}
+		u.mu.Unlock()
+		u.wg.Wait()
+	}()
+
+	u.intercepts = make(map[string]*interceptState)
+	u.removed = make(map[string]struct{})
+	for first := true; ; first = false {
+		if err := u.reconcile(ctx, first); err != nil {
+			if first || ctx.Err() != nil {
+				return err
+			}
+			fmt.Fprintln(u.cmd.ErrOrStderr(), err)
+		}
+		if len(u.removed) == len(u.ws.Intercepts) {
+			fmt.Fprintln(u.cmd.OutOrStdout(), "All intercepts have been removed")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(upReconcileInterval):
+		}
+	}
+}
+
+// reconcile creates the declared intercepts that don't exist and (re)starts their containers. An
+// intercept that disappears while the session remains the same was removed by the user and is not
+// re-created. When the session changes, the connector re-creates the intercepts, and reconcile
+// creates the ones that are still missing in the round after that.
+func (u *upState) reconcile(ctx context.Context, first bool) error {
+	connInfo, err := u.connectorClient.Status(ctx, u.cr)
+	if err != nil {
+		return err
+	}
+	if connInfo.Error != connector.ConnectInfo_ALREADY_CONNECTED || connInfo.SessionInfo == nil {
+		return errcat.User.New("not connected to the traffic-manager; waiting for the connection to be restored")
+	}
+	existing := make(map[string]struct{})
+	for _, ii := range connInfo.GetIntercepts().GetIntercepts() {
+		existing[ii.Spec.Name] = struct{}{}
+	}
+
+	if connInfo.SessionInfo.SessionId != u.sessionID {
+		u.sessionID = connInfo.SessionInfo.SessionId
+		if !first {
+			fmt.Fprintln(u.cmd.OutOrStdout(), "The session with the traffic-manager was lost; re-creating intercepts")
+			u.recovering = true
+			return nil
+		}
+	}
+	recovering := u.recovering
+	u.recovering = false
+
+	for _, wi := range u.ws.Intercepts {
+		name := wi.Name
+		if _, ok := u.removed[name]; ok {
+			continue
+		}
+		if _, ok := existing[name]; ok {
+			if wi.DockerRun == nil || u.containerRunning(name) {
+				continue
+			}
+			if is, ok := u.intercepts[name]; ok {
+				fmt.Fprintf(u.cmd.OutOrStdout(), "Container of intercept %s ended; restarting it\n", name)
+				u.startContainer(ctx, is)
+				continue
+			}
+			// The intercept was created by someone else, so its environment is unknown. It's not ours to replace.
+			return errcat.User.Newf("intercept %s was not created by this command, so its container cannot be started; "+
+				"remove it with `telepresence leave %s` and then try again", name, name)
+		} else if !first && !recovering {
+			fmt.Fprintf(u.cmd.OutOrStdout(), "Intercept %s was removed\n", name)
+			u.removed[name] = struct{}{}
+			delete(u.intercepts, name)
+			u.stopContainer(name)
+			continue
+		}
+		u.stopContainer(name)
+		if err = u.createIntercept(ctx, wi, connInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createIntercept creates the declared intercept and starts its container.
+func (u *upState) createIntercept(ctx context.Context, wi *workspaceIntercept, connInfo *connector.ConnectInfo) error {
+	args, err := wi.interceptArgs(ctx)
+	if err != nil {
+		return err
+	}
+	if err = loginIfNeeded(ctx, args); err != nil {
+		return err
+	}
+	is := newInterceptState(ctx, u.cmd, args, u.connectorClient, u.managerClient, connInfo)
+	if _, err = is.EnsureState(ctx); err != nil {
+		return fmt.Errorf("intercept %s: %w", wi.Name, err)
+	}
+	u.intercepts[wi.Name] = is
+	if args.dockerRun {
+		u.startContainer(ctx, is)
+	}
+	return nil
+}
+
+func (u *upState) containerRunning(name string) bool {
+	u.mu.Lock()
+	_, ok := u.containers[name]
+	u.mu.Unlock()
+	return ok
+}
+
+func (u *upState) startContainer(ctx context.Context, is *interceptState) {
+	name := is.args.name
+	ctx, cancel := context.WithCancel(ctx)
+	c := &upContainer{cancel: cancel}
+	u.mu.Lock()
+	u.containers[name] = c
+	u.mu.Unlock()
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+		if err := is.runInDocker(ctx, is.cmd, is.args.cmdline); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(is.cmd.ErrOrStderr(), "Container of intercept %s: %v\n", name, err)
+		}
+		u.mu.Lock()
+		if u.containers[name] == c {
+			delete(u.containers, name)
+		}
+		u.mu.Unlock()
+	}()
+}
+
+func (u *upState) stopContainer(name string) {
+	u.mu.Lock()
+	c, ok := u.containers[name]
+	delete(u.containers, name)
+	u.mu.Unlock()
+	if ok {
+		c.cancel()
+	}
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+func TestLoadWorkspace(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), defaultWorkspaceFile)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		ws, err := loadWorkspace(write(t, `
+connection:
+  context: dev
+  mappedNamespaces: [team-a, team-b]
+  alsoProxy: [10.88.0.0/16]
+  egressVia: [10.89.0.0/16=db-proxy.data]
+intercepts:
+  - name: api
+    port: "9000:http"
+    envFile: api.env
+    mount: "false"
+  - name: web-team-a
+    workload: web
+    namespace: team-a
+    dockerRun:
+      args: [--rm, example/web:dev]
+`))
+		require.NoError(t, err)
+		require.Len(t, ws.Intercepts, 2)
+
+		kubeFlags = pflag.NewFlagSet("", 0)
+		mappedNamespaces = nil
+		connectionName = ""
+		cr := ws.connectRequest()
+		assert.Equal(t, map[string]string{"context": "dev"}, cr.KubeFlags)
+		assert.Equal(t, []string{"team-a", "team-b"}, cr.MappedNamespaces)
+		assert.Equal(t, []string{"10.88.0.0/16"}, cr.AlsoProxy)
+		assert.Equal(t, []string{"10.89.0.0/16=db-proxy.data"}, cr.EgressVia)
+
+		ctx := newTestContext(t)
+		args, err := ws.Intercepts[0].interceptArgs(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "api", args.agentName)
+		assert.Equal(t, "9000:http", args.port)
+		assert.Equal(t, "false", args.mount)
+		assert.True(t, args.mountSet)
+		assert.False(t, args.dockerRun)
+
+		args, err = ws.Intercepts[1].interceptArgs(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "web", args.agentName)
+		assert.Equal(t, "8080", args.port)
+		assert.Equal(t, "true", args.mount)
+		assert.False(t, args.mountSet)
+		assert.True(t, args.dockerRun)
+		assert.Equal(t, []string{"--rm", "example/web:dev"}, args.cmdline)
+	})
+
+	invalid := map[string]string{
+		"unknown field":  "intercepts:\n  - name: api\n    workloads: api\n",
+		"no intercepts":  "connection:\n  context: dev\n",
+		"no name":        "intercepts:\n  - workload: api\n",
+		"duplicate name": "intercepts:\n  - name: api\n  - name: api\n",
+		"no docker args": "intercepts:\n  - name: api\n    dockerRun:\n      mount: /data\n",
+		"detached":       "intercepts:\n  - name: api\n    dockerRun:\n      args: [-d, example/api]\n",
+	}
+	for name, content := range invalid {
+		content := content
+		t.Run(name, func(t *testing.T) {
+			_, err := loadWorkspace(write(t, content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+// statusConnector is a connector.ConnectorClient that only implements Status.
+type statusConnector struct {
+	connector.ConnectorClient
+	info *connector.ConnectInfo
+}
+
+func (c *statusConnector) Status(context.Context, *connector.ConnectRequest, ...grpc.CallOption) (*connector.ConnectInfo, error) {
+	return c.info, nil
+}
+
+func TestUpReconcile_foreignIntercept(t *testing.T) {
+	// An intercept that declares dockerRun exists, but it wasn't created by up
+	u := &upState{
+		cmd: safeCobraCommandImpl{&cobra.Command{}},
+		ws:  &workspace{Intercepts: []*workspaceIntercept{{Name: "web", DockerRun: &workspaceDockerRun{Args: []string{"example/web:dev"}}}}},
+		cr:  &connector.ConnectRequest{},
+		connectorClient: &statusConnector{info: &connector.ConnectInfo{
+			Error:       connector.ConnectInfo_ALREADY_CONNECTED,
+			SessionInfo: &manager.SessionInfo{SessionId: "session-1"},
+			Intercepts: &manager.InterceptInfoSnapshot{Intercepts: []*manager.InterceptInfo{
+				{Spec: &manager.InterceptSpec{Name: "web"}},
+			}},
+		}},
+		intercepts: make(map[string]*interceptState),
+		removed:    make(map[string]struct{}),
+		containers: make(map[string]*upContainer),
+	}
+	err := u.reconcile(context.Background(), true)
+	require.Error(t, err)
+	assert.Equal(t, errcat.User, errcat.GetCategory(err))
+	assert.Contains(t, err.Error(), "telepresence leave web")
+}
@@ -15,6 +15,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	empty "google.golang.org/protobuf/types/known/emptypb"
@@ -32,6 +33,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/log"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
 type interceptArgs struct {
@@ -51,8 +53,18 @@ type interceptArgs struct {
 	mountSet bool     // whether --mount was passed
 	toPod    []string // --to-pod
 
-	dockerRun   bool   // --docker-run
-	dockerMount string // --docker-mount // where to mount in a docker container. Defaults to mount unless mount is "true" or "false".
+	dockerRun           bool   // --docker-run
+	dockerMount         string // --docker-mount // where to mount in a docker container. Defaults to mount unless mount is "true" or "false".
+	dockerBridgeNetwork bool   // --docker-bridge-network
+
+	dockerCompose  string // --docker-compose
+	composeService string // --compose-service
+
+	latency       time.Duration // --latency // only valid if !localOnly
+	jitter        time.Duration // --jitter // only valid if !localOnly
+	bandwidth     string        // --bandwidth // only valid if !localOnly
+	loss          string        // --loss // only valid if !localOnly
+	shapeOutbound bool          // --shape-outbound // only valid if !localOnly
 
 	extState         *extensions.ExtensionsState // extension flags
 	extRequiresLogin bool                        // pre-extracted from extState
@@ -66,6 +78,12 @@ type interceptArgs struct {
 	ingressL5   string
 }
 
+// runsContainer returns true if the intercept handler runs in a container, started by either
+// --docker-run or --docker-compose.
+func (a *interceptArgs) runsContainer() bool {
+	return a.dockerRun || a.dockerCompose != ""
+}
+
 // safeCobraCommand is more-or-less a subset of *cobra.Command, with less stuff exposed so I don't
 // have to worry about things using it in ways they shouldn't.
 type safeCobraCommand interface {
@@ -98,8 +116,9 @@ type interceptState struct {
 	// set later ///////////////////////////////////////////////////////////
 
 	env        map[string]string
-	mountPoint string // if non-empty, this the final mount point of a successful mount
-	localPort  uint16 // the parsed <local port>
+	intercept  *manager.InterceptInfo // the intercept, once it has been created
+	mountPoint string                 // if non-empty, this the final mount point of a successful mount
+	localPort  uint16                 // the parsed <local port>
 
 	dockerPort uint16
 }
@@ -120,7 +139,7 @@ func interceptCommand(ctx context.Context) *cobra.Command {
 	flags.StringVarP(&args.port, "port", "p", "8080", ``+
 		`Local port to forward to. If intercepting a service with multiple ports, `+
 		`use <local port>:<svcPortIdentifier>, where the identifier is the port name or port number. `+
-		`With --docker-run, use <local port>:<container port> or <local port>:<container port>:<svcPortIdentifier>.`,
+		`With --docker-run or --docker-compose, use <local port>:<container port> or <local port>:<container port>:<svcPortIdentifier>.`,
 	)
 
 	flags.StringVar(&args.serviceName, "service", "", "Name of service to intercept. If not provided, we will try to auto-detect one")
@@ -151,12 +170,39 @@ func interceptCommand(ctx context.Context) *cobra.Command {
 
 	flags.BoolVarP(&args.dockerRun, "docker-run", "", false, ``+
 		`Run a Docker container with intercepted environment, volume mount, by passing arguments after -- to 'docker run', `+
-		`e.g. '--docker-run -- -it --rm ubuntu:20.04 /bin/bash'`)
+		`e.g. '--docker-run -- -it --rm ubuntu:20.04 /bin/bash'. The container assumes the identity of the intercepted `+
+		`pod: its hostname, DNS search paths, and service account token. On Linux, the container joins the host network `+
+		`unless the container port differs from the local port, the arguments publish a port or declare a network, `+
+		`or --docker-bridge-network is given`)
+
+	flags.BoolVar(&args.dockerBridgeNetwork, "docker-bridge-network", false, ``+
+		`Run the --docker-run container on Docker's bridge network instead of the host network on Linux`)
 
 	flags.StringVarP(&args.dockerMount, "docker-mount", "", "", ``+
 		`The volume mount point in docker. Defaults to same as "--mount"`)
 
+	flags.StringVar(&args.dockerCompose, "docker-compose", "", ``+
+		`Run the services of a Docker Compose file, with the intercepted environment, volume mount, and port injected `+
+		`into the service given by --compose-service. Arguments after -- are passed to 'docker compose up'. `+
+		`The services are removed when the intercept ends. On Linux, the services can't resolve the names of `+
+		`the cluster's services`)
+
+	flags.StringVar(&args.composeService, "compose-service", "", ``+
+		`The service of the --docker-compose file that handles the intercepted traffic`)
+
+	flags.DurationVar(&args.latency, "latency", 0, ``+
+		`Simulate a network latency, e.g. 100ms, on the intercepted traffic`)
+	flags.DurationVar(&args.jitter, "jitter", 0, ``+
+		`Vary the simulated latency of the intercepted traffic by up to this duration`)
+	flags.StringVar(&args.bandwidth, "bandwidth", "", ``+
+		`Limit the bandwidth of the intercepted traffic, e.g. 1mbit or 500kb`)
+	flags.StringVar(&args.loss, "loss", "", ``+
+		`Simulate packet loss, e.g. 1%, on the intercepted traffic. Lost packets are retransmitted after a delay`)
+	flags.BoolVar(&args.shapeOutbound, "shape-outbound", false, ``+
+		`Also simulate the network conditions on connections from the local machine to the cluster`)
+
 	flags.StringVarP(&args.namespace, "namespace", "n", "", "If present, the namespace scope for this CLI request")
+	addConnectionFlag(flags)
 
 	flags.StringVar(&args.ingressHost, "ingress-host", "", "If this flag is set, the ingress dialogue will be skipped,"+
 		" and this value will be used as the ingress hostname.")
@@ -167,8 +213,9 @@ func interceptCommand(ctx context.Context) *cobra.Command {
 	flags.StringVar(&args.ingressL5, "ingress-l5", "", "If this flag is set, the ingress dialogue will be skipped,"+
 		" and this value will be used as the L5 hostname. If the dialogue is skipped, this flag will default to the ingress-host value")
 
+	// The extensions declare flags, so they must be loaded before the --connection flag is parsed.
 	var extErr error
-	args.extState, extErr = extensions.LoadExtensions(ctx, flags)
+	args.extState, extErr = extensions.LoadExtensions(ctx, "", flags)
 
 	cmd.RunE = func(cmd *cobra.Command, positional []string) error {
 		if extErr != nil {
@@ -176,6 +223,11 @@ func interceptCommand(ctx context.Context) *cobra.Command {
 		}
 		// arg-parsing
 		var err error
+		if connectionName != "" {
+			if err = args.extState.LoadClientPolicy(cmd.Context(), connectionName); err != nil {
+				return err
+			}
+		}
 		args.extRequiresLogin, err = args.extState.RequiresAPIKeyOrLicense()
 		if err != nil {
 			return err
@@ -200,6 +252,11 @@ func interceptCommand(ctx context.Context) *cobra.Command {
 			if cmd.Flag("preview-url").Changed && args.previewEnabled {
 				return errcat.User.New("a local-only intercept cannot be previewed")
 			}
+			for _, f := range []string{"latency", "jitter", "bandwidth", "loss", "shape-outbound"} {
+				if cmd.Flag(f).Changed {
+					return errcat.User.Newf("a local-only intercept cannot have --%s", f)
+				}
+			}
 		case false:
 			// Actually intercepting something
 			if args.agentName == "" {
@@ -210,7 +267,20 @@ func interceptCommand(ctx context.Context) *cobra.Command {
 			}
 		}
 		args.mountSet = cmd.Flag("mount").Changed
-		if args.dockerRun {
+		if args.dockerCompose != "" {
+			if args.dockerRun {
+				return errcat.User.New("--docker-compose cannot be used together with --docker-run")
+			}
+			if args.composeService == "" {
+				return errcat.User.New("--docker-compose requires --compose-service")
+			}
+		} else if args.composeService != "" {
+			return errcat.User.New("--compose-service must be used together with --docker-compose")
+		}
+		if args.dockerBridgeNetwork && !args.dockerRun {
+			return errcat.User.New("--docker-bridge-network must be used together with --docker-run")
+		}
+		if args.runsContainer() {
 			if err := validateDockerArgs(args.cmdline); err != nil {
 				return err
 			}
@@ -219,19 +289,50 @@ func interceptCommand(ctx context.Context) *cobra.Command {
 		return intercept(cmd, args)
 	}
 
+	cmd.AddCommand(interceptTransferCommand())
 	return cmd
 }
 
 func leaveCommand() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:  "leave [flags] <intercept_name>",
 		Args: cobra.ExactArgs(1),
 
 		Short: "Remove existing intercept",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return removeIntercept(cmd.Context(), strings.TrimSpace(args[0]))
+			return removeIntercept(client.WithConnectionName(cmd.Context(), connectionName), strings.TrimSpace(args[0]))
+		},
+	}
+	addConnectionFlag(cmd.Flags())
+	return cmd
+}
+
+func interceptTransferCommand() *cobra.Command {
+	var to string
+	cmd := &cobra.Command{
+		Use:  "transfer [flags] <intercept_name> --to <client>",
+		Args: cobra.ExactArgs(1),
+
+		Short: "Hand an existing intercept over to another client",
+		Long: `Hand an existing intercept over to another client without interrupting the intercepted traffic.
+The receiving client is identified by its session ID or by its name (user@host), as shown by
+"telepresence admin list-sessions". It must be connected to the same traffic-manager and serve
+the intercepted port locally. Both clients are notified.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := strings.TrimSpace(args[0])
+			ctx := client.WithConnectionName(cmd.Context(), connectionName)
+			if err := transferIntercept(ctx, name, to); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Intercept %s transferred to %s\n", name, to)
+			return nil
 		},
 	}
+	flags := cmd.Flags()
+	flags.StringVar(&to, "to", "", "Session ID or name of the client that will own the intercept")
+	_ = cmd.MarkFlagRequired("to")
+	addConnectionFlag(flags)
+	return cmd
 }
 
 // Checks if login is necessary and then takes the necessary actions
@@ -243,6 +344,12 @@ func loginIfNeeded(ctx context.Context, args interceptArgs) error {
 				// We default to assuming they can connect to Ambassador Cloud
 				// unless the cluster tells us they can't
 				canConnect := true
+				if !args.extRequiresLogin {
+					// The preview URLs of a traffic-manager that serves them itself don't require a login.
+					if gwDomain, err := previewGatewayDomain(ctx, managerClient); err == nil && gwDomain != "" {
+						return nil
+					}
+				}
 				if resp, err := managerClient.CanConnectAmbassadorCloud(ctx, &empty.Empty{}); err == nil {
 					// We got a response from the manager; trust that response.
 					canConnect = resp.CanConnect
@@ -260,7 +367,7 @@ func loginIfNeeded(ctx context.Context, args interceptArgs) error {
 }
 
 func intercept(cmd *cobra.Command, args interceptArgs) error {
-	if len(args.cmdline) == 0 && !args.dockerRun {
+	if len(args.cmdline) == 0 && !args.runsContainer() {
 		// start and retain the intercept
 		return withConnector(cmd, true, func(ctx context.Context, connectorClient connector.ConnectorClient, connInfo *connector.ConnectInfo, _ daemon.DaemonClient) error {
 			if err := loginIfNeeded(ctx, args); err != nil {
@@ -284,6 +391,9 @@ func intercept(cmd *cobra.Command, args interceptArgs) error {
 				if args.dockerRun {
 					return is.runInDocker(ctx, is.cmd, args.cmdline)
 				}
+				if args.dockerCompose != "" {
+					return is.runInCompose(ctx, args.cmdline)
+				}
 				return proc.Run(ctx, is.env, args.cmdline[0], args.cmdline[1:]...)
 			})
 		})
@@ -318,6 +428,10 @@ func interceptMessage(r *connector.InterceptResult) error {
 		return nil
 	case connector.InterceptError_NO_CONNECTION:
 		msg = "Local network is not connected to the cluster"
+		if r.ErrorText != "" {
+			// The connection couldn't be selected
+			msg = r.ErrorText
+		}
 	case connector.InterceptError_NO_TRAFFIC_MANAGER:
 		msg = "Intercept unavailable: no traffic manager"
 	case connector.InterceptError_TRAFFIC_MANAGER_CONNECTING:
@@ -352,6 +466,12 @@ func interceptMessage(r *connector.InterceptResult) error {
 		msg = fmt.Sprintf("Intercept named %q not found", r.ErrorText)
 	case connector.InterceptError_MOUNT_POINT_BUSY:
 		msg = fmt.Sprintf("Mount point already in use by intercept %q", r.ErrorText)
+	case connector.InterceptError_MECHANISM_PLUGIN_ERROR:
+		msg = fmt.Sprintf("Mechanism plugin failed: %s", r.ErrorText)
+	case connector.InterceptError_AGENT_IMAGE_NOT_ALLOWED:
+		msg = r.ErrorText
+	case connector.InterceptError_INVALID_NETWORK_SHAPING:
+		msg = fmt.Sprintf("Invalid network shaping: %s", r.ErrorText)
 	default:
 		msg = fmt.Sprintf("Unknown error code %d", r.Error)
 	}
@@ -413,7 +533,7 @@ func (is *interceptState) createRequest(ctx context.Context) (*connector.CreateI
 	// Parse port into spec based on how it's formatted
 	portMapping := strings.Split(is.args.port, ":")
 	portError := func() error {
-		if is.args.dockerRun {
+		if is.args.runsContainer() {
 			return errcat.User.New("ports must be of the format --ports <local-port>:<container-port>[:<svcPortIdentifier>]")
 		}
 		return errcat.User.New("ports must be of the format --ports <local-port>[:<svcPortIdentifier>]")
@@ -437,13 +557,13 @@ func (is *interceptState) createRequest(ctx context.Context) (*connector.CreateI
 	switch len(portMapping) {
 	case 1:
 	case 2:
-		if port, err = parsePort(portMapping[1]); err == nil && is.args.dockerRun {
+		if port, err = parsePort(portMapping[1]); err == nil && is.args.runsContainer() {
 			is.dockerPort = port
 		} else {
 			spec.ServicePortIdentifier = portMapping[1]
 		}
 	case 3:
-		if !is.args.dockerRun {
+		if !is.args.runsContainer() {
 			return nil, portError()
 		}
 		if port, err = parsePort(portMapping[1]); err != nil {
@@ -455,7 +575,7 @@ func (is *interceptState) createRequest(ctx context.Context) (*connector.CreateI
 		return nil, portError()
 	}
 
-	if is.args.dockerRun && is.dockerPort == 0 {
+	if is.args.runsContainer() && is.dockerPort == 0 {
 		is.dockerPort = is.localPort
 	}
 
@@ -483,8 +603,8 @@ func (is *interceptState) createRequest(ctx context.Context) (*connector.CreateI
 	}
 
 	if is.args.dockerMount != "" {
-		if !is.args.dockerRun {
-			return nil, errcat.User.New("--docker-mount must be used together with --docker-run")
+		if !is.args.runsContainer() {
+			return nil, errcat.User.New("--docker-mount must be used together with --docker-run or --docker-compose")
 		}
 		if !doMount {
 			return nil, errcat.User.New("--docker-mount cannot be used with --mount=false")
@@ -499,14 +619,54 @@ func (is *interceptState) createRequest(ctx context.Context) (*connector.CreateI
 	if err != nil {
 		return nil, err
 	}
+	ir.MechanismPlugin, err = is.args.extState.MechanismPlugin(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	ir.AgentImage, err = is.args.extState.AgentImage(ctx)
 	if err != nil {
 		return nil, err
 	}
+
+	if ir.NetworkShaping, err = is.args.networkShaping(); err != nil {
+		return nil, err
+	}
+	if is.args.shapeOutbound {
+		if ir.NetworkShaping == "" {
+			return nil, errcat.User.New("--shape-outbound requires --latency, --jitter, --bandwidth, or --loss")
+		}
+		ir.ShapeOutbound = true
+	}
 	return ir, nil
 }
 
+// networkShaping returns the network conditions that the --latency, --jitter, --bandwidth, and --loss
+// flags ask for, in the format that tunnel.ParseFaults understands, or an empty string when none was given.
+func (a *interceptArgs) networkShaping() (string, error) {
+	var parts []string
+	if a.latency != 0 {
+		parts = append(parts, "latency="+a.latency.String())
+	}
+	if a.jitter != 0 {
+		parts = append(parts, "jitter="+a.jitter.String())
+	}
+	if a.bandwidth != "" {
+		parts = append(parts, "bandwidth="+a.bandwidth)
+	}
+	if a.loss != "" {
+		parts = append(parts, "loss="+a.loss)
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	spec := strings.Join(parts, ",")
+	if _, err := tunnel.ParseFaults(spec); err != nil {
+		return "", errcat.User.New(err)
+	}
+	return spec, nil
+}
+
 func (is *interceptState) getMountPoint() (string, bool, error) {
 	mountPoint := ""
 	doMount, err := strconv.ParseBool(is.args.mount)
@@ -569,8 +729,18 @@ func (is *interceptState) EnsureState(ctx context.Context) (acquired bool, err e
 		}
 	}()
 
+	// A traffic-manager that serves the preview URLs itself doesn't need to know the ingress
+	needsIngress := is.args.previewEnabled && is.args.previewSpec.Ingress == nil
+	if needsIngress {
+		gwDomain, err := previewGatewayDomain(ctx, is.managerClient)
+		if err != nil {
+			return false, err
+		}
+		needsIngress = gwDomain == ""
+	}
+
 	// if any of the ingress flags are present, skip the ingress dialogue and use flag values
-	if is.args.previewEnabled && is.args.previewSpec.Ingress == nil && (is.args.ingressHost != "" || is.args.ingressPort != 0 || is.args.ingressTLS || is.args.ingressL5 != "") {
+	if needsIngress && (is.args.ingressHost != "" || is.args.ingressPort != 0 || is.args.ingressTLS || is.args.ingressL5 != "") {
 		ingress, err := makeIngressInfo(is.args.ingressHost, is.args.ingressPort, is.args.ingressTLS, is.args.ingressL5)
 		if err != nil {
 			return false, err
@@ -579,7 +749,7 @@ func (is *interceptState) EnsureState(ctx context.Context) (acquired bool, err e
 	}
 
 	// Fill defaults
-	if is.args.previewEnabled && is.args.previewSpec.Ingress == nil {
+	if needsIngress && is.args.previewSpec.Ingress == nil {
 		ingress, err := selectIngress(ctx, is.cmd.InOrStdin(), is.cmd.OutOrStdout(), is.connInfo, is.args.name, is.args.namespace)
 		if err != nil {
 			return false, err
@@ -645,6 +815,7 @@ func (is *interceptState) EnsureState(ctx context.Context) (acquired bool, err e
 		}
 		is.Scout.SetMetadatum("intercept_id", intercept.Id)
 
+		is.intercept = intercept
 		is.env = r.Environment
 		is.env["TELEPRESENCE_INTERCEPT_ID"] = intercept.Id
 		if is.args.envFile != "" {
@@ -693,6 +864,19 @@ func removeIntercept(ctx context.Context, name string) error {
 	})
 }
 
+func transferIntercept(ctx context.Context, name, to string) error {
+	return cliutil.WithStartedConnector(ctx, func(ctx context.Context, connectorClient connector.ConnectorClient) error {
+		r, err := connectorClient.TransferIntercept(dcontext.WithoutCancel(ctx), &manager.TransferInterceptRequest{Name: name, To: to})
+		if err != nil {
+			return err
+		}
+		if r.Error != connector.InterceptError_UNSPECIFIED {
+			return interceptMessage(r)
+		}
+		return nil
+	})
+}
+
 func validateDockerArgs(args []string) error {
 	for _, arg := range args {
 		if arg == "-d" || arg == "--detach" {
@@ -702,26 +886,69 @@ func validateDockerArgs(args []string) error {
 	return nil
 }
 
+// dockerEnvFile returns the environment file given by --env-file, or a temporary environment file
+// that the returned function removes.
+func (is *interceptState) dockerEnvFile() (string, func(), error) {
+	if is.args.envFile != "" {
+		return is.args.envFile, func() {}, nil
+	}
+	file, err := os.CreateTemp("", "tel-*.env")
+	if err != nil {
+		return "", nil, errcat.NoLogs.Newf("failed to create temporary environment file. %w", err)
+	}
+	remove := func() { _ = os.Remove(file.Name()) }
+	if err = is.writeEnvToFileAndClose(file); err != nil {
+		remove()
+		return "", nil, err
+	}
+	return file.Name(), remove, nil
+}
+
+// dockerMountPoint returns the volume mount point in the container, or an empty string when there is
+// no mount.
+func (is *interceptState) dockerMountPoint() string {
+	if is.mountPoint == "" {
+		return ""
+	}
+	if is.args.dockerMount != "" {
+		return is.args.dockerMount
+	}
+	return is.mountPoint
+}
+
 func (is *interceptState) runInDocker(ctx context.Context, cmd safeCobraCommand, args []string) error {
-	envFile := is.args.envFile
-	if envFile == "" {
-		file, err := os.CreateTemp("", "tel-*.env")
-		if err != nil {
-			return errcat.NoLogs.Newf("failed to create temporary environment file. %w", err)
-		}
-		defer os.Remove(file.Name())
+	dockerArgs, cleanup, err := is.dockerRunArgs(ctx, is.clusterDomain(ctx), args)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return proc.Run(ctx, nil, "docker", dockerArgs...)
+}
 
-		if err = is.writeEnvToFileAndClose(file); err != nil {
-			return err
-		}
-		envFile = file.Name()
+// dockerRunArgs returns the "docker run" arguments that start the container, followed by the given
+// arguments. The returned function removes the temporary files that the arguments refer to.
+func (is *interceptState) dockerRunArgs(ctx context.Context, clusterDomain string, args []string) ([]string, func(), error) {
+	envFile, removeEnvFile, err := is.dockerEnvFile()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostNetwork := is.joinsHostNetwork(args)
+	sandboxArgs, removeSandboxFiles, err := is.sandboxArgs(ctx, clusterDomain, hostNetwork)
+	if err != nil {
+		removeEnvFile()
+		return nil, nil, err
+	}
+	cleanup := func() {
+		removeSandboxFiles()
+		removeEnvFile()
 	}
 
 	ourArgs := []string{
 		"run",
-		"--dns-search", "tel2-search",
 		"--env-file", envFile,
 	}
+	ourArgs = append(ourArgs, sandboxArgs...)
 	hasArg := func(s string) bool {
 		for _, arg := range args {
 			if s == arg {
@@ -734,20 +961,14 @@ func (is *interceptState) runInDocker(ctx context.Context, cmd safeCobraCommand,
 		ourArgs = append(ourArgs, "--name", fmt.Sprintf("intercept-%s-%d", is.args.name, is.localPort))
 	}
 
-	if is.dockerPort != 0 {
+	if is.dockerPort != 0 && !hostNetwork {
 		ourArgs = append(ourArgs, "-p", fmt.Sprintf("%d:%d", is.localPort, is.dockerPort))
 	}
 
-	dockerMount := ""
-	if is.mountPoint != "" { // do we have a mount point at all?
-		if dockerMount = is.args.dockerMount; dockerMount == "" {
-			dockerMount = is.mountPoint
-		}
-	}
-	if dockerMount != "" {
+	if dockerMount := is.dockerMountPoint(); dockerMount != "" {
 		ourArgs = append(ourArgs, "-v", fmt.Sprintf("%s:%s", is.mountPoint, dockerMount))
 	}
-	return proc.Run(ctx, nil, "docker", append(ourArgs, args...)...)
+	return append(ourArgs, args...), cleanup, nil
 }
 
 func (is *interceptState) writeEnvFile() error {
@@ -42,7 +42,7 @@ func ClusterIdCommand() *cobra.Command {
 }
 
 func connectCommand() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:  "connect [flags] [-- <command to run while connected>]",
 		Args: cobra.ArbitraryArgs,
 
@@ -58,6 +58,15 @@ func connectCommand() *cobra.Command {
 			})
 		},
 	}
+	cmd.Flags().StringVar(&connectionName, "name", "", ``+
+		`Name of the connection. Use different names to connect to several clusters at the same time `+
+		`(default "`+client.DefaultConnectionName+`")`)
+	cmd.Flags().StringSliceVar(&egressVia, "egress-via", nil, ``+
+		`Route outbound connections through a traffic-agent of a workload, so that they originate from its pod. `+
+		`The rule is on the form [<subnet>=]<workload>[.<namespace>]. The subnet is proxied via the cluster. `+
+		`Without subnet, the rule applies to the connections to the cluster's subnets and the also-proxy subnets `+
+		`that no other rule applies to. Other destinations are not affected. Comma separated list of rules`)
+	return cmd
 }
 
 func dashboardCommand() *cobra.Command {
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dexec"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+// composeFile is the part of a Docker Compose file that Telepresence cares about.
+type composeFile struct {
+	Version  string                 `json:"version,omitempty"`
+	Services map[string]interface{} `json:"services"`
+}
+
+// composeService is the part of a service that Telepresence adds to a Docker Compose file.
+type composeService struct {
+	EnvFile   []string `json:"env_file,omitempty"`
+	Ports     []string `json:"ports,omitempty"`
+	Volumes   []string `json:"volumes,omitempty"`
+	DNSSearch []string `json:"dns_search,omitempty"`
+}
+
+func readComposeFile(path string) (*composeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errcat.User.New(err)
+	}
+	var cf composeFile
+	if err = yaml.Unmarshal(data, &cf); err != nil {
+		return nil, errcat.User.Newf("unable to parse %s: %w", path, err)
+	}
+	if len(cf.Services) == 0 {
+		return nil, errcat.User.Newf("%s doesn't declare any services", path)
+	}
+	return &cf, nil
+}
+
+// composeDNSWarning returns a warning about the resolution of cluster names in the services of a Docker
+// Compose file, or an empty string when they resolve cluster names like the host does. On Linux, Docker's
+// embedded DNS server forwards the queries of the services to the nameservers of the host's resolv.conf,
+// and not to the resolver that Telepresence configures. The services can't join the host network the way
+// a --docker-run container does, because they would no longer reach each other by name.
+func composeDNSWarning() string {
+	if !hostNetworkSupported {
+		return ""
+	}
+	return "Warning: on Linux, the services of a Docker Compose file reach the cluster's IP addresses, but " +
+		"they can't resolve the names of the cluster's services. Use --docker-run to run a container that " +
+		"resolves them"
+}
+
+// composeOverride returns a Docker Compose file that, when combined with the given file, injects the
+// environment file, port, and volume mount into the intercepted service. All services use the DNS
+// search path that Telepresence uses to resolve single label names, so that they reach the cluster
+// the same way as the intercepted service does. See composeDNSWarning for the limitation on Linux.
+func composeOverride(cf *composeFile, service, envFile, port, volume string) *composeFile {
+	override := &composeFile{
+		// Older versions of docker-compose require that all files declare the same version
+		Version:  cf.Version,
+		Services: make(map[string]interface{}, len(cf.Services)),
+	}
+	for name, svc := range cf.Services {
+		cs := &composeService{}
+		// A service that uses the network of another service or the host cannot declare DNS settings.
+		if m, ok := svc.(map[string]interface{}); !ok || m["network_mode"] == nil {
+			cs.DNSSearch = []string{"tel2-search"}
+		}
+		if name == service {
+			cs.EnvFile = []string{envFile}
+			if port != "" {
+				cs.Ports = []string{port}
+			}
+			if volume != "" {
+				cs.Volumes = []string{volume}
+			}
+		}
+		override.Services[name] = cs
+	}
+	return override
+}
+
+var invalidProjectChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// composeProject returns the compose project name of the intercept. Like the name of a --docker-run
+// container, it's derived from the intercept name and the local port.
+func (is *interceptState) composeProject() string {
+	return invalidProjectChars.ReplaceAllString(strings.ToLower(fmt.Sprintf("intercept-%s-%d", is.args.name, is.localPort)), "-")
+}
+
+// composeCommand returns the command that runs Docker Compose. The "docker compose" plugin is
+// preferred over the standalone "docker-compose".
+func composeCommand(ctx context.Context) []string {
+	cmd := dexec.CommandContext(ctx, "docker", "compose", "version")
+	cmd.DisableLogging = true
+	if _, err := cmd.CombinedOutput(); err == nil {
+		return []string{"docker", "compose"}
+	}
+	return []string{"docker-compose"}
+}
+
+// runInCompose runs the services of the --docker-compose file until they end or the context is
+// cancelled, and then tears them down.
+func (is *interceptState) runInCompose(ctx context.Context, args []string) error {
+	cf, err := readComposeFile(is.args.dockerCompose)
+	if err != nil {
+		return err
+	}
+	if _, ok := cf.Services[is.args.composeService]; !ok {
+		names := make([]string, 0, len(cf.Services))
+		for name := range cf.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return errcat.User.Newf("service %q is not declared in %s. Declared services are %s",
+			is.args.composeService, is.args.dockerCompose, strings.Join(names, ", "))
+	}
+
+	envFile, removeEnvFile, err := is.dockerEnvFile()
+	if err != nil {
+		return err
+	}
+	defer removeEnvFile()
+	// Relative paths are relative to the directory of the first compose file
+	if envFile, err = filepath.Abs(envFile); err != nil {
+		return err
+	}
+
+	port := ""
+	if is.dockerPort != 0 {
+		port = fmt.Sprintf("%d:%d", is.localPort, is.dockerPort)
+	}
+	volume := ""
+	if dockerMount := is.dockerMountPoint(); dockerMount != "" {
+		volume = fmt.Sprintf("%s:%s", is.mountPoint, dockerMount)
+	}
+	data, err := yaml.Marshal(composeOverride(cf, is.args.composeService, envFile, port, volume))
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp("", "tel-*.compose.yaml")
+	if err != nil {
+		return errcat.NoLogs.Newf("failed to create temporary compose file. %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errcat.NoLogs.Newf("failed to write temporary compose file. %w", err)
+	}
+
+	if warning := composeDNSWarning(); warning != "" {
+		fmt.Fprintln(is.cmd.ErrOrStderr(), warning)
+	}
+	compose := composeCommand(ctx)
+	run := func(ctx context.Context, args ...string) error {
+		cmdArgs := append([]string{}, compose[1:]...)
+		cmdArgs = append(cmdArgs, "--project-name", is.composeProject(), "--file", is.args.dockerCompose, "--file", file.Name())
+		return proc.Run(ctx, nil, compose[0], append(cmdArgs, args...)...)
+	}
+	defer func() {
+		// The services are torn down even when the intercept was interrupted.
+		if err := run(dcontext.WithoutCancel(ctx), "down"); err != nil {
+			dlog.Errorf(ctx, "unable to tear down the services of %s: %v", is.args.dockerCompose, err)
+		}
+	}()
+	return run(ctx, append([]string{"up"}, args...)...)
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestComposeOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+version: "3.8"
+services:
+  api:
+    image: example/api
+    environment:
+      LOG_LEVEL: debug
+  db:
+    image: postgres
+  proxy:
+    image: example/proxy
+    network_mode: service:api
+`), 0o600))
+	cf, err := readComposeFile(path)
+	require.NoError(t, err)
+
+	data, err := yaml.Marshal(composeOverride(cf, "api", "/tmp/tel-1.env", "8080:80", "/tmp/telfs-1:/data"))
+	require.NoError(t, err)
+	assert.YAMLEq(t, `
+version: "3.8"
+services:
+  api:
+    env_file: [/tmp/tel-1.env]
+    ports: ["8080:80"]
+    volumes: ["/tmp/telfs-1:/data"]
+    dns_search: [tel2-search]
+  db:
+    dns_search: [tel2-search]
+  proxy: {}
+`, string(data))
+
+	require.NoError(t, os.WriteFile(path, []byte("version: \"3.8\"\n"), 0o600))
+	_, err = readComposeFile(path)
+	assert.Error(t, err)
+
+	is := &interceptState{args: interceptArgs{name: "Echo.Server"}, localPort: 8080}
+	assert.Equal(t, "intercept-echo-server-8080", is.composeProject())
+}
+
+func TestComposeDNSWarning(t *testing.T) {
+	defer func(supported bool) { hostNetworkSupported = supported }(hostNetworkSupported)
+
+	// Docker Desktop routes the DNS queries of the services through the host
+	hostNetworkSupported = false
+	assert.Empty(t, composeDNSWarning())
+
+	// On Linux, the services of a bridge network can't resolve cluster names
+	hostNetworkSupported = true
+	assert.Contains(t, composeDNSWarning(), "can't resolve the names")
+}
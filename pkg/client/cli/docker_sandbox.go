@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+// hostNetworkSupported is true when a --docker-run container can join the host network. That's only
+// needed on Linux. Docker Desktop routes the traffic of its containers through the host, and hence
+// through the VIF.
+var hostNetworkSupported = runtime.GOOS == "linux"
+
+const defaultClusterDomain = "cluster.local"
+
+// joinsHostNetwork returns true when the --docker-run container joins the host network so that it
+// reaches the cluster the same way as the host does. It doesn't when the user opted out using
+// --docker-bridge-network, when the container listens on another port than the local port, because such
+// a port must be published, or when the given "docker run" arguments publish ports or declare a network.
+// Docker ignores published ports on the host network.
+func (is *interceptState) joinsHostNetwork(args []string) bool {
+	if !hostNetworkSupported || is.args.dockerBridgeNetwork || is.dockerPort != is.localPort {
+		return false
+	}
+	for _, arg := range args {
+		switch {
+		case arg == "--network" || arg == "--net" || strings.HasPrefix(arg, "--network=") || strings.HasPrefix(arg, "--net="):
+			return false
+		case arg == "--publish" || arg == "--publish-all" || strings.HasPrefix(arg, "--publish="):
+			return false
+		case strings.HasPrefix(arg, "-p") || arg == "-P":
+			return false
+		}
+	}
+	return true
+}
+
+// sandboxArgs returns the "docker run" arguments that make the container assume the identity of the
+// intercepted pod: its hostname, DNS search paths, and service account token. The returned function
+// removes the temporary files that the arguments refer to.
+func (is *interceptState) sandboxArgs(ctx context.Context, clusterDomain string, hostNetwork bool) ([]string, func(), error) {
+	var args []string
+	cleanup := func() {}
+	if hostNetwork {
+		args = append(args, "--network", "host")
+	}
+
+	// The hostname resolves to the loopback address, so that the container reaches itself using it. The
+	// IP of the pod would reach the pod, and not the container.
+	hostname := is.env["TELEPRESENCE_HOSTNAME"]
+	if hostname != "" {
+		args = append(args, "--hostname", hostname, "--add-host", hostname+":127.0.0.1")
+	} else {
+		dlog.Warn(ctx, "the traffic-agent doesn't report the hostname of the pod, so the container keeps its own")
+	}
+
+	searchPaths := sandboxSearchPaths(is.intercept.GetSpec().GetNamespace(), clusterDomain)
+	if hostNetwork {
+		// The container shares the resolver of the host, so only the search paths differ.
+		nameservers, err := hostNameservers()
+		if err != nil {
+			return nil, nil, err
+		}
+		file, err := os.CreateTemp("", "tel-*.resolv.conf")
+		if err != nil {
+			return nil, nil, errcat.NoLogs.Newf("failed to create temporary resolv.conf. %w", err)
+		}
+		cleanup = func() { _ = os.Remove(file.Name()) }
+		_, err = file.WriteString(sandboxResolvConf(nameservers, searchPaths))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, errcat.NoLogs.Newf("failed to write temporary resolv.conf. %w", err)
+		}
+		args = append(args, "-v", file.Name()+":/etc/resolv.conf:ro")
+	} else {
+		for _, sp := range searchPaths {
+			args = append(args, "--dns-search", sp)
+		}
+		args = append(args, "--dns-option", "ndots:5")
+	}
+
+	// Secrets, such as the projected service account token, are mounted where the pod has them.
+	if is.mountPoint != "" {
+		for _, mount := range strings.Split(is.env["TELEPRESENCE_MOUNTS"], ":") {
+			if strings.HasPrefix(mount, "/var/run/secrets/") {
+				args = append(args, "-v", fmt.Sprintf("%s:%s:ro", filepath.Join(is.mountPoint, filepath.FromSlash(mount)), mount))
+			}
+		}
+	}
+	return args, cleanup, nil
+}
+
+// clusterDomain returns the cluster domain declared by the traffic-manager, without a trailing dot.
+func (is *interceptState) clusterDomain(ctx context.Context) string {
+	tCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	infoStream, err := is.managerClient.WatchClusterInfo(tCtx, is.connInfo.SessionInfo)
+	if err == nil {
+		var mgrInfo *manager.ClusterInfo
+		if mgrInfo, err = infoStream.Recv(); err == nil {
+			if cd := strings.TrimSuffix(mgrInfo.ClusterDomain, "."); cd != "" {
+				return cd
+			}
+			return defaultClusterDomain
+		}
+	}
+	dlog.Warnf(ctx, "unable to get the cluster domain from the traffic-manager, using %q: %v", defaultClusterDomain, err)
+	return defaultClusterDomain
+}
+
+// sandboxSearchPaths returns the DNS search paths of a pod in the given namespace, followed by the
+// search path that Telepresence uses to resolve single label names.
+func sandboxSearchPaths(namespace, clusterDomain string) []string {
+	var paths []string
+	if namespace != "" {
+		paths = append(paths, namespace+".svc."+clusterDomain)
+	}
+	return append(paths, "svc."+clusterDomain, clusterDomain, "tel2-search")
+}
+
+// sandboxResolvConf returns the contents of a resolv.conf that uses the given nameservers and
+// search paths, and the same ndots option as a pod.
+func sandboxResolvConf(nameservers, searchPaths []string) string {
+	sb := strings.Builder{}
+	for _, ns := range nameservers {
+		fmt.Fprintf(&sb, "nameserver %s\n", ns)
+	}
+	fmt.Fprintf(&sb, "search %s\n", strings.Join(searchPaths, " "))
+	sb.WriteString("options ndots:5\n")
+	return sb.String()
+}
+
+// hostNameservers returns the nameservers declared in the host's /etc/resolv.conf.
+func hostNameservers() ([]string, error) {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil, errcat.NoLogs.Newf("failed to read /etc/resolv.conf. %w", err)
+	}
+	defer file.Close()
+	var nameservers []string
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		if fields := strings.Fields(sc.Text()); len(fields) >= 2 && fields[0] == "nameserver" {
+			nameservers = append(nameservers, fields[1])
+		}
+	}
+	return nameservers, sc.Err()
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestSandboxResolvConf(t *testing.T) {
+	searchPaths := sandboxSearchPaths("team-a", "cluster.local")
+	assert.Equal(t, []string{"team-a.svc.cluster.local", "svc.cluster.local", "cluster.local", "tel2-search"}, searchPaths)
+	assert.Equal(t, []string{"svc.example.org", "example.org", "tel2-search"}, sandboxSearchPaths("", "example.org"))
+
+	assert.Equal(t, ""+
+		"nameserver 127.0.0.53\n"+
+		"search team-a.svc.cluster.local svc.cluster.local cluster.local tel2-search\n"+
+		"options ndots:5\n",
+		sandboxResolvConf([]string{"127.0.0.53"}, searchPaths))
+}
+
+func TestDockerRunArgs(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	defer func(supported bool) { hostNetworkSupported = supported }(hostNetworkSupported)
+
+	const token = "/var/run/secrets/kubernetes.io/serviceaccount"
+	mountPoint := filepath.Join(t.TempDir(), "telfs")
+	newState := func(dockerPort uint16) *interceptState {
+		return &interceptState{
+			args: interceptArgs{name: "echo"},
+			env: map[string]string{
+				"LOG_LEVEL":             "debug",
+				"TELEPRESENCE_HOSTNAME": "echo-7f6c9",
+				"TELEPRESENCE_MOUNTS":   token + ":/data",
+			},
+			intercept:  &manager.InterceptInfo{Spec: &manager.InterceptSpec{Namespace: "team-a"}},
+			mountPoint: mountPoint,
+			localPort:  8080,
+			dockerPort: dockerPort,
+		}
+	}
+
+	// argValue returns the value that follows the given flag in args.
+	argValue := func(args []string, flag string) string {
+		for i, arg := range args {
+			if arg == flag && i+1 < len(args) {
+				return args[i+1]
+			}
+		}
+		return ""
+	}
+
+	t.Run("bridge network", func(t *testing.T) {
+		hostNetworkSupported = false
+		args, cleanup, err := newState(80).dockerRunArgs(ctx, "example.org", []string{"--rm", "example/echo"})
+		require.NoError(t, err)
+		envFile := argValue(args, "--env-file")
+		require.NotEmpty(t, envFile)
+
+		// The container gets the environment of the pod.
+		env, err := os.ReadFile(envFile)
+		require.NoError(t, err)
+		assert.Equal(t, ""+
+			"LOG_LEVEL=debug\n"+
+			"TELEPRESENCE_HOSTNAME=echo-7f6c9\n"+
+			"TELEPRESENCE_MOUNTS="+token+":/data\n",
+			string(env))
+
+		assert.Equal(t, []string{
+			"run",
+			"--env-file", envFile,
+			"--hostname", "echo-7f6c9",
+			"--add-host", "echo-7f6c9:127.0.0.1",
+			"--dns-search", "team-a.svc.example.org",
+			"--dns-search", "svc.example.org",
+			"--dns-search", "example.org",
+			"--dns-search", "tel2-search",
+			"--dns-option", "ndots:5",
+			"-v", filepath.Join(mountPoint, filepath.FromSlash(token)) + ":" + token + ":ro",
+			"--name", "intercept-echo-8080",
+			"-p", "8080:80",
+			"-v", mountPoint + ":" + mountPoint,
+			"--rm", "example/echo",
+		}, args)
+
+		cleanup()
+		_, err = os.Stat(envFile)
+		assert.True(t, os.IsNotExist(err), "the environment file is not removed")
+	})
+
+	t.Run("host network", func(t *testing.T) {
+		hostNetworkSupported = true
+		if _, err := os.Stat("/etc/resolv.conf"); err != nil {
+			t.Skip("the host has no /etc/resolv.conf")
+		}
+		args, cleanup, err := newState(8080).dockerRunArgs(ctx, "example.org", []string{"--name", "echo", "example/echo"})
+		require.NoError(t, err)
+		defer cleanup()
+
+		assert.Equal(t, "host", argValue(args, "--network"))
+		assert.Equal(t, "echo-7f6c9", argValue(args, "--hostname"))
+		assert.Equal(t, "echo-7f6c9:127.0.0.1", argValue(args, "--add-host"))
+		assert.NotContains(t, args, "-p", "ports can't be published on the host network")
+		assert.NotContains(t, args, "--dns-search")
+		assert.NotContains(t, args, "intercept-echo-8080")
+
+		// The container uses the resolver of the host with the search paths of the pod.
+		var resolvConf string
+		for _, arg := range args {
+			if strings.HasSuffix(arg, ":/etc/resolv.conf:ro") {
+				resolvConf = strings.TrimSuffix(arg, ":/etc/resolv.conf:ro")
+			}
+		}
+		require.NotEmpty(t, resolvConf)
+		data, err := os.ReadFile(resolvConf)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "search team-a.svc.example.org svc.example.org example.org tel2-search\n")
+	})
+
+	t.Run("declared network", func(t *testing.T) {
+		hostNetworkSupported = true
+		is := newState(8080)
+		assert.True(t, is.joinsHostNetwork([]string{"--rm", "example/echo"}))
+		assert.False(t, is.joinsHostNetwork([]string{"--network", "dev", "example/echo"}))
+		assert.False(t, is.joinsHostNetwork([]string{"--net=dev", "example/echo"}))
+		assert.False(t, newState(80).joinsHostNetwork([]string{"example/echo"}), "a port that differs from the local port must be published")
+	})
+
+	t.Run("published ports", func(t *testing.T) {
+		hostNetworkSupported = true
+		is := newState(8080)
+		assert.False(t, is.joinsHostNetwork([]string{"-p", "9000:9000", "example/echo"}))
+		assert.False(t, is.joinsHostNetwork([]string{"-p9000:9000", "example/echo"}))
+		assert.False(t, is.joinsHostNetwork([]string{"--publish=9000:9000", "example/echo"}))
+		assert.False(t, is.joinsHostNetwork([]string{"-P", "example/echo"}))
+		assert.False(t, is.joinsHostNetwork([]string{"--publish-all", "example/echo"}))
+
+		args, cleanup, err := is.dockerRunArgs(ctx, "example.org", []string{"-p", "9000:9000", "example/echo"})
+		require.NoError(t, err)
+		defer cleanup()
+		assert.NotContains(t, args, "host")
+		assert.Equal(t, "8080:8080", argValue(args, "-p"))
+	})
+
+	t.Run("bridge network opt-out", func(t *testing.T) {
+		hostNetworkSupported = true
+		is := newState(8080)
+		is.args.dockerBridgeNetwork = true
+		assert.False(t, is.joinsHostNetwork([]string{"--rm", "example/echo"}))
+	})
+
+	t.Run("no hostname", func(t *testing.T) {
+		hostNetworkSupported = false
+		is := newState(8080)
+		delete(is.env, "TELEPRESENCE_HOSTNAME")
+		args, cleanup, err := is.dockerRunArgs(ctx, "example.org", nil)
+		require.NoError(t, err)
+		defer cleanup()
+		assert.NotContains(t, args, "--hostname")
+		assert.NotContains(t, args, "--add-host")
+	})
+}
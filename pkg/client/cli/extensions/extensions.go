@@ -15,17 +15,24 @@ import (
 	"github.com/spf13/pflag"
 	"sigs.k8s.io/yaml"
 
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
 )
 
+// policyExtensionFile is the pseudo filename of the extensions of the client policy.
+const policyExtensionFile = "<client policy>"
+
 type ExtensionsState struct {
 	// Data that is static after initialization
 	ext2file map[string]string        // initialized in step 1
 	exts     map[string]ExtensionInfo // initialized in step 2
 	mech2ext map[string]string        // initialized in step 3
+	policy   *manager.ClientPolicy    // initialized in step 1
 
 	// Stateful data
 	flags           *pflag.FlagSet // initialized in step 4
@@ -51,7 +58,11 @@ type ExtensionsState struct {
 // The basename of the extension YAML filename (i.e. the non-directory part, with the ".yml" suffix
 // removed) identifies the name of the extension.  The content of the extension YAML file must be an
 // ExtensionInfo object serialized as YAML.  See the docs for ExtensionInfo for more information.
-func LoadExtensions(ctx context.Context, existingFlags *pflag.FlagSet) (es *ExtensionsState, err error) {
+//
+// The extensions of the client policy that the traffic-manager of the given connection published
+// when the connector last connected have the lowest precedence.  They cannot declare a plugin.  An
+// empty connection name denotes the connection with the default name.
+func LoadExtensions(ctx context.Context, connectionName string, existingFlags *pflag.FlagSet) (es *ExtensionsState, err error) {
 	defer func() {
 		// Consider all errors issued here to belong to the Config category.
 		if err != nil {
@@ -104,9 +115,27 @@ func LoadExtensions(ctx context.Context, existingFlags *pflag.FlagSet) (es *Exte
 			es.ext2file[extname] = filepath.Join(dir, "extensions", fileinfo.Name())
 		}
 	}
+	if es.policy, err = cache.LoadClientPolicyFromUserCache(ctx, connectionName); err != nil {
+		return nil, err
+	}
+	for extname := range es.policy.GetExtensions() {
+		if _, masked := es.ext2file[extname]; !masked {
+			es.ext2file[extname] = policyExtensionFile
+		}
+	}
 
 	// 2. Load selected files (es.exts) ////////////////////////////////////
 
+	// Files that were added from a remote source must match their pinned digest.
+	reg, err := loadRegistry(filepath.Join(userDir, "extensions"))
+	if err != nil {
+		return nil, err
+	}
+	pinned := make(map[string]*RegistryEntry, len(reg.Extensions))
+	for extname, entry := range reg.Extensions {
+		pinned[filepath.Join(userDir, "extensions", extname+".yml")] = entry
+	}
+
 	// Do this in a deterministic order, so that any error message is consistent.
 	extnames := make([]string, 0, len(es.ext2file))
 	for extname := range es.ext2file {
@@ -118,11 +147,26 @@ func LoadExtensions(ctx context.Context, existingFlags *pflag.FlagSet) (es *Exte
 			continue
 		}
 		filename := es.ext2file[extname]
+		if filename == policyExtensionFile {
+			var extdata ExtensionInfo
+			if err := yaml.UnmarshalStrict([]byte(es.policy.Extensions[extname]), &extdata); err != nil {
+				return nil, fmt.Errorf("%q of %s: %w", extname, filename, err)
+			}
+			if extdata.Plugin != "" {
+				return nil, fmt.Errorf("%q of %s: extensions of the client policy cannot declare a plugin", extname, filename)
+			}
+			es.exts[extname] = extdata
+			continue
+		}
 
 		bs, err := os.ReadFile(filename)
 		if err != nil {
 			return nil, err
 		}
+		if entry, ok := pinned[filename]; ok && digestOf(bs) != entry.Digest {
+			return nil, fmt.Errorf("%q: digest %s doesn't match the pinned digest %s; use \"telepresence extensions update %s\" to restore it",
+				filename, digestOf(bs), entry.Digest, extname)
+		}
 		var extdata ExtensionInfo
 		if err := yaml.UnmarshalStrict(bs, &extdata); err != nil {
 			return nil, fmt.Errorf("%q: %w", filename, err)
@@ -210,12 +254,38 @@ func LoadExtensions(ctx context.Context, existingFlags *pflag.FlagSet) (es *Exte
 	return es, nil
 }
 
+// LoadClientPolicy replaces the client policy with the one that the traffic-manager of the given
+// connection published. It's used when the connection isn't known until after the extensions have
+// been loaded. The extensions remain those of the policy that LoadExtensions loaded, since the flags
+// of their mechanisms have already been declared.
+func (es *ExtensionsState) LoadClientPolicy(ctx context.Context, connectionName string) (err error) {
+	if es.policy, err = cache.LoadClientPolicyFromUserCache(ctx, connectionName); err != nil {
+		return errcat.Config.New(err)
+	}
+	// The default mechanism and the agent image may be declared by the policy.
+	if flag := es.flags.Lookup("mechanism"); !flag.Changed {
+		mechname := es.defaultMechanism(ctx)
+		flag.DefValue = mechname
+		if err = flag.Value.Set(mechname); err != nil {
+			return err
+		}
+	}
+	es.cachedMechanism.Mech, es.cachedMechanism.Err = "", nil
+	es.cachedImage.Image, es.cachedImage.Err = "", nil
+	return nil
+}
+
 func (es *ExtensionsState) defaultMechanism(ctx context.Context) string {
 	type prefData struct {
 		preference int
 		name       string
 	}
 	canAPIKey := cliutil.HasLoggedIn(ctx)
+	if mechname := es.policy.GetDefaultMechanism(); mechname != "" {
+		if extname, ok := es.mech2ext[mechname]; ok && (canAPIKey || !es.exts[extname].RequiresAPIKeyOrLicense) {
+			return mechname
+		}
+	}
 	var preferences []prefData
 	for _, extdata := range es.exts {
 		if extdata.RequiresAPIKeyOrLicense && !canAPIKey {
@@ -306,11 +376,17 @@ func urlSchemeIsOneOf(urlStr string, schemes ...string) bool {
 }
 
 // AgentImage returns the repository/name combination that will be assigned to the container
-// image attribute.
+// image attribute. The images.agentImage of the config.yml takes precedence over the preferred image
+// of the client policy, which in turn takes precedence over the image of the extension. The image
+// must be allowed by the client policy.
 func (es *ExtensionsState) AgentImage(ctx context.Context) (string, error) {
 	cfg := client.GetConfig(ctx)
-	if cfg.Images.AgentImage != "" {
-		return fmt.Sprintf("%s/%s", cfg.Images.Registry, cfg.Images.AgentImage), nil
+	if image := cfg.AgentImage(es.policy); image != "" {
+		if cfg.Images.AgentImage != "" && !install.AgentImageAllowed(es.policy, image) {
+			return "", errcat.Config.Newf("images.agentImage %s in %s is not allowed by the traffic-manager's client policy; allowed images are %v",
+				image, client.GetConfigFile(ctx), es.policy.AllowedAgentImages)
+		}
+		return image, nil
 	}
 	if es.cachedImage.Image != "" || es.cachedImage.Err != nil {
 		return es.cachedImage.Image, es.cachedImage.Err
@@ -358,10 +434,30 @@ func (es *ExtensionsState) AgentImage(ctx context.Context) (string, error) {
 			return "", err
 		}
 	}
+	if !install.AgentImageAllowed(es.policy, image) {
+		es.cachedImage.Err = errcat.Config.Newf("the agent image %s of mechanism %s is not allowed by the traffic-manager's client policy; allowed images are %v",
+			image, mechname, es.policy.AllowedAgentImages)
+		return "", es.cachedImage.Err
+	}
 	es.cachedImage.Image = image
 	return image, nil
 }
 
+// MechanismPlugin returns the absolute path of the plugin executable of the selected mechanism, or
+// an empty string if its extension doesn't declare a plugin.
+func (es *ExtensionsState) MechanismPlugin(ctx context.Context) (string, error) {
+	mechname, err := es.Mechanism()
+	if err != nil {
+		return "", err
+	}
+	extname := es.mech2ext[mechname]
+	plugin := os.Expand(es.exts[extname].Plugin, client.GetEnv(ctx).Get)
+	if plugin == "" || filepath.IsAbs(plugin) {
+		return plugin, nil
+	}
+	return filepath.Join(filepath.Dir(es.ext2file[extname]), plugin), nil
+}
+
 func (es *ExtensionsState) MechanismArgs() ([]string, error) {
 	mechname, err := es.Mechanism()
 	if err != nil {
@@ -397,6 +493,15 @@ type ExtensionInfo struct {
 	// Mechanisms describes the mechanisms that the agent sidecar image supports.  The keys in
 	// the map are the mechanism names.
 	Mechanisms map[string]MechanismInfo `json:"mechanisms"`
+	// Plugin is the path of a local executable that implements the
+	// `telepresence.extension.MechanismPlugin` gRPC service (see rpc/extension/plugin.proto).
+	// The connector runs the plugin for the lifetime of each intercept that uses one of the
+	// extension's mechanisms.  The plugin validates and describes the mechanism args, and may
+	// start client-side services, such as a local proxy, before the intercept becomes ACTIVE.
+	//
+	// A relative path is relative to the directory of the extension YAML file.  The path has
+	// environment variables expanded via os.Expand.  This field is optional.
+	Plugin string `json:"plugin,omitempty"`
 }
 
 // MechanismInfo is the information about a mechanism in ExtensionInfo.
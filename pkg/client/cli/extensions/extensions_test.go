@@ -0,0 +1,91 @@
+package extensions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
+)
+
+func TestClientPolicyExtensions(t *testing.T) {
+	ctx := testRegistryContext(t, "")
+	require.NoError(t, cache.SaveClientPolicyToUserCache(ctx, "", &manager.ClientPolicy{
+		PreferredAgentImage: "example.com/color-agent:1.1",
+		AllowedAgentImages:  []string{"example.com/color-agent:*"},
+		Extensions:          map[string]string{"color": colorExtension},
+		DefaultMechanism:    "color",
+	}))
+
+	es, err := LoadExtensions(ctx, "", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	require.NoError(t, err)
+	assert.Equal(t, policyExtensionFile, es.ext2file["color"])
+	mech, err := es.Mechanism()
+	require.NoError(t, err)
+	assert.Equal(t, "color", mech)
+	image, err := es.AgentImage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/color-agent:1.1", image)
+
+	// A local override must be allowed by the policy
+	cfg := *client.GetConfig(ctx)
+	cfg.Images.AgentImage = "tel2:2.4.9"
+	_, err = es.AgentImage(client.WithConfig(ctx, &cfg))
+	assert.Error(t, err)
+
+	// Extension files take precedence over the extensions of the policy
+	dir, err := extensionsDir(ctx)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "color.yml"), []byte(colorExtension+"  shade: {}\n"), 0o644))
+	es, err = LoadExtensions(ctx, "", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	require.NoError(t, err)
+	assert.Contains(t, es.mech2ext, "shade")
+
+	// The extensions of a policy cannot run plugins on the client
+	require.NoError(t, os.Remove(filepath.Join(dir, "color.yml")))
+	require.NoError(t, cache.SaveClientPolicyToUserCache(ctx, "", &manager.ClientPolicy{
+		Extensions: map[string]string{"color": "plugin: /bin/sh\n" + colorExtension},
+	}))
+	_, err = LoadExtensions(ctx, "", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	assert.Error(t, err)
+}
+
+func TestClientPolicyPerConnection(t *testing.T) {
+	ctx := testRegistryContext(t, "")
+	require.NoError(t, cache.SaveClientPolicyToUserCache(ctx, "staging", &manager.ClientPolicy{
+		Extensions:       map[string]string{"color": colorExtension},
+		DefaultMechanism: "color",
+	}))
+
+	// The policy of one connection doesn't apply to another.
+	es, err := LoadExtensions(ctx, "", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	require.NoError(t, err)
+	assert.NotContains(t, es.ext2file, "color")
+
+	es, err = LoadExtensions(ctx, "staging", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	require.NoError(t, err)
+	mech, err := es.Mechanism()
+	require.NoError(t, err)
+	assert.Equal(t, "color", mech)
+
+	// A policy can be selected after the extensions have been loaded.
+	require.NoError(t, cache.SaveClientPolicyToUserCache(ctx, "", &manager.ClientPolicy{
+		Extensions: map[string]string{"color": colorExtension},
+	}))
+	es, err = LoadExtensions(ctx, "", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	require.NoError(t, err)
+	mech, err = es.Mechanism()
+	require.NoError(t, err)
+	assert.NotEqual(t, "color", mech)
+	require.NoError(t, es.LoadClientPolicy(ctx, "staging"))
+	mech, err = es.Mechanism()
+	require.NoError(t, err)
+	assert.Equal(t, "color", mech)
+}
@@ -0,0 +1,354 @@
+package extensions
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+// The extension registry keeps track of the extension YAML files that have been added to the
+// "extensions/" subdirectory of filelocation.AppUserConfigDir from a remote source.  Each such file is
+// pinned by the digest of its content in the ".registry.yml" file of that directory, which
+// LoadExtensions ignores because its name begins with ".".  LoadExtensions refuses to load a file
+// that no longer matches its digest.
+const registryFile = ".registry.yml"
+
+// RegistryEntry describes an extension file that was added from a remote source.
+type RegistryEntry struct {
+	Name string `json:"-"`
+	// Source is the URL or path that the file was fetched from.
+	Source string `json:"source"`
+	// Digest is the "sha256:<hex>" digest that the file is pinned to.
+	Digest string `json:"digest"`
+	// Signed is true if the file's signature was verified.
+	Signed bool `json:"signed,omitempty"`
+	// Modified is true if the file doesn't match its digest.
+	Modified bool `json:"-"`
+}
+
+type registry struct {
+	Extensions map[string]*RegistryEntry `json:"extensions"`
+}
+
+var validExtensionName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func extensionsDir(ctx context.Context) (string, error) {
+	userDir, err := filelocation.AppUserConfigDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, "extensions"), nil
+}
+
+func loadRegistry(dir string) (*registry, error) {
+	reg := &registry{Extensions: make(map[string]*RegistryEntry)}
+	bs, err := os.ReadFile(filepath.Join(dir, registryFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return reg, nil
+		}
+		return nil, err
+	}
+	if err = yaml.UnmarshalStrict(bs, reg); err != nil {
+		return nil, fmt.Errorf("%q: %w", filepath.Join(dir, registryFile), err)
+	}
+	if reg.Extensions == nil {
+		reg.Extensions = make(map[string]*RegistryEntry)
+	}
+	for name, entry := range reg.Extensions {
+		entry.Name = name
+	}
+	return reg, nil
+}
+
+func (reg *registry) save(dir string) error {
+	bs, err := yaml.Marshal(reg)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, registryFile), bs)
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// matchesDigest returns true if the content of the given file matches the pinned digest.
+func (e *RegistryEntry) matchesDigest(filename string) bool {
+	bs, err := os.ReadFile(filename)
+	return err == nil && digestOf(bs) == e.Digest
+}
+
+// isLocalPath returns true if the given source is a local path rather than a URL. A single letter
+// scheme is a Windows drive letter.
+func isLocalPath(source string) bool {
+	u, err := url.Parse(source)
+	return err != nil || len(u.Scheme) <= 1
+}
+
+// fetch returns the content of the given http:// or https:// URL, file:// URL, or local path.
+func fetch(ctx context.Context, source string) ([]byte, error) {
+	if isLocalPath(source) {
+		return os.ReadFile(source)
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return os.ReadFile(filepath.FromSlash(u.Path))
+	case "http", "https":
+	default:
+		return nil, errcat.User.Newf("unsupported URL scheme %q", u.Scheme)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errcat.NoLogs.Newf("%s returned HTTP %v", source, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// publicKey returns the public key that extension files must be signed with, or nil if signatures
+// aren't required.
+func publicKey(ctx context.Context) (ed25519.PublicKey, error) {
+	keyPEM := client.GetConfig(ctx).Extensions.PublicKey
+	if keyPEM == "" {
+		return nil, nil
+	}
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errcat.Config.New("extensions.publicKey is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errcat.Config.Newf("extensions.publicKey: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errcat.Config.Newf("extensions.publicKey must be an ed25519 key, not %T", key)
+	}
+	return edKey, nil
+}
+
+// verifySignature verifies the signature of an extension file when a public key is configured. The
+// signature is fetched from the source URL with a ".sig" suffix, and must contain the base64 encoded
+// ed25519 signature of the file. It returns true if the signature was verified.
+func verifySignature(ctx context.Context, source string, data []byte) (bool, error) {
+	key, err := publicKey(ctx)
+	if err != nil || key == nil {
+		return false, err
+	}
+	sigData, err := fetch(ctx, source+".sig")
+	if err != nil {
+		return false, errcat.User.Newf("unable to fetch the signature of %s: %w", source, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil {
+		return false, errcat.User.Newf("the signature of %s is not base64 encoded: %w", source, err)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return false, errcat.User.Newf("the signature of %s doesn't match extensions.publicKey", source)
+	}
+	return true, nil
+}
+
+// fetchExtension fetches, verifies, and validates the extension file of the given entry, and
+// returns its content.
+func fetchExtension(ctx context.Context, e *RegistryEntry, digest string) ([]byte, error) {
+	data, err := fetch(ctx, e.Source)
+	if err != nil {
+		return nil, err
+	}
+	if digest != "" && digestOf(data) != digest {
+		return nil, errcat.User.Newf("digest %s of %s doesn't match %s", digestOf(data), e.Source, digest)
+	}
+	if e.Signed, err = verifySignature(ctx, e.Source, data); err != nil {
+		return nil, err
+	}
+	var ext ExtensionInfo
+	if err = yaml.UnmarshalStrict(data, &ext); err != nil {
+		return nil, errcat.User.Newf("%s is not a valid extension file: %w", e.Source, err)
+	}
+	if len(ext.Mechanisms) == 0 {
+		return nil, errcat.User.Newf("%s doesn't declare any mechanisms", e.Source)
+	}
+	e.Digest = digestOf(data)
+	return data, nil
+}
+
+// AddExtension fetches an extension file from the given source and adds it to the user's extension
+// directory, pinned by its digest.  The name defaults to the basename of the source without its
+// ".yml" or ".yaml" suffix.  When a digest is given, the fetched file must match it.
+func AddExtension(ctx context.Context, name, source, digest string) (*RegistryEntry, error) {
+	if name == "" {
+		base := source
+		if u, err := url.Parse(source); err == nil && u.Path != "" {
+			base = u.Path
+		}
+		name = path.Base(filepath.ToSlash(base))
+		for _, sfx := range []string{".yml", ".yaml"} {
+			name = strings.TrimSuffix(name, sfx)
+		}
+	}
+	if !validExtensionName.MatchString(name) {
+		return nil, errcat.User.Newf("invalid extension name %q", name)
+	}
+	dir, err := extensionsDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := loadRegistry(dir)
+	if err != nil {
+		return nil, errcat.Config.New(err)
+	}
+	if _, ok := reg.Extensions[name]; ok {
+		return nil, errcat.User.Newf("extension %q has already been added; use \"telepresence extensions update %s\" to update it", name, name)
+	}
+	filename := filepath.Join(dir, name+".yml")
+	if _, err := os.Stat(filename); err == nil {
+		return nil, errcat.User.Newf("extension file %q already exists", filename)
+	}
+
+	if isLocalPath(source) {
+		// Updates must find a local file regardless of the current directory
+		if source, err = filepath.Abs(source); err != nil {
+			return nil, err
+		}
+	}
+	e := &RegistryEntry{Name: name, Source: source}
+	data, err := fetchExtension(ctx, e, digest)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err = writeFileAtomic(filename, data); err != nil {
+		return nil, err
+	}
+	reg.Extensions[name] = e
+	if err = reg.save(dir); err != nil {
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	return e, nil
+}
+
+// UpdateExtension fetches the extension file with the given name from its source again, and pins it
+// to the new digest.  It returns the previous digest along with the updated entry.
+func UpdateExtension(ctx context.Context, name string) (string, *RegistryEntry, error) {
+	dir, err := extensionsDir(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	reg, err := loadRegistry(dir)
+	if err != nil {
+		return "", nil, errcat.Config.New(err)
+	}
+	e, ok := reg.Extensions[name]
+	if !ok {
+		return "", nil, errcat.User.Newf("extension %q has not been added", name)
+	}
+	prev := e.Digest
+	updated := *e
+	data, err := fetchExtension(ctx, &updated, "")
+	if err != nil {
+		return "", nil, err
+	}
+	if err = writeFileAtomic(filepath.Join(dir, name+".yml"), data); err != nil {
+		return "", nil, err
+	}
+	reg.Extensions[name] = &updated
+	if err = reg.save(dir); err != nil {
+		return "", nil, err
+	}
+	return prev, &updated, nil
+}
+
+// RemoveExtension removes an extension file that was added from a remote source.
+func RemoveExtension(ctx context.Context, name string) error {
+	dir, err := extensionsDir(ctx)
+	if err != nil {
+		return err
+	}
+	reg, err := loadRegistry(dir)
+	if err != nil {
+		return errcat.Config.New(err)
+	}
+	if _, ok := reg.Extensions[name]; !ok {
+		return errcat.User.Newf("extension %q has not been added", name)
+	}
+	if err = os.Remove(filepath.Join(dir, name+".yml")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	delete(reg.Extensions, name)
+	return reg.save(dir)
+}
+
+// ListExtensions returns the extension files that were added from a remote source, sorted by name.
+func ListExtensions(ctx context.Context) ([]*RegistryEntry, error) {
+	dir, err := extensionsDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := loadRegistry(dir)
+	if err != nil {
+		return nil, errcat.Config.New(err)
+	}
+	entries := make([]*RegistryEntry, 0, len(reg.Extensions))
+	for _, e := range reg.Extensions {
+		e.Modified = !e.matchesDigest(filepath.Join(dir, e.Name+".yml"))
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
@@ -0,0 +1,133 @@
+package extensions
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+const colorExtension = `image: example.com/color-agent:1.0
+mechanisms:
+  color:
+    flags:
+      name:
+        type: string
+`
+
+func testRegistryContext(t *testing.T, publicKey string) context.Context {
+	ctx := dlog.NewTestContext(t, false)
+	tmp := t.TempDir()
+	ctx = filelocation.WithUserHomeDir(ctx, tmp)
+	ctx = filelocation.WithAppUserConfigDir(ctx, filepath.Join(tmp, "config"))
+	ctx = filelocation.WithAppSystemConfigDirs(ctx, nil)
+	env, err := client.LoadEnv(ctx)
+	require.NoError(t, err)
+	ctx = client.WithEnv(ctx, env)
+	cfg := client.GetDefaultConfig(ctx)
+	cfg.Extensions.PublicKey = publicKey
+	return client.WithConfig(ctx, &cfg)
+}
+
+func TestRegistry(t *testing.T) {
+	files := map[string]string{"/color.yml": colorExtension}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+	ctx := testRegistryContext(t, "")
+
+	_, err := AddExtension(ctx, "", srv.URL+"/color.yml", "sha256:0000")
+	assert.Error(t, err, "the digest doesn't match")
+	_, err = AddExtension(ctx, "", srv.URL+"/missing.yml", "")
+	assert.Error(t, err)
+
+	e, err := AddExtension(ctx, "", srv.URL+"/color.yml", digestOf([]byte(colorExtension)))
+	require.NoError(t, err)
+	assert.Equal(t, "color", e.Name)
+	assert.False(t, e.Signed)
+	_, err = AddExtension(ctx, "", srv.URL+"/color.yml", "")
+	assert.Error(t, err, "the extension has already been added")
+
+	es, err := LoadExtensions(ctx, "", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	require.NoError(t, err)
+	assert.Contains(t, es.mech2ext, "color")
+
+	// A modified file is listed as such, and isn't loaded
+	dir, err := extensionsDir(ctx)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "color.yml"), []byte(colorExtension+"  other: {}\n"), 0o644))
+	entries, err := ListExtensions(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, entries[0].Modified)
+	_, err = LoadExtensions(ctx, "", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	assert.Error(t, err)
+
+	// Updating restores the file and pins a new digest when the source has changed
+	files["/color.yml"] = colorExtension + "  shade: {}\n"
+	prev, e, err := UpdateExtension(ctx, "color")
+	require.NoError(t, err)
+	assert.Equal(t, digestOf([]byte(colorExtension)), prev)
+	assert.Equal(t, digestOf([]byte(files["/color.yml"])), e.Digest)
+	entries, err = ListExtensions(ctx)
+	require.NoError(t, err)
+	assert.False(t, entries[0].Modified)
+	es, err = LoadExtensions(ctx, "", pflag.NewFlagSet("intercept", pflag.ContinueOnError))
+	require.NoError(t, err)
+	assert.Contains(t, es.mech2ext, "shade")
+
+	require.NoError(t, RemoveExtension(ctx, "color"))
+	assert.NoFileExists(t, filepath.Join(dir, "color.yml"))
+	entries, err = ListExtensions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Error(t, RemoveExtension(ctx, "color"))
+}
+
+func TestRegistrySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	ctx := testRegistryContext(t, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+
+	src := t.TempDir()
+	signed := filepath.Join(src, "signed.yml")
+	require.NoError(t, os.WriteFile(signed, []byte(colorExtension), 0o644))
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(colorExtension)))
+	require.NoError(t, os.WriteFile(signed+".sig", []byte(sig), 0o644))
+	unsigned := filepath.Join(src, "unsigned.yml")
+	require.NoError(t, os.WriteFile(unsigned, []byte(colorExtension), 0o644))
+	forged := filepath.Join(src, "forged.yml")
+	require.NoError(t, os.WriteFile(forged, []byte(colorExtension+"  shade: {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(forged+".sig", []byte(sig), 0o644))
+
+	e, err := AddExtension(ctx, "", signed, "")
+	require.NoError(t, err)
+	assert.True(t, e.Signed)
+	_, err = AddExtension(ctx, "", unsigned, "")
+	assert.Error(t, err)
+	_, err = AddExtension(ctx, "", forged, "")
+	assert.Error(t, err)
+}
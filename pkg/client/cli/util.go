@@ -11,6 +11,7 @@ import (
 	"github.com/datawire/dlib/dcontext"
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 )
@@ -25,6 +26,13 @@ func kubeFlagMap() map[string]string {
 	return kubeFlagMap
 }
 
+// addConnectionFlag adds the --connection flag that selects which of the connector's connections
+// that a command concerns.
+func addConnectionFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&connectionName, "connection", "", ``+
+		`Name of the connection to use. Only needed when connected to several clusters at the same time`)
+}
+
 // withConnector is like cliutil.WithConnector, but also
 //
 //  - Ensures that the damon is running too
@@ -34,6 +42,17 @@ func kubeFlagMap() map[string]string {
 //
 //  - Makes the connector.Connect gRPC call to set up networking
 func withConnector(cmd *cobra.Command, retain bool, f func(context.Context, connector.ConnectorClient, *connector.ConnectInfo, daemon.DaemonClient) error) error {
+	return withConnectRequest(cmd, retain, &connector.ConnectRequest{
+		KubeFlags:        kubeFlagMap(),
+		MappedNamespaces: mappedNamespaces,
+		Name:             connectionName,
+		EgressVia:        egressVia,
+	}, f)
+}
+
+// withConnectRequest is like withConnector but uses the given request rather than the global flags
+// when making the connector.Connect gRPC call.
+func withConnectRequest(cmd *cobra.Command, retain bool, cr *connector.ConnectRequest, f func(context.Context, connector.ConnectorClient, *connector.ConnectInfo, daemon.DaemonClient) error) error {
 	return cliutil.WithDaemon(cmd.Context(), dnsIP, func(ctx context.Context, daemonClient daemon.DaemonClient) (err error) {
 		if cliutil.DidLaunchDaemon(ctx) {
 			defer func() {
@@ -43,6 +62,8 @@ func withConnector(cmd *cobra.Command, retain bool, f func(context.Context, conn
 			}()
 		}
 		return cliutil.WithConnector(ctx, func(ctx context.Context, connectorClient connector.ConnectorClient) (err error) {
+			// All calls made on behalf of this command concern the selected connection
+			ctx = client.WithConnectionName(ctx, cr.Name)
 			if cliutil.DidLaunchConnector(ctx) && !cliutil.DidLaunchDaemon(ctx) {
 				// Don't shut down the connector if we're shutting down the daemon.
 				// The daemon will shut down the connector for us, and if we shut it
@@ -53,7 +74,7 @@ func withConnector(cmd *cobra.Command, retain bool, f func(context.Context, conn
 					}
 				}()
 			}
-			connInfo, err := setConnectInfo(ctx, cmd.OutOrStdout())
+			connInfo, err := setConnectInfo(ctx, cmd.OutOrStdout(), cr)
 			if err != nil {
 				return err
 			}
@@ -62,14 +83,11 @@ func withConnector(cmd *cobra.Command, retain bool, f func(context.Context, conn
 	})
 }
 
-func setConnectInfo(ctx context.Context, stdout io.Writer) (*connector.ConnectInfo, error) {
+func setConnectInfo(ctx context.Context, stdout io.Writer, cr *connector.ConnectRequest) (*connector.ConnectInfo, error) {
 	var resp *connector.ConnectInfo
 	err := cliutil.WithStartedConnector(ctx, func(ctx context.Context, connectorClient connector.ConnectorClient) error {
 		var err error
-		resp, err = connectorClient.Connect(ctx, &connector.ConnectRequest{
-			KubeFlags:        kubeFlagMap(),
-			MappedNamespaces: mappedNamespaces,
-		})
+		resp, err = connectorClient.Connect(ctx, cr)
 		if err != nil {
 			return err
 		}
@@ -78,7 +96,11 @@ func setConnectInfo(ctx context.Context, stdout io.Writer) (*connector.ConnectIn
 		cat := errcat.Unknown
 		switch resp.Error {
 		case connector.ConnectInfo_UNSPECIFIED:
-			fmt.Fprintf(stdout, "Connected to context %s (%s)\n", resp.ClusterContext, resp.ClusterServer)
+			if name := resp.ConnectionName; name != "" && name != client.DefaultConnectionName {
+				fmt.Fprintf(stdout, "Connected to context %s (%s) using connection %q\n", resp.ClusterContext, resp.ClusterServer, name)
+			} else {
+				fmt.Fprintf(stdout, "Connected to context %s (%s)\n", resp.ClusterContext, resp.ClusterServer)
+			}
 			return nil
 		case connector.ConnectInfo_ALREADY_CONNECTED:
 			return nil
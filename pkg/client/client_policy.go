@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+// WithClientPolicy returns a copy of the config, merged with the cluster-wide client policy that is
+// published by the traffic-manager. A timeout of the policy is used unless the timeout is set in the
+// config.yml, even when it's set to its default value.
+func (c *Config) WithClientPolicy(ctx context.Context, policy *manager.ClientPolicy) *Config {
+	cfg := *c
+	if policy == nil {
+		return &cfg
+	}
+	for name, pd := range policy.Timeouts {
+		dp := cfg.Timeouts.durationPtr(name)
+		if dp == nil {
+			dlog.Warnf(ctx, "ignoring unknown timeout %q of the traffic-manager's client policy", name)
+			continue
+		}
+		if !cfg.Timeouts.isExplicit(name) {
+			*dp = pd.AsDuration()
+		}
+	}
+	return &cfg
+}
+
+// CheckAgentImage returns an error when the images.agentImage of the config.yml isn't allowed by the
+// client policy.
+func (c *Config) CheckAgentImage(ctx context.Context, policy *manager.ClientPolicy) error {
+	if c.Images.AgentImage == "" {
+		return nil
+	}
+	image := fmt.Sprintf("%s/%s", c.Images.Registry, c.Images.AgentImage)
+	if !install.AgentImageAllowed(policy, image) {
+		return errcat.Config.Newf("images.agentImage %s in %s is not allowed by the traffic-manager's client policy; allowed images are %v",
+			image, GetConfigFile(ctx), policy.AllowedAgentImages)
+	}
+	return nil
+}
+
+// AgentImage returns the agent image that the client policy prefers, unless the images.agentImage
+// of the config overrides it.
+func (c *Config) AgentImage(policy *manager.ClientPolicy) string {
+	if c.Images.AgentImage != "" {
+		return fmt.Sprintf("%s/%s", c.Images.Registry, c.Images.AgentImage)
+	}
+	return policy.GetPreferredAgentImage()
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+func TestWithClientPolicy(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	env, err := LoadEnv(ctx)
+	require.NoError(t, err)
+	ctx = WithEnv(ctx, env)
+	cfg := GetDefaultConfig(ctx)
+	cfg.Timeouts.PrivateIntercept = 7 * time.Second
+	cfg.Timeouts.PrivateProxyDial = defaultTimeoutsProxyDial
+	cfg.Timeouts.explicit = map[string]struct{}{"intercept": {}, "proxyDial": {}}
+	policy := &manager.ClientPolicy{
+		PreferredAgentImage: "docker.io/datawire/tel2:2.4.9",
+		AllowedAgentImages:  []string{"docker.io/datawire/tel2:*"},
+		Timeouts: map[string]*durationpb.Duration{
+			"agentInstall": durationpb.New(3 * time.Minute),
+			"intercept":    durationpb.New(time.Minute),
+			"proxyDial":    durationpb.New(time.Minute),
+			"helm":         durationpb.New(defaultTimeoutsHelm + time.Second),
+			"unknown":      durationpb.New(time.Minute),
+		},
+	}
+
+	merged := cfg.WithClientPolicy(ctx, policy)
+	require.NoError(t, cfg.CheckAgentImage(ctx, policy))
+	assert.Equal(t, 3*time.Minute, merged.Timeouts.PrivateAgentInstall)
+	assert.Equal(t, 7*time.Second, merged.Timeouts.PrivateIntercept, "a timeout of the config.yml takes precedence")
+	assert.Equal(t, defaultTimeoutsProxyDial, merged.Timeouts.PrivateProxyDial, "a timeout of the config.yml that is set to its default takes precedence")
+	assert.Equal(t, defaultTimeoutsHelm+time.Second, merged.Timeouts.PrivateHelm)
+	assert.Equal(t, defaultTimeoutsAgentInstall, cfg.Timeouts.PrivateAgentInstall, "the original config is unchanged")
+	assert.Equal(t, "docker.io/datawire/tel2:2.4.9", merged.AgentImage(policy))
+
+	cfg.Images.AgentImage = "tel2:2.4.8"
+	merged = cfg.WithClientPolicy(ctx, policy)
+	require.NoError(t, merged.CheckAgentImage(ctx, policy))
+	assert.Equal(t, "docker.io/datawire/tel2:2.4.8", merged.AgentImage(policy))
+
+	cfg.Images.AgentImage = "other-agent:1.0"
+	merged = cfg.WithClientPolicy(ctx, policy)
+	assert.Error(t, merged.CheckAgentImage(ctx, policy))
+	assert.Equal(t, 3*time.Minute, merged.Timeouts.PrivateAgentInstall, "the timeouts are merged even when the agent image isn't allowed")
+
+	// No policy allows everything
+	merged = cfg.WithClientPolicy(ctx, nil)
+	require.NoError(t, merged.CheckAgentImage(ctx, nil))
+	assert.True(t, install.AgentImageAllowed(nil, "docker.io/datawire/other-agent:1.0"))
+	assert.Equal(t, "docker.io/datawire/other-agent:1.0", merged.AgentImage(nil))
+}
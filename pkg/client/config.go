@@ -16,6 +16,7 @@ import (
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
 const configFile = "config.yml"
@@ -28,6 +29,10 @@ type Config struct {
 	Cloud           Cloud           `json:"cloud,omitempty" yaml:"cloud,omitempty"`
 	Grpc            Grpc            `json:"grpc,omitempty" yaml:"grpc,omitempty"`
 	TelepresenceAPI TelepresenceAPI `json:"telepresenceAPI,omitempty" yaml:"telepresenceAPI,omitempty"`
+	LogRotation     LogRotation     `json:"logRotation,omitempty" yaml:"logRotation,omitempty"`
+	Routing         Routing         `json:"routing,omitempty" yaml:"routing,omitempty"`
+	Extensions      Extensions      `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+	Tunnel          Tunnel          `json:"tunnel,omitempty" yaml:"tunnel,omitempty"`
 }
 
 // merge merges this instance with the non-zero values of the given argument. The argument values take priority.
@@ -38,6 +43,10 @@ func (c *Config) Merge(o *Config) {
 	c.Cloud.merge(&o.Cloud)
 	c.Grpc.merge(&o.Grpc)
 	c.TelepresenceAPI.merge(&o.TelepresenceAPI)
+	c.LogRotation.merge(&o.LogRotation)
+	c.Routing.merge(&o.Routing)
+	c.Extensions.merge(&o.Extensions)
+	c.Tunnel.merge(&o.Tunnel)
 }
 
 func stringKey(n *yaml.Node) (string, error) {
@@ -72,6 +81,14 @@ func (c *Config) UnmarshalYAML(node *yaml.Node) error {
 			err = ms[i+1].Decode(&c.Grpc)
 		case kv == "telepresenceAPI":
 			err = ms[i+1].Decode(&c.TelepresenceAPI)
+		case kv == "logRotation":
+			err = ms[i+1].Decode(&c.LogRotation)
+		case kv == "routing":
+			err = ms[i+1].Decode(&c.Routing)
+		case kv == "extensions":
+			err = ms[i+1].Decode(&c.Extensions)
+		case kv == "tunnel":
+			err = ms[i+1].Decode(&c.Tunnel)
 		case parseContext != nil:
 			dlog.Warn(parseContext, withLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
 		}
@@ -110,6 +127,9 @@ type Timeouts struct {
 	PrivateTrafficManagerAPI time.Duration `json:"trafficManagerAPI,omitempty" yaml:"trafficManagerAPI,omitempty"`
 	// PrivateTrafficManagerConnect is how long to wait for the initial port-forwards to the traffic-manager
 	PrivateTrafficManagerConnect time.Duration `json:"trafficManagerConnect,omitempty" yaml:"trafficManagerConnect,omitempty"`
+
+	// explicit contains the names of the timeouts that are set in a config.yml.
+	explicit map[string]struct{}
 }
 
 type TimeoutID int
@@ -244,6 +264,34 @@ func CheckTimeout(ctx context.Context, err error) error {
 	return err
 }
 
+// durationPtr returns a pointer to the timeout with the given YAML name, or nil if there's no such timeout.
+func (t *Timeouts) durationPtr(name string) *time.Duration {
+	switch name {
+	case "agentInstall":
+		return &t.PrivateAgentInstall
+	case "apply":
+		return &t.PrivateApply
+	case "clusterConnect":
+		return &t.PrivateClusterConnect
+	case "endpointDial":
+		return &t.PrivateEndpointDial
+	case "helm":
+		return &t.PrivateHelm
+	case "intercept":
+		return &t.PrivateIntercept
+	case "proxyDial":
+		return &t.PrivateProxyDial
+	case "roundtripLatency":
+		return &t.PrivateRoundtripLatency
+	case "trafficManagerAPI":
+		return &t.PrivateTrafficManagerAPI
+	case "trafficManagerConnect":
+		return &t.PrivateTrafficManagerConnect
+	default:
+		return nil
+	}
+}
+
 // UnmarshalYAML caters for the unfortunate fact that time.Duration doesn't do YAML or JSON at all.
 func (t *Timeouts) UnmarshalYAML(node *yaml.Node) (err error) {
 	if node.Kind != yaml.MappingNode {
@@ -256,29 +304,8 @@ func (t *Timeouts) UnmarshalYAML(node *yaml.Node) (err error) {
 		if err != nil {
 			return err
 		}
-		var dp *time.Duration
-		switch kv {
-		case "agentInstall":
-			dp = &t.PrivateAgentInstall
-		case "apply":
-			dp = &t.PrivateApply
-		case "clusterConnect":
-			dp = &t.PrivateClusterConnect
-		case "endpointDial":
-			dp = &t.PrivateEndpointDial
-		case "helm":
-			dp = &t.PrivateHelm
-		case "intercept":
-			dp = &t.PrivateIntercept
-		case "proxyDial":
-			dp = &t.PrivateProxyDial
-		case "roundtripLatency":
-			dp = &t.PrivateRoundtripLatency
-		case "trafficManagerAPI":
-			dp = &t.PrivateTrafficManagerAPI
-		case "trafficManagerConnect":
-			dp = &t.PrivateTrafficManagerConnect
-		default:
+		dp := t.durationPtr(kv)
+		if dp == nil {
 			if parseContext != nil {
 				dlog.Warn(parseContext, withLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
 			}
@@ -300,10 +327,20 @@ func (t *Timeouts) UnmarshalYAML(node *yaml.Node) (err error) {
 				return errors.New(withLoc(fmt.Sprintf("%q is not a valid duration", vv), v))
 			}
 		}
+		if t.explicit == nil {
+			t.explicit = make(map[string]struct{})
+		}
+		t.explicit[kv] = struct{}{}
 	}
 	return nil
 }
 
+// isExplicit returns true if the timeout with the given name is set in a config.yml.
+func (t *Timeouts) isExplicit(name string) bool {
+	_, ok := t.explicit[name]
+	return ok
+}
+
 const defaultTimeoutsAgentInstall = 120 * time.Second
 const defaultTimeoutsApply = 1 * time.Minute
 const defaultTimeoutsClusterConnect = 20 * time.Second
@@ -383,6 +420,16 @@ func (t *Timeouts) merge(o *Timeouts) {
 	if o.PrivateTrafficManagerConnect != 0 {
 		t.PrivateTrafficManagerConnect = o.PrivateTrafficManagerConnect
 	}
+	if len(o.explicit) > 0 {
+		explicit := make(map[string]struct{}, len(t.explicit)+len(o.explicit))
+		for name := range t.explicit {
+			explicit[name] = struct{}{}
+		}
+		for name := range o.explicit {
+			explicit[name] = struct{}{}
+		}
+		t.explicit = explicit
+	}
 }
 
 type LogLevels struct {
@@ -633,6 +680,198 @@ func (g *TelepresenceAPI) merge(o *TelepresenceAPI) {
 	}
 }
 
+type LogRotation struct {
+	// MaxSize is the size that a log file may grow to before it is rotated. A zero value means that the
+	// log files are rotated only when a daemon starts.
+	MaxSize resource.Quantity `json:"maxSize,omitempty" yaml:"maxSize,omitempty"`
+
+	// MaxFiles is the maximum number of log files that are retained for each daemon, including the
+	// currently active log file. A zero value means unlimited. It's a pointer so that an explicit zero
+	// can be told apart from an absent value when configs are merged.
+	MaxFiles *uint16 `json:"maxFiles,omitempty" yaml:"maxFiles,omitempty"`
+
+	// Compress controls whether rotated log files are gzip compressed.
+	Compress bool `json:"compress,omitempty" yaml:"compress,omitempty"`
+}
+
+const defaultLogRotationMaxFiles = 5
+
+// GetMaxFiles returns the MaxFiles value, or its default when it isn't set.
+func (lr *LogRotation) GetMaxFiles() uint16 {
+	if lr.MaxFiles == nil {
+		return defaultLogRotationMaxFiles
+	}
+	return *lr.MaxFiles
+}
+
+func (lr *LogRotation) merge(o *LogRotation) {
+	if !o.MaxSize.IsZero() {
+		lr.MaxSize = o.MaxSize
+	}
+	if o.MaxFiles != nil {
+		lr.MaxFiles = o.MaxFiles
+	}
+	if o.Compress {
+		lr.Compress = o.Compress
+	}
+}
+
+// UnmarshalYAML parses the logRotation YAML
+func (lr *LogRotation) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(withLoc("logRotation must be an object", node))
+	}
+
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := stringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		v := ms[i+1]
+		switch kv {
+		case "maxSize":
+			val, err := resource.ParseQuantity(v.Value)
+			if err != nil {
+				dlog.Warningf(parseContext, "unable to parse quantity %q: %v", v.Value, withLoc(err.Error(), ms[i]))
+			} else {
+				lr.MaxSize = val
+			}
+		case "maxFiles":
+			val, err := strconv.ParseUint(v.Value, 10, 16)
+			if err != nil {
+				dlog.Warn(parseContext, withLoc(fmt.Sprintf("unsigned integer expected for key %q", kv), ms[i]))
+			} else {
+				mx := uint16(val)
+				lr.MaxFiles = &mx
+			}
+		case "compress":
+			val, err := strconv.ParseBool(v.Value)
+			if err != nil {
+				dlog.Warn(parseContext, withLoc(fmt.Sprintf("bool expected for key %q", kv), ms[i]))
+			} else {
+				lr.Compress = val
+			}
+		default:
+			if parseContext != nil {
+				dlog.Warn(parseContext, withLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalYAML is not using pointer receiver here, because LogRotation is not pointer in the Config struct
+func (lr LogRotation) MarshalYAML() (interface{}, error) {
+	cm := make(map[string]interface{})
+	if !lr.MaxSize.IsZero() {
+		cm["maxSize"] = lr.MaxSize.String()
+	}
+	if lr.MaxFiles != nil && *lr.MaxFiles != defaultLogRotationMaxFiles {
+		cm["maxFiles"] = *lr.MaxFiles
+	}
+	if lr.Compress {
+		cm["compress"] = true
+	}
+	return cm, nil
+}
+
+type Routing struct {
+	// RemapConflictingSubnets controls whether a cluster subnet that overlaps a subnet that is routed
+	// locally, e.g. by a VPN or a docker bridge, is mapped to a synthetic subnet that doesn't overlap.
+	RemapConflictingSubnets bool `json:"remapConflictingSubnets,omitempty" yaml:"remapConflictingSubnets,omitempty"`
+}
+
+func (r *Routing) merge(o *Routing) {
+	if o.RemapConflictingSubnets {
+		r.RemapConflictingSubnets = o.RemapConflictingSubnets
+	}
+}
+
+type Extensions struct {
+	// PublicKey is a PEM encoded ed25519 public key. When set, the extension files that are added or
+	// updated using "telepresence extensions" must have a signature made with the matching private key.
+	PublicKey string `json:"publicKey,omitempty" yaml:"publicKey,omitempty"`
+}
+
+func (e *Extensions) merge(o *Extensions) {
+	if o.PublicKey != "" {
+		e.PublicKey = o.PublicKey
+	}
+}
+
+type Tunnel struct {
+	// Faults are network faults, e.g. "latency=100ms,drop=1%", that the root daemon injects into its tunnels to
+	// the cluster in order to simulate a poor connection. Intended for testing only. See tunnel.ParseFaults.
+	Faults string `json:"faults,omitempty" yaml:"faults,omitempty"`
+
+	// Compression is the compressions, in order of preference, that the root daemon offers when it establishes
+	// tunnels to the cluster, e.g. "deflate", or "none" to disable compression. See tunnel.ParseCompressions.
+	Compression string `json:"compression,omitempty" yaml:"compression,omitempty"`
+}
+
+func (t *Tunnel) merge(o *Tunnel) {
+	if o.Faults != "" {
+		t.Faults = o.Faults
+	}
+	if o.Compression != "" {
+		t.Compression = o.Compression
+	}
+}
+
+// UnmarshalYAML parses the tunnel YAML
+func (t *Tunnel) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(withLoc("tunnel must be an object", node))
+	}
+
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := stringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		v := ms[i+1]
+		switch kv {
+		case "faults":
+			if _, err := tunnel.ParseFaults(v.Value); err != nil {
+				dlog.Warningf(parseContext, "unable to parse faults %q: %v", v.Value, withLoc(err.Error(), ms[i]))
+			} else {
+				t.Faults = v.Value
+			}
+		case "compression":
+			if _, err := tunnel.ParseCompressions(v.Value); err != nil {
+				dlog.Warningf(parseContext, "unable to parse compression %q: %v", v.Value, withLoc(err.Error(), ms[i]))
+			} else {
+				t.Compression = v.Value
+			}
+		default:
+			if parseContext != nil {
+				dlog.Warn(parseContext, withLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+			}
+		}
+	}
+	return nil
+}
+
+// GetFaults returns the faults to inject into tunnels, or nil if none are configured.
+func (t *Tunnel) GetFaults() *tunnel.Faults {
+	// Faults that can't be parsed are rejected when the config is loaded
+	if faults, err := tunnel.ParseFaults(t.Faults); err == nil && faults.Enabled() {
+		return faults
+	}
+	return nil
+}
+
+// GetCompressions returns the compressions to offer when establishing tunnels.
+func (t *Tunnel) GetCompressions() []tunnel.Compression {
+	// Compressions that can't be parsed are rejected when the config is loaded
+	cs, _ := tunnel.ParseCompressions(t.Compression)
+	return cs
+}
+
 var parseContext context.Context
 
 type parsedFile struct{}
@@ -695,6 +934,8 @@ func GetDefaultConfig(c context.Context) Config {
 		Grpc:            Grpc{},
 		TelepresenceAPI: TelepresenceAPI{},
 	}
+	maxFiles := uint16(defaultLogRotationMaxFiles)
+	cfg.LogRotation.MaxFiles = &maxFiles
 	env := GetEnv(c)
 	cfg.Images.Registry = env.Registry
 	cfg.Images.WebhookRegistry = env.Registry
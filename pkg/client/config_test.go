@@ -14,6 +14,7 @@ import (
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
 func TestGetConfig(t *testing.T) {
@@ -30,6 +31,8 @@ timeouts:
   apply: 33s
 logLevels:
   userDaemon: debug
+logRotation:
+  maxFiles: 10
 `,
 		/* user */ `
 timeouts:
@@ -43,6 +46,17 @@ images:
   webhookAgentImage: ambassador-telepresence-webhook-image:0.0.2
 telepresenceAPI:
   port: 1234
+logRotation:
+  maxSize: 10Mi
+  maxFiles: 0
+  compress: true
+routing:
+  remapConflictingSubnets: true
+extensions:
+  publicKey: user-key
+tunnel:
+  faults: latency=100ms,drop=1%
+  compression: none
 `,
 	}
 
@@ -72,6 +86,10 @@ telepresenceAPI:
 	assert.Equal(t, 33*time.Second, to.PrivateApply)                      // from sys2
 	assert.Equal(t, 25*time.Second, to.PrivateClusterConnect)             // from user
 	assert.Equal(t, 17*time.Second, to.PrivateProxyDial)                  // from user
+	assert.True(t, to.isExplicit("agentInstall"))
+	assert.True(t, to.isExplicit("apply"))
+	assert.True(t, to.isExplicit("proxyDial"))
+	assert.False(t, to.isExplicit("helm"))
 
 	assert.Equal(t, logrus.DebugLevel, cfg.LogLevels.UserDaemon) // from sys2
 	assert.Equal(t, logrus.TraceLevel, cfg.LogLevels.RootDaemon) // from user
@@ -80,6 +98,17 @@ telepresenceAPI:
 	assert.Equal(t, "ambassador-telepresence-client-image:0.0.1", cfg.Images.AgentImage)         // from user
 	assert.Equal(t, "ambassador-telepresence-webhook-image:0.0.2", cfg.Images.WebhookAgentImage) // from user
 	assert.Equal(t, 1234, cfg.TelepresenceAPI.Port)                                              // from user
+
+	assert.Equal(t, int64(10*1024*1024), cfg.LogRotation.MaxSize.Value()) // from user
+	assert.Equal(t, uint16(0), cfg.LogRotation.GetMaxFiles())             // from user, overriding sys2
+	assert.True(t, cfg.LogRotation.Compress)                              // from user
+
+	assert.True(t, cfg.Routing.RemapConflictingSubnets)   // from user
+	assert.Equal(t, "user-key", cfg.Extensions.PublicKey) // from user
+
+	faults := cfg.Tunnel.GetFaults() // from user
+	assert.Equal(t, &tunnel.Faults{Latency: 100 * time.Millisecond, DropRate: 0.01}, faults)
+	assert.Empty(t, cfg.Tunnel.GetCompressions()) // from user
 }
 
 func Test_ConfigMarshalYAML(t *testing.T) {
@@ -94,6 +123,14 @@ func Test_ConfigMarshalYAML(t *testing.T) {
 	cfg.LogLevels.UserDaemon = logrus.TraceLevel
 	cfg.Grpc.MaxReceiveSize, _ = resource.ParseQuantity("20Mi")
 	cfg.TelepresenceAPI.Port = 4567
+	cfg.LogRotation.MaxSize, _ = resource.ParseQuantity("5Mi")
+	maxFiles := uint16(10)
+	cfg.LogRotation.MaxFiles = &maxFiles
+	cfg.LogRotation.Compress = true
+	cfg.Routing.RemapConflictingSubnets = true
+	cfg.Extensions.PublicKey = "some-key"
+	cfg.Tunnel.Faults = "latency=10ms"
+	cfg.Tunnel.Compression = "deflate"
 	cfgBytes, err := yaml.Marshal(cfg)
 	require.NoError(t, err)
 
@@ -105,5 +142,8 @@ func Test_ConfigMarshalYAML(t *testing.T) {
 	// Load from file and compare
 	cfg2, err := LoadConfig(ctx)
 	require.NoError(t, err)
+
+	// The timeouts that differ from their defaults are written to the file, and are hence explicit when loaded.
+	cfg.Timeouts.explicit = map[string]struct{}{"trafficManagerAPI": {}}
 	require.Equal(t, &cfg, cfg2)
 }
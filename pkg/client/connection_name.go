@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultConnectionName is the name of the connection that is created when connecting without
+// giving an explicit name.
+const DefaultConnectionName = "default"
+
+// connectionNameKey is the gRPC metadata key that carries the name of the connection that a call
+// to the user daemon, the root daemon, or the traffic-manager proxy is intended for.
+const connectionNameKey = "telepresence-connection"
+
+// WithConnectionName returns a context that passes the given connection name in the metadata of
+// outgoing gRPC calls. The context is returned unchanged when the name is empty.
+func WithConnectionName(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, connectionNameKey, name)
+}
+
+// GetConnectionName returns the connection name passed in the metadata of an incoming gRPC call,
+// or an empty string when no name was passed.
+func GetConnectionName(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if names := md.Get(connectionNameKey); len(names) > 0 {
+			return names[0]
+		}
+	}
+	return ""
+}
+
+// WithConnectionNameDialOptions returns dial options that will pass the given connection name in
+// the metadata of all calls made using the resulting connection.
+func WithConnectionNameDialOptions(name string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(func(
+			ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+		) error {
+			return invoker(WithConnectionName(ctx, name), method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(
+			ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption,
+		) (grpc.ClientStream, error) {
+			return streamer(WithConnectionName(ctx, name), desc, cc, method, opts...)
+		}),
+	}
+}
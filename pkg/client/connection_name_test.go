@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+// incoming converts the outgoing metadata of the given context into incoming metadata, the way a
+// gRPC call would.
+func incoming(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestConnectionName(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "", GetConnectionName(incoming(ctx)))
+
+	// An empty name doesn't select a connection
+	assert.Equal(t, ctx, WithConnectionName(ctx, ""))
+
+	assert.Equal(t, "platform", GetConnectionName(incoming(WithConnectionName(ctx, "platform"))))
+
+	// The name is only passed in incoming metadata
+	assert.Equal(t, "", GetConnectionName(WithConnectionName(ctx, "platform")))
+}
@@ -2,7 +2,9 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -11,6 +13,9 @@ import (
 
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/datawire/dlib/dcontext"
 	"github.com/datawire/dlib/derror"
@@ -49,6 +54,7 @@ to troubleshoot problems.
 type parsedConnectRequest struct {
 	*rpc.ConnectRequest
 	*userd_k8s.Config
+	conn *sharedstate.Connection
 }
 
 type ScoutReport = scout.ScoutReport
@@ -59,14 +65,25 @@ type service struct {
 
 	cancel func()
 
-	// Must hold connectMu to use the sharedState.MaybeSetXXX methods.
+	// Must hold connectMu to add connections to the sharedState.
 	connectMu   sync.Mutex
 	sharedState *sharedstate.State
 
+	// daemonClient is created by the first connectWorker and then shared by all connections.
+	daemonClient daemon.DaemonClient
+
 	// These are used to communicate between the various goroutines.
 	scout           chan ScoutReport          // any-of-scoutUsers -> background-metriton
 	connectRequest  chan parsedConnectRequest // server-grpc.connect() -> connectWorker
 	connectResponse chan *rpc.ConnectInfo     // connectWorker -> server-grpc.connect()
+	connectionStart chan *connectionRun       // connectWorker -> background-manager
+}
+
+// connectionRun contains what the background-manager needs to run a connection.
+type connectionRun struct {
+	conn    *sharedstate.Connection
+	cluster *userd_k8s.Cluster
+	mgr     sharedstate.TrafficManager
 }
 
 // Command returns the CLI sub-command for "connector-foreground"
@@ -101,8 +118,38 @@ func (s *service) connect(c context.Context, cr *rpc.ConnectRequest, dryRun bool
 	if err != nil && !dryRun {
 		return connectError(rpc.ConnectInfo_CLUSTER_FAILED, err)
 	}
-	if cluster := s.sharedState.GetClusterNonBlocking(); cluster != nil {
-		if cluster.Config.ContextServiceAndFlagsEqual(config) {
+	if config != nil {
+		if err = config.AddAlsoProxy(cr.AlsoProxy); err != nil {
+			return connectError(rpc.ConnectInfo_CLUSTER_FAILED, err)
+		}
+		if err = config.AddEgressVia(cr.EgressVia); err != nil {
+			return connectError(rpc.ConnectInfo_CLUSTER_FAILED, err)
+		}
+	}
+	var conn *sharedstate.Connection
+	if cr.Name != "" {
+		conn = s.sharedState.GetConnection(cr.Name)
+	} else if conn, err = s.sharedState.SelectConnection(""); err != nil {
+		return connectError(rpc.ConnectInfo_CLUSTER_FAILED, err)
+	}
+	var cluster *userd_k8s.Cluster
+	if conn != nil {
+		cluster = conn.GetClusterNonBlocking()
+	}
+	if cluster != nil {
+		// The egress rules and the also-proxy subnets are part of the session, so they can't change while
+		// connected. Those that the request doesn't declare are retained.
+		if config != nil {
+			if len(cr.EgressVia) == 0 {
+				config.AddEgressRules(cluster.Config.EgressRules)
+			}
+			if len(cr.AlsoProxy) == 0 {
+				config.AlsoProxy = cluster.Config.AlsoProxy
+			}
+		}
+		if cluster.Config.ContextServiceAndFlagsEqual(config) &&
+			(len(cr.EgressVia) == 0 || cluster.Config.EgressRulesEqual(config)) &&
+			(len(cr.AlsoProxy) == 0 || cluster.Config.AlsoProxyEqual(config)) {
 			cluster.Config = config // namespace might have changed
 			if mns := cr.MappedNamespaces; len(mns) > 0 {
 				if len(mns) == 1 && mns[0] == "all" {
@@ -121,8 +168,11 @@ func (s *service) connect(c context.Context, cr *rpc.ConnectRequest, dryRun bool
 				ClusterServer:  cluster.Config.Server,
 				ClusterId:      cluster.GetClusterId(c),
 				IngressInfos:   ingressInfo,
+				ConnectionName: conn.Name(),
+			}
+			if mgr := conn.GetTrafficManagerNonBlocking(); mgr != nil {
+				mgr.SetStatus(c, ret)
 			}
-			s.sharedState.GetTrafficManagerNonBlocking().SetStatus(c, ret)
 			return ret
 		} else {
 			ret := &rpc.ConnectInfo{
@@ -130,29 +180,126 @@ func (s *service) connect(c context.Context, cr *rpc.ConnectRequest, dryRun bool
 				ClusterContext: cluster.Config.Context,
 				ClusterServer:  cluster.Config.Server,
 				ClusterId:      cluster.GetClusterId(c),
+				ConnectionName: conn.Name(),
+			}
+			if mgr := conn.GetTrafficManagerNonBlocking(); mgr != nil {
+				mgr.SetStatus(c, ret)
 			}
-			s.sharedState.GetTrafficManagerNonBlocking().SetStatus(c, ret)
 			return ret
 		}
 	} else {
-		// This is the first call to Connect; we have to tell the background connect
-		// goroutine to actually do the work.
+		// This is the first call to Connect for this connection; we have to tell the
+		// background connect goroutine to actually do the work.
 		if dryRun {
 			return &rpc.ConnectInfo{
 				Error: rpc.ConnectInfo_DISCONNECTED,
 			}
 		} else {
+			name := cr.Name
+			if name == "" {
+				name = client.DefaultConnectionName
+			}
+			conn = s.sharedState.AddConnection(name)
+			if conn == nil {
+				// The connection exists but has no cluster, so it's in the process of being dropped.
+				return connectError(rpc.ConnectInfo_CLUSTER_FAILED, errcat.User.Newf("connection %q is shutting down, please try again", name))
+			}
 			s.connectRequest <- parsedConnectRequest{
 				ConnectRequest: cr,
 				Config:         config,
+				conn:           conn,
 			}
-			close(s.connectRequest)
 			return <-s.connectResponse
 		}
 	}
 }
 
-func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sConfig *userd_k8s.Config, svc *grpc.Server) *rpc.ConnectInfo {
+// listConnections returns the status of all connections.
+func (s *service) listConnections(c context.Context) *rpc.ConnectionList {
+	cl := &rpc.ConnectionList{}
+	for _, conn := range s.sharedState.GetConnections() {
+		cluster := conn.GetClusterNonBlocking()
+		if cluster == nil {
+			// Still connecting
+			continue
+		}
+		ci := &rpc.ConnectInfo{
+			Error:          rpc.ConnectInfo_ALREADY_CONNECTED,
+			ClusterContext: cluster.Config.Context,
+			ClusterServer:  cluster.Config.Server,
+			ClusterId:      cluster.GetClusterId(c),
+			ConnectionName: conn.Name(),
+		}
+		if mgr := conn.GetTrafficManagerNonBlocking(); mgr != nil {
+			mgr.SetStatus(c, ci)
+		}
+		cl.Connections = append(cl.Connections, ci)
+	}
+	return cl
+}
+
+// dropConnection removes a connection that failed or ended, and tells the daemon to release the
+// router and TUN device of the connection. The connector quits when the last connection is dropped,
+// unless it's already quitting.
+func (s *service) dropConnection(c context.Context, conn *sharedstate.Connection) {
+	conn.MaybeSetCluster(nil)
+	conn.MaybeSetTrafficManager(nil)
+	remaining, removed := s.sharedState.RemoveConnection(conn)
+	if !removed || c.Err() != nil {
+		return
+	}
+	if s.daemonClient != nil {
+		if _, err := s.daemonClient.RemoveConnection(client.WithConnectionName(c, conn.Name()), &empty.Empty{}); err != nil {
+			// The connection might have failed before the daemon created a router for it.
+			dlog.Debugf(c, "daemon didn't remove connection %q: %v", conn.Name(), err)
+		}
+	}
+	if remaining == 0 {
+		s.cancel()
+	}
+}
+
+// managerClient returns the client of the traffic-manager for the connection that the given
+// context of an incoming gRPC call selects. It is used by the manager proxy.
+func (s *service) managerClient(c context.Context) (manager.ManagerClient, error) {
+	conn, err := s.sharedState.SelectConnection(client.GetConnectionName(c))
+	if err != nil {
+		return nil, grpcStatus.Error(grpcCodes.NotFound, err.Error())
+	}
+	if conn == nil {
+		return nil, grpcStatus.Error(grpcCodes.Unavailable, "not connected")
+	}
+	mgr, err := conn.GetTrafficManagerBlocking(c)
+	if err != nil {
+		return nil, err
+	}
+	if mgr == nil {
+		return nil, grpcStatus.Errorf(grpcCodes.Unavailable, "connection %q has no traffic-manager", conn.Name())
+	}
+	return mgr.GetClientBlocking(c)
+}
+
+// watchDaemonNotifications forwards the root daemon's notifications, such as warnings about subnet
+// conflicts, to the user.
+func (s *service) watchDaemonNotifications(c context.Context, daemonClient daemon.DaemonClient) {
+	stream, err := daemonClient.WatchNotifications(c, &empty.Empty{})
+	if err != nil {
+		dlog.Errorf(c, "unable to watch daemon notifications: %v", err)
+		return
+	}
+	for {
+		n, err := stream.Recv()
+		if err != nil {
+			if c.Err() == nil && !errors.Is(err, io.EOF) {
+				dlog.Debugf(c, "daemon notifications ended: %v", err)
+			}
+			return
+		}
+		s.sharedState.UserNotifications.Push(n.Message)
+	}
+}
+
+func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sConfig *userd_k8s.Config, conn *sharedstate.Connection) *rpc.ConnectInfo {
 	mappedNamespaces := cr.MappedNamespaces
 	if len(mappedNamespaces) == 1 && mappedNamespaces[0] == "all" {
 		mappedNamespaces = nil
@@ -163,21 +310,35 @@ func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sCo
 		Action: "connect",
 	}
 
-	// establish a connection to the daemon gRPC service
-	dlog.Info(c, "Connecting to daemon...")
-	conn, err := client.DialSocket(c, client.DaemonSocketName)
-	if err != nil {
-		dlog.Errorf(c, "unable to connect to daemon: %+v", err)
-		s.sharedState.MaybeSetCluster(nil)
-		s.sharedState.MaybeSetTrafficManager(nil)
-		s.cancel()
-		return connectError(rpc.ConnectInfo_DAEMON_FAILED, err)
+	if s.daemonClient == nil {
+		// establish a connection to the daemon gRPC service
+		dlog.Info(c, "Connecting to daemon...")
+		daemonConn, err := client.DialSocket(c, client.DaemonSocketName)
+		if err != nil {
+			dlog.Errorf(c, "unable to connect to daemon: %+v", err)
+			s.dropConnection(c, conn)
+			return connectError(rpc.ConnectInfo_DAEMON_FAILED, err)
+		}
+		// Don't bother calling 'daemonConn.Close()', it should remain open until we shut down, and just
+		// prefer to let the OS close it when we exit.
+		s.daemonClient = daemon.NewDaemonClient(daemonConn)
+		go s.watchDaemonNotifications(c, s.daemonClient)
+	}
+	daemonClient := s.daemonClient
+
+	// All calls to the daemon that concern this connection must carry its name.
+	name := conn.Name()
+	setDNSSearchPath := func(ctx context.Context, in *daemon.Paths, opts ...grpc.CallOption) (*empty.Empty, error) {
+		return daemonClient.SetDnsSearchPath(client.WithConnectionName(ctx, name), in, opts...)
+	}
+	setOutboundInfo := func(ctx context.Context, in *daemon.OutboundInfo, opts ...grpc.CallOption) (*empty.Empty, error) {
+		return daemonClient.SetOutboundInfo(client.WithConnectionName(ctx, name), in, opts...)
+	}
+	setNetworkShaping := func(ctx context.Context, in *daemon.NetworkShaping, opts ...grpc.CallOption) (*empty.Empty, error) {
+		return daemonClient.SetNetworkShaping(client.WithConnectionName(ctx, name), in, opts...)
 	}
-	// Don't bother calling 'conn.Close()', it should remain open until we shut down, and just
-	// prefer to let the OS close it when we exit.
-	daemonClient := daemon.NewDaemonClient(conn)
 
-	dlog.Info(c, "Connecting to k8s cluster...")
+	dlog.Infof(c, "Connecting to k8s cluster using connection %q...", name)
 	cluster, err := func() (*userd_k8s.Cluster, error) {
 		c, cancel := client.GetConfig(c).Timeouts.TimeoutContext(c, client.TimeoutClusterConnect)
 		defer cancel()
@@ -185,7 +346,7 @@ func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sCo
 			k8sConfig,
 			mappedNamespaces,
 			userd_k8s.Callbacks{
-				SetDNSSearchPath: daemonClient.SetDnsSearchPath,
+				SetDNSSearchPath: setDNSSearchPath,
 			},
 		)
 		if err != nil {
@@ -195,12 +356,10 @@ func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sCo
 	}()
 	if err != nil {
 		dlog.Errorf(c, "unable to track k8s cluster: %+v", err)
-		s.sharedState.MaybeSetCluster(nil)
-		s.sharedState.MaybeSetTrafficManager(nil)
-		s.cancel()
+		s.dropConnection(c, conn)
 		return connectError(rpc.ConnectInfo_CLUSTER_FAILED, err)
 	}
-	s.sharedState.MaybeSetCluster(cluster)
+	conn.MaybeSetCluster(cluster)
 	dlog.Infof(c, "Connected to context %s (%s)", cluster.Context, cluster.Server)
 
 	// Phone home with the information about the size of the cluster
@@ -219,23 +378,30 @@ func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sCo
 
 	dlog.Info(c, "Connecting to traffic manager...")
 	tmgr, err := userd_trafficmgr.New(c,
+		name,
 		cluster,
 		s.scoutClient.Reporter.InstallID(),
 		userd_trafficmgr.Callbacks{
-			GetCloudAPIKey: s.sharedState.GetCloudAPIKey,
-			RegisterManagerServer: func(mgrSrv manager.ManagerServer) {
-				manager.RegisterManagerServer(svc, mgrSrv)
-			},
-			SetOutboundInfo: daemonClient.SetOutboundInfo,
+			GetCloudAPIKey:    s.sharedState.GetCloudAPIKey,
+			SetOutboundInfo:   setOutboundInfo,
+			SetNetworkShaping: setNetworkShaping,
+			Notify:            s.sharedState.UserNotifications.Push,
 		})
 	if err != nil {
 		dlog.Errorf(c, "Unable to connect to TrafficManager: %s", err)
 		// No point in continuing without a traffic manager
-		s.sharedState.MaybeSetTrafficManager(nil)
-		s.cancel()
+		s.dropConnection(c, conn)
 		return connectError(rpc.ConnectInfo_TRAFFIC_MANAGER_FAILED, err)
 	}
-	s.sharedState.MaybeSetTrafficManager(tmgr)
+	conn.MaybeSetTrafficManager(tmgr)
+
+	// Let the background-manager run the k8s watchers and the traffic manager session
+	select {
+	case <-c.Done():
+		s.dropConnection(c, conn)
+		return connectError(rpc.ConnectInfo_TRAFFIC_MANAGER_FAILED, c.Err())
+	case s.connectionStart <- &connectionRun{conn: conn, cluster: cluster, mgr: tmgr}:
+	}
 
 	// Wait for traffic manager to connect
 	dlog.Info(c, "Waiting for TrafficManager to connect")
@@ -244,13 +410,13 @@ func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sCo
 	if _, err := tmgr.GetClientBlocking(tc); err != nil {
 		dlog.Errorf(c, "Failed to initialize session with traffic-manager: %v", err)
 		// No point in continuing without a traffic manager
-		s.cancel()
+		s.dropConnection(c, conn)
 		return connectError(rpc.ConnectInfo_TRAFFIC_MANAGER_FAILED, err)
 	}
 
-	// Wait until all of the k8s watches (in the "background-k8swatch" goroutine) are running.
+	// Wait until all of the k8s watches (in the "background-manager" goroutine) are running.
 	if err = cluster.WaitUntilReady(c); err != nil {
-		s.cancel()
+		s.dropConnection(c, conn)
 		return connectError(rpc.ConnectInfo_CLUSTER_FAILED, err)
 	}
 
@@ -264,7 +430,7 @@ func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sCo
 
 	ingressInfo, err := cluster.DetectIngressBehavior(c)
 	if err != nil {
-		s.cancel()
+		s.dropConnection(c, conn)
 		return connectError(rpc.ConnectInfo_CLUSTER_FAILED, err)
 	}
 
@@ -274,11 +440,44 @@ func (s *service) connectWorker(c context.Context, cr *rpc.ConnectRequest, k8sCo
 		ClusterServer:  cluster.Config.Server,
 		ClusterId:      cluster.GetClusterId(c),
 		IngressInfos:   ingressInfo,
+		ConnectionName: name,
 	}
 	tmgr.SetStatus(c, ret)
 	return ret
 }
 
+// runConnection runs the k8s watchers and the traffic manager session of a connection until one of
+// them fails, the connection is dropped, or the context is cancelled.
+func (s *service) runConnection(c context.Context, cr *connectionRun) {
+	gc, cancel := context.WithCancel(c)
+	defer cancel()
+	go func() {
+		select {
+		case <-gc.Done():
+		case <-cr.conn.Done():
+			cancel()
+		}
+	}()
+	g := dgroup.NewGroup(gc, dgroup.GroupConfig{})
+
+	// k8s-watch watches all of the nescessary Kubernetes resources.
+	g.Go("k8s-watch", cr.cluster.RunWatchers)
+
+	// traffic-manager (1) starts up with ensuring that the manager is installed and running,
+	// but then for most of its life
+	//  - (2) calls manager.ArriveAsClient and then periodically calls manager.Remain
+	//  - watch the intercepts (manager.WatchIntercepts) and then
+	//    + (3) listen on the appropriate local ports and forward them to the intercepted
+	//      Services, and
+	//    + (4) mount the appropriate remote volumes.
+	g.Go("traffic-manager", cr.mgr.Run)
+
+	if err := g.Wait(); err != nil {
+		dlog.Errorf(c, "connection %q ended with: %v", cr.conn.Name(), err)
+	}
+	s.dropConnection(c, cr.conn)
+}
+
 // run is the main function when executing as the connector
 func run(c context.Context) error {
 	cfg, err := client.LoadConfig(c)
@@ -310,6 +509,7 @@ func run(c context.Context) error {
 		scout:           make(chan ScoutReport, 10),
 		connectRequest:  make(chan parsedConnectRequest),
 		connectResponse: make(chan *rpc.ConnectInfo),
+		connectionStart: make(chan *connectionRun),
 	}
 	if s.sharedState, err = sharedstate.NewState(c, ProcessName); err != nil {
 		return err
@@ -334,8 +534,6 @@ func run(c context.Context) error {
 	dlog.Infof(c, "PID is %d", os.Getpid())
 	dlog.Info(c, "")
 
-	svcCh := make(chan *grpc.Server, 1)
-
 	grpcQuitCh := make(chan func()) // Channel uses to propagate the grpcQuit cancel function. It must originate inside "server-grpc".
 	g.Go("server-grpc", func(c context.Context) (err error) {
 		// Prevent that the gRPC server is stopped before the "background-manager" completes. Termination goes like this:
@@ -351,7 +549,6 @@ func run(c context.Context) error {
 		close(grpcQuitCh)
 
 		defer func() {
-			close(svcCh)
 			if perr := derror.PanicToError(recover()); perr != nil {
 				dlog.Error(c, perr)
 			}
@@ -385,10 +582,11 @@ func run(c context.Context) error {
 				InterceptStatus: s.interceptStatus,
 				Cancel:          s.cancel,
 				Connect:         s.connect,
+				ListConnections: s.listConnections,
 			},
 			s.sharedState,
 		))
-		svcCh <- svc
+		manager.RegisterManagerServer(svc, userd_grpc.NewManagerProxy(s.managerClient))
 
 		sc := &dhttp.ServerConfig{
 			Handler: svc,
@@ -397,55 +595,48 @@ func run(c context.Context) error {
 		return sc.Serve(grpcSoft, grpcListener)
 	})
 
-	// background-init handles the work done by the initial connector.Connect RPC call.  This
-	// happens in a separate goroutine from the gRPC server's connection handler so that the
-	// request getting cancelled doesn't cancel the work.
+	// background-init handles the work done by the connector.Connect RPC calls that create new
+	// connections.  This happens in a separate goroutine from the gRPC server's connection handler
+	// so that the request getting cancelled doesn't cancel the work.
 	g.Go("background-init", func(c context.Context) error {
 		defer func() {
 			close(s.connectResponse) // -> server-grpc.connect()
-			s.sharedState.MaybeSetCluster(nil)
-			s.sharedState.MaybeSetTrafficManager(nil)
-			<-c.Done() // Don't trip ShutdownOnNonError in the parent group.
+			<-c.Done()               // Don't trip ShutdownOnNonError in the parent group.
 			scoutUsers.Done()
 		}()
 
-		pcr, ok := <-s.connectRequest
-		if !ok {
-			return nil
-		}
-		svc, ok := <-svcCh
-		if !ok {
-			return nil
-		}
-		s.connectResponse <- s.connectWorker(c, pcr.ConnectRequest, pcr.Config, svc)
-
-		return nil
-	})
-
-	// background-k8swatch watches all of the nescessary Kubernetes resources.
-	g.Go("background-k8swatch", func(c context.Context) error {
-		cluster, _ := s.sharedState.GetClusterBlocking(c)
-		if cluster == nil {
-			return nil
+		for {
+			select {
+			case <-c.Done():
+				return nil
+			case pcr, ok := <-s.connectRequest:
+				if !ok {
+					return nil
+				}
+				s.connectResponse <- s.connectWorker(c, pcr.ConnectRequest, pcr.Config, pcr.conn)
+			}
 		}
-		return cluster.RunWatchers(c)
 	})
 
-	// background-manager (1) starts up with ensuring that the manager is installed and running,
-	// but then for most of its life
-	//  - (2) calls manager.ArriveAsClient and then periodically calls manager.Remain
-	//  - watch the intercepts (manager.WatchIntercepts) and then
-	//    + (3) listen on the appropriate local ports and forward them to the intercepted
-	//      Services, and
-	//    + (4) mount the appropriate remote volumes.
+	// background-manager runs each connection, i.e. the watchers of its Kubernetes resources and
+	// its traffic-manager session, once the connection has been initialized by background-init.
 	g.Go("background-manager", func(c context.Context) error {
 		grpcQuit := <-grpcQuitCh
 		defer grpcQuit()
-		mgr, _ := s.sharedState.GetTrafficManagerBlocking(c)
-		if mgr == nil {
-			return nil
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		for {
+			select {
+			case <-c.Done():
+				return nil
+			case cr := <-s.connectionStart:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.runConnection(dgroup.WithGoroutineName(c, "/"+cr.conn.Name()), cr)
+				}()
+			}
 		}
-		return mgr.Run(c)
 	})
 
 	// background-systema runs a localhost HTTP server for handling callbacks from the
@@ -2,14 +2,18 @@ package connector
 
 import (
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/connector/sharedstate"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 )
 
-func (s *service) interceptStatus() *rpc.InterceptResult {
+func (s *service) interceptStatus(conn *sharedstate.Connection) *rpc.InterceptResult {
 	var ie rpc.InterceptError
-	mgr := s.sharedState.GetTrafficManagerNonBlocking()
+	var mgr sharedstate.TrafficManager
+	if conn != nil {
+		mgr = conn.GetTrafficManagerNonBlocking()
+	}
 	switch {
-	case s.sharedState.GetClusterNonBlocking() == nil:
+	case conn == nil || conn.GetClusterNonBlocking() == nil:
 		ie = rpc.InterceptError_NO_CONNECTION
 	case mgr == nil:
 		ie = rpc.InterceptError_NO_TRAFFIC_MANAGER
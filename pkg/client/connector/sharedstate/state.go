@@ -2,6 +2,8 @@ package sharedstate
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
@@ -11,6 +13,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/connector/userd_auth"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/connector/userd_auth/authdata"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/connector/userd_k8s"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
 	"github.com/telepresenceio/telepresence/v2/pkg/log"
 )
@@ -35,94 +38,199 @@ type TrafficManager interface {
 
 	AddIntercept(context.Context, *connector.CreateInterceptRequest) (*connector.InterceptResult, error)
 	RemoveIntercept(context.Context, string) error
+	TransferIntercept(ctx context.Context, name, to string) (*manager.InterceptInfo, error)
 	WorkloadInfoSnapshot(context.Context, *connector.ListRequest) *connector.WorkloadInfoSnapshot
 	Uninstall(context.Context, *connector.UninstallRequest) (*connector.UninstallResult, error)
 	SetStatus(context.Context, *connector.ConnectInfo)
 }
 
-type State struct {
-	LoginExecutor     userd_auth.LoginExecutor
-	UserNotifications broadcastqueue.BroadcastQueue
+// A Connection is a named connection to a cluster and to the traffic-manager in that cluster. The
+// connector can maintain several connections simultaneously.
+type Connection struct {
+	name string
+
+	// done is closed when the connection is removed
+	done chan struct{}
 
 	clusterFinalized chan struct{}
 	cluster          *userd_k8s.Cluster
 
 	trafficMgrFinalized chan struct{}
 	trafficMgr          TrafficManager
-	procName            string
-	timedLogLevel       log.TimedLevel
 }
 
-func NewState(ctx context.Context, procName string) (*State, error) {
-	s := &State{
-		//LoginExecutor:     "Caller will initialize this later",
-		//UserNotifications: "The zero value is fine",
-		clusterFinalized:    make(chan struct{}),
-		trafficMgrFinalized: make(chan struct{}),
-		procName:            procName,
-		timedLogLevel:       log.NewTimedLevel(client.GetConfig(ctx).LogLevels.UserDaemon.String(), log.SetLevel),
-	}
-	return s, logging.LoadTimedLevelFromCache(ctx, s.timedLogLevel, procName)
+func (c *Connection) Name() string {
+	return c.name
+}
+
+// Done returns a channel that is closed when the connection is removed from the State.
+func (c *Connection) Done() <-chan struct{} {
+	return c.done
 }
 
-func (s *State) MaybeSetCluster(cluster *userd_k8s.Cluster) bool {
+func (c *Connection) MaybeSetCluster(cluster *userd_k8s.Cluster) bool {
 	select {
-	case <-s.clusterFinalized:
+	case <-c.clusterFinalized:
 		return false
 	default:
-		s.cluster = cluster
-		close(s.clusterFinalized)
+		c.cluster = cluster
+		close(c.clusterFinalized)
 		return true
 	}
 }
 
-func (s *State) GetClusterBlocking(ctx context.Context) (*userd_k8s.Cluster, error) {
+func (c *Connection) GetClusterBlocking(ctx context.Context) (*userd_k8s.Cluster, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
-	case <-s.clusterFinalized:
-		return s.cluster, nil
+	case <-c.clusterFinalized:
+		return c.cluster, nil
 	}
 }
 
-func (s *State) GetClusterNonBlocking() *userd_k8s.Cluster {
+func (c *Connection) GetClusterNonBlocking() *userd_k8s.Cluster {
 	select {
-	case <-s.clusterFinalized:
-		return s.cluster
+	case <-c.clusterFinalized:
+		return c.cluster
 	default:
 		return nil
 	}
 }
 
-func (s *State) MaybeSetTrafficManager(mgr TrafficManager) bool {
+func (c *Connection) MaybeSetTrafficManager(mgr TrafficManager) bool {
 	select {
-	case <-s.trafficMgrFinalized:
+	case <-c.trafficMgrFinalized:
 		return false
 	default:
-		s.trafficMgr = mgr
-		close(s.trafficMgrFinalized)
+		c.trafficMgr = mgr
+		close(c.trafficMgrFinalized)
 		return true
 	}
 }
 
-func (s *State) GetTrafficManagerBlocking(ctx context.Context) (TrafficManager, error) {
+func (c *Connection) GetTrafficManagerBlocking(ctx context.Context) (TrafficManager, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
-	case <-s.trafficMgrFinalized:
-		return s.trafficMgr, nil
+	case <-c.trafficMgrFinalized:
+		return c.trafficMgr, nil
 	}
 }
 
-func (s *State) GetTrafficManagerNonBlocking() TrafficManager {
+func (c *Connection) GetTrafficManagerNonBlocking() TrafficManager {
 	select {
-	case <-s.trafficMgrFinalized:
-		return s.trafficMgr
+	case <-c.trafficMgrFinalized:
+		return c.trafficMgr
 	default:
 		return nil
 	}
 }
 
+type State struct {
+	LoginExecutor     userd_auth.LoginExecutor
+	UserNotifications broadcastqueue.BroadcastQueue
+
+	// connections in the order that they were added
+	connections     []*Connection
+	connectionsLock sync.RWMutex
+
+	procName      string
+	timedLogLevel log.TimedLevel
+}
+
+func NewState(ctx context.Context, procName string) (*State, error) {
+	s := &State{
+		//LoginExecutor:     "Caller will initialize this later",
+		//UserNotifications: "The zero value is fine",
+		procName:      procName,
+		timedLogLevel: log.NewTimedLevel(client.GetConfig(ctx).LogLevels.UserDaemon.String(), log.SetLevel),
+	}
+	return s, logging.LoadTimedLevelFromCache(ctx, s.timedLogLevel, procName)
+}
+
+// AddConnection adds a new connection with the given name, or returns nil if a connection with
+// that name already exists.
+func (s *State) AddConnection(name string) *Connection {
+	s.connectionsLock.Lock()
+	defer s.connectionsLock.Unlock()
+	for _, c := range s.connections {
+		if c.name == name {
+			return nil
+		}
+	}
+	c := &Connection{
+		name:                name,
+		done:                make(chan struct{}),
+		clusterFinalized:    make(chan struct{}),
+		trafficMgrFinalized: make(chan struct{}),
+	}
+	s.connections = append(s.connections, c)
+	return c
+}
+
+// RemoveConnection removes the given connection and closes its Done channel. It returns the number
+// of remaining connections, and false if the connection had already been removed.
+func (s *State) RemoveConnection(conn *Connection) (int, bool) {
+	s.connectionsLock.Lock()
+	defer s.connectionsLock.Unlock()
+	for i, c := range s.connections {
+		if c == conn {
+			s.connections = append(s.connections[:i], s.connections[i+1:]...)
+			close(c.done)
+			return len(s.connections), true
+		}
+	}
+	return len(s.connections), false
+}
+
+// GetConnection returns the connection with the given name, or nil if no such connection exists.
+func (s *State) GetConnection(name string) *Connection {
+	s.connectionsLock.RLock()
+	defer s.connectionsLock.RUnlock()
+	for _, c := range s.connections {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// GetConnections returns all connections in the order that they were added.
+func (s *State) GetConnections() []*Connection {
+	s.connectionsLock.RLock()
+	defer s.connectionsLock.RUnlock()
+	cs := make([]*Connection, len(s.connections))
+	copy(cs, s.connections)
+	return cs
+}
+
+// SelectConnection returns the connection with the given name. An empty name selects the only
+// connection, or the connection with the default name when there are several. A nil connection
+// and a nil error is returned when the name is empty and there are no connections.
+func (s *State) SelectConnection(name string) (*Connection, error) {
+	if name != "" {
+		if c := s.GetConnection(name); c != nil {
+			return c, nil
+		}
+		return nil, errcat.User.Newf("there is no connection named %q", name)
+	}
+	cs := s.GetConnections()
+	switch len(cs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return cs[0], nil
+	}
+	names := make([]string, len(cs))
+	for i, c := range cs {
+		if c.name == client.DefaultConnectionName {
+			return c, nil
+		}
+		names[i] = c.name
+	}
+	return nil, errcat.User.Newf("there are several connections (%s); use --connection to select one", strings.Join(names, ", "))
+}
+
 func (s *State) GetCloudUserInfo(ctx context.Context, refresh, autoLogin bool) (*authdata.UserInfo, error) {
 	info, err := s.LoginExecutor.GetUserInfo(ctx, refresh)
 	if autoLogin && err != nil {
@@ -0,0 +1,66 @@
+package sharedstate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+func TestState_connections(t *testing.T) {
+	s := &State{}
+
+	// An empty name selects nothing when there are no connections.
+	c, err := s.SelectConnection("")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	alpha := s.AddConnection("alpha")
+	require.NotNil(t, alpha)
+	assert.Nil(t, s.AddConnection("alpha"), "a name can only be used by one connection")
+
+	// An empty name selects the only connection.
+	c, err = s.SelectConnection("")
+	assert.NoError(t, err)
+	assert.Same(t, alpha, c)
+
+	// An empty name is ambiguous when there are two connections and none of them has the default name.
+	beta := s.AddConnection("beta")
+	require.NotNil(t, beta)
+	assert.Equal(t, []*Connection{alpha, beta}, s.GetConnections())
+	_, err = s.SelectConnection("")
+	assert.Error(t, err)
+
+	c, err = s.SelectConnection("alpha")
+	assert.NoError(t, err)
+	assert.Same(t, alpha, c)
+	c, err = s.SelectConnection("beta")
+	assert.NoError(t, err)
+	assert.Same(t, beta, c)
+	_, err = s.SelectConnection("gamma")
+	assert.Error(t, err)
+
+	// The connection with the default name is selected by an empty name.
+	dflt := s.AddConnection(client.DefaultConnectionName)
+	require.NotNil(t, dflt)
+	c, err = s.SelectConnection("")
+	assert.NoError(t, err)
+	assert.Same(t, dflt, c)
+
+	// Removing a connection closes its Done channel and makes its name available again.
+	remaining, removed := s.RemoveConnection(beta)
+	assert.True(t, removed)
+	assert.Equal(t, 2, remaining)
+	select {
+	case <-beta.Done():
+	default:
+		t.Error("the Done channel of a removed connection is not closed")
+	}
+	_, removed = s.RemoveConnection(beta)
+	assert.False(t, removed)
+	_, err = s.SelectConnection("beta")
+	assert.Error(t, err)
+	assert.NotNil(t, s.AddConnection("beta"))
+}
@@ -25,9 +25,10 @@ import (
 )
 
 type Callbacks struct {
-	InterceptStatus func() *rpc.InterceptResult
+	InterceptStatus func(*sharedstate.Connection) *rpc.InterceptResult
 	Cancel          func()
 	Connect         func(c context.Context, cr *rpc.ConnectRequest, dryRun bool) *rpc.ConnectInfo
+	ListConnections func(c context.Context) *rpc.ConnectionList
 }
 
 type service struct {
@@ -68,6 +69,20 @@ func (s *service) callCtx(ctx context.Context, name string) context.Context {
 	return dgroup.WithGoroutineName(ctx, fmt.Sprintf("/%s-%d", name, atomic.AddInt64(&s.ucn, 1)))
 }
 
+// interceptStatus selects the connection that the call is intended for and returns a non-nil
+// result if that connection isn't ready for intercepts.
+func (s *service) interceptStatus(c context.Context) (*sharedstate.Connection, *rpc.InterceptResult) {
+	conn, err := s.sharedState.SelectConnection(client.GetConnectionName(c))
+	if err != nil {
+		return nil, &rpc.InterceptResult{
+			Error:         rpc.InterceptError_NO_CONNECTION,
+			ErrorText:     err.Error(),
+			ErrorCategory: int32(errcat.GetCategory(err)),
+		}
+	}
+	return conn, s.callbacks.InterceptStatus(conn)
+}
+
 func (s *service) Version(_ context.Context, _ *empty.Empty) (*common.VersionInfo, error) {
 	return &common.VersionInfo{
 		ApiVersion: client.APIVersion,
@@ -93,16 +108,25 @@ func (s *service) Status(c context.Context, cr *rpc.ConnectRequest) (ci *rpc.Con
 	return
 }
 
+func (s *service) ListConnections(c context.Context, _ *empty.Empty) (cl *rpc.ConnectionList, err error) {
+	c = s.callCtx(c, "ListConnections")
+	dlog.Debug(c, "called")
+	defer func() { err = callRecovery(c, recover(), err) }()
+	cl, err = s.callbacks.ListConnections(c), nil
+	dlog.Debug(c, "returned")
+	return
+}
+
 func (s *service) CreateIntercept(c context.Context, ir *rpc.CreateInterceptRequest) (result *rpc.InterceptResult, err error) {
 	c = s.callCtx(c, "CreateIntercept")
 	dlog.Debug(c, "called")
-	result = s.callbacks.InterceptStatus()
+	conn, result := s.interceptStatus(c)
 	if result != nil {
 		dlog.Debug(c, "returned")
 		return result, nil
 	}
 	defer func() { err = callRecovery(c, recover(), err) }()
-	mgr, err := s.sharedState.GetTrafficManagerBlocking(c)
+	mgr, err := conn.GetTrafficManagerBlocking(c)
 	if mgr == nil {
 		dlog.Debug(c, "returned")
 		return nil, err
@@ -115,13 +139,13 @@ func (s *service) CreateIntercept(c context.Context, ir *rpc.CreateInterceptRequ
 func (s *service) RemoveIntercept(c context.Context, rr *manager.RemoveInterceptRequest2) (result *rpc.InterceptResult, err error) {
 	c = s.callCtx(c, "RemoveIntercept")
 	dlog.Debug(c, "called")
-	result = s.callbacks.InterceptStatus()
+	conn, result := s.interceptStatus(c)
 	if result != nil {
 		dlog.Debug(c, "returned")
 		return result, nil
 	}
 	defer func() { err = callRecovery(c, recover(), err) }()
-	mgr, err := s.sharedState.GetTrafficManagerBlocking(c)
+	mgr, err := conn.GetTrafficManagerBlocking(c)
 	if mgr == nil {
 		dlog.Debug(c, "returned")
 		return nil, err
@@ -142,11 +166,51 @@ func (s *service) RemoveIntercept(c context.Context, rr *manager.RemoveIntercept
 	return result, nil
 }
 
+func (s *service) TransferIntercept(c context.Context, tr *manager.TransferInterceptRequest) (result *rpc.InterceptResult, err error) {
+	c = s.callCtx(c, "TransferIntercept")
+	dlog.Debug(c, "called")
+	conn, result := s.interceptStatus(c)
+	if result != nil {
+		dlog.Debug(c, "returned")
+		return result, nil
+	}
+	defer func() { err = callRecovery(c, recover(), err) }()
+	mgr, err := conn.GetTrafficManagerBlocking(c)
+	if mgr == nil {
+		dlog.Debug(c, "returned")
+		return nil, err
+	}
+	result = &rpc.InterceptResult{}
+	if result.InterceptInfo, err = mgr.TransferIntercept(c, tr.Name, tr.To); err != nil {
+		switch grpcStatus.Code(err) {
+		case grpcCodes.NotFound, grpcCodes.InvalidArgument, grpcCodes.AlreadyExists, grpcCodes.FailedPrecondition, grpcCodes.PermissionDenied:
+			// The manager's message describes what the user got wrong
+			result.Error = rpc.InterceptError_TRAFFIC_MANAGER_ERROR
+			result.ErrorText = grpcStatus.Convert(err).Message()
+			result.ErrorCategory = int32(errcat.User)
+		default:
+			result.Error = rpc.InterceptError_TRAFFIC_MANAGER_ERROR
+			result.ErrorText = err.Error()
+			result.ErrorCategory = int32(errcat.Unknown)
+		}
+	}
+	dlog.Debug(c, "returned")
+	return result, nil
+}
+
 func (s *service) List(c context.Context, lr *rpc.ListRequest) (result *rpc.WorkloadInfoSnapshot, err error) {
 	c = s.callCtx(c, "List")
 	dlog.Debug(c, "called")
+	conn, err := s.sharedState.SelectConnection(client.GetConnectionName(c))
+	if err != nil {
+		dlog.Debug(c, "returned")
+		return nil, err
+	}
 	haveManager := false
-	manager, _ := s.sharedState.GetTrafficManagerBlocking(c)
+	var manager sharedstate.TrafficManager
+	if conn != nil {
+		manager, _ = conn.GetTrafficManagerBlocking(c)
+	}
 	if manager != nil {
 		managerClient, _ := manager.GetClientNonBlocking()
 		haveManager = (managerClient != nil)
@@ -165,7 +229,12 @@ func (s *service) Uninstall(c context.Context, ur *rpc.UninstallRequest) (result
 	c = s.callCtx(c, "Uninstall")
 	dlog.Debug(c, "called")
 	defer func() { err = callRecovery(c, recover(), err) }()
-	mgr, err := s.sharedState.GetTrafficManagerBlocking(c)
+	conn, err := s.sharedState.SelectConnection(client.GetConnectionName(c))
+	if conn == nil {
+		dlog.Debug(c, "returned")
+		return nil, err
+	}
+	mgr, err := conn.GetTrafficManagerBlocking(c)
 	if mgr == nil {
 		dlog.Debug(c, "returned")
 		return nil, err
@@ -17,59 +17,121 @@ import (
 
 // mgrProxy implements rpc.ManagerServer, but just proxies all requests through a rpc.ManagerClient.
 type mgrProxy struct {
-	client      managerrpc.ManagerClient
+	getClient   func(context.Context) (managerrpc.ManagerClient, error)
 	callOptions []grpc.CallOption
 
 	managerrpc.UnsafeManagerServer
 }
 
-// NewManagerProxy returns a rpc.ManagerServer that just proxies all requests through the given rpc.ManagerClient.
-func NewManagerProxy(client managerrpc.ManagerClient, callOptions ...grpc.CallOption) managerrpc.ManagerServer {
+// NewManagerProxy returns a rpc.ManagerServer that just proxies all requests through the rpc.ManagerClient
+// that getClient returns for the request's context. This allows the proxy to serve several connections, each
+// with its own traffic-manager.
+func NewManagerProxy(getClient func(context.Context) (managerrpc.ManagerClient, error), callOptions ...grpc.CallOption) managerrpc.ManagerServer {
 	return &mgrProxy{
-		client:      client,
+		getClient:   getClient,
 		callOptions: callOptions,
 	}
 }
 func (p *mgrProxy) GetIntercept(ctx context.Context, arg *managerrpc.GetInterceptRequest) (*managerrpc.InterceptInfo, error) {
-	return p.client.GetIntercept(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetIntercept(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) Version(ctx context.Context, arg *empty.Empty) (*managerrpc.VersionInfo2, error) {
-	return p.client.Version(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Version(ctx, arg, p.callOptions...)
 }
 func (p *mgrProxy) GetLicense(ctx context.Context, arg *empty.Empty) (*managerrpc.License, error) {
-	return p.client.GetLicense(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetLicense(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) GetTelepresenceAPI(ctx context.Context, arg *empty.Empty) (*managerrpc.TelepresenceAPIInfo, error) {
-	return p.client.GetTelepresenceAPI(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetTelepresenceAPI(ctx, arg, p.callOptions...)
+}
+
+func (p *mgrProxy) GetPreviewGateway(ctx context.Context, arg *empty.Empty) (*managerrpc.PreviewGateway, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPreviewGateway(ctx, arg, p.callOptions...)
+}
+
+func (p *mgrProxy) GetClientPolicy(ctx context.Context, arg *empty.Empty) (*managerrpc.ClientPolicy, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetClientPolicy(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) CanConnectAmbassadorCloud(ctx context.Context, arg *empty.Empty) (*managerrpc.AmbassadorCloudConnection, error) {
-	return p.client.CanConnectAmbassadorCloud(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.CanConnectAmbassadorCloud(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) GetCloudConfig(ctx context.Context, arg *empty.Empty) (*managerrpc.AmbassadorCloudConfig, error) {
 	// TODO (dyung): We might want to make this always return an error since the
 	// client should already have the config.
-	return p.client.GetCloudConfig(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetCloudConfig(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) ArriveAsClient(ctx context.Context, arg *managerrpc.ClientInfo) (*managerrpc.SessionInfo, error) {
-	return p.client.ArriveAsClient(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ArriveAsClient(ctx, arg, p.callOptions...)
 }
 func (p *mgrProxy) ArriveAsAgent(ctx context.Context, arg *managerrpc.AgentInfo) (*managerrpc.SessionInfo, error) {
-	return p.client.ArriveAsAgent(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ArriveAsAgent(ctx, arg, p.callOptions...)
 }
 func (p *mgrProxy) Remain(ctx context.Context, arg *managerrpc.RemainRequest) (*empty.Empty, error) {
-	return p.client.Remain(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Remain(ctx, arg, p.callOptions...)
 }
 func (p *mgrProxy) Depart(ctx context.Context, arg *managerrpc.SessionInfo) (*empty.Empty, error) {
-	return p.client.Depart(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Depart(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) WatchAgents(arg *managerrpc.SessionInfo, srv managerrpc.Manager_WatchAgentsServer) error {
-	cli, err := p.client.WatchAgents(srv.Context(), arg, p.callOptions...)
+	client, err := p.getClient(srv.Context())
+	if err != nil {
+		return err
+	}
+	cli, err := client.WatchAgents(srv.Context(), arg, p.callOptions...)
 	if err != nil {
 		return err
 	}
@@ -87,7 +149,11 @@ func (p *mgrProxy) WatchAgents(arg *managerrpc.SessionInfo, srv managerrpc.Manag
 	}
 }
 func (p *mgrProxy) WatchIntercepts(arg *managerrpc.SessionInfo, srv managerrpc.Manager_WatchInterceptsServer) error {
-	cli, err := p.client.WatchIntercepts(srv.Context(), arg, p.callOptions...)
+	client, err := p.getClient(srv.Context())
+	if err != nil {
+		return err
+	}
+	cli, err := client.WatchIntercepts(srv.Context(), arg, p.callOptions...)
 	if err != nil {
 		return err
 	}
@@ -111,16 +177,31 @@ func (p *mgrProxy) CreateIntercept(ctx context.Context, arg *managerrpc.CreateIn
 func (p *mgrProxy) RemoveIntercept(ctx context.Context, arg *managerrpc.RemoveInterceptRequest2) (*empty.Empty, error) {
 	return nil, errors.New("must call connector.RemoveIntercept instead of manager.RemoveIntercept")
 }
+func (p *mgrProxy) TransferIntercept(ctx context.Context, arg *managerrpc.TransferInterceptRequest) (*managerrpc.InterceptInfo, error) {
+	return nil, errors.New("must call connector.TransferIntercept instead of manager.TransferIntercept")
+}
 func (p *mgrProxy) UpdateIntercept(ctx context.Context, arg *managerrpc.UpdateInterceptRequest) (*managerrpc.InterceptInfo, error) {
-	return p.client.UpdateIntercept(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.UpdateIntercept(ctx, arg, p.callOptions...)
 }
 func (p *mgrProxy) ReviewIntercept(ctx context.Context, arg *managerrpc.ReviewInterceptRequest) (*empty.Empty, error) {
-	return p.client.ReviewIntercept(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ReviewIntercept(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) ClientTunnel(fhDaemon managerrpc.Manager_ClientTunnelServer) error {
 	ctx := fhDaemon.Context()
-	fhManager, err := p.client.ClientTunnel(ctx, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	fhManager, err := client.ClientTunnel(ctx, p.callOptions...)
 	if err != nil {
 		return err
 	}
@@ -225,7 +306,11 @@ func sendLoop(ctx context.Context, who string, out tmSender, in <-chan *managerr
 
 func (p *mgrProxy) Tunnel(fhClient managerrpc.Manager_TunnelServer) error {
 	ctx := fhClient.Context()
-	fhManager, err := p.client.Tunnel(ctx, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	fhManager, err := client.Tunnel(ctx, p.callOptions...)
 	if err != nil {
 		return err
 	}
@@ -243,7 +328,11 @@ func (p *mgrProxy) Tunnel(fhClient managerrpc.Manager_TunnelServer) error {
 }
 
 func (p *mgrProxy) WatchDial(arg *managerrpc.SessionInfo, srv managerrpc.Manager_WatchDialServer) error {
-	cli, err := p.client.WatchDial(srv.Context(), arg, p.callOptions...)
+	client, err := p.getClient(srv.Context())
+	if err != nil {
+		return err
+	}
+	cli, err := client.WatchDial(srv.Context(), arg, p.callOptions...)
 	if err != nil {
 		return err
 	}
@@ -262,11 +351,19 @@ func (p *mgrProxy) WatchDial(arg *managerrpc.SessionInfo, srv managerrpc.Manager
 }
 
 func (p *mgrProxy) LookupHost(ctx context.Context, arg *managerrpc.LookupHostRequest) (*managerrpc.LookupHostResponse, error) {
-	return p.client.LookupHost(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.LookupHost(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) AgentLookupHostResponse(ctx context.Context, arg *managerrpc.LookupHostAgentResponse) (*empty.Empty, error) {
-	return p.client.AgentLookupHostResponse(ctx, arg, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.AgentLookupHostResponse(ctx, arg, p.callOptions...)
 }
 
 func (p *mgrProxy) WatchLookupHost(_ *managerrpc.SessionInfo, server managerrpc.Manager_WatchLookupHostServer) error {
@@ -274,7 +371,11 @@ func (p *mgrProxy) WatchLookupHost(_ *managerrpc.SessionInfo, server managerrpc.
 }
 
 func (p *mgrProxy) WatchClusterInfo(arg *managerrpc.SessionInfo, srv managerrpc.Manager_WatchClusterInfoServer) error {
-	cli, err := p.client.WatchClusterInfo(srv.Context(), arg, p.callOptions...)
+	client, err := p.getClient(srv.Context())
+	if err != nil {
+		return err
+	}
+	cli, err := client.WatchClusterInfo(srv.Context(), arg, p.callOptions...)
 	if err != nil {
 		return err
 	}
@@ -292,12 +393,106 @@ func (p *mgrProxy) WatchClusterInfo(arg *managerrpc.SessionInfo, srv managerrpc.
 	}
 }
 
+func (p *mgrProxy) GetEgressStatus(ctx context.Context, arg *managerrpc.SessionInfo) (*managerrpc.EgressRuleStatusList, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetEgressStatus(ctx, arg, p.callOptions...)
+}
+
 func (p *mgrProxy) SetLogLevel(ctx context.Context, request *managerrpc.LogLevelRequest) (*empty.Empty, error) {
-	return p.client.SetLogLevel(ctx, request, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.SetLogLevel(ctx, request, p.callOptions...)
 }
 
 func (p *mgrProxy) GetLogs(ctx context.Context, request *managerrpc.GetLogsRequest) (*managerrpc.LogsResponse, error) {
-	return p.client.GetLogs(ctx, request, p.callOptions...)
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetLogs(ctx, request, p.callOptions...)
+}
+
+func (p *mgrProxy) WatchLogs(request *managerrpc.WatchLogsRequest, srv managerrpc.Manager_WatchLogsServer) error {
+	client, err := p.getClient(srv.Context())
+	if err != nil {
+		return err
+	}
+	cli, err := client.WatchLogs(srv.Context(), request, p.callOptions...)
+	if err != nil {
+		return err
+	}
+	for {
+		line, err := cli.Recv()
+		if err != nil {
+			if err == io.EOF || srv.Context().Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err = srv.Send(line); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *mgrProxy) RunDiagnostics(ctx context.Context, arg *empty.Empty) (*managerrpc.DiagnosticResults, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.RunDiagnostics(ctx, arg, p.callOptions...)
+}
+
+func (p *mgrProxy) AdminListSessions(ctx context.Context, arg *managerrpc.AdminRequest) (*managerrpc.AdminSessionList, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.AdminListSessions(ctx, arg, p.callOptions...)
+}
+
+func (p *mgrProxy) AdminRemoveIntercept(ctx context.Context, arg *managerrpc.AdminRequest) (*empty.Empty, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.AdminRemoveIntercept(ctx, arg, p.callOptions...)
+}
+
+func (p *mgrProxy) AdminExpireSession(ctx context.Context, arg *managerrpc.AdminRequest) (*empty.Empty, error) {
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.AdminExpireSession(ctx, arg, p.callOptions...)
+}
+
+func (p *mgrProxy) WatchNotifications(arg *managerrpc.SessionInfo, srv managerrpc.Manager_WatchNotificationsServer) error {
+	client, err := p.getClient(srv.Context())
+	if err != nil {
+		return err
+	}
+	cli, err := client.WatchNotifications(srv.Context(), arg, p.callOptions...)
+	if err != nil {
+		return err
+	}
+	for {
+		n, err := cli.Recv()
+		if err != nil {
+			if err == io.EOF || srv.Context().Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err = srv.Send(n); err != nil {
+			return err
+		}
+	}
 }
 
 func (p *mgrProxy) WatchLogLevel(e *empty.Empty, server managerrpc.Manager_WatchLogLevelServer) error {
@@ -3,16 +3,20 @@ package userd_k8s
 import (
 	"context"
 	"encoding/json"
+	"net"
 	"sort"
 
 	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 
 	"github.com/datawire/ambassador/v2/pkg/kates"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/egress"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
 )
 
@@ -44,6 +48,53 @@ type dnsConfig struct {
 type managerConfig struct {
 	// Namespace is the name of the namespace where the traffic manager is to be found
 	Namespace string `json:"namespace,omitempty"`
+
+	// Address is the host:port of a LoadBalancer, Ingress, or NodePort where the traffic manager's
+	// TLS protected gRPC API can be reached directly, i.e. without a port-forward through the API
+	// server.
+	Address string `json:"address,omitempty"`
+
+	// Direct enables a direct connection to the traffic manager using an address that is discovered
+	// from the traffic-manager-direct Service. It's implied when an Address is given.
+	Direct bool `json:"direct,omitempty"`
+
+	// ServerName is the name used when verifying the certificate of the traffic manager. It
+	// defaults to the host of the address.
+	ServerName string `json:"server-name,omitempty"`
+
+	// CertificateAuthority is the path to a PEM encoded certificate authority used when verifying
+	// the certificate of the traffic manager.
+	CertificateAuthority string `json:"certificate-authority,omitempty"`
+
+	// CertificateAuthorityData is a PEM encoded certificate authority used when verifying the
+	// certificate of the traffic manager. It takes precedence over CertificateAuthority.
+	CertificateAuthorityData []byte `json:"certificate-authority-data,omitempty"`
+
+	// InsecureSkipTLSVerify disables verification of the traffic manager's certificate.
+	InsecureSkipTLSVerify bool `json:"insecure-skip-tls-verify,omitempty"`
+
+	// ClientCertificate is the path to a PEM encoded certificate that the client presents to the
+	// traffic manager when it requires mutual TLS. Only used by a direct connection.
+	ClientCertificate string `json:"client-certificate,omitempty"`
+
+	// ClientCertificateData is a PEM encoded client certificate. It takes precedence over
+	// ClientCertificate.
+	ClientCertificateData []byte `json:"client-certificate-data,omitempty"`
+
+	// ClientKey is the path to the PEM encoded private key of the client certificate.
+	ClientKey string `json:"client-key,omitempty"`
+
+	// ClientKeyData is the PEM encoded private key of the client certificate. It takes precedence
+	// over ClientKey.
+	ClientKeyData []byte `json:"client-key-data,omitempty"`
+
+	// Token is a bearer token, typically that of a ServiceAccount, that the client presents to a
+	// traffic manager that authenticates clients using a TokenReview.
+	Token string `json:"token,omitempty"`
+
+	// TokenFile is the path to a file containing the bearer token. The file is read on each call,
+	// so that rotated tokens are picked up. Token takes precedence over TokenFile.
+	TokenFile string `json:"token-file,omitempty"`
 }
 
 // kubeconfigExtension is an extension read from the selected kubeconfig Cluster.
@@ -56,6 +107,7 @@ type kubeconfigExtension struct {
 
 type Config struct {
 	kubeconfigExtension
+	EgressRules []*manager.EgressRule
 	Namespace   string // default cluster namespace.
 	Context     string
 	Server      string
@@ -148,6 +200,81 @@ func NewConfig(c context.Context, flagMap map[string]string) (*Config, error) {
 	return k, nil
 }
 
+// AddAlsoProxy adds the given subnets, in CIDR notation, to the also-proxy subnets declared in the
+// kubeconfig extension.
+func (kf *Config) AddAlsoProxy(cidrs []string) error {
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errcat.User.Newf("invalid also-proxy subnet %q: %w", cidr, err)
+		}
+		kf.AlsoProxy = append(kf.AlsoProxy, (*iputil.Subnet)(ipNet))
+	}
+	return nil
+}
+
+// AddEgressVia adds the egress rules declared by the given specs, on the form
+// [<subnet>=]<workload>[.<namespace>]. The subnets of the rules are proxied too.
+func (kf *Config) AddEgressVia(specs []string) error {
+	rules := make([]*manager.EgressRule, len(specs))
+	for i, spec := range specs {
+		rule, err := egress.Parse(spec, kf.Namespace)
+		if err != nil {
+			return errcat.User.New(err)
+		}
+		rules[i] = rule
+	}
+	kf.AddEgressRules(rules)
+	return nil
+}
+
+// AddEgressRules adds the given egress rules and proxies their subnets.
+func (kf *Config) AddEgressRules(rules []*manager.EgressRule) {
+	for _, rule := range rules {
+		if rule.Subnet != "" {
+			if _, ipNet, err := net.ParseCIDR(rule.Subnet); err == nil {
+				kf.AlsoProxy = append(kf.AlsoProxy, (*iputil.Subnet)(ipNet))
+			}
+		}
+	}
+	kf.EgressRules = append(kf.EgressRules, rules...)
+}
+
+// EgressRulesEqual determines if this instance has the same egress rules as the given instance.
+func (kf *Config) EgressRulesEqual(okf *Config) bool {
+	if len(kf.EgressRules) != len(okf.EgressRules) {
+		return false
+	}
+	for i, rule := range kf.EgressRules {
+		if !proto.Equal(rule, okf.EgressRules[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// AlsoProxyEqual determines if this instance proxies the same subnets as the given instance, regardless of
+// their order.
+func (kf *Config) AlsoProxyEqual(okf *Config) bool {
+	set := func(subnets []*iputil.Subnet) map[string]struct{} {
+		s := make(map[string]struct{}, len(subnets))
+		for _, subnet := range subnets {
+			s[(*net.IPNet)(subnet).String()] = struct{}{}
+		}
+		return s
+	}
+	as, bs := set(kf.AlsoProxy), set(okf.AlsoProxy)
+	if len(as) != len(bs) {
+		return false
+	}
+	for k := range as {
+		if _, ok := bs[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // ContextServiceAndFlagsEqual determines if this instance is equal to the given instance with respect to context,
 // server, and flag arguments.
 func (kf *Config) ContextServiceAndFlagsEqual(okf *Config) bool {
@@ -161,6 +288,13 @@ func (kf *Config) GetManagerNamespace() string {
 	return kf.kubeconfigExtension.Manager.Namespace
 }
 
+// UseDirectManagerConnection returns true if a direct connection to the traffic manager should be
+// attempted before resorting to a port-forward.
+func (kf *Config) UseDirectManagerConnection() bool {
+	mc := kf.kubeconfigExtension.Manager
+	return mc.Direct || mc.Address != ""
+}
+
 func sliceEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -8,11 +8,18 @@ import (
 
 func (tm *trafficManager) dialRequestWatcher(ctx context.Context) error {
 	<-tm.startup
-	// Deal with dial requests from the manager
-	dialerStream, err := tm.managerClient.WatchDial(ctx, tm.sessionInfo)
-	if err != nil {
-		return err
+	ctx = tunnel.WithShaping(ctx, tm.interceptShaping)
+	for {
+		// Deal with dial requests from the manager. The stream ends with the session, so a new
+		// stream is started when the session is replaced.
+		session := tm.session()
+		dialerStream, err := tm.managerClient.WatchDial(ctx, session)
+		if err != nil {
+			return err
+		}
+		tunnel.DialWaitLoop(ctx, tm.managerClient, dialerStream, session.SessionId)
+		if !tm.awaitSessionChange(ctx, session.SessionId) {
+			return nil
+		}
 	}
-	tunnel.DialWaitLoop(ctx, tm.managerClient, dialerStream, tm.sessionInfo.SessionId)
-	return nil
 }
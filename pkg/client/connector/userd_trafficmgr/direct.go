@@ -0,0 +1,274 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/datawire/ambassador/v2/pkg/kates"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnet"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+// directAddressAnnotation can be set on the traffic-manager-direct Service to declare the address
+// of an Ingress, or of some other endpoint that can't be discovered from the Service itself.
+const directAddressAnnotation = install.DomainPrefix + "direct-address"
+
+// directDialTimeout limits the attempt to connect directly to the traffic-manager, so that an
+// unreachable address doesn't delay the fallback to a port-forward for long.
+const directDialTimeout = 5 * time.Second
+
+// dialManager returns a connection to the traffic-manager's gRPC API. When enabled in the kubeconfig
+// extension, a direct TLS protected connection is attempted first. A port-forward through the API
+// server is used when the direct connection isn't enabled or fails.
+func (tm *trafficManager) dialManager(c context.Context) (*grpc.ClientConn, error) {
+	return dialWithFallback(c, tm.UseDirectManagerConnection(), tm.dialManagerDirect, tm.dialManagerPortForward)
+}
+
+// dialWithFallback calls dialDirect when direct is true, and dialPortForward when direct is false or
+// dialDirect fails. The direct dial is limited by the directDialTimeout.
+func dialWithFallback(c context.Context, direct bool, dialDirect, dialPortForward func(context.Context) (*grpc.ClientConn, error)) (*grpc.ClientConn, error) {
+	if direct {
+		tc, cancel := context.WithTimeout(c, directDialTimeout)
+		conn, err := dialDirect(tc)
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		dlog.Warnf(c, "Unable to connect directly to the traffic-manager, falling back to port-forward: %v", err)
+	}
+	return dialPortForward(c)
+}
+
+// dialManagerPortForward dials the traffic-manager's gRPC port using a port-forward through the API server.
+func (tm *trafficManager) dialManagerPortForward(c context.Context) (*grpc.ClientConn, error) {
+	grpcDialer, err := dnet.NewK8sPortForwardDialer(c, tm.ConfigFlags, tm.Client())
+	if err != nil {
+		return nil, err
+	}
+	grpcAddr := net.JoinHostPort(
+		"svc/traffic-manager."+tm.GetManagerNamespace(),
+		fmt.Sprint(install.ManagerPortHTTP))
+
+	tc, cancel := client.GetConfig(c).Timeouts.TimeoutContext(c, client.TimeoutTrafficManagerAPI)
+	defer cancel()
+	conn, err := grpc.DialContext(tc, grpcAddr, append(tm.managerAuthDialOptions(true),
+		grpc.WithContextDialer(grpcDialer),
+		grpc.WithInsecure(),
+		grpc.WithNoProxy(),
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError())...)
+	if err != nil {
+		return nil, client.CheckTimeout(tc, fmt.Errorf("dial manager: %w", err))
+	}
+	return conn, nil
+}
+
+// dialManagerDirect dials the traffic-manager's TLS protected gRPC port without going through the
+// API server.
+func (tm *trafficManager) dialManagerDirect(c context.Context) (*grpc.ClientConn, error) {
+	addr, err := tm.directManagerAddress(c)
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errcat.Config.Newf("invalid traffic-manager address %q: %w", addr, err)
+	}
+	tlsConfig, err := tm.managerTLSConfig(c, host)
+	if err != nil {
+		return nil, err
+	}
+	dlog.Infof(c, "Connecting directly to the traffic-manager at %s", addr)
+	conn, err := grpc.DialContext(c, addr, append(tm.managerAuthDialOptions(false),
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError())...)
+	if err != nil {
+		return nil, client.CheckTimeout(c, fmt.Errorf("dial manager at %s: %w", addr, err))
+	}
+	return conn, nil
+}
+
+// directManagerAddress returns the address declared in the kubeconfig extension, or else the
+// address discovered from the traffic-manager-direct Service. The Service's direct-address
+// annotation takes precedence. Otherwise, the ingress of a LoadBalancer Service or the address of
+// a node for a NodePort Service is used.
+func (tm *trafficManager) directManagerAddress(c context.Context) (string, error) {
+	if addr := tm.Manager.Address; addr != "" {
+		return addr, nil
+	}
+
+	ns := tm.GetManagerNamespace()
+	svc := &kates.Service{
+		TypeMeta:   kates.TypeMeta{Kind: "Service"},
+		ObjectMeta: kates.ObjectMeta{Name: install.ManagerDirectServiceName, Namespace: ns},
+	}
+	if err := tm.Client().Get(c, svc, svc); err != nil {
+		return "", fmt.Errorf("unable to get service %s.%s: %w", install.ManagerDirectServiceName, ns, err)
+	}
+	if addr := svc.Annotations[directAddressAnnotation]; addr != "" {
+		return addr, nil
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return "", errcat.Config.Newf("service %s.%s has no ports", svc.Name, ns)
+	}
+	port := svc.Spec.Ports[0]
+
+	switch svc.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		for _, ing := range svc.Status.LoadBalancer.Ingress {
+			host := ing.Hostname
+			if host == "" {
+				host = ing.IP
+			}
+			if host != "" {
+				return net.JoinHostPort(host, fmt.Sprint(port.Port)), nil
+			}
+		}
+		return "", errcat.Config.Newf("the LoadBalancer of service %s.%s has no ingress address", svc.Name, ns)
+	case corev1.ServiceTypeNodePort:
+		var nodes []*kates.Node
+		if err := tm.Client().List(c, kates.Query{Kind: "Node"}, &nodes); err != nil {
+			return "", fmt.Errorf("unable to list nodes: %w", err)
+		}
+		for _, addrType := range []corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP} {
+			for _, node := range nodes {
+				for _, na := range node.Status.Addresses {
+					if na.Type == addrType {
+						return net.JoinHostPort(na.Address, fmt.Sprint(port.NodePort)), nil
+					}
+				}
+			}
+		}
+		return "", errcat.Config.Newf("unable to find the address of a node for service %s.%s", svc.Name, ns)
+	default:
+		return "", errcat.Config.Newf("service %s.%s of type %s cannot be reached directly; "+
+			"declare its address in the %s annotation", svc.Name, ns, svc.Spec.Type, directAddressAnnotation)
+	}
+}
+
+// managerAuthDialOptions returns the dial options that make the client present the token declared
+// in the kubeconfig extension to the traffic-manager. The token is never sent over a plaintext
+// connection, unless portForward is true.
+func (tm *trafficManager) managerAuthDialOptions(portForward bool) []grpc.DialOption {
+	mc := tm.Manager
+	if mc.Token == "" && mc.TokenFile == "" {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithPerRPCCredentials(&tokenCredentials{token: mc.Token, file: mc.TokenFile, portForward: portForward})}
+}
+
+// tokenCredentials implements credentials.PerRPCCredentials by passing a bearer token in the
+// authorization metadata of each call.
+type tokenCredentials struct {
+	token       string
+	file        string
+	portForward bool
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (tc *tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	token := tc.token
+	if token == "" {
+		data, err := os.ReadFile(tc.file)
+		if err != nil {
+			return nil, errcat.Config.Newf("unable to read the traffic-manager token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials. The plaintext connection of a
+// port-forward is the only exception, because the port-forward is protected by the API server's TLS,
+// and the traffic-manager only accepts tokens over plaintext when they arrive from a port-forward.
+func (tc *tokenCredentials) RequireTransportSecurity() bool {
+	return !tc.portForward
+}
+
+// addClientCertificate adds the client certificate that is presented to a traffic-manager that
+// requires mutual TLS, unless no certificate is configured. Data takes precedence over files.
+func addClientCertificate(tlsConfig *tls.Config, certData, keyData []byte, certFile, keyFile string) error {
+	var err error
+	if len(certData) == 0 && certFile != "" {
+		if certData, err = os.ReadFile(certFile); err != nil {
+			return errcat.Config.Newf("unable to read the traffic-manager client certificate: %w", err)
+		}
+	}
+	if len(keyData) == 0 && keyFile != "" {
+		if keyData, err = os.ReadFile(keyFile); err != nil {
+			return errcat.Config.Newf("unable to read the traffic-manager client key: %w", err)
+		}
+	}
+	if len(certData) == 0 && len(keyData) == 0 {
+		return nil
+	}
+	cert, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return errcat.Config.Newf("invalid traffic-manager client certificate: %w", err)
+	}
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	return nil
+}
+
+// managerTLSConfig returns the TLS configuration used when connecting directly to the traffic-manager.
+// The certificate authority is taken from the kubeconfig extension, or else from the traffic-manager-ca
+// ConfigMap. The system's certificate authorities are used when neither is found. The client
+// certificate declared in the kubeconfig extension, if any, is presented to the traffic-manager.
+func (tm *trafficManager) managerTLSConfig(c context.Context, host string) (*tls.Config, error) {
+	mc := tm.Manager
+	tlsConfig := &tls.Config{
+		ServerName: mc.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	if err := addClientCertificate(tlsConfig, mc.ClientCertificateData, mc.ClientKeyData, mc.ClientCertificate, mc.ClientKey); err != nil {
+		return nil, err
+	}
+	if mc.InsecureSkipTLSVerify {
+		// #nosec G402
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+
+	caData := mc.CertificateAuthorityData
+	if len(caData) == 0 && mc.CertificateAuthority != "" {
+		var err error
+		if caData, err = os.ReadFile(mc.CertificateAuthority); err != nil {
+			return nil, errcat.Config.Newf("unable to read the traffic-manager certificate authority: %w", err)
+		}
+	}
+	if len(caData) == 0 {
+		ns := tm.GetManagerNamespace()
+		cm := &kates.ConfigMap{
+			TypeMeta:   kates.TypeMeta{Kind: "ConfigMap"},
+			ObjectMeta: kates.ObjectMeta{Name: install.ManagerDirectCAName, Namespace: ns},
+		}
+		if err := tm.Client().Get(c, cm, cm); err != nil {
+			dlog.Debugf(c, "unable to get configmap %s.%s, using the system's certificate authorities: %v", install.ManagerDirectCAName, ns, err)
+			return tlsConfig, nil
+		}
+		caData = []byte(cm.Data["ca.pem"])
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, errcat.Config.New("no valid PEM encoded certificates found in the traffic-manager certificate authority")
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
@@ -0,0 +1,318 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/connector/userd_k8s"
+)
+
+// testCA is a certificate authority that issues the certificates of the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "traffic-manager-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM encoded certificate and key of a server certificate for the given IPs and DNS
+// names, or of a client certificate when no IPs or names are given.
+func (ca *testCA) issue(t *testing.T, ips []net.IP, dnsNames ...string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	usage := x509.ExtKeyUsageServerAuth
+	if len(ips) == 0 && len(dnsNames) == 0 {
+		usage = x509.ExtKeyUsageClientAuth
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "traffic-manager"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  ips,
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// versionServer is a traffic-manager that only serves its version. It records the authorization
+// metadata of the last call.
+type versionServer struct {
+	manager.UnimplementedManagerServer
+	sync.Mutex
+	authorization string
+}
+
+func (s *versionServer) Version(ctx context.Context, _ *empty.Empty) (*manager.VersionInfo2, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.Lock()
+	s.authorization = strings.Join(md.Get("authorization"), ",")
+	s.Unlock()
+	return &manager.VersionInfo2{Version: "v2.4.9"}, nil
+}
+
+func (s *versionServer) getAuthorization() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.authorization
+}
+
+// startDirectServer starts a TLS protected traffic-manager on a loopback address, using a certificate
+// with an IP SAN for that address. Client certificates signed by clientCA are required unless
+// clientCA is nil.
+func startDirectServer(t *testing.T, ca, clientCA *testCA) (string, *versionServer) {
+	certPEM, keyPEM := ca.issue(t, []net.IP{{127, 0, 0, 1}}, "traffic-manager-direct.ambassador")
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA.cert)
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	vs := &versionServer{}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	manager.RegisterManagerServer(srv, vs)
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	t.Cleanup(srv.Stop)
+	return l.Addr().String(), vs
+}
+
+func testDirectContext(t *testing.T) context.Context {
+	ctx := dlog.NewTestContext(t, false)
+	env, err := client.LoadEnv(ctx)
+	require.NoError(t, err)
+	ctx = client.WithEnv(ctx, env)
+	cfg := client.GetDefaultConfig(ctx)
+	cfg.Timeouts.PrivateTrafficManagerAPI = 5 * time.Second
+	return client.WithConfig(ctx, &cfg)
+}
+
+// newDirectTrafficManager returns a trafficManager with a kubeconfig that declares the given settings
+// in the manager section of its extension. The API server of the kubeconfig is never contacted.
+func newDirectTrafficManager(t *testing.T, ctx context.Context, managerSettings map[string]interface{}) *trafficManager {
+	var ext strings.Builder
+	for k, v := range managerSettings {
+		js, err := json.Marshal(v)
+		require.NoError(t, err)
+		fmt.Fprintf(&ext, "\n          %s: %s", k, js)
+	}
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+    extensions:
+    - name: telepresence.io
+      extension:
+        manager:`+ext.String()+`
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user: {}
+`), 0o600))
+	cfg, err := userd_k8s.NewConfig(ctx, map[string]string{"kubeconfig": kubeconfig})
+	require.NoError(t, err)
+	return &trafficManager{installer: &installer{Cluster: &userd_k8s.Cluster{Config: cfg}}}
+}
+
+// directVersion dials the traffic-manager directly and calls its Version method. A dial that is
+// rejected keeps retrying until the context is done, so the timeout is short.
+func directVersion(ctx context.Context, tm *trafficManager) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	conn, err := tm.dialManagerDirect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = manager.NewManagerClient(conn).Version(ctx, &empty.Empty{})
+	return err
+}
+
+func TestDialManagerDirect(t *testing.T) {
+	ctx := testDirectContext(t)
+	ca := newTestCA(t)
+	addr, vs := startDirectServer(t, ca, nil)
+	caData := base64.StdEncoding.EncodeToString(ca.pem)
+
+	t.Run("verified by IP", func(t *testing.T) {
+		tm := newDirectTrafficManager(t, ctx, map[string]interface{}{
+			"address":                    addr,
+			"certificate-authority-data": caData,
+			"token":                      "secret",
+		})
+		assert.True(t, tm.UseDirectManagerConnection())
+		require.NoError(t, directVersion(ctx, tm))
+		assert.Equal(t, "Bearer secret", vs.getAuthorization())
+	})
+
+	t.Run("verified by server name", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("rotated\n"), 0o600))
+		tm := newDirectTrafficManager(t, ctx, map[string]interface{}{
+			"address":               addr,
+			"server-name":           "traffic-manager-direct.ambassador",
+			"certificate-authority": caFile,
+			"token-file":            tokenFile,
+		})
+		require.NoError(t, directVersion(ctx, tm))
+		assert.Equal(t, "Bearer rotated", vs.getAuthorization())
+	})
+
+	t.Run("wrong server name", func(t *testing.T) {
+		tm := newDirectTrafficManager(t, ctx, map[string]interface{}{
+			"address":                    addr,
+			"server-name":                "tm.example.com",
+			"certificate-authority-data": caData,
+		})
+		assert.Error(t, directVersion(ctx, tm))
+	})
+
+	t.Run("untrusted certificate authority", func(t *testing.T) {
+		tm := newDirectTrafficManager(t, ctx, map[string]interface{}{
+			"address":                    addr,
+			"certificate-authority-data": base64.StdEncoding.EncodeToString(newTestCA(t).pem),
+		})
+		assert.Error(t, directVersion(ctx, tm))
+	})
+
+	t.Run("insecure", func(t *testing.T) {
+		tm := newDirectTrafficManager(t, ctx, map[string]interface{}{
+			"address":                  addr,
+			"insecure-skip-tls-verify": true,
+		})
+		assert.NoError(t, directVersion(ctx, tm))
+	})
+
+	t.Run("invalid certificate authority", func(t *testing.T) {
+		tm := newDirectTrafficManager(t, ctx, map[string]interface{}{
+			"address":                    addr,
+			"certificate-authority-data": base64.StdEncoding.EncodeToString([]byte("not a certificate")),
+		})
+		assert.Error(t, directVersion(ctx, tm))
+	})
+}
+
+func TestDialManagerDirect_mutualTLS(t *testing.T) {
+	ctx := testDirectContext(t)
+	ca := newTestCA(t)
+	clientCA := newTestCA(t)
+	addr, _ := startDirectServer(t, ca, clientCA)
+	settings := map[string]interface{}{
+		"address":                    addr,
+		"certificate-authority-data": base64.StdEncoding.EncodeToString(ca.pem),
+	}
+
+	// The traffic-manager rejects a client without a certificate.
+	assert.Error(t, directVersion(ctx, newDirectTrafficManager(t, ctx, settings)))
+
+	certPEM, keyPEM := clientCA.issue(t, nil)
+	settings["client-certificate-data"] = base64.StdEncoding.EncodeToString(certPEM)
+	settings["client-key-data"] = base64.StdEncoding.EncodeToString(keyPEM)
+	assert.NoError(t, directVersion(ctx, newDirectTrafficManager(t, ctx, settings)))
+}
+
+func TestDialWithFallback(t *testing.T) {
+	ctx := testDirectContext(t)
+	direct := &grpc.ClientConn{}
+	portForward := &grpc.ClientConn{}
+	var calls []string
+	dialer := func(name string, conn *grpc.ClientConn, err error) func(context.Context) (*grpc.ClientConn, error) {
+		return func(c context.Context) (*grpc.ClientConn, error) {
+			calls = append(calls, name)
+			if dl, ok := c.Deadline(); name == "direct" && (!ok || time.Until(dl) > directDialTimeout) {
+				return nil, errors.New("the direct dial has no short timeout")
+			}
+			return conn, err
+		}
+	}
+
+	conn, err := dialWithFallback(ctx, false, dialer("direct", direct, nil), dialer("port-forward", portForward, nil))
+	assert.NoError(t, err)
+	assert.Same(t, portForward, conn)
+	assert.Equal(t, []string{"port-forward"}, calls)
+
+	calls = nil
+	conn, err = dialWithFallback(ctx, true, dialer("direct", direct, nil), dialer("port-forward", portForward, nil))
+	assert.NoError(t, err)
+	assert.Same(t, direct, conn)
+	assert.Equal(t, []string{"direct"}, calls)
+
+	calls = nil
+	conn, err = dialWithFallback(ctx, true, dialer("direct", nil, errors.New("unreachable")), dialer("port-forward", portForward, nil))
+	assert.NoError(t, err)
+	assert.Same(t, portForward, conn)
+	assert.Equal(t, []string{"direct", "port-forward"}, calls)
+
+	calls = nil
+	_, err = dialWithFallback(ctx, true, dialer("direct", nil, errors.New("unreachable")), dialer("port-forward", nil, errors.New("no cluster")))
+	assert.EqualError(t, err, "no cluster")
+}
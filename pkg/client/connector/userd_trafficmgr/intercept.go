@@ -33,6 +33,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/header"
 	"github.com/telepresenceio/telepresence/v2/pkg/install"
 	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
 type forwardKey struct {
@@ -153,7 +154,8 @@ func (tm *trafficManager) workerPortForwardIntercepts(ctx context.Context) error
 	backoff := 100 * time.Millisecond
 	for ctx.Err() == nil {
 		<-tm.startup
-		stream, err := tm.managerClient.WatchIntercepts(ctx, tm.session())
+		session := tm.session()
+		stream, err := tm.managerClient.WatchIntercepts(ctx, session)
 		if err != nil {
 			err = fmt.Errorf("manager.WatchIntercepts dial: %w", err)
 		}
@@ -213,6 +215,9 @@ func (tm *trafficManager) workerPortForwardIntercepts(ctx context.Context) error
 			tm.reconcileMountPoints(ctx, allNames)
 			if ctx.Err() == nil {
 				tm.SetInterceptedNamespaces(ctx, namespaces)
+				if err == nil {
+					tm.forgetVanishedIntercepts(ctx, session.SessionId, allNames)
+				}
 			}
 		}
 
@@ -282,6 +287,7 @@ func (tm *trafficManager) AddIntercept(c context.Context, ir *rpc.CreateIntercep
 	}
 
 	<-tm.startup
+	c = tm.withConfig(c)
 	for _, iCept := range tm.getCurrentIntercepts() {
 		if iCept.Spec.Name == spec.Name {
 			return interceptError(rpc.InterceptError_ALREADY_EXISTS, errcat.User.Newf(spec.Name)), nil
@@ -296,16 +302,68 @@ func (tm *trafficManager) AddIntercept(c context.Context, ir *rpc.CreateIntercep
 		}
 	}
 
+	if ir.NetworkShaping != "" {
+		if spec.Agent == "" {
+			return interceptError(rpc.InterceptError_INVALID_NETWORK_SHAPING, errcat.User.New(
+				"network shaping cannot be used with a local-only intercept")), nil
+		}
+		if _, err := tunnel.ParseFaults(ir.NetworkShaping); err != nil {
+			return interceptError(rpc.InterceptError_INVALID_NETWORK_SHAPING, errcat.User.New(err)), nil
+		}
+	} else if ir.ShapeOutbound {
+		return interceptError(rpc.InterceptError_INVALID_NETWORK_SHAPING, errcat.User.New(
+			"outbound shaping requires network conditions to simulate")), nil
+	}
+
 	if spec.Agent == "" {
 		return tm.AddLocalOnlyIntercept(c, spec)
 	}
 
+	cfg := client.GetConfig(c)
+	if err := cfg.CheckAgentImage(c, tm.clientPolicy); err != nil {
+		return interceptError(rpc.InterceptError_AGENT_IMAGE_NOT_ALLOWED, err), nil
+	}
+	if ir.AgentImage == "" {
+		ir.AgentImage = cfg.AgentImage(tm.clientPolicy)
+	}
+	if ir.AgentImage != "" && !install.AgentImageAllowed(tm.clientPolicy, ir.AgentImage) {
+		return interceptError(rpc.InterceptError_AGENT_IMAGE_NOT_ALLOWED, errcat.Config.Newf(
+			"agent image %s is not allowed by the traffic-manager's client policy; allowed images are %v",
+			ir.AgentImage, tm.clientPolicy.AllowedAgentImages)), nil
+	}
+
 	spec.Client = tm.userAndHost
 	if spec.Mechanism == "" {
-		spec.Mechanism = "tcp"
+		spec.Mechanism = tm.clientPolicy.GetDefaultMechanism()
+		if spec.Mechanism == "" {
+			spec.Mechanism = "tcp"
+		}
+	}
+
+	// A recreated intercept must start from the original request, not from the spec that the
+	// mechanism plugin modified.
+	remembered := ir
+	var plugin *mechanismPlugin
+	var pluginEnv map[string]string
+	if ir.MechanismPlugin != "" {
+		remembered = proto.Clone(ir).(*rpc.CreateInterceptRequest)
+		// The plugin of an intercept in a lost session is still running
+		tm.stopPlugin(spec.Name)
+		var err error
+		if plugin, err = startMechanismPlugin(c, spec.Name, ir.MechanismPlugin); err != nil {
+			return interceptError(rpc.InterceptError_MECHANISM_PLUGIN_ERROR, err), nil
+		}
+		defer func() {
+			// The plugin is only kept when the intercept was established
+			if plugin != nil {
+				plugin.stop()
+			}
+		}()
+		if pluginEnv, err = plugin.startIntercept(c, spec); err != nil {
+			return interceptError(rpc.InterceptError_MECHANISM_PLUGIN_ERROR, err), nil
+		}
 	}
 
-	cfg := client.GetConfig(c)
 	apiPort := uint16(cfg.TelepresenceAPI.Port)
 	if apiPort == 0 {
 		// Default to the API port declared by the traffic-manager
@@ -391,6 +449,15 @@ func (tm *trafficManager) AddIntercept(c context.Context, ir *rpc.CreateIntercep
 			deleteMount = false // Mount-point is busy until intercept ends
 			ii.Spec.MountPoint = ir.MountPoint
 		}
+		if plugin != nil {
+			for k, v := range pluginEnv {
+				result.Environment[k] = v
+			}
+			tm.addPlugin(plugin)
+			plugin = nil // Plugin runs until intercept ends
+		}
+		tm.rememberIntercept(remembered)
+		tm.updateOutboundShaping(c)
 		return result, nil
 	}
 }
@@ -517,7 +584,11 @@ func (tm *trafficManager) RemoveIntercept(c context.Context, name string) error
 		return tm.RemoveLocalOnlyIntercept(c, name, ns)
 	}
 	dlog.Debugf(c, "telling manager to remove intercept %s", name)
+	tm.forgetIntercept(name)
+	tm.stopPlugin(name)
+	tm.updateOutboundShaping(c)
 	<-tm.startup
+	c = tm.withConfig(c)
 	_, err := tm.managerClient.RemoveIntercept(c, &manager.RemoveInterceptRequest2{
 		Session: tm.session(),
 		Name:    name,
@@ -525,6 +596,27 @@ func (tm *trafficManager) RemoveIntercept(c context.Context, name string) error
 	return err
 }
 
+// TransferIntercept hands one intercept over to the client with the given session ID or name.
+func (tm *trafficManager) TransferIntercept(c context.Context, name, to string) (*manager.InterceptInfo, error) {
+	if _, ok := tm.LocalIntercepts[name]; ok {
+		return nil, grpcStatus.Errorf(grpcCodes.InvalidArgument, "local-only intercept %q cannot be transferred", name)
+	}
+	dlog.Debugf(c, "telling manager to transfer intercept %s to %s", name, to)
+	<-tm.startup
+	c = tm.withConfig(c)
+	ii, err := tm.managerClient.TransferIntercept(c, &manager.TransferInterceptRequest{
+		Session: tm.session(),
+		Name:    name,
+		To:      to,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tm.forgetIntercept(name)
+	tm.stopPlugin(name)
+	return ii, nil
+}
+
 // clearIntercepts removes all intercepts
 func (tm *trafficManager) clearIntercepts(c context.Context) error {
 	<-tm.startup
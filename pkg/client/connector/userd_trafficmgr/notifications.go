@@ -0,0 +1,37 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+// notificationWatcher forwards the messages that the traffic-manager wants to convey to the user, such
+// as notices about intercepts that an administrator removed.
+func (tm *trafficManager) notificationWatcher(ctx context.Context) error {
+	<-tm.startup
+	for {
+		// The stream ends with the session, so a new stream is started when the session is replaced.
+		session := tm.session()
+		stream, err := tm.managerClient.WatchNotifications(ctx, session)
+		if err != nil {
+			return err
+		}
+		for {
+			n, err := stream.Recv()
+			if err != nil {
+				// Older traffic-managers don't implement WatchNotifications, so this isn't an error.
+				if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+					dlog.Debugf(ctx, "manager notifications ended: %v", err)
+				}
+				break
+			}
+			tm.callbacks.Notify(n.Message)
+		}
+		if !tm.awaitSessionChange(ctx, session.SessionId) {
+			return nil
+		}
+	}
+}
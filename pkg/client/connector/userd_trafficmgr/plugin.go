@@ -0,0 +1,178 @@
+package userd_trafficmgr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dexec"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/extension"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+const pluginTimeout = 10 * time.Second
+
+// mechanismPlugin is a running plugin executable that implements the MechanismPlugin service for the
+// mechanism of one intercept.
+type mechanismPlugin struct {
+	extension.MechanismPluginClient
+	ctx    context.Context
+	name   string
+	conn   *grpc.ClientConn
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the process has ended
+}
+
+// startMechanismPlugin starts the given plugin executable and connects to the address that it
+// announces on the first line of its stdout. The plugin runs until it's stopped, even if the given
+// context is cancelled before that.
+func startMechanismPlugin(ctx context.Context, name, path string) (*mechanismPlugin, error) {
+	ctx, cancel := context.WithCancel(dcontext.WithoutCancel(ctx))
+	cmd := dexec.CommandContext(ctx, path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("unable to start plugin %s: %w", path, err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			dlog.Errorf(ctx, "plugin %s of intercept %s ended: %v", path, name, err)
+		}
+	}()
+	abort := func() {
+		cancel()
+		<-done
+	}
+
+	type result struct {
+		line string
+		err  error
+	}
+	rc := make(chan result, 1)
+	go func() {
+		rd := bufio.NewReader(stdout)
+		line, err := rd.ReadString('\n')
+		rc <- result{strings.TrimSpace(line), err}
+		// Whatever else the plugin writes on stdout is discarded
+		_, _ = io.Copy(io.Discard, rd)
+	}()
+	var address string
+	select {
+	case r := <-rc:
+		if r.err != nil && r.line == "" {
+			abort()
+			return nil, fmt.Errorf("plugin %s didn't announce its address: %w", path, r.err)
+		}
+		address = r.line
+	case <-time.After(pluginTimeout):
+		abort()
+		return nil, fmt.Errorf("plugin %s didn't announce its address within %s", path, pluginTimeout)
+	}
+
+	tc, tCancel := context.WithTimeout(ctx, pluginTimeout)
+	defer tCancel()
+	conn, err := grpc.DialContext(tc, address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		abort()
+		return nil, fmt.Errorf("unable to connect to plugin %s at %s: %w", path, address, err)
+	}
+	return &mechanismPlugin{
+		MechanismPluginClient: extension.NewMechanismPluginClient(conn),
+		ctx:                   ctx,
+		name:                  name,
+		conn:                  conn,
+		cancel:                cancel,
+		done:                  done,
+	}, nil
+}
+
+// stop tells the plugin that the intercept has ended, and then terminates it.
+func (p *mechanismPlugin) stop() {
+	tc, cancel := context.WithTimeout(p.ctx, pluginTimeout)
+	defer cancel()
+	if _, err := p.StopIntercept(tc, &extension.StopInterceptRequest{Name: p.name}); err != nil {
+		dlog.Errorf(p.ctx, "plugin StopIntercept of %s: %v", p.name, err)
+	}
+	_ = p.conn.Close()
+	p.cancel()
+	<-p.done
+}
+
+// startIntercept lets the plugin validate and describe the mechanism args of the given spec, and
+// start its client-side services. The spec is updated with the normalized args, their description,
+// and the target port of the plugin's proxy, if any. The returned environment is added to the
+// environment of the intercept handler.
+func (p *mechanismPlugin) startIntercept(ctx context.Context, spec *manager.InterceptSpec) (map[string]string, error) {
+	tc, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+	va, err := p.ValidateArgs(tc, &extension.MechanismArgs{Mechanism: spec.Mechanism, Args: spec.MechanismArgs})
+	if err != nil {
+		if st := status.Convert(err); st.Code() == codes.InvalidArgument {
+			return nil, errcat.User.New(st.Message())
+		}
+		return nil, err
+	}
+	spec.MechanismArgs = va.Args
+	spec.MechanismArgsDesc = va.Description
+
+	sr, err := p.StartIntercept(tc, &extension.StartInterceptRequest{
+		Name:          spec.Name,
+		MechanismArgs: &extension.MechanismArgs{Mechanism: spec.Mechanism, Args: spec.MechanismArgs},
+		TargetHost:    spec.TargetHost,
+		TargetPort:    spec.TargetPort,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sr.TargetPort != 0 {
+		spec.TargetPort = sr.TargetPort
+	}
+	return sr.Environment, nil
+}
+
+// addPlugin registers the plugin of an intercept, so that it's stopped when the intercept ends.
+func (tm *trafficManager) addPlugin(p *mechanismPlugin) {
+	tm.pluginsLock.Lock()
+	tm.plugins[p.name] = p
+	tm.pluginsLock.Unlock()
+}
+
+// stopPlugin stops the plugin of the given intercept, if it has one.
+func (tm *trafficManager) stopPlugin(name string) {
+	tm.pluginsLock.Lock()
+	p, ok := tm.plugins[name]
+	delete(tm.plugins, name)
+	tm.pluginsLock.Unlock()
+	if ok {
+		p.stop()
+	}
+}
+
+// stopPluginsOnExit stops all plugins when the context is cancelled.
+func (tm *trafficManager) stopPluginsOnExit(ctx context.Context) error {
+	<-ctx.Done()
+	tm.pluginsLock.Lock()
+	plugins := tm.plugins
+	tm.plugins = make(map[string]*mechanismPlugin)
+	tm.pluginsLock.Unlock()
+	for _, p := range plugins {
+		p.stop()
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/extension"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") == "1" {
+		os.Exit(testPluginHelper())
+	}
+	os.Exit(m.Run())
+}
+
+// testPlugin is a MechanismPlugin that only accepts a --color arg.
+type testPlugin struct {
+	extension.UnimplementedMechanismPluginServer
+}
+
+func (testPlugin) ValidateArgs(_ context.Context, in *extension.MechanismArgs) (*extension.ValidatedArgs, error) {
+	var colors []string
+	for _, arg := range in.Args {
+		if !strings.HasPrefix(arg, "--color=") {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown arg %q", arg)
+		}
+		colors = append(colors, strings.ToLower(strings.TrimPrefix(arg, "--color=")))
+	}
+	va := &extension.ValidatedArgs{Description: "all TCP connections"}
+	for _, c := range colors {
+		va.Args = append(va.Args, "--color="+c)
+	}
+	if len(colors) > 0 {
+		va.Description = fmt.Sprintf("%s requests", strings.Join(colors, " and "))
+	}
+	return va, nil
+}
+
+func (testPlugin) StartIntercept(_ context.Context, in *extension.StartInterceptRequest) (*extension.StartInterceptResponse, error) {
+	return &extension.StartInterceptResponse{
+		TargetPort:  in.TargetPort + 1,
+		Environment: map[string]string{"COLOR_PROXY": fmt.Sprintf("%s:%d", in.TargetHost, in.TargetPort+1)},
+	}, nil
+}
+
+func (testPlugin) StopIntercept(context.Context, *extension.StopInterceptRequest) (*empty.Empty, error) {
+	return &empty.Empty{}, nil
+}
+
+func testPluginHelper() int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(l.Addr().String())
+	srv := grpc.NewServer()
+	extension.RegisterMechanismPluginServer(srv, testPlugin{})
+	if err := srv.Serve(l); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func TestMechanismPlugin(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	start := func(name string) *mechanismPlugin {
+		p, err := startMechanismPlugin(ctx, name, os.Args[0])
+		require.NoError(t, err)
+		return p
+	}
+
+	tm := &trafficManager{plugins: make(map[string]*mechanismPlugin)}
+	p := start("echo")
+	spec := &manager.InterceptSpec{
+		Name:          "echo",
+		Mechanism:     "color",
+		MechanismArgs: []string{"--color=Blue"},
+		TargetHost:    "127.0.0.1",
+		TargetPort:    8080,
+	}
+	env, err := p.startIntercept(ctx, spec)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--color=blue"}, spec.MechanismArgs)
+	assert.Equal(t, "blue requests", spec.MechanismArgsDesc)
+	assert.Equal(t, int32(8081), spec.TargetPort)
+	assert.Equal(t, map[string]string{"COLOR_PROXY": "127.0.0.1:8081"}, env)
+	tm.addPlugin(p)
+
+	// Invalid args are user errors
+	p2 := start("echo2")
+	defer p2.stop()
+	_, err = p2.startIntercept(ctx, &manager.InterceptSpec{Name: "echo2", MechanismArgs: []string{"--size=10"}})
+	require.Error(t, err)
+	assert.Equal(t, errcat.User, errcat.GetCategory(err))
+
+	tm.stopPlugin("echo")
+	assert.Empty(t, tm.plugins)
+	assert.Error(t, p.ctx.Err(), "the plugin process should be terminated")
+	tm.stopPlugin("echo") // no-op
+
+	_, err = startMechanismPlugin(ctx, "echo", "/no/such/plugin")
+	assert.Error(t, err)
+}
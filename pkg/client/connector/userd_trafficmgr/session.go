@@ -0,0 +1,173 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+// recoverSession establishes a new session with the traffic-manager after the current session was
+// lost, and recreates the intercepts that this connector created in the lost session. The intercept
+// watcher restarts the port forwards and mounts once the recreated intercepts become active.
+func (tm *trafficManager) recoverSession(c context.Context) error {
+	dlog.Infof(c, "Session %s was lost, establishing a new session", tm.session().SessionId)
+	si, err := func() (*manager.SessionInfo, error) {
+		tc, cancel := client.GetConfig(c).Timeouts.TimeoutContext(c, client.TimeoutTrafficManagerAPI)
+		defer cancel()
+		si, err := tm.managerClient.ArriveAsClient(tc, tm.clientInfo(c))
+		if err != nil {
+			return nil, client.CheckTimeout(tc, fmt.Errorf("manager.ArriveAsClient: %w", err))
+		}
+		return si, nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	tm.sessionLock.Lock()
+	tm.sessionInfo = si
+	close(tm.sessionChanged)
+	tm.sessionChanged = make(chan struct{})
+	tm.recovering = true
+	requests := make([]*rpc.CreateInterceptRequest, 0, len(tm.interceptRequests))
+	for _, ir := range tm.interceptRequests {
+		requests = append(requests, ir)
+	}
+	tm.sessionLock.Unlock()
+	defer func() {
+		tm.sessionLock.Lock()
+		tm.recovering = false
+		tm.sessionLock.Unlock()
+	}()
+	dlog.Infof(c, "Established session %s", si.SessionId)
+
+	// The traffic-manager removed the intercepts of the lost session, so the snapshot is stale.
+	tm.setCurrentIntercepts(c, nil)
+	if _, err := tm.callbacks.SetOutboundInfo(c, tm.getOutboundInfo(c)); err != nil {
+		dlog.Errorf(c, "daemon.SetOutboundInfo: %v", err)
+	}
+
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Spec.Name < requests[j].Spec.Name })
+	var recreated, failed []string
+	for _, ir := range requests {
+		name := ir.Spec.Name
+		if err := tm.recreateIntercept(c, ir); err != nil {
+			dlog.Errorf(c, "Unable to recreate intercept %s: %v", name, err)
+			tm.forgetIntercept(name)
+			tm.stopPlugin(name)
+			failed = append(failed, name)
+		} else {
+			recreated = append(recreated, name)
+		}
+	}
+	tm.updateOutboundShaping(c)
+
+	msg := "The session with the traffic-manager was lost and a new session has been established."
+	if len(recreated) > 0 {
+		msg += fmt.Sprintf(" Recreated intercepts: %s.", strings.Join(recreated, ", "))
+	}
+	if len(failed) > 0 {
+		msg += fmt.Sprintf(" Unable to recreate intercepts: %s.", strings.Join(failed, ", "))
+	}
+	tm.callbacks.Notify(msg)
+	return nil
+}
+
+func (tm *trafficManager) recreateIntercept(c context.Context, ir *rpc.CreateInterceptRequest) error {
+	// The mount point may still be marked as busy by the lost intercept.
+	if ir.MountPoint != "" {
+		if name, ok := tm.mountPoints.Load(ir.MountPoint); ok && name.(string) == ir.Spec.Name {
+			tm.mountPoints.Delete(ir.MountPoint)
+		}
+	}
+	result, err := tm.AddIntercept(c, proto.Clone(ir).(*rpc.CreateInterceptRequest))
+	if err != nil {
+		return err
+	}
+	if result.Error != rpc.InterceptError_UNSPECIFIED {
+		return fmt.Errorf("%s: %s", result.Error, result.ErrorText)
+	}
+	return nil
+}
+
+// awaitSessionChange blocks until the session with the given ID has been replaced by a new session.
+// It returns false if the context is cancelled before that happens.
+func (tm *trafficManager) awaitSessionChange(ctx context.Context, sessionID string) bool {
+	tm.sessionLock.Lock()
+	changed := tm.sessionInfo.SessionId != sessionID
+	ch := tm.sessionChanged
+	tm.sessionLock.Unlock()
+	if changed {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-ch:
+		return true
+	}
+}
+
+// rememberIntercept remembers the request that created an intercept so that the intercept can be
+// recreated if the session is lost.
+func (tm *trafficManager) rememberIntercept(ir *rpc.CreateInterceptRequest) {
+	ir = proto.Clone(ir).(*rpc.CreateInterceptRequest)
+	tm.sessionLock.Lock()
+	tm.interceptRequests[ir.Spec.Name] = ir
+	tm.sessionLock.Unlock()
+}
+
+// forgetIntercept forgets the request that created an intercept that is no longer wanted.
+func (tm *trafficManager) forgetIntercept(name string) {
+	tm.sessionLock.Lock()
+	delete(tm.interceptRequests, name)
+	tm.sessionLock.Unlock()
+}
+
+// forgetVanishedIntercepts forgets the requests of intercepts that are absent from a snapshot that
+// was received in the given session, e.g. because an administrator removed them. Nothing is forgotten
+// while the session is being replaced, because the intercepts of a lost session vanish too.
+func (tm *trafficManager) forgetVanishedIntercepts(ctx context.Context, sessionID string, existing map[string]struct{}) {
+	tm.sessionLock.Lock()
+	var vanished []string
+	if !tm.recovering && tm.sessionInfo.SessionId == sessionID {
+		for name := range tm.interceptRequests {
+			if _, ok := existing[name]; !ok {
+				vanished = append(vanished, name)
+			}
+		}
+	}
+	tm.sessionLock.Unlock()
+	if len(vanished) == 0 {
+		return
+	}
+
+	// The snapshot that precedes the end of an expired session is empty, so ensure that the session
+	// is still alive before forgetting anything.
+	if _, err := tm.managerClient.Remain(ctx, &manager.RemainRequest{Session: &manager.SessionInfo{SessionId: sessionID}}); err != nil {
+		return
+	}
+
+	tm.sessionLock.Lock()
+	if !tm.recovering && tm.sessionInfo.SessionId == sessionID {
+		for _, name := range vanished {
+			dlog.Debugf(ctx, "Forgetting intercept %s", name)
+			delete(tm.interceptRequests, name)
+		}
+	} else {
+		vanished = nil
+	}
+	tm.sessionLock.Unlock()
+	for _, name := range vanished {
+		tm.stopPlugin(name)
+	}
+	tm.updateOutboundShaping(ctx)
+}
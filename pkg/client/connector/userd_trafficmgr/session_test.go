@@ -0,0 +1,86 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// remainClient is a manager client that only knows one live session.
+type remainClient struct {
+	manager.ManagerClient
+	liveSession string
+}
+
+func (c *remainClient) Remain(_ context.Context, in *manager.RemainRequest, _ ...grpc.CallOption) (*empty.Empty, error) {
+	if in.Session.SessionId != c.liveSession {
+		return nil, status.Errorf(codes.NotFound, "Session %q not found", in.Session.SessionId)
+	}
+	return &empty.Empty{}, nil
+}
+
+func TestForgetVanishedIntercepts(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	mc := &remainClient{liveSession: "s1"}
+	tm := &trafficManager{
+		managerClient:     mc,
+		sessionInfo:       &manager.SessionInfo{SessionId: "s1"},
+		sessionChanged:    make(chan struct{}),
+		interceptRequests: make(map[string]*rpc.CreateInterceptRequest),
+	}
+	remembered := func() []string {
+		var names []string
+		for name := range tm.interceptRequests {
+			names = append(names, name)
+		}
+		return names
+	}
+	for _, name := range []string{"a", "b"} {
+		tm.rememberIntercept(&rpc.CreateInterceptRequest{Spec: &manager.InterceptSpec{Name: name}})
+	}
+	existing := map[string]struct{}{"a": {}}
+
+	// Nothing is forgotten while recovering, or when the snapshot stems from another session
+	tm.recovering = true
+	tm.forgetVanishedIntercepts(ctx, "s1", existing)
+	tm.recovering = false
+	tm.forgetVanishedIntercepts(ctx, "s0", existing)
+	assert.ElementsMatch(t, []string{"a", "b"}, remembered())
+
+	// Nothing is forgotten when the session has expired
+	mc.liveSession = "s2"
+	tm.forgetVanishedIntercepts(ctx, "s1", existing)
+	assert.ElementsMatch(t, []string{"a", "b"}, remembered())
+
+	mc.liveSession = "s1"
+	tm.forgetVanishedIntercepts(ctx, "s1", existing)
+	assert.Equal(t, []string{"a"}, remembered())
+	tm.forgetIntercept("a")
+	assert.Empty(t, remembered())
+
+	// Waiters are released when the session is replaced
+	done := make(chan bool)
+	go func() { done <- tm.awaitSessionChange(ctx, "s1") }()
+	tm.sessionLock.Lock()
+	tm.sessionInfo = &manager.SessionInfo{SessionId: "s2"}
+	close(tm.sessionChanged)
+	tm.sessionChanged = make(chan struct{})
+	tm.sessionLock.Unlock()
+	select {
+	case changed := <-done:
+		assert.True(t, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("awaitSessionChange didn't return")
+	}
+	assert.True(t, tm.awaitSessionChange(ctx, "s1"))
+}
@@ -0,0 +1,93 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"sort"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+// interceptShaping returns the network conditions to simulate for a connection that was intercepted by
+// the intercept with the given ID, or nil when that intercept has no network shaping.
+//
+// The intercept ID is passed on by the traffic-manager in the DialRequest, so only connections that an
+// agent sends through the manager's Tunnel are shaped. Connections from agents or traffic-managers that
+// predate the Tunnel use the legacy muxed tunnel, which never dials the client, and get no shaping.
+func (tm *trafficManager) interceptShaping(_ tunnel.ConnID, interceptID string) *tunnel.Faults {
+	if interceptID == "" {
+		return nil
+	}
+	var name string
+	tm.currentInterceptsLock.Lock()
+	for _, ii := range tm.currentIntercepts {
+		if ii.Id == interceptID {
+			name = ii.Spec.Name
+			break
+		}
+	}
+	tm.currentInterceptsLock.Unlock()
+	if name == "" {
+		return nil
+	}
+
+	tm.sessionLock.Lock()
+	defer tm.sessionLock.Unlock()
+	if ir, ok := tm.interceptRequests[name]; ok && ir.NetworkShaping != "" {
+		// The spec was validated when the intercept was created.
+		faults, _ := tunnel.ParseFaults(ir.NetworkShaping)
+		return faults
+	}
+	return nil
+}
+
+// networkShaping returns the network shaping of each intercept that has one, keyed by intercept name.
+func (tm *trafficManager) networkShaping() map[string]string {
+	tm.sessionLock.Lock()
+	defer tm.sessionLock.Unlock()
+	var shaping map[string]string
+	for name, ir := range tm.interceptRequests {
+		if ir.NetworkShaping != "" {
+			if shaping == nil {
+				shaping = make(map[string]string)
+			}
+			shaping[name] = ir.NetworkShaping
+		}
+	}
+	return shaping
+}
+
+// outboundShapingSpec returns the network shaping that outbound connections should be subject to. When
+// several intercepts shape outbound traffic, the one with the alphabetically first name wins.
+func (tm *trafficManager) outboundShapingSpec() string {
+	tm.sessionLock.Lock()
+	defer tm.sessionLock.Unlock()
+	var names []string
+	for name, ir := range tm.interceptRequests {
+		if ir.ShapeOutbound && ir.NetworkShaping != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return tm.interceptRequests[names[0]].NetworkShaping
+}
+
+// updateOutboundShaping tells the root daemon to simulate the network conditions of the intercepts that
+// shape outbound traffic. Nothing is sent unless those conditions changed since the last update.
+func (tm *trafficManager) updateOutboundShaping(ctx context.Context) {
+	tm.shapingLock.Lock()
+	defer tm.shapingLock.Unlock()
+	spec := tm.outboundShapingSpec()
+	if spec == tm.outboundShaping {
+		return
+	}
+	if _, err := tm.callbacks.SetNetworkShaping(ctx, &daemon.NetworkShaping{Outbound: spec}); err != nil {
+		dlog.Errorf(ctx, "daemon.SetNetworkShaping: %v", err)
+		return
+	}
+	tm.outboundShaping = spec
+}
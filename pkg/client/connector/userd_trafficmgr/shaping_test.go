@@ -0,0 +1,67 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+func TestNetworkShaping(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	var updates []string
+	tm := &trafficManager{
+		interceptRequests: make(map[string]*rpc.CreateInterceptRequest),
+		callbacks: Callbacks{
+			SetNetworkShaping: func(_ context.Context, in *daemon.NetworkShaping, _ ...grpc.CallOption) (*empty.Empty, error) {
+				updates = append(updates, in.Outbound)
+				return &empty.Empty{}, nil
+			},
+		},
+	}
+	remember := func(name string, shaping string, outbound bool) {
+		spec := &manager.InterceptSpec{Name: name, TargetHost: "127.0.0.1", TargetPort: 8080}
+		tm.rememberIntercept(&rpc.CreateInterceptRequest{
+			Spec:           spec,
+			NetworkShaping: shaping,
+			ShapeOutbound:  outbound,
+		})
+		tm.currentIntercepts = append(tm.currentIntercepts, &manager.InterceptInfo{Id: "session:" + name, Spec: spec})
+		tm.updateOutboundShaping(ctx)
+	}
+	id := tunnel.NewConnID(ipproto.TCP, iputil.Parse("10.0.0.1"), iputil.Parse("127.0.0.1"), 4711, 8080)
+
+	remember("a", "", false)
+	remember("b", "latency=100ms", false)
+	remember("c", "loss=10%", true)
+	remember("d", "latency=1s", true)
+
+	// Intercepted connections are shaped by the intercept that they were intercepted by, even when
+	// several intercepts target the same port.
+	assert.Nil(t, tm.interceptShaping(id, "session:a"))
+	assert.Equal(t, &tunnel.Faults{Latency: 100 * time.Millisecond}, tm.interceptShaping(id, "session:b"))
+	assert.Equal(t, &tunnel.Faults{Latency: time.Second}, tm.interceptShaping(id, "session:d"))
+	assert.Nil(t, tm.interceptShaping(id, "session:x"))
+	assert.Nil(t, tm.interceptShaping(id, ""))
+	assert.Equal(t, map[string]string{"b": "latency=100ms", "c": "loss=10%", "d": "latency=1s"}, tm.networkShaping())
+
+	// The daemon is only told when the outbound shaping changes
+	assert.Equal(t, []string{"loss=10%"}, updates)
+	tm.forgetIntercept("c")
+	tm.updateOutboundShaping(ctx)
+	tm.forgetIntercept("d")
+	tm.updateOutboundShaping(ctx)
+	tm.updateOutboundShaping(ctx)
+	assert.Equal(t, []string{"loss=10%", "latency=1s", ""}, updates)
+}
@@ -26,10 +26,9 @@ import (
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/a8rcloud"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
-	"github.com/telepresenceio/telepresence/v2/pkg/client/connector/userd_grpc"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/connector/userd_k8s"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
-	"github.com/telepresenceio/telepresence/v2/pkg/dnet"
 	"github.com/telepresenceio/telepresence/v2/pkg/header"
 	"github.com/telepresenceio/telepresence/v2/pkg/install"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
@@ -37,9 +36,10 @@ import (
 )
 
 type Callbacks struct {
-	GetCloudAPIKey        func(context.Context, string, bool) (string, error)
-	RegisterManagerServer func(server manager.ManagerServer)
-	SetOutboundInfo       func(ctx context.Context, in *daemon.OutboundInfo, opts ...grpc.CallOption) (*empty.Empty, error)
+	GetCloudAPIKey    func(context.Context, string, bool) (string, error)
+	SetOutboundInfo   func(ctx context.Context, in *daemon.OutboundInfo, opts ...grpc.CallOption) (*empty.Empty, error)
+	SetNetworkShaping func(ctx context.Context, in *daemon.NetworkShaping, opts ...grpc.CallOption) (*empty.Empty, error)
+	Notify            func(string)
 }
 
 type apiServer struct {
@@ -54,8 +54,9 @@ type trafficManager struct {
 	callbacks  Callbacks
 
 	// local information
-	installID   string // telepresence's install ID
-	userAndHost string // "laptop-username@laptop-hostname"
+	connectionName string // name of the connector connection that the traffic manager serves
+	installID      string // telepresence's install ID
+	userAndHost    string // "laptop-username@laptop-hostname"
 
 	// manager client
 	managerClient manager.ManagerClient
@@ -66,6 +67,33 @@ type trafficManager struct {
 	// until .startup is closed, and it isn't safe to mutate them after .startup is closed.
 
 	sessionInfo *manager.SessionInfo // sessionInfo returned by the traffic-manager
+	sessionLock sync.Mutex
+
+	// clientPolicy is the client policy published by the traffic-manager, or nil if it publishes
+	// none. Like the managerClient, it's set before .startup is closed.
+	clientPolicy *manager.ClientPolicy
+
+	// config is the config of the connector, merged with the clientPolicy. Like the clientPolicy,
+	// it's set before .startup is closed.
+	config *client.Config
+
+	// sessionChanged is closed and replaced each time the session is replaced by a new one
+	sessionChanged chan struct{}
+
+	// interceptRequests contains the requests of the intercepts that this connector created, keyed
+	// by intercept name, so that they can be recreated when the session is lost. Guarded by
+	// sessionLock, just like recovering, which is true while that happens.
+	interceptRequests map[string]*rpc.CreateInterceptRequest
+	recovering        bool
+
+	// outboundShaping is the network shaping that the root daemon was last told to simulate for
+	// outbound connections.
+	shapingLock     sync.Mutex
+	outboundShaping string
+
+	// plugins contains the running mechanism plugins, keyed by intercept name
+	plugins     map[string]*mechanismPlugin
+	pluginsLock sync.Mutex
 
 	// Map of desired mount points for intercepts
 	mountPoints sync.Map
@@ -100,6 +128,7 @@ type interceptResult struct {
 // New returns a TrafficManager resource for the given cluster if it has a Traffic Manager service.
 func New(
 	_ context.Context,
+	connectionName string,
 	cluster *userd_k8s.Cluster,
 	installID string,
 	callbacks Callbacks,
@@ -119,11 +148,15 @@ func New(
 		return nil, errors.Wrap(err, "new installer")
 	}
 	tm := &trafficManager{
-		installer:   ti.(*installer),
-		installID:   installID,
-		startup:     make(chan struct{}),
-		userAndHost: fmt.Sprintf("%s@%s", userinfo.Username, host),
-		callbacks:   callbacks,
+		installer:         ti.(*installer),
+		connectionName:    connectionName,
+		installID:         installID,
+		startup:           make(chan struct{}),
+		userAndHost:       fmt.Sprintf("%s@%s", userinfo.Username, host),
+		callbacks:         callbacks,
+		sessionChanged:    make(chan struct{}),
+		interceptRequests: make(map[string]*rpc.CreateInterceptRequest),
+		plugins:           make(map[string]*mechanismPlugin),
 	}
 
 	return tm, nil
@@ -137,20 +170,7 @@ func (tm *trafficManager) Run(c context.Context) error {
 		return err
 	}
 
-	grpcDialer, err := dnet.NewK8sPortForwardDialer(c, tm.ConfigFlags, tm.Client())
-	if err != nil {
-		return err
-	}
-	grpcAddr := net.JoinHostPort(
-		"svc/traffic-manager."+tm.GetManagerNamespace(),
-		fmt.Sprint(install.ManagerPortHTTP))
-
 	// First check. Establish connection
-	clientConfig := client.GetConfig(c)
-	tos := &clientConfig.Timeouts
-	tc, cancel := tos.TimeoutContext(c, client.TimeoutTrafficManagerAPI)
-	defer cancel()
-
 	var conn *grpc.ClientConn
 	defer func() {
 		if err != nil && conn != nil {
@@ -167,39 +187,22 @@ func (tm *trafficManager) Run(c context.Context) error {
 		}
 	}()
 
-	opts := []grpc.DialOption{grpc.WithContextDialer(grpcDialer),
-		grpc.WithInsecure(),
-		grpc.WithNoProxy(),
-		grpc.WithBlock(),
-		grpc.WithReturnConnectionError()}
-
-	conn, err = grpc.DialContext(tc, grpcAddr, opts...)
+	conn, err = tm.dialManager(c)
 	if err != nil {
-		return client.CheckTimeout(tc, fmt.Errorf("dial manager: %w", err))
+		return err
 	}
 
+	tc, cancel := client.GetConfig(c).Timeouts.TimeoutContext(c, client.TimeoutTrafficManagerAPI)
+	defer cancel()
 	mClient := manager.NewManagerClient(conn)
-	si, err := mClient.ArriveAsClient(tc, &manager.ClientInfo{
-		Name:      tm.userAndHost,
-		InstallId: tm.installID,
-		Product:   "telepresence",
-		Version:   client.Version(),
-		ApiKey: func() string {
-			// Discard any errors; including an apikey with this request is optional.
-			// We might not even be logged in.
-			tok, _ := tm.callbacks.GetCloudAPIKey(c, a8rcloud.KeyDescTrafficManager, false)
-			return tok
-		}(),
-	})
+	si, err := mClient.ArriveAsClient(tc, tm.clientInfo(c))
 	if err != nil {
 		return client.CheckTimeout(tc, fmt.Errorf("manager.ArriveAsClient: %w", err))
 	}
 	tm.managerClient = mClient
 	tm.sessionInfo = si
-
-	// Gotta call RegisterManagerServer before we call daemon.SetOutboundInfo which tells the
-	// daemon to use the proxy.
-	tm.callbacks.RegisterManagerServer(userd_grpc.NewManagerProxy(tm.managerClient))
+	tm.fetchClientPolicy(c)
+	c = tm.withConfig(c)
 
 	// Tell daemon what it needs to know in order to establish outbound traffic to the cluster
 	if _, err := tm.callbacks.SetOutboundInfo(c, tm.getOutboundInfo(c)); err != nil {
@@ -214,10 +217,60 @@ func (tm *trafficManager) Run(c context.Context) error {
 	g.Go("intercept-port-forward", tm.workerPortForwardIntercepts)
 	g.Go("agent-watcher", tm.agentInfoWatcher)
 	g.Go("dial-request-watcher", tm.dialRequestWatcher)
+	g.Go("notification-watcher", tm.notificationWatcher)
+	g.Go("mechanism-plugins", tm.stopPluginsOnExit)
 	return g.Wait()
 }
 
+// fetchClientPolicy retrieves the client policy of the traffic-manager and saves it in the user cache,
+// where the CLI finds the policy's extensions, default mechanism, and agent images.
+func (tm *trafficManager) fetchClientPolicy(c context.Context) {
+	tc, cancel := client.GetConfig(c).Timeouts.TimeoutContext(c, client.TimeoutTrafficManagerAPI)
+	defer cancel()
+	policy, err := tm.managerClient.GetClientPolicy(tc, &empty.Empty{})
+	if err != nil {
+		// An older traffic-manager doesn't publish a policy
+		if status.Code(err) != codes.Unimplemented {
+			dlog.Errorf(c, "unable to get the client policy of the traffic-manager: %v", err)
+		}
+		policy = nil
+	}
+	tm.clientPolicy = policy
+	tm.config = client.GetConfig(c).WithClientPolicy(c, policy)
+	if err = cache.SaveClientPolicyToUserCache(c, tm.connectionName, policy); err != nil {
+		dlog.Errorf(c, "failed to save the client policy to the user cache: %v", err)
+	}
+}
+
+// withConfig returns a context that carries the config that is merged with the client policy, so that
+// the calls made on behalf of the session use the policy's timeouts. It must not be called before
+// .startup is closed.
+func (tm *trafficManager) withConfig(c context.Context) context.Context {
+	if tm.config == nil {
+		return c
+	}
+	return client.WithConfig(c, tm.config)
+}
+
+func (tm *trafficManager) clientInfo(c context.Context) *manager.ClientInfo {
+	return &manager.ClientInfo{
+		Name:      tm.userAndHost,
+		InstallId: tm.installID,
+		Product:   "telepresence",
+		Version:   client.Version(),
+		ApiKey: func() string {
+			// Discard any errors; including an apikey with this request is optional.
+			// We might not even be logged in.
+			tok, _ := tm.callbacks.GetCloudAPIKey(c, a8rcloud.KeyDescTrafficManager, false)
+			return tok
+		}(),
+		EgressRules: tm.EgressRules,
+	}
+}
+
 func (tm *trafficManager) session() *manager.SessionInfo {
+	tm.sessionLock.Lock()
+	defer tm.sessionLock.Unlock()
 	return tm.sessionInfo
 }
 
@@ -338,6 +391,7 @@ func (tm *trafficManager) WorkloadInfoSnapshot(ctx context.Context, rq *rpc.List
 	}
 
 	<-tm.startup
+	ctx = tm.withConfig(ctx)
 	is := tm.getCurrentIntercepts()
 	iMap = make(map[string]*manager.InterceptInfo, len(is))
 	for _, i := range is {
@@ -402,7 +456,15 @@ func (tm *trafficManager) remain(c context.Context) error {
 				}(),
 			})
 			if err != nil && c.Err() == nil {
-				dlog.Error(c, err)
+				if status.Code(err) != codes.NotFound {
+					dlog.Error(c, err)
+					continue
+				}
+				// The traffic-manager has expired the session, typically because this machine
+				// was asleep or offline for too long.
+				if err = tm.recoverSession(c); err != nil && c.Err() == nil {
+					dlog.Errorf(c, "unable to establish a new session: %v", err)
+				}
 			}
 		}
 	}
@@ -413,6 +475,7 @@ func (tm *trafficManager) SetStatus(ctx context.Context, r *rpc.ConnectInfo) {
 		return
 	}
 	<-tm.startup
+	ctx = tm.withConfig(ctx)
 	if tm.managerClient == nil {
 		r.BridgeOk = false
 		r.Intercepts = &manager.InterceptInfoSnapshot{}
@@ -424,10 +487,37 @@ func (tm *trafficManager) SetStatus(ctx context.Context, r *rpc.ConnectInfo) {
 		r.Agents = &manager.AgentInfoSnapshot{Agents: tm.getCurrentAgents()}
 		r.Intercepts = &manager.InterceptInfoSnapshot{Intercepts: tm.getCurrentIntercepts()}
 		r.SessionInfo = tm.session()
+		r.NetworkShaping = tm.networkShaping()
+		r.Egress = tm.egressStatus(ctx)
 		r.BridgeOk = true
 	}
 }
 
+// egressStatus returns the egress rules of the session together with the pod IPs of the agents that the
+// traffic-manager currently routes the connections that match the rules through. The rules are returned
+// without pod IPs when the traffic-manager can't tell.
+func (tm *trafficManager) egressStatus(ctx context.Context) []*rpc.EgressStatus {
+	if len(tm.EgressRules) == 0 {
+		return nil
+	}
+	tc, cancel := client.GetConfig(ctx).Timeouts.TimeoutContext(ctx, client.TimeoutTrafficManagerAPI)
+	defer cancel()
+	rs, err := tm.managerClient.GetEgressStatus(tc, tm.session())
+	if err != nil {
+		dlog.Debugf(ctx, "unable to get the egress status from the traffic-manager: %v", err)
+		es := make([]*rpc.EgressStatus, len(tm.EgressRules))
+		for i, rule := range tm.EgressRules {
+			es[i] = &rpc.EgressStatus{Rule: rule}
+		}
+		return es
+	}
+	es := make([]*rpc.EgressStatus, len(rs.Rules))
+	for i, r := range rs.Rules {
+		es[i] = &rpc.EgressStatus{Rule: r.Rule, PodIp: r.PodIp}
+	}
+	return es
+}
+
 // Given a slice of AgentInfo, this returns another slice of agents with one
 // agent per namespace, name pair.
 func getRepresentativeAgents(_ context.Context, agents []*manager.AgentInfo) []*manager.AgentInfo {
@@ -449,6 +539,7 @@ func getRepresentativeAgents(_ context.Context, agents []*manager.AgentInfo) []*
 func (tm *trafficManager) Uninstall(c context.Context, ur *rpc.UninstallRequest) (*rpc.UninstallResult, error) {
 	result := &rpc.UninstallResult{}
 	<-tm.startup
+	c = tm.withConfig(c)
 	agents := tm.getCurrentAgents()
 
 	// Since workloads can have more than one replica, we get a slice of agents
@@ -537,7 +628,7 @@ func (tm *trafficManager) getOutboundInfo(ctx context.Context) *daemon.OutboundI
 		neverProxy = append(neverProxy, iputil.IPNetToRPC((*net.IPNet)(np)))
 	}
 	info := &daemon.OutboundInfo{
-		Session:           tm.sessionInfo,
+		Session:           tm.session(),
 		NeverProxySubnets: neverProxy,
 	}
 
@@ -0,0 +1,54 @@
+package userd_trafficmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+// policyClient is a manager client that publishes a client policy.
+type policyClient struct {
+	manager.ManagerClient
+	policy *manager.ClientPolicy
+}
+
+func (c *policyClient) GetClientPolicy(context.Context, *empty.Empty, ...grpc.CallOption) (*manager.ClientPolicy, error) {
+	return c.policy, nil
+}
+
+func TestFetchClientPolicy(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ctx = filelocation.WithUserHomeDir(ctx, t.TempDir())
+	env, err := client.LoadEnv(ctx)
+	require.NoError(t, err)
+	ctx = client.WithEnv(ctx, env)
+	cfg := client.GetDefaultConfig(ctx)
+	cfg.Images.AgentImage = "other-agent:1.0"
+	ctx = client.WithConfig(ctx, &cfg)
+
+	tm := &trafficManager{
+		connectionName: "default",
+		managerClient: &policyClient{policy: &manager.ClientPolicy{
+			AllowedAgentImages: []string{"docker.io/datawire/tel2:*"},
+			Timeouts:           map[string]*durationpb.Duration{"trafficManagerAPI": durationpb.New(time.Minute)},
+		}},
+	}
+	tm.fetchClientPolicy(ctx)
+
+	// The calls made on behalf of the session use the timeouts of the policy, even when the
+	// agent image of the config isn't allowed.
+	assert.Equal(t, time.Minute, client.GetConfig(tm.withConfig(ctx)).Timeouts.PrivateTrafficManagerAPI)
+	assert.Equal(t, cfg.Timeouts.PrivateTrafficManagerAPI, client.GetConfig(ctx).Timeouts.PrivateTrafficManagerAPI, "the config of the connector is unchanged")
+	assert.Error(t, client.GetConfig(tm.withConfig(ctx)).CheckAgentImage(ctx, tm.clientPolicy))
+}
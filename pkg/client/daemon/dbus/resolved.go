@@ -65,12 +65,13 @@ func SetLinkDNS(c context.Context, networkIndex int, ips ...net.IP) error {
 		addrs := make([]resolvedLinkAddress, len(ips))
 		for i, ip := range ips {
 			addr := &addrs[i]
-			switch len(ip) {
-			case 4:
+			if ip4 := ip.To4(); ip4 != nil {
+				// An IPv4 address may be represented using 16 bytes
 				addr.Dialect = unix.AF_INET
-			case 16:
+				ip = ip4
+			} else if len(ip) == 16 {
 				addr.Dialect = unix.AF_INET6
-			default:
+			} else {
 				return errors.New("illegal IP (not AF_INET or AF_INET6")
 			}
 			addr.IP = ip
@@ -0,0 +1,161 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/subnet"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif/routing"
+)
+
+const vpnDocsURL = "https://www.telepresence.io/docs/latest/reference/vpn"
+
+// RunDiagnostics performs the root daemon's self-checks on behalf of the `telepresence doctor` command.
+func (d *service) RunDiagnostics(ctx context.Context, _ *empty.Empty) (*manager.DiagnosticResults, error) {
+	dlog.Debug(ctx, "Received gRPC RunDiagnostics")
+	results := &manager.DiagnosticResults{}
+	add := func(rs ...*manager.DiagnosticResult) {
+		results.Results = append(results.Results, rs...)
+	}
+
+	add(d.outbound.dnsRecursionDiagnostic())
+
+	select {
+	case <-d.outbound.router.cfgComplete:
+	default:
+		// The checks below require cluster information
+		return results, nil
+	}
+
+	subnets, err := d.GetClusterSubnets(ctx, &empty.Empty{})
+	if err != nil {
+		add(&manager.DiagnosticResult{
+			Name:    "cluster-subnets",
+			Status:  manager.DiagnosticResult_FAIL,
+			Message: fmt.Sprintf("unable to get the cluster subnets from the traffic-manager: %v", err),
+			Hint:    "ensure that the traffic-manager is running and that it can determine the cluster's subnets",
+		})
+		return results, nil
+	}
+	table, err := routing.GetRoutingTable(ctx)
+	if err != nil {
+		add(&manager.DiagnosticResult{
+			Name:    "routing-table",
+			Status:  manager.DiagnosticResult_FAIL,
+			Message: fmt.Sprintf("unable to read the local routing table: %v", err),
+		})
+		return results, nil
+	}
+	r := d.outbound.router
+	add(r.subnetConflictDiagnostics("pod", subnets.PodSubnets, table)...)
+	add(r.subnetConflictDiagnostics("service", subnets.SvcSubnets, table)...)
+	add(neverProxyDiagnostics(r.neverProxySubnets, r.unroutedNeverProxySubnets)...)
+	return results, nil
+}
+
+// dnsRecursionDiagnostic reports whether the cluster's DNS resolver has been found to recurse back
+// into the local DNS server.
+func (o *outbound) dnsRecursionDiagnostic() *manager.DiagnosticResult {
+	result := &manager.DiagnosticResult{Name: "dns-recursion"}
+	s := o.getDNSServer()
+	if s == nil {
+		result.Status = manager.DiagnosticResult_WARN
+		result.Message = "the local DNS server has not been started"
+		result.Hint = "run `telepresence connect` to start it"
+		return result
+	}
+	switch recursive, tested := s.Recursive(); {
+	case !tested:
+		result.Status = manager.DiagnosticResult_WARN
+		result.Message = "the DNS recursion check has not completed yet"
+		result.Hint = "perform a DNS lookup of a cluster service and then run this command again"
+	case recursive:
+		result.Status = manager.DiagnosticResult_WARN
+		result.Message = "the cluster's DNS resolver recurses back into the local DNS server"
+		result.Hint = "this is expected when the cluster runs on this host (e.g. k3s in docker). " +
+			"Telepresence compensates for it, but lookups of names that don't exist in the cluster may be slow"
+	default:
+		result.Message = "the cluster's DNS resolver does not recurse back into the local DNS server"
+	}
+	return result
+}
+
+// subnetConflictDiagnostics checks each of the given cluster subnets for overlaps with the routes
+// in the given routing table. Subnets that have been mapped to a synthetic subnet are reported as such.
+func (t *tunRouter) subnetConflictDiagnostics(subnetType string, subnets []*manager.IPNet, table []routing.Route) []*manager.DiagnosticResult {
+	results := make([]*manager.DiagnosticResult, 0, len(subnets))
+	for _, rpcSn := range subnets {
+		sn := iputil.IPNetFromRPC(rpcSn)
+		result := &manager.DiagnosticResult{
+			Name:    subnetType + "-subnet " + sn.String(),
+			Message: fmt.Sprintf("%s subnet %s does not conflict with any local routes", subnetType, sn),
+		}
+		results = append(results, result)
+		rt := conflictingRoute(sn, table, t.dev.Name(), t.neverProxySubnets)
+		if rt == nil {
+			continue
+		}
+		result.Status = manager.DiagnosticResult_WARN
+		if synthetic := t.syntheticSubnet(sn); synthetic != nil {
+			result.Message = fmt.Sprintf("%s subnet %s conflicts with the local route %s and has been mapped to %s",
+				subnetType, sn, rt, synthetic)
+			result.Hint = fmt.Sprintf("use addresses in %s to reach hosts in %s, or move %s subnet %s to a subnet that isn't routed locally",
+				synthetic, sn, subnetType, sn)
+			continue
+		}
+		if subnet.OverlapOf(sn, rt.RoutedNet) == subnet.MaskedBy {
+			result.Message = fmt.Sprintf("%s subnet %s is masked by the local route %s. "+
+				"Hosts in %s will be unreachable through the cluster", subnetType, sn, rt, rt.RoutedNet)
+			result.Hint = fmt.Sprintf("move %s subnet %s to a subnet that isn't routed locally, shrink the mask of %s, "+
+				"or set routing.remapConflictingSubnets to true in the config. See %s",
+				subnetType, sn, rt.RoutedNet, vpnDocsURL)
+		} else {
+			result.Message = fmt.Sprintf("%s subnet %s is masking the local route %s. "+
+				"Hosts in %s may be unreachable while Telepresence is connected", subnetType, sn, rt, rt.RoutedNet)
+			result.Hint = fmt.Sprintf("move %s subnet %s to a subnet that isn't routed locally, add %s to the never-proxy list, "+
+				"or set routing.remapConflictingSubnets to true in the config. See %s",
+				subnetType, sn, rt.RoutedNet, vpnDocsURL)
+		}
+	}
+	return results
+}
+
+// neverProxyDiagnostics reports whether the never-proxy subnets could be routed.
+func neverProxyDiagnostics(routed []routing.Route, unrouted []*net.IPNet) []*manager.DiagnosticResult {
+	results := make([]*manager.DiagnosticResult, 0, len(routed)+len(unrouted))
+	for _, rt := range routed {
+		results = append(results, &manager.DiagnosticResult{
+			Name:    "never-proxy " + rt.RoutedNet.String(),
+			Message: fmt.Sprintf("never-proxy subnet %s is routed via %s", rt.RoutedNet, rt.Interface.Name),
+		})
+	}
+	for _, sn := range unrouted {
+		results = append(results, &manager.DiagnosticResult{
+			Name:    "never-proxy " + sn.String(),
+			Status:  manager.DiagnosticResult_FAIL,
+			Message: fmt.Sprintf("never-proxy subnet %s is not routable", sn),
+			Hint:    "ensure that a local route exists for the subnet, or remove it from the never-proxy list in your kubeconfig",
+		})
+	}
+	return results
+}
+
+func isDefaultRoute(rt *routing.Route) bool {
+	ones, _ := rt.RoutedNet.Mask.Size()
+	return ones == 0
+}
+
+func isNeverProxyRoute(rt *routing.Route, neverProxy []routing.Route) bool {
+	for _, np := range neverProxy {
+		if np.RoutedNet.String() == rt.RoutedNet.String() {
+			return true
+		}
+	}
+	return false
+}
@@ -64,6 +64,19 @@ func (s *Server) RequestCount() int {
 	return int(atomic.LoadInt64(&s.requestCount))
 }
 
+// Recursive returns whether the cluster's DNS resolver has been found to call this server
+// recursively. The tested return value is false until the recursion check has completed.
+func (s *Server) Recursive() (recursive, tested bool) {
+	switch atomic.LoadInt32(&s.recursive) {
+	case 1:
+		return false, true
+	case 2:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
 func copyRRs(rrs []dns.RR, qType uint16) []dns.RR {
 	if len(rrs) == 0 {
 		return rrs
@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+)
+
+// maxNotifications is the number of messages that the notifier retains for watchers that haven't
+// received them yet.
+const maxNotifications = 64
+
+// notifier retains messages that are intended for the user so that they can be streamed to the user
+// daemon, which in turn forwards them to the CLI. The most recent messages are retained, so a watcher
+// that starts late will still receive the messages that were added before it started.
+type notifier struct {
+	sync.Mutex
+
+	// messages are the most recent messages, and dropped is the number of older messages that have
+	// been discarded.
+	messages []string
+	dropped  int
+
+	// added is closed and replaced each time a message is added
+	added chan struct{}
+}
+
+func (n *notifier) notify(msg string) {
+	n.Lock()
+	n.messages = append(n.messages, msg)
+	if excess := len(n.messages) - maxNotifications; excess > 0 {
+		n.messages = append(n.messages[:0:0], n.messages[excess:]...)
+		n.dropped += excess
+	}
+	if n.added != nil {
+		close(n.added)
+		n.added = nil
+	}
+	n.Unlock()
+}
+
+// watch calls the given function for each retained message, starting with the oldest one, until the
+// context is cancelled or the function returns an error. A watcher that falls behind by more than
+// maxNotifications misses the oldest messages.
+func (n *notifier) watch(ctx context.Context, f func(string) error) error {
+	next := 0
+	for {
+		n.Lock()
+		if next < n.dropped {
+			next = n.dropped
+		}
+		msgs := n.messages[next-n.dropped:]
+		next = n.dropped + len(n.messages)
+		if n.added == nil {
+			n.added = make(chan struct{})
+		}
+		added := n.added
+		n.Unlock()
+
+		for _, msg := range msgs {
+			if err := f(msg); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-added:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+func TestNotifier_retainsMostRecent(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	n := &notifier{}
+	for i := 0; i < maxNotifications+10; i++ {
+		n.notify(fmt.Sprintf("msg-%d", i))
+	}
+	assert.Len(t, n.messages, maxNotifications)
+
+	// A late watcher receives the retained messages, starting with the oldest one.
+	stop := errors.New("stop")
+	var received []string
+	err := n.watch(ctx, func(msg string) error {
+		received = append(received, msg)
+		if len(received) == maxNotifications {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, "msg-10", received[0])
+	assert.Equal(t, fmt.Sprintf("msg-%d", maxNotifications+9), received[maxNotifications-1])
+
+	// A watcher receives the messages that are added while it's watching.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	received = nil
+	go func() {
+		done <- n.watch(ctx, func(msg string) error {
+			received = append(received, msg)
+			if msg == "last" {
+				return stop
+			}
+			return nil
+		})
+	}()
+	n.notify("last")
+	assert.Equal(t, stop, <-done)
+	assert.Equal(t, "last", received[len(received)-1])
+}
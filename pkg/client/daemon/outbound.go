@@ -6,8 +6,10 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	dns2 "github.com/miekg/dns"
 	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/datawire/dlib/dgroup"
@@ -15,6 +17,8 @@ import (
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/daemon/dns"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
 )
@@ -24,7 +28,21 @@ import (
 // A zero outbound is invalid; you must use newOutbound.
 type outbound struct {
 	noSearch bool
-	router   *tunRouter
+
+	// router is the primary router. Its TUN device is the one that is integrated with the DNS
+	// resolver of the OS.
+	router *tunRouter
+
+	// routers contains one router for each connection of the connector, in the order they were
+	// created. The first one is the primary router.
+	routers     []*tunRouter
+	routersLock sync.RWMutex
+
+	// routersCreated is the number of routers that have been created. It's guarded by the routersLock.
+	routersCreated int
+
+	// notifier retains messages for the user. It's shared by all routers.
+	notifier notifier
 
 	// Namespaces, accessible using <service-name>.<namespace-name>
 	namespaces map[string]struct{}
@@ -40,9 +58,26 @@ type outbound struct {
 
 	dnsConfig *rpc.DNSConfig
 
+	// dnsServer is the *dns.Server that is currently in use, if any
+	dnsServer atomic.Value
+
 	scout chan<- scout.ScoutReport
 }
 
+// newDNSServer creates a new DNS server that uses the dnsCache of this outbound and retains it
+// so that its state can be examined later.
+func (o *outbound) newDNSServer(listeners []net.PacketConn, fallback *dns2.Conn, resolve dns.Resolver) *dns.Server {
+	s := dns.NewServer(listeners, fallback, resolve, &o.dnsCache)
+	o.dnsServer.Store(s)
+	return s
+}
+
+// getDNSServer returns the DNS server created by newDNSServer, or nil if no server has been created.
+func (o *outbound) getDNSServer() *dns.Server {
+	s, _ := o.dnsServer.Load().(*dns.Server)
+	return s
+}
+
 func newLocalUDPListener(c context.Context) (net.PacketConn, error) {
 	lc := &net.ListenConfig{}
 	return lc.ListenPacket(c, "udp", "127.0.0.1:0")
@@ -68,12 +103,176 @@ func newOutbound(c context.Context, dnsIPStr string, noSearch bool, scout chan<-
 	}
 
 	var err error
-	if ret.router, err = newTunRouter(c); err != nil {
+	if ret.router, err = newTunRouter(c, &ret.notifier); err != nil {
 		return nil, err
 	}
+	ret.router.primary = true
+	ret.router.connectionUsing = ret.connectionUsing
+	ret.routers = []*tunRouter{ret.router}
 	return ret, nil
 }
 
+// routerFor returns the router of the connection that the given context of an incoming gRPC call
+// selects. The first connection claims the primary router. A new router, with a TUN device of its
+// own, is created and started for each additional connection.
+func (o *outbound) routerFor(ctx context.Context) (*tunRouter, error) {
+	name := client.GetConnectionName(ctx)
+	if name == "" {
+		return o.router, nil
+	}
+	o.routersLock.Lock()
+	defer o.routersLock.Unlock()
+	for _, r := range o.routers {
+		if r.name == name {
+			return r, nil
+		}
+	}
+	if o.router.name == "" && !o.router.isRemoved() {
+		o.router.name = name
+		return o.router, nil
+	}
+	r, err := newTunRouter(ctx, &o.notifier)
+	if err != nil {
+		return nil, err
+	}
+	o.routersCreated++
+	r.name = name
+	r.seq = o.routersCreated
+	r.connectionUsing = o.connectionUsing
+	o.routers = append(o.routers, r)
+	dlog.Infof(ctx, "Using TUN device %s for connection %q", r.dev.Name(), name)
+	r.start()
+	return r, nil
+}
+
+// removeRouter releases the router of the connection that the given context of an incoming gRPC
+// call selects. The router is forgotten so that a connection that later reuses the name gets a new
+// router. The primary router keeps its TUN device, but it's never claimed or asked to resolve names
+// again.
+func (o *outbound) removeRouter(ctx context.Context) error {
+	name := client.GetConnectionName(ctx)
+	if name == "" {
+		return errcat.User.New("no connection name was given")
+	}
+	o.routersLock.Lock()
+	var removed *tunRouter
+	for i, r := range o.routers {
+		if r.name == name {
+			removed = r
+			if r.primary {
+				r.name = ""
+				r.includeSuffixes = nil
+				r.searchPaths = nil
+				r.namespaces = nil
+			} else {
+				o.routers = append(o.routers[:i:i], o.routers[i+1:]...)
+			}
+			break
+		}
+	}
+	allPaths := o.unlockedSearchPaths()
+	o.routersLock.Unlock()
+	if removed == nil {
+		return errcat.User.Newf("there is no connection named %q", name)
+	}
+
+	dlog.Infof(ctx, "Releasing TUN device %s of connection %q", removed.dev.Name(), name)
+	removed.release(ctx)
+	o.flushDNS()
+	select {
+	case <-ctx.Done():
+	case o.searchPathCh <- allPaths:
+	}
+	return nil
+}
+
+// getRouter returns the existing router of the connection that the given context of an incoming
+// gRPC call selects. The primary router is returned when no connection is selected.
+func (o *outbound) getRouter(ctx context.Context) (*tunRouter, error) {
+	name := client.GetConnectionName(ctx)
+	if name == "" {
+		return o.router, nil
+	}
+	o.routersLock.RLock()
+	defer o.routersLock.RUnlock()
+	for _, r := range o.routers {
+		if r.name == name {
+			return r, nil
+		}
+	}
+	return nil, errcat.User.Newf("there is no connection named %q", name)
+}
+
+// allRouters returns a snapshot of all routers, the primary router first.
+func (o *outbound) allRouters() []*tunRouter {
+	o.routersLock.RLock()
+	defer o.routersLock.RUnlock()
+	return append([]*tunRouter(nil), o.routers...)
+}
+
+// connectionUsing returns the name of the connection whose router owns the TUN device with the
+// given name, or an empty string if no router owns it.
+func (o *outbound) connectionUsing(devName string) string {
+	for _, r := range o.allRouters() {
+		if r.dev.Name() == devName {
+			o.routersLock.RLock()
+			name := r.name
+			o.routersLock.RUnlock()
+			return name
+		}
+	}
+	return ""
+}
+
+// includeSuffixes returns the union of the DNS include suffixes of all connections.
+func (o *outbound) includeSuffixes() []string {
+	o.routersLock.RLock()
+	defer o.routersLock.RUnlock()
+	var sfxs []string
+	for _, r := range o.routers {
+		sfxs = append(sfxs, r.includeSuffixes...)
+	}
+	return sfxs
+}
+
+// routersForQuery returns the routers that should be asked to resolve the given name, in order of
+// preference. Routers of connections that include a matching suffix or that have a namespace
+// matching the second label of the name come first. The rest follow, the primary router first.
+// Routers of connections that have been removed are never returned.
+func (o *outbound) routersForQuery(query string) []*tunRouter {
+	o.routersLock.RLock()
+	defer o.routersLock.RUnlock()
+	if len(o.routers) == 1 {
+		if o.router.isRemoved() {
+			return nil
+		}
+		return []*tunRouter{o.router}
+	}
+	labels := strings.Split(query, ".")
+	var preferred, others []*tunRouter
+	for _, r := range o.routers {
+		if r.isRemoved() {
+			continue
+		}
+		match := false
+		for _, sfx := range r.includeSuffixes {
+			if strings.HasSuffix(query, sfx) {
+				match = true
+				break
+			}
+		}
+		if !match && len(labels) > 1 {
+			_, match = r.namespaces[labels[1]]
+		}
+		if match {
+			preferred = append(preferred, r)
+		} else {
+			others = append(others, r)
+		}
+	}
+	return append(preferred, others...)
+}
+
 // tel2SubDomain aims to fix a search-path problem when using Docker on non-linux systems where
 // Docker uses its own search-path for single label names. This means that the search path that
 // is declared in the macOS resolver is ignored although the rest of the DNS-resolution works OK.
@@ -89,14 +288,20 @@ const tel2SubDomainDot = tel2SubDomain + "."
 var localhostIPs = []net.IP{{127, 0, 0, 1}, {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}}
 
 func (o *outbound) shouldDoClusterLookup(query string) bool {
-	if strings.HasSuffix(query, "."+o.router.clusterDomain) && strings.Count(query, ".") < 4 {
-		return false
+	for _, r := range o.allRouters() {
+		if r.clusterDomain == "" && !r.primary || r.isRemoved() {
+			// Not yet configured, or no longer in use
+			continue
+		}
+		if strings.HasSuffix(query, "."+r.clusterDomain) && strings.Count(query, ".") < 4 {
+			return false
+		}
 	}
 
 	query = query[:len(query)-1] // skip last dot
 
 	// Always include configured includeSuffixes
-	for _, sfx := range o.dnsConfig.IncludeSuffixes {
+	for _, sfx := range o.includeSuffixes() {
 		if strings.HasSuffix(query, sfx) {
 			return true
 		}
@@ -150,25 +355,19 @@ func (o *outbound) resolveInCluster(c context.Context, query string) (results []
 
 	queryWithNoTrailingDot := query[:len(query)-1]
 	dlog.Debugf(c, "LookupHost %q", queryWithNoTrailingDot)
-	response, err := o.router.managerClient.LookupHost(c, &manager.LookupHostRequest{
-		Session: o.router.session,
-		Host:    queryWithNoTrailingDot,
-	})
-	if err != nil {
-		dlog.Error(c, client.CheckTimeout(c, err))
-		return nil
-	}
-	if len(response.Ips) == 0 {
-		return nil
-	}
-	ips := make(iputil.IPs, len(response.Ips))
-	for i, ip := range response.Ips {
-		ips[i] = ip
+	for _, r := range o.routersForQuery(queryWithNoTrailingDot) {
+		if ips := r.lookupHost(c, queryWithNoTrailingDot); len(ips) > 0 {
+			return ips
+		}
 	}
-	return ips
+	return nil
 }
 
 func (o *outbound) setInfo(ctx context.Context, info *rpc.OutboundInfo) error {
+	r, err := o.routerFor(ctx)
+	if err != nil {
+		return err
+	}
 	if info.Dns == nil {
 		info.Dns = &rpc.DNSConfig{}
 	}
@@ -188,8 +387,13 @@ func (o *outbound) setInfo(ctx context.Context, info *rpc.OutboundInfo) error {
 	if info.Dns.LookupTimeout.AsDuration() <= 0 {
 		info.Dns.LookupTimeout = durationpb.New(4 * time.Second)
 	}
-	o.dnsConfig = info.Dns
-	return o.router.setOutboundInfo(ctx, info)
+	o.routersLock.Lock()
+	r.includeSuffixes = info.Dns.IncludeSuffixes
+	o.routersLock.Unlock()
+	if r.primary {
+		o.dnsConfig = info.Dns
+	}
+	return r.setOutboundInfo(ctx, info)
 }
 
 func (o *outbound) getInfo() *rpc.OutboundInfo {
@@ -222,16 +426,50 @@ func (o *outbound) getInfo() *rpc.OutboundInfo {
 	return &info
 }
 
-// SetSearchPath updates the DNS search path used by the resolver
-func (o *outbound) setSearchPath(ctx context.Context, paths, namespaces []string) {
+// SetSearchPath updates the DNS search path of a connection. The resolver uses the search paths of
+// all connections.
+func (o *outbound) setSearchPath(ctx context.Context, paths, namespaces []string) error {
+	r, err := o.routerFor(ctx)
+	if err != nil {
+		return err
+	}
 	// Provide direct access to intercepted namespaces
 	for _, ns := range namespaces {
-		paths = append(paths, ns+".svc."+o.router.clusterDomain)
+		paths = append(paths, ns+".svc."+r.clusterDomain)
 	}
+
+	o.routersLock.Lock()
+	r.searchPaths = paths
+	r.namespaces = make(map[string]struct{})
+	for _, path := range paths {
+		if path != "" && !strings.ContainsRune(path, '.') {
+			r.namespaces[path] = struct{}{}
+		}
+	}
+	allPaths := o.unlockedSearchPaths()
+	o.routersLock.Unlock()
+
 	select {
 	case <-ctx.Done():
-	case o.searchPathCh <- paths:
+	case o.searchPathCh <- allPaths:
+	}
+	return nil
+}
+
+// unlockedSearchPaths returns the union of the search paths of all connections. The caller must
+// hold the routersLock.
+func (o *outbound) unlockedSearchPaths() []string {
+	var allPaths []string
+	seen := make(map[string]struct{})
+	for _, r := range o.routers {
+		for _, path := range r.searchPaths {
+			if _, ok := seen[path]; !ok {
+				seen[path] = struct{}{}
+				allPaths = append(allPaths, path)
+			}
+		}
 	}
+	return allPaths
 }
 
 func (o *outbound) processSearchPaths(g *dgroup.Group, processor func(context.Context, []string) error) {
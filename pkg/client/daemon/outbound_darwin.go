@@ -13,7 +13,6 @@ import (
 
 	"github.com/datawire/dlib/dgroup"
 	"github.com/datawire/dlib/dlog"
-	"github.com/telepresenceio/telepresence/v2/pkg/client/daemon/dns"
 )
 
 type resolveFile struct {
@@ -183,7 +182,7 @@ func (o *outbound) dnsServerWorker(c context.Context) error {
 			o.processSearchPaths(g, func(c context.Context, paths []string) error {
 				return o.updateResolverFiles(c, resolverDirName, resolverFileName, dnsAddr, paths)
 			})
-			return dns.NewServer([]net.PacketConn{listener}, nil, o.resolveInCluster, &o.dnsCache).Run(c, make(chan struct{}))
+			return o.newDNSServer([]net.PacketConn{listener}, nil, o.resolveInCluster).Run(c, make(chan struct{}))
 		}
 	})
 	return g.Wait()
@@ -206,11 +205,12 @@ func (o *outbound) updateResolverFiles(c context.Context, resolverDirName, resol
 	}
 	namespaces[tel2SubDomain] = struct{}{}
 
-	domains := make(map[string]struct{}, len(namespaces)+len(o.dnsConfig.IncludeSuffixes))
+	includeSuffixes := o.includeSuffixes()
+	domains := make(map[string]struct{}, len(namespaces)+len(includeSuffixes))
 	for ns, v := range namespaces {
 		domains[ns] = v
 	}
-	for _, sfx := range o.dnsConfig.IncludeSuffixes {
+	for _, sfx := range includeSuffixes {
 		domains[strings.TrimPrefix(sfx, ".")] = struct{}{}
 	}
 
@@ -17,7 +17,6 @@ import (
 	"github.com/datawire/dlib/dgroup"
 	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/dlib/dtime"
-	"github.com/telepresenceio/telepresence/v2/pkg/client/daemon/dns"
 )
 
 var errResolveDNotConfigured = errors.New("resolved not configured")
@@ -163,7 +162,7 @@ func (o *outbound) runOverridingServer(c context.Context) error {
 				o.flushDNS()
 				return nil
 			})
-			return dns.NewServer(listeners, conn, o.resolveInSearch, &o.dnsCache).Run(c, serverStarted)
+			return o.newDNSServer(listeners, conn, o.resolveInSearch).Run(c, serverStarted)
 		}
 	})
 
@@ -277,11 +276,15 @@ func runningInDocker() bool {
 	return err == nil
 }
 
-// runNatTableCmd runs "iptables -t nat ..."
-func runNatTableCmd(c context.Context, args ...string) error {
+// runNatTableCmd runs "iptables -t nat ...", or "ip6tables -t nat ..." when ipv6 is true
+func runNatTableCmd(c context.Context, ipv6 bool, args ...string) error {
 	// We specifically don't want to use the cancellation of 'ctx' here, because we don't ever
 	// want to leave things in a half-cleaned-up state.
-	return dexec.CommandContext(c, "iptables", append([]string{"-t", "nat"}, args...)...).Run()
+	cmd := "iptables"
+	if ipv6 {
+		cmd = "ip6tables"
+	}
+	return dexec.CommandContext(c, cmd, append([]string{"-t", "nat"}, args...)...).Run()
 }
 
 const tpDNSChain = "telepresence-dns"
@@ -291,19 +294,25 @@ const tpDNSChain = "telepresence-dns"
 // DNS service. Another rule ensures that when our local DNS service cannot resolve and
 // uses a fallback, that fallback reaches the original DNS service.
 func routeDNS(c context.Context, dnsIP net.IP, toPort int, fallback *net.UDPAddr) (err error) {
+	ipv6 := dnsIP.To4() == nil
+	hostMask := "/32"
+	if ipv6 {
+		hostMask = "/128"
+	}
+
 	// create the chain
 	unrouteDNS(c)
-	if err = runNatTableCmd(c, "-N", tpDNSChain); err != nil {
+	if err = runNatTableCmd(c, ipv6, "-N", tpDNSChain); err != nil {
 		return err
 	}
 	// Alter locally generated packets before routing
-	if err = runNatTableCmd(c, "-I", "OUTPUT", "1", "-j", tpDNSChain); err != nil {
+	if err = runNatTableCmd(c, ipv6, "-I", "OUTPUT", "1", "-j", tpDNSChain); err != nil {
 		return err
 	}
 
 	// This rule prevents that any rules in this table applies to the fallback address. I.e. we
 	// let the fallback reach the original DNS service
-	if err = runNatTableCmd(c, "-A", tpDNSChain,
+	if err = runNatTableCmd(c, ipv6, "-A", tpDNSChain,
 		"-p", "udp",
 		"--source", fallback.IP.String(),
 		"--sport", strconv.Itoa(fallback.Port),
@@ -313,9 +322,9 @@ func routeDNS(c context.Context, dnsIP net.IP, toPort int, fallback *net.UDPAddr
 	}
 
 	// This rule redirects all packets intended for the DNS service to our local DNS service
-	return runNatTableCmd(c, "-A", tpDNSChain,
+	return runNatTableCmd(c, ipv6, "-A", tpDNSChain,
 		"-p", "udp",
-		"--dest", dnsIP.String()+"/32",
+		"--dest", dnsIP.String()+hostMask,
 		"--dport", "53",
 		"-j", "REDIRECT",
 		"--to-ports", strconv.Itoa(toPort),
@@ -326,7 +335,9 @@ func routeDNS(c context.Context, dnsIP net.IP, toPort int, fallback *net.UDPAddr
 func unrouteDNS(c context.Context) {
 	// The errors returned by these commands aren't of any interest besides logging. And they
 	// are already logged since dexec is used.
-	_ = runNatTableCmd(c, "-D", "OUTPUT", "-j", tpDNSChain)
-	_ = runNatTableCmd(c, "-F", tpDNSChain)
-	_ = runNatTableCmd(c, "-X", tpDNSChain)
+	for _, ipv6 := range []bool{false, true} {
+		_ = runNatTableCmd(c, ipv6, "-D", "OUTPUT", "-j", tpDNSChain)
+		_ = runNatTableCmd(c, ipv6, "-F", tpDNSChain)
+		_ = runNatTableCmd(c, ipv6, "-X", tpDNSChain)
+	}
 }
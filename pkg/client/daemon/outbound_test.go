@@ -0,0 +1,124 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/datawire/dlib/dgroup"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif"
+)
+
+// connectionContext returns a context that selects the given connection the way that the incoming
+// gRPC calls from the connector do.
+func connectionContext(ctx context.Context, name string) context.Context {
+	md, _ := metadata.FromOutgoingContext(client.WithConnectionName(ctx, name))
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func TestOutbound_routers(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	env, err := client.LoadEnv(ctx)
+	require.NoError(t, err)
+	ctx = client.WithEnv(ctx, env)
+	cfg := client.GetDefaultConfig(ctx)
+	ctx = client.WithConfig(ctx, &cfg)
+
+	// The routers use real TUN devices, so this test can only run with the privileges to create them.
+	dev, err := vif.OpenTun(ctx)
+	if err != nil {
+		t.Skipf("unable to create TUN devices: %v", err)
+	}
+	require.NoError(t, dev.Close())
+
+	o, err := newOutbound(ctx, "", false, nil)
+	require.NoError(t, err)
+	defer func() {
+		for _, r := range o.allRouters() {
+			r.stop(ctx)
+			assert.NoError(t, r.wait())
+		}
+	}()
+
+	// The daemon's group shuts down when any of its goroutines ends, so removing a connection must not
+	// end any goroutine in it.
+	ctx, cancel := context.WithCancel(ctx)
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{ShutdownOnNonError: true})
+	o.processSearchPaths(g, func(context.Context, []string) error { return nil })
+	g.Go("server-router", o.router.serve)
+	g.Go("test", func(ctx context.Context) error {
+		defer cancel()
+		alphaCtx := connectionContext(ctx, "alpha")
+		betaCtx := connectionContext(ctx, "beta")
+
+		// The first connection claims the primary router and the second gets a router of its own.
+		alpha, err := o.routerFor(alphaCtx)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		assert.Same(t, o.router, alpha)
+		beta, err := o.routerFor(betaCtx)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		assert.False(t, beta.primary)
+		assert.NotEqual(t, alpha.dev.Name(), beta.dev.Name())
+		assert.Equal(t, "beta", o.connectionUsing(beta.dev.Name()))
+
+		r, err := o.routerFor(alphaCtx)
+		assert.NoError(t, err)
+		assert.Same(t, alpha, r)
+		r, err = o.getRouter(betaCtx)
+		assert.NoError(t, err)
+		assert.Same(t, beta, r)
+		_, err = o.getRouter(connectionContext(ctx, "gamma"))
+		assert.Error(t, err)
+
+		// Queries are sent to the routers of matching connections first.
+		assert.NoError(t, o.setSearchPath(betaCtx, []string{"beta-ns"}, nil))
+		o.routersLock.Lock()
+		alpha.includeSuffixes = []string{".alpha.example"}
+		o.routersLock.Unlock()
+		assert.Equal(t, []*tunRouter{alpha, beta}, o.routersForQuery("svc.other-ns"))
+		assert.Equal(t, []*tunRouter{beta, alpha}, o.routersForQuery("svc.beta-ns"))
+		assert.Equal(t, []*tunRouter{alpha, beta}, o.routersForQuery("db.alpha.example"))
+
+		// Removing a connection closes its router, and a new router is created when the name is reused.
+		assert.NoError(t, o.removeRouter(betaCtx))
+		assert.True(t, beta.isRemoved())
+		assert.Equal(t, []*tunRouter{alpha}, o.allRouters())
+		assert.Equal(t, []*tunRouter{alpha}, o.routersForQuery("svc.beta-ns"))
+		assert.Equal(t, "", o.connectionUsing(beta.dev.Name()))
+		r, err = o.routerFor(betaCtx)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		assert.NotSame(t, beta, r)
+		beta = r
+
+		// The primary router keeps its TUN device when its connection is removed, but it's no longer
+		// claimed or used.
+		assert.NoError(t, o.removeRouter(alphaCtx))
+		assert.True(t, alpha.isRemoved())
+		assert.Equal(t, []*tunRouter{alpha, beta}, o.allRouters())
+		assert.Equal(t, []*tunRouter{beta}, o.routersForQuery("db.alpha.example"))
+		assert.Empty(t, o.includeSuffixes())
+		gamma, err := o.routerFor(connectionContext(ctx, "gamma"))
+		if assert.NoError(t, err) {
+			assert.NotSame(t, alpha, gamma)
+		}
+
+		assert.Error(t, o.removeRouter(alphaCtx))
+		assert.Error(t, o.removeRouter(ctx))
+		assert.Never(t, func() bool { return ctx.Err() != nil }, 200*time.Millisecond, 10*time.Millisecond,
+			"the removal of a connection shut down the group")
+		return nil
+	})
+	require.NoError(t, g.Wait())
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/datawire/dlib/dgroup"
-	"github.com/telepresenceio/telepresence/v2/pkg/client/daemon/dns"
 )
 
 func (o *outbound) dnsServerWorker(c context.Context) error {
@@ -30,7 +29,7 @@ func (o *outbound) dnsServerWorker(c context.Context) error {
 			return nil
 		case <-o.router.configured():
 			o.processSearchPaths(g, o.updateRouterDNS)
-			return dns.NewServer([]net.PacketConn{listener}, nil, o.resolveInCluster, &o.dnsCache).Run(c, make(chan struct{}))
+			return o.newDNSServer([]net.PacketConn{listener}, nil, o.resolveInCluster).Run(c, make(chan struct{}))
 		}
 	})
 	return g.Wait()
@@ -49,9 +49,9 @@ func (o *outbound) tryResolveD(c context.Context, dev *vif.Device) error {
 			initDone <- struct{}{}
 			return nil
 		case <-o.router.configured():
-			dnsIP := o.router.dnsIP
-			dlog.Infof(c, "Configuring DNS IP %s", dnsIP)
-			if err = dbus.SetLinkDNS(c, int(dev.Index()), dnsIP); err != nil {
+			dnsIPs := o.router.dnsIPs
+			dlog.Infof(c, "Configuring DNS IPs %v", dnsIPs)
+			if err = dbus.SetLinkDNS(c, int(dev.Index()), dnsIPs...); err != nil {
 				dlog.Error(c, err)
 				initDone <- struct{}{}
 				return errResolveDNotConfigured
@@ -77,7 +77,7 @@ func (o *outbound) tryResolveD(c context.Context, dev *vif.Device) error {
 				initDone <- struct{}{}
 				return errResolveDNotConfigured
 			}
-			dnsServer = dns.NewServer(listeners, nil, o.resolveInCluster, &o.dnsCache)
+			dnsServer = o.newDNSServer(listeners, nil, o.resolveInCluster)
 			return dnsServer.Run(c, initDone)
 		}
 	})
@@ -120,7 +120,7 @@ func (o *outbound) updateLinkDomains(c context.Context, paths []string) error {
 			paths[i] = "~" + path
 		}
 	}
-	for _, sfx := range o.dnsConfig.IncludeSuffixes {
+	for _, sfx := range o.includeSuffixes() {
 		paths = append(paths, "~"+strings.TrimPrefix(sfx, "."))
 	}
 	paths = append(paths, o.router.clusterDomain)
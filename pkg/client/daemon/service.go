@@ -80,11 +80,25 @@ func (d *service) Version(_ context.Context, _ *empty.Empty) (*common.VersionInf
 	}, nil
 }
 
-func (d *service) Status(_ context.Context, _ *empty.Empty) (*rpc.DaemonStatus, error) {
-	r := &rpc.DaemonStatus{
-		OutboundConfig: d.outbound.getInfo(),
+// Status returns the outbound configuration, and the network conditions that the router of the calling
+// connection simulates.
+func (d *service) Status(ctx context.Context, _ *empty.Empty) (*rpc.DaemonStatus, error) {
+	r, err := d.outbound.getRouter(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return r, nil
+	_, shaping := r.getShaping()
+	stats := r.stats.Stats()
+	return &rpc.DaemonStatus{
+		OutboundConfig: d.outbound.getInfo(),
+		NetworkShaping: &rpc.NetworkShaping{Outbound: shaping},
+		TunnelStats: &rpc.TunnelStats{
+			Sent:         stats.Sent,
+			SentWire:     stats.SentWire,
+			Received:     stats.Received,
+			ReceivedWire: stats.ReceivedWire,
+		},
+	}, nil
 }
 
 func (d *service) Quit(ctx context.Context, _ *empty.Empty) (*empty.Empty, error) {
@@ -94,8 +108,7 @@ func (d *service) Quit(ctx context.Context, _ *empty.Empty) (*empty.Empty, error
 }
 
 func (d *service) SetDnsSearchPath(ctx context.Context, paths *rpc.Paths) (*empty.Empty, error) {
-	d.outbound.setSearchPath(ctx, paths.Paths, paths.Namespaces)
-	return &empty.Empty{}, nil
+	return &empty.Empty{}, d.outbound.setSearchPath(ctx, paths.Paths, paths.Namespaces)
 }
 
 func (d *service) SetOutboundInfo(ctx context.Context, info *rpc.OutboundInfo) (*empty.Empty, error) {
@@ -103,8 +116,12 @@ func (d *service) SetOutboundInfo(ctx context.Context, info *rpc.OutboundInfo) (
 }
 
 func (d *service) GetClusterSubnets(ctx context.Context, _ *empty.Empty) (*rpc.ClusterSubnets, error) {
+	r, err := d.outbound.getRouter(ctx)
+	if err != nil {
+		return nil, err
+	}
 	select {
-	case <-d.outbound.router.cfgComplete:
+	case <-r.cfgComplete:
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
@@ -112,7 +129,7 @@ func (d *service) GetClusterSubnets(ctx context.Context, _ *empty.Empty) (*rpc.C
 	// we should expect to have everything
 	tCtx, tCancel := context.WithTimeout(ctx, 5*time.Second)
 	defer tCancel()
-	infoStream, err := d.outbound.router.managerClient.WatchClusterInfo(tCtx, d.outbound.router.session)
+	infoStream, err := r.managerClient.WatchClusterInfo(tCtx, r.getSession())
 	if err != nil {
 		return nil, err
 	}
@@ -126,7 +143,9 @@ func (d *service) GetClusterSubnets(ctx context.Context, _ *empty.Empty) (*rpc.C
 			}
 			break
 		}
-		if mgrInfo.ServiceSubnet != nil {
+		if len(mgrInfo.ServiceSubnets) > 0 {
+			svcSubnets = append(svcSubnets, mgrInfo.ServiceSubnets...)
+		} else if mgrInfo.ServiceSubnet != nil {
 			svcSubnets = append(svcSubnets, mgrInfo.ServiceSubnet)
 		}
 		podSubnets = append(podSubnets, mgrInfo.PodSubnets...)
@@ -134,6 +153,31 @@ func (d *service) GetClusterSubnets(ctx context.Context, _ *empty.Empty) (*rpc.C
 	return &rpc.ClusterSubnets{PodSubnets: podSubnets, SvcSubnets: svcSubnets}, nil
 }
 
+// WatchNotifications streams messages for the user, such as warnings about cluster subnets that
+// conflict with local routes. Messages that were produced before the call are streamed too.
+func (d *service) WatchNotifications(_ *empty.Empty, stream rpc.Daemon_WatchNotificationsServer) error {
+	ctx := stream.Context()
+	dlog.Debug(ctx, "Received gRPC WatchNotifications")
+	return d.outbound.notifier.watch(ctx, func(msg string) error {
+		return stream.Send(&rpc.Notification{Message: msg})
+	})
+}
+
+// SetNetworkShaping sets the network conditions that the router of the calling connection simulates for
+// new outbound connections.
+func (d *service) SetNetworkShaping(ctx context.Context, shaping *rpc.NetworkShaping) (*empty.Empty, error) {
+	r, err := d.outbound.getRouter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &empty.Empty{}, r.setShaping(ctx, shaping.Outbound)
+}
+
+// RemoveConnection releases the router of the calling connection along with its routes and TUN device.
+func (d *service) RemoveConnection(ctx context.Context, _ *empty.Empty) (*empty.Empty, error) {
+	return &empty.Empty{}, d.outbound.removeRouter(ctx)
+}
+
 func (d *service) SetLogLevel(ctx context.Context, request *manager.LogLevelRequest) (*empty.Empty, error) {
 	duration := time.Duration(0)
 	if request.Duration != nil {
@@ -237,7 +281,7 @@ func run(c context.Context, loggingDir, configDir, dns string) error {
 	scoutUsers.Add(1)
 	g.Go("server-router", func(ctx context.Context) error {
 		defer scoutUsers.Done()
-		return d.outbound.router.run(ctx)
+		return d.outbound.router.serve(ctx)
 	})
 
 	// server-grpc listens on /var/run/telepresence-daemon.socket and services gRPC requests
@@ -308,9 +352,14 @@ func run(c context.Context, loggingDir, configDir, dns string) error {
 	return err
 }
 
-// quitAll shuts down the router and calls quitConnector
+// quitAll shuts down the routers and calls quitConnector
 func (d *service) quitAll(c context.Context) error {
-	d.outbound.router.stop(c)
+	for _, r := range d.outbound.allRouters() {
+		r.stop(c)
+		if err := r.wait(); err != nil {
+			dlog.Error(c, err)
+		}
+	}
 	return d.quitConnector(c)
 }
 
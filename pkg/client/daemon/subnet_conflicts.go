@@ -0,0 +1,191 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/subnet"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif/routing"
+)
+
+// subnetMapping maps a cluster subnet that conflicts with a local route to a synthetic subnet of the
+// same size that is routed to the TUN device in its place.
+type subnetMapping struct {
+	real      *net.IPNet
+	synthetic *net.IPNet
+}
+
+// syntheticSubnetCandidates are the ranges from which synthetic subnets are allocated, in order of
+// preference.
+var syntheticSubnetCandidates = func() []*net.IPNet {
+	cidrs := []string{
+		"198.18.0.0/15",  // Benchmarking (RFC 2544)
+		"100.64.0.0/10",  // Shared address space (RFC 6598)
+		"172.16.0.0/12",  // Private (RFC 1918)
+		"10.0.0.0/8",     // Private (RFC 1918)
+		"192.168.0.0/16", // Private (RFC 1918)
+		"fd74:6c32::/32", // Unique local (RFC 4193)
+	}
+	sns := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, sns[i], _ = net.ParseCIDR(cidr)
+	}
+	return sns
+}()
+
+// conflictingRoute returns the first route in the given routing table that overlaps the given
+// subnet, or nil if no such route exists. Default routes, routes that use the TUN device, and the
+// static routes that are added for never-proxied subnets are expected and therefore ignored.
+func conflictingRoute(sn *net.IPNet, table []routing.Route, tunName string, neverProxy []routing.Route) *routing.Route {
+	for i := range table {
+		rt := &table[i]
+		if (rt.Interface != nil && rt.Interface.Name == tunName) || isDefaultRoute(rt) || isNeverProxyRoute(rt, neverProxy) {
+			continue
+		}
+		if subnet.OverlapOf(sn, rt.RoutedNet) != subnet.Disjoint {
+			return rt
+		}
+	}
+	return nil
+}
+
+// resolveSubnetConflicts compares the given cluster subnets with the local routing table and returns
+// the subnets that should be routed to the TUN device. The user is notified about each conflicting
+// subnet. If remapping of conflicting subnets is enabled in the config, a conflicting subnet is
+// replaced by a synthetic subnet that doesn't overlap any local route.
+func (t *tunRouter) resolveSubnetConflicts(ctx context.Context, subnets []*net.IPNet) []*net.IPNet {
+	table, err := routing.GetRoutingTable(ctx)
+	if err != nil {
+		dlog.Errorf(ctx, "unable to check cluster subnets for conflicts: %v", err)
+		return subnets
+	}
+	remap := client.GetConfig(ctx).Routing.RemapConflictingSubnets
+
+	t.subnetMappingsLock.RLock()
+	oldMappings := t.subnetMappings
+	t.subnetMappingsLock.RUnlock()
+
+	// Subnets that a synthetic subnet must not overlap
+	var occupied []*net.IPNet
+	for i := range table {
+		if rt := &table[i]; !isDefaultRoute(rt) {
+			occupied = append(occupied, rt.RoutedNet)
+		}
+	}
+	occupied = append(occupied, subnets...)
+	occupied = append(occupied, t.alsoProxySubnets...)
+	for _, m := range oldMappings {
+		occupied = append(occupied, m.synthetic)
+	}
+
+	if t.reportedConflicts == nil {
+		t.reportedConflicts = make(map[string]struct{})
+	}
+	result := make([]*net.IPNet, 0, len(subnets))
+	var mappings []subnetMapping
+nextSubnet:
+	for _, sn := range subnets {
+		for _, m := range oldMappings {
+			if subnet.Equal(m.real, sn) {
+				mappings = append(mappings, m)
+				result = append(result, m.synthetic)
+				continue nextSubnet
+			}
+		}
+		rt := conflictingRoute(sn, table, t.dev.Name(), t.neverProxySubnets)
+		if rt == nil {
+			result = append(result, sn)
+			continue
+		}
+
+		// The conflicting route may belong to the TUN device of another connection
+		rtDesc := "the local route " + rt.String()
+		if rt.Interface != nil && t.connectionUsing != nil {
+			if owner := t.connectionUsing(rt.Interface.Name); owner != "" {
+				rtDesc = fmt.Sprintf("the route %s of connection %q", rt, owner)
+			}
+		}
+
+		_, reported := t.reportedConflicts[sn.String()]
+		t.reportedConflicts[sn.String()] = struct{}{}
+		if !remap {
+			if !reported {
+				t.notify(ctx, fmt.Sprintf("Cluster subnet %s conflicts with %s. Hosts in %s may be unreachable. "+
+					"Set routing.remapConflictingSubnets to true in %s to map the cluster subnet to a synthetic subnet. See %s",
+					sn, rtDesc, rt.RoutedNet, client.GetConfigFile(ctx), vpnDocsURL))
+			}
+			result = append(result, sn)
+			continue
+		}
+
+		ones, bits := sn.Mask.Size()
+		synthetic := subnet.FindAvailable(ones, bits, syntheticSubnetCandidates, occupied)
+		if synthetic == nil {
+			if !reported {
+				t.notify(ctx, fmt.Sprintf("Cluster subnet %s conflicts with %s, and no synthetic subnet "+
+					"could be found to map it to. See %s", sn, rtDesc, vpnDocsURL))
+			}
+			result = append(result, sn)
+			continue
+		}
+		occupied = append(occupied, synthetic)
+		mappings = append(mappings, subnetMapping{real: sn, synthetic: synthetic})
+		result = append(result, synthetic)
+		t.notify(ctx, fmt.Sprintf("Cluster subnet %s conflicts with %s and has been mapped to %s. "+
+			"Addresses in %s are reachable using the corresponding addresses in %s, and DNS lookups of cluster names "+
+			"return addresses in %s", sn, rtDesc, synthetic, sn, synthetic, synthetic))
+	}
+
+	t.subnetMappingsLock.Lock()
+	t.subnetMappings = mappings
+	t.subnetMappingsLock.Unlock()
+	return result
+}
+
+// notify logs the given message as a warning and makes it available to the WatchNotifications call.
+func (t *tunRouter) notify(ctx context.Context, msg string) {
+	dlog.Warn(ctx, msg)
+	t.notifier.notify(msg)
+}
+
+// syntheticSubnet returns the synthetic subnet that the given cluster subnet is mapped to, or nil
+// when the cluster subnet isn't mapped.
+func (t *tunRouter) syntheticSubnet(sn *net.IPNet) *net.IPNet {
+	t.subnetMappingsLock.RLock()
+	defer t.subnetMappingsLock.RUnlock()
+	for _, m := range t.subnetMappings {
+		if subnet.Equal(m.real, sn) {
+			return m.synthetic
+		}
+	}
+	return nil
+}
+
+// toSynthetic translates an IP in a mapped cluster subnet to the corresponding IP in the synthetic
+// subnet. Other IPs are returned unchanged.
+func (t *tunRouter) toSynthetic(ip net.IP) net.IP {
+	t.subnetMappingsLock.RLock()
+	defer t.subnetMappingsLock.RUnlock()
+	for _, m := range t.subnetMappings {
+		if m.real.Contains(ip) {
+			return subnet.Translate(ip, m.synthetic)
+		}
+	}
+	return ip
+}
+
+// toReal translates an IP in a synthetic subnet to the corresponding IP in the cluster subnet that
+// it is mapped from. Other IPs are returned unchanged.
+func (t *tunRouter) toReal(ip net.IP) net.IP {
+	t.subnetMappingsLock.RLock()
+	defer t.subnetMappingsLock.RUnlock()
+	for _, m := range t.subnetMappings {
+		if m.synthetic.Contains(ip) {
+			return subnet.Translate(ip, m.real)
+		}
+	}
+	return ip
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -17,12 +18,14 @@ import (
 	"google.golang.org/grpc"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 
+	"github.com/datawire/dlib/dcontext"
 	"github.com/datawire/dlib/dgroup"
 	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/dlib/dtime"
 	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/connpool"
 	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
@@ -64,6 +67,33 @@ import (
 // packets to the manager. TCP will send some control packets. One to verify that a connection can
 // be established at the manager side, and one when the connection is closed (from either side).
 type tunRouter struct {
+	// stats accumulates the payload byte counts of the tunnel streams of outbound connections. It's
+	// reported in the daemon status.
+	stats *tunnel.StatsCounter
+
+	// name is the name of the connector connection that this router serves. It's empty for the
+	// primary router until the first connection claims it.
+	name string
+
+	// primary is true for the router whose TUN device is integrated with the DNS resolver of
+	// the OS. There's always exactly one primary router.
+	primary bool
+
+	// seq is a sequence number that keeps the goroutine names of routers unique when a connection
+	// name is reused after the connection has been removed.
+	seq int
+
+	// includeSuffixes, searchPaths, and namespaces are reported by the connection and used when
+	// deciding which router that resolves a DNS query. They are guarded by the routersLock of
+	// the outbound.
+	includeSuffixes []string
+	searchPaths     []string
+	namespaces      map[string]struct{}
+
+	// connectionUsing returns the name of the connection whose router owns the given TUN device,
+	// or an empty string when the device is unknown.
+	connectionUsing func(devName string) string
+
 	// dev is the TUN device that gets configured with the subnets found in the cluster
 	dev *vif.Device
 
@@ -87,6 +117,10 @@ type tunRouter struct {
 	dnsIP   net.IP
 	dnsPort uint16
 
+	// dnsIPs are all IPs of the DNS server, one for each IP family that it supports. The first
+	// one is equal to dnsIP.
+	dnsIPs []net.IP
+
 	// dnsLocalAddr is the address of the local DNS server
 	dnsLocalAddr *net.UDPAddr
 
@@ -101,6 +135,23 @@ type tunRouter struct {
 
 	// Subnets configured not to be proxied
 	neverProxySubnets []routing.Route
+
+	// Subnets configured not to be proxied for which no route could be found
+	unroutedNeverProxySubnets []*net.IPNet
+
+	// Cluster subnets that conflict with local routes, mapped to the synthetic subnets that are
+	// routed in their place. Only populated when routing.remapConflictingSubnets is enabled.
+	subnetMappings     []subnetMapping
+	subnetMappingsLock sync.RWMutex
+
+	// Cluster subnets for which a conflict has been reported to the user. Only used in the
+	// resolveSubnetConflicts() method.
+	reportedConflicts map[string]struct{}
+
+	// notifier retains messages for the user, such as warnings about subnet conflicts. It's
+	// shared by all routers.
+	notifier *notifier
+
 	// Subnets that the router is currently configured with. Managed, and only used in
 	// the refreshSubnets() method.
 	curSubnets      []*net.IPNet
@@ -112,8 +163,16 @@ type tunRouter struct {
 	//   2 = closed
 	closing int32
 
-	// session contains the manager session
-	session *manager.SessionInfo
+	// session contains the manager session. It's replaced when the connector establishes a
+	// new session after the previous one expired.
+	session     *manager.SessionInfo
+	sessionLock sync.Mutex
+
+	// shaping is the network conditions, as parsed by tunnel.ParseFaults, that are simulated
+	// for new outbound connections. Its spec is reported in the daemon status.
+	shaping     *tunnel.Faults
+	shapingSpec string
+	shapingLock sync.Mutex
 
 	// cfgComplete will be closed as soon as the connector has sent over the correct port to
 	// the traffic manager and the managerClient has been connected.
@@ -123,25 +182,129 @@ type tunRouter struct {
 	// the traffic manager
 	tmVerOk chan struct{}
 
+	// peerVersion is the tunnel version of the traffic manager. Only valid once tmVerOk is closed.
+	peerVersion uint16
+
+	// muxSession carries the streams of outbound connections when the traffic manager supports
+	// multiplexed sessions. It's nil until the session is established and while it's reestablished.
+	muxSession     *tunnel.MuxSession
+	muxSessionLock sync.Mutex
+
 	// rndSource is the source for the random number generator in the TCP handlers
 	rndSource rand.Source
+
+	// removed is closed when the connection that the router serves is removed.
+	removed     chan struct{}
+	removedOnce sync.Once
+
+	// group runs the goroutines of the router. It's owned by the router rather than by the daemon,
+	// because the daemon's group shuts down when any of its goroutines ends, and the goroutines of
+	// the router end when its connection is removed.
+	group       *dgroup.Group
+	cancelGroup context.CancelFunc
+	waitOnce    sync.Once
+
+	// ended is closed when the run method of the router returns.
+	ended chan struct{}
 }
 
-func newTunRouter(ctx context.Context) (*tunRouter, error) {
+func newTunRouter(ctx context.Context, n *notifier) (*tunRouter, error) {
 	td, err := vif.OpenTun(ctx)
 	if err != nil {
 		return nil, err
 	}
+	// The group must outlive the given context, which is the context of a gRPC call for all but
+	// the primary router.
+	gc, cancel := context.WithCancel(dcontext.WithoutCancel(ctx))
 	return &tunRouter{
+		group:       dgroup.NewGroup(gc, dgroup.GroupConfig{}),
+		cancelGroup: cancel,
+		ended:       make(chan struct{}),
+		stats:       &tunnel.StatsCounter{},
+		notifier:    n,
 		dev:         td,
 		handlers:    tunnel.NewPool(),
 		cfgComplete: make(chan struct{}),
 		tmVerOk:     make(chan struct{}),
 		fragmentMap: make(map[uint16][]*buffer.Data),
 		rndSource:   rand.NewSource(time.Now().UnixNano()),
+		removed:     make(chan struct{}),
 	}, nil
 }
 
+// isRemoved returns true when the connection that the router serves has been removed.
+func (t *tunRouter) isRemoved() bool {
+	select {
+	case <-t.removed:
+		return true
+	default:
+		return false
+	}
+}
+
+// start runs the router in the group that the router owns.
+func (t *tunRouter) start() {
+	t.group.Go(t.goroutineName("server-router"), func(c context.Context) error {
+		defer close(t.ended)
+		return t.run(c)
+	})
+}
+
+// serve starts the router and waits until the given context is cancelled. An error is returned when the
+// router fails. The router isn't stopped when its connection is removed, so serve keeps waiting in that
+// case.
+func (t *tunRouter) serve(c context.Context) error {
+	t.start()
+	select {
+	case <-c.Done():
+	case <-t.ended:
+		if t.isRemoved() {
+			<-c.Done()
+		}
+	}
+	return t.wait()
+}
+
+// wait cancels the goroutines of the router and waits for them to end.
+func (t *tunRouter) wait() (err error) {
+	t.waitOnce.Do(func() {
+		t.cancelGroup()
+		err = t.group.Wait()
+	})
+	return err
+}
+
+// release stops the goroutines of a router whose connection has been removed, waits for them to
+// end, and closes the connections that it handles. The TUN device of the primary router remains
+// open, because it's integrated with the DNS resolver of the OS, but the routes that it was given
+// are removed. The TUN device of any other router is closed.
+func (t *tunRouter) release(c context.Context) {
+	t.removedOnce.Do(func() { close(t.removed) })
+	if err := t.wait(); err != nil {
+		dlog.Errorf(c, "router of connection %q ended with: %v", t.name, err)
+	}
+	if !t.primary {
+		t.stop(c)
+		return
+	}
+	t.handlers.CloseAll(c)
+	t.clusterSubnets = nil
+	t.alsoProxySubnets = nil
+	if err := t.refreshSubnets(c); err != nil {
+		dlog.Error(c, err)
+	}
+}
+
+// goroutineName returns the given name for the primary router, and the given name suffixed with the
+// connection name and the sequence number for other routers, so that goroutines of different routers
+// can coexist.
+func (t *tunRouter) goroutineName(name string) string {
+	if t.primary {
+		return name
+	}
+	return fmt.Sprintf("%s-%s-%d", name, t.name, t.seq)
+}
+
 func (t *tunRouter) configured() <-chan struct{} {
 	return t.tmVerOk
 }
@@ -151,6 +314,16 @@ func (t *tunRouter) configureDNS(_ context.Context, dnsLocalAddr *net.UDPAddr) {
 	t.dnsLocalAddr = dnsLocalAddr
 }
 
+// isDNSIP returns true if the given IP is one of the IPs of the DNS server
+func (t *tunRouter) isDNSIP(ip net.IP) bool {
+	for _, dnsIP := range t.dnsIPs {
+		if ip.Equal(dnsIP) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *tunRouter) reconcileStaticRoutes(ctx context.Context) error {
 	desired := []routing.Route{}
 
@@ -258,7 +431,7 @@ func (t *tunRouter) setOutboundInfo(ctx context.Context, mi *daemon.OutboundInfo
 			}
 			return client.CheckTimeout(tc, err)
 		}
-		t.session = mi.Session
+		t.setSession(mi.Session)
 		t.managerClient = manager.NewManagerClient(conn)
 
 		if len(mi.AlsoProxySubnets) > 0 {
@@ -271,6 +444,7 @@ func (t *tunRouter) setOutboundInfo(ctx context.Context, mi *daemon.OutboundInfo
 		}
 		if len(mi.NeverProxySubnets) > 0 {
 			t.neverProxySubnets = []routing.Route{}
+			t.unroutedNeverProxySubnets = nil
 			for _, np := range mi.NeverProxySubnets {
 				npSn := iputil.IPNetFromRPC(np)
 				dlog.Infof(ctx, "Adding never-proxy subnet %s", npSn)
@@ -280,27 +454,71 @@ func (t *tunRouter) setOutboundInfo(ctx context.Context, mi *daemon.OutboundInfo
 						"If this is your kubernetes API server you may want to open an issue, since telepresence may not work if it falls within the CIDR for pods/services. "+
 						"Error: %v",
 						iputil.IPNetFromRPC(np), err)
+					t.unroutedNeverProxySubnets = append(t.unroutedNeverProxySubnets, npSn)
 					continue
 				}
 				t.neverProxySubnets = append(t.neverProxySubnets, route)
 			}
 		}
 		t.dnsIP = mi.Dns.RemoteIp
+		if t.dnsIP != nil {
+			t.dnsIPs = []net.IP{t.dnsIP}
+		}
 
-		dgroup.ParentGroup(ctx).Go("watch-cluster-info", func(ctx context.Context) error {
+		t.group.Go(t.goroutineName("watch-cluster-info"), func(ctx context.Context) error {
 			t.watchClusterInfo(ctx)
 			return nil
 		})
+	} else if mi.Session.GetSessionId() != t.getSession().GetSessionId() {
+		dlog.Infof(ctx, "Switching to traffic-manager session %s", mi.Session.GetSessionId())
+		t.setSession(mi.Session)
 	}
 	return nil
 }
 
+func (t *tunRouter) getSession() *manager.SessionInfo {
+	t.sessionLock.Lock()
+	defer t.sessionLock.Unlock()
+	return t.session
+}
+
+func (t *tunRouter) setSession(session *manager.SessionInfo) {
+	t.sessionLock.Lock()
+	t.session = session
+	t.sessionLock.Unlock()
+}
+
+// setShaping sets the network conditions to simulate for new outbound connections. An empty spec
+// removes them.
+func (t *tunRouter) setShaping(ctx context.Context, spec string) error {
+	shaping, err := tunnel.ParseFaults(spec)
+	if err != nil {
+		return errcat.User.New(err)
+	}
+	t.shapingLock.Lock()
+	t.shaping = shaping
+	t.shapingSpec = spec
+	t.shapingLock.Unlock()
+	if shaping.Enabled() {
+		dlog.Infof(ctx, "Simulating network conditions for outbound connections: %s", shaping)
+	} else {
+		dlog.Info(ctx, "No longer simulating network conditions for outbound connections")
+	}
+	return nil
+}
+
+func (t *tunRouter) getShaping() (*tunnel.Faults, string) {
+	t.shapingLock.Lock()
+	defer t.shapingLock.Unlock()
+	return t.shaping, t.shapingSpec
+}
+
 func (t *tunRouter) watchClusterInfo(ctx context.Context) {
 	cfgComplete := t.cfgComplete
 	backoff := 100 * time.Millisecond
 
 	for ctx.Err() == nil {
-		infoStream, err := t.managerClient.WatchClusterInfo(ctx, t.session)
+		infoStream, err := t.managerClient.WatchClusterInfo(ctx, t.getSession())
 		if err != nil {
 			err = fmt.Errorf("error when calling WatchClusterInfo: %w", err)
 			dlog.Warn(ctx, err)
@@ -316,9 +534,14 @@ func (t *tunRouter) watchClusterInfo(ctx context.Context) {
 			}
 			dlog.Debugf(ctx, "WatchClusterInfo update")
 
-			subnets := make([]*net.IPNet, 0, 1+len(mgrInfo.PodSubnets))
-			if mgrInfo.ServiceSubnet != nil {
-				cidr := iputil.IPNetFromRPC(mgrInfo.ServiceSubnet)
+			svcSubnets := mgrInfo.ServiceSubnets
+			if len(svcSubnets) == 0 && mgrInfo.ServiceSubnet != nil {
+				// Traffic manager predates dual-stack support
+				svcSubnets = []*manager.IPNet{mgrInfo.ServiceSubnet}
+			}
+			subnets := make([]*net.IPNet, 0, len(svcSubnets)+len(mgrInfo.PodSubnets))
+			for _, sn := range svcSubnets {
+				cidr := iputil.IPNetFromRPC(sn)
 				dlog.Infof(ctx, "Adding service subnet %s", cidr)
 				subnets = append(subnets, cidr)
 			}
@@ -329,7 +552,7 @@ func (t *tunRouter) watchClusterInfo(ctx context.Context) {
 				subnets = append(subnets, cidr)
 			}
 
-			t.clusterSubnets = subnets
+			t.clusterSubnets = t.resolveSubnetConflicts(ctx, subnets)
 			if err := t.refreshSubnets(ctx); err != nil {
 				dlog.Error(ctx, err)
 			}
@@ -337,8 +560,18 @@ func (t *tunRouter) watchClusterInfo(ctx context.Context) {
 			if cfgComplete != nil {
 				// Only set clusterDNS when it hasn't been explicitly set with the --dns option
 				if t.dnsIP == nil {
-					dlog.Infof(ctx, "Setting cluster DNS to %s", net.IP(mgrInfo.KubeDnsIp))
-					t.dnsIP = mgrInfo.KubeDnsIp
+					dnsIPs := mgrInfo.KubeDnsIps
+					if len(dnsIPs) == 0 && mgrInfo.KubeDnsIp != nil {
+						// Traffic manager predates dual-stack support
+						dnsIPs = [][]byte{mgrInfo.KubeDnsIp}
+					}
+					for _, ip := range dnsIPs {
+						dlog.Infof(ctx, "Setting cluster DNS to %s", net.IP(ip))
+						t.dnsIPs = append(t.dnsIPs, ip)
+					}
+					if len(t.dnsIPs) > 0 {
+						t.dnsIP = t.dnsIPs[0]
+					}
 				}
 				dlog.Infof(ctx, "Setting cluster domain to %q", mgrInfo.ClusterDomain)
 				t.clusterDomain = mgrInfo.ClusterDomain
@@ -359,6 +592,33 @@ func (t *tunRouter) watchClusterInfo(ctx context.Context) {
 	}
 }
 
+// lookupHost asks the traffic-manager to resolve the given host. Answers in a cluster subnet that
+// has been remapped use the synthetic subnet.
+func (t *tunRouter) lookupHost(c context.Context, host string) iputil.IPs {
+	select {
+	case <-t.cfgComplete:
+	default:
+		// Not connected to the traffic-manager yet
+		return nil
+	}
+	response, err := t.managerClient.LookupHost(c, &manager.LookupHostRequest{
+		Session: t.getSession(),
+		Host:    host,
+	})
+	if err != nil {
+		dlog.Error(c, client.CheckTimeout(c, err))
+		return nil
+	}
+	if len(response.Ips) == 0 {
+		return nil
+	}
+	ips := make(iputil.IPs, len(response.Ips))
+	for i, ip := range response.Ips {
+		ips[i] = t.toSynthetic(ip)
+	}
+	return ips
+}
+
 func (t *tunRouter) stop(c context.Context) {
 	if atomic.CompareAndSwapInt32(&t.closing, 0, 1) {
 		cc, cancel := context.WithTimeout(c, time.Second)
@@ -388,6 +648,9 @@ var blockedUDPPorts = map[uint16]bool{
 }
 
 func (t *tunRouter) run(c context.Context) error {
+	if faults := client.GetConfig(c).Tunnel.GetFaults(); faults != nil {
+		dlog.Warnf(c, "Injecting faults into tunnels: %s", faults)
+	}
 	g := dgroup.NewGroup(c, dgroup.GroupConfig{})
 
 	g.Go("MGR stream", func(c context.Context) error {
@@ -413,8 +676,8 @@ func (t *tunRouter) run(c context.Context) error {
 			if err != nil {
 				return err
 			}
-			muxTunnel := connpool.NewMuxTunnel(clientTunnel)
-			if err = muxTunnel.Send(c, connpool.SessionInfoControl(t.session)); err != nil {
+			muxTunnel := connpool.NewFaultyMuxTunnel(c, clientTunnel, client.GetConfig(c).Tunnel.GetFaults())
+			if err = muxTunnel.Send(c, connpool.SessionInfoControl(t.getSession())); err != nil {
 				return err
 			}
 
@@ -441,6 +704,7 @@ func (t *tunRouter) run(c context.Context) error {
 					<-c.Done()
 				}
 			} else {
+				t.peerVersion = peerVersion
 				close(t.tmVerOk)
 				dlog.Debug(c, "closing since a more recent system detected")
 				err = muxTunnel.CloseSend()
@@ -449,6 +713,43 @@ func (t *tunRouter) run(c context.Context) error {
 		})
 	})
 
+	g.Go("MUX session", func(c context.Context) error {
+		select {
+		case <-c.Done():
+			return nil
+		case <-t.tmVerOk:
+		}
+		// Versions >= 4 multiplex the streams of all connections over one session.
+		if t.peerVersion < 4 {
+			return nil
+		}
+		return client.Retry(c, "MUX session", func(c context.Context) error {
+			mt, err := t.managerClient.Tunnel(c)
+			if err != nil {
+				return err
+			}
+			ms, err := tunnel.NewMuxSession(tunnel.WithCompressions(c, client.GetConfig(c).Tunnel.GetCompressions()), mt)
+			if err != nil {
+				return err
+			}
+			t.muxSessionLock.Lock()
+			t.muxSession = ms
+			t.muxSessionLock.Unlock()
+			defer func() {
+				t.muxSessionLock.Lock()
+				t.muxSession = nil
+				t.muxSessionLock.Unlock()
+			}()
+			select {
+			case <-c.Done():
+				return nil
+			case <-ms.Done():
+				// Retry until the context is cancelled
+				return errors.New("multiplexed session ended")
+			}
+		})
+	})
+
 	g.Go("TUN reader", func(c context.Context) error {
 		dlog.Debug(c, "Waiting until manager gRPC is configured")
 		select {
@@ -459,38 +760,56 @@ func (t *tunRouter) run(c context.Context) error {
 
 		dlog.Debug(c, "TUN read loop starting")
 
-		// bufCh is just a small buffer to enable better parallel processing between
-		// the actual TUN reader loop and the packet handlers.
-		bufCh := make(chan *buffer.Data, 100)
-		defer close(bufCh)
-
+		// A read from the TUN device blocks until a packet arrives, so the read loop runs in a
+		// goroutine of its own. The TUN device of the primary router remains open when its
+		// connection is removed, and the router must still be able to stop.
+		errCh := make(chan error, 1)
 		go func() {
-			for data := range bufCh {
-				t.handlePacket(c, data)
-			}
+			errCh <- t.readLoop(c)
 		}()
+		select {
+		case <-c.Done():
+			return nil
+		case err := <-errCh:
+			return err
+		}
+	})
+	return g.Wait()
+}
 
-		for atomic.LoadInt32(&t.closing) < 2 {
-			data := buffer.DataPool.Get(buffer.DataPool.MTU)
-			for {
-				n, err := t.dev.ReadPacket(data)
-				if err != nil {
-					buffer.DataPool.Put(data)
-					if c.Err() != nil || atomic.LoadInt32(&t.closing) == 2 {
-						return nil
-					}
-					return fmt.Errorf("read packet error: %w", err)
-				}
-				if n > 0 {
-					data.SetLength(n)
-					bufCh <- data
-					break
+// readLoop reads packets from the TUN device and dispatches them to the packet handlers until the
+// given context is cancelled or the router is closing.
+func (t *tunRouter) readLoop(c context.Context) error {
+	// bufCh is just a small buffer to enable better parallel processing between
+	// the actual TUN reader loop and the packet handlers.
+	bufCh := make(chan *buffer.Data, 100)
+	defer close(bufCh)
+
+	go func() {
+		for data := range bufCh {
+			t.handlePacket(c, data)
+		}
+	}()
+
+	for atomic.LoadInt32(&t.closing) < 2 && c.Err() == nil {
+		data := buffer.DataPool.Get(buffer.DataPool.MTU)
+		for {
+			n, err := t.dev.ReadPacket(data)
+			if err != nil {
+				buffer.DataPool.Put(data)
+				if c.Err() != nil || atomic.LoadInt32(&t.closing) == 2 {
+					return nil
 				}
+				return fmt.Errorf("read packet error: %w", err)
+			}
+			if n > 0 {
+				data.SetLength(n)
+				bufCh <- data
+				break
 			}
 		}
-		return nil
-	})
-	return g.Wait()
+	}
+	return nil
 }
 
 func (t *tunRouter) handlePacket(c context.Context, data *buffer.Data) {
@@ -604,7 +923,7 @@ func (t *tunRouter) tcp(c context.Context, pkt tcp.Packet) {
 		return
 	}
 
-	if tcpHdr.DestinationPort() == t.dnsPort && ipHdr.Destination().Equal(t.dnsIP) {
+	if tcpHdr.DestinationPort() == t.dnsPort && t.isDNSIP(ipHdr.Destination()) {
 		// Ignore TCP packets intended for the DNS resolver for now
 		// TODO: Add support to DNS over TCP. The github.com/miekg/dns can do that.
 		pkt.Release()
@@ -628,7 +947,7 @@ func (t *tunRouter) udp(c context.Context, dg udp.Datagram) {
 	connID := tunnel.NewConnID(ipproto.UDP, ipHdr.Source(), ipHdr.Destination(), udpHdr.SourcePort(), udpHdr.DestinationPort())
 	uh, _, err := t.handlers.GetOrCreate(c, connID, func(c context.Context, remove func()) (tunnel.Handler, error) {
 		w := vifWriter{t.dev}
-		if t.dnsLocalAddr != nil && udpHdr.DestinationPort() == t.dnsPort && ipHdr.Destination().Equal(t.dnsIP) {
+		if t.dnsLocalAddr != nil && udpHdr.DestinationPort() == t.dnsPort && t.isDNSIP(ipHdr.Destination()) {
 			return udp.NewDnsInterceptor(w, connID, remove, t.dnsLocalAddr)
 		}
 		stream, err := t.maybeOpenStream(c, connID)
@@ -654,12 +973,39 @@ func (t *tunRouter) maybeOpenStream(c context.Context, id tunnel.ConnID) (tunnel
 
 func (t *tunRouter) streamCreator(id tunnel.ConnID) tcp.StreamCreator {
 	return func(c context.Context) (tunnel.Stream, error) {
+		if dst := t.toReal(id.Destination()); !dst.Equal(id.Destination()) {
+			// The destination is in a synthetic subnet. The tunnel must use the real destination
+			// but the handler that owns the stream will continue to use the synthetic one when it
+			// writes to the TUN device. Remapping isn't supported by the legacy muxTunnel.
+			id = tunnel.NewConnID(id.Protocol(), id.Source(), dst, id.SourcePort(), id.DestinationPort())
+		}
 		dlog.Debugf(c, "Opening tunnel for id %s", id)
-		ct, err := t.managerClient.Tunnel(c)
-		if err != nil {
-			return nil, err
+		cfg := client.GetConfig(c)
+		tc := cfg.Timeouts
+		c = tunnel.WithStatsCounter(c, t.stats)
+		var s tunnel.Stream
+		t.muxSessionLock.Lock()
+		ms := t.muxSession
+		t.muxSessionLock.Unlock()
+		if ms != nil {
+			var err error
+			if s, err = ms.Open(c, id, t.getSession().SessionId, tc.Get(client.TimeoutRoundtripLatency), tc.Get(client.TimeoutEndpointDial)); err != nil {
+				// The session ended. Fall back to a stream of its own while the session is reestablished.
+				dlog.Debugf(c, "unable to open multiplexed stream for id %s: %v", id, err)
+				s = nil
+			}
+		}
+		if s == nil {
+			ct, err := t.managerClient.Tunnel(c)
+			if err != nil {
+				return nil, err
+			}
+			c = tunnel.WithCompressions(c, cfg.Tunnel.GetCompressions())
+			if s, err = tunnel.NewClientStream(c, ct, id, t.getSession().SessionId, tc.Get(client.TimeoutRoundtripLatency), tc.Get(client.TimeoutEndpointDial)); err != nil {
+				return nil, err
+			}
 		}
-		tc := client.GetConfig(c).Timeouts
-		return tunnel.NewClientStream(c, ct, id, t.session.SessionId, tc.Get(client.TimeoutRoundtripLatency), tc.Get(client.TimeoutEndpointDial))
+		shaping, _ := t.getShaping()
+		return tunnel.NewShapedStream(tunnel.NewFaultyStream(s, cfg.Tunnel.GetFaults()), shaping), nil
 	}
 }
@@ -20,6 +20,10 @@ import (
 // loggerForTest exposes internals to initcontext_test.go
 var loggerForTest *logrus.Logger
 
+// backupTimeFormat is the format of the timestamp in the names of rotated log files. A log file can be
+// rotated by size several times within a second, so the timestamp has sub-second resolution.
+const backupTimeFormat = "20060102T150405.000000"
+
 // InitContext sets up standard Telepresence logging for a background process
 func InitContext(ctx context.Context, name string) (context.Context, error) {
 	logger := logrus.New()
@@ -37,15 +41,20 @@ func InitContext(ctx context.Context, name string) (context.Context, error) {
 		if err != nil {
 			return ctx, err
 		}
-		maxFiles := uint16(5)
+		rotation := client.GetConfig(ctx).LogRotation
+		maxFiles := rotation.GetMaxFiles()
 
-		// TODO: Also make this a configurable setting in config.yml
+		// The environment variable takes precedence over the config.yml setting
 		if me := os.Getenv("TELEPRESENCE_MAX_LOGFILES"); me != "" {
 			if mx, err := strconv.Atoi(me); err == nil && mx >= 0 {
 				maxFiles = uint16(mx)
 			}
 		}
-		rf, err := OpenRotatingFile(filepath.Join(dir, name+".log"), "20060102T150405", true, true, 0600, NewRotateOnce(), maxFiles)
+		strategy := NewRotateOnce()
+		if maxSize, ok := rotation.MaxSize.AsInt64(); ok && maxSize > 0 {
+			strategy = NewRotateAnyOf(strategy, NewRotateBySize(maxSize))
+		}
+		rf, err := OpenRotatingFile(filepath.Join(dir, name+".log"), backupTimeFormat, true, true, 0600, strategy, maxFiles, rotation.Compress)
 		if err != nil {
 			return ctx, err
 		}
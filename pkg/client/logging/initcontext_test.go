@@ -150,7 +150,7 @@ func TestInitContext(t *testing.T) {
 		defer closeLog(t)
 
 		check.FileExists(logFile)
-		backupFile := filepath.Join(logDir, fmt.Sprintf("%s-%s.log", logName, dtime.Now().Format("20060102T150405")))
+		backupFile := filepath.Join(logDir, fmt.Sprintf("%s-%s.log", logName, dtime.Now().Format(backupTimeFormat)))
 
 		ft.Step(time.Second)
 		infoTs := dtime.Now().Format("2006-01-02 15:04:05.0000")
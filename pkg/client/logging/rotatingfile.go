@@ -1,7 +1,9 @@
 package logging
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -60,6 +62,47 @@ func (rotateDaily) RotateNow(rf *RotatingFile, _ int) bool {
 	return dtime.Now().In(bt.Location()).Day() != rf.BirthTime().Day()
 }
 
+// A rotateBySize strategy ensures that the file is rotated when a write would cause it to exceed a
+// given size. The file is never rotated when it's empty, so a single write that is larger than the
+// max size will still end up in one file.
+type rotateBySize int64
+
+// NewRotateBySize returns a strategy that rotates the file when a call to Write() would make it
+// grow beyond maxSize bytes.
+func NewRotateBySize(maxSize int64) RotationStrategy {
+	return rotateBySize(maxSize)
+}
+
+func (r rotateBySize) RotateNow(rf *RotatingFile, writeSize int) bool {
+	sz := rf.Size()
+	return sz > 0 && sz+int64(writeSize) > int64(r)
+}
+
+// A rotateAnyOf strategy answers true to the RotateNow question when at least one of its
+// strategies does so.
+type rotateAnyOf []RotationStrategy
+
+// NewRotateAnyOf returns a strategy that will rotate the file when any of the given strategies
+// wants it rotated. All strategies are consulted on each call since some of them, like the one
+// returned by NewRotateOnce, are stateful.
+func NewRotateAnyOf(strategies ...RotationStrategy) RotationStrategy {
+	return rotateAnyOf(strategies)
+}
+
+func (r rotateAnyOf) RotateNow(rf *RotatingFile, writeSize int) bool {
+	rotate := false
+	for _, s := range r {
+		if s.RotateNow(rf, writeSize) {
+			rotate = true
+		}
+	}
+	return rotate
+}
+
+// compressedExt is the extension that is appended to the name of rotated files when they are
+// compressed.
+const compressedExt = ".gz"
+
 type RotatingFile struct {
 	fileMode    fs.FileMode
 	dirName     string
@@ -67,11 +110,15 @@ type RotatingFile struct {
 	timeFormat  string
 	localTime   bool
 	captureStd  bool
+	compress    bool
 	maxFiles    uint16
 	strategy    RotationStrategy
 	mutex       sync.Mutex
 	removeMutex sync.Mutex
 
+	// cleanups tracks the goroutines that compress and remove rotated files.
+	cleanups sync.WaitGroup
+
 	// file is the current file. It is never nil
 	file *os.File
 
@@ -106,6 +153,8 @@ type RotatingFile struct {
 //
 // - maxFiles: maximum number of files in rotation, including the currently active logfile. A value of zero means
 // unlimited
+//
+// - compress: if true, rotated files are gzip compressed and given an additional ".gz" extension
 func OpenRotatingFile(
 	logfilePath string,
 	timeFormat string,
@@ -114,6 +163,7 @@ func OpenRotatingFile(
 	fileMode fs.FileMode,
 	strategy RotationStrategy,
 	maxFiles uint16,
+	compress bool,
 ) (*RotatingFile, error) {
 	logfileDir, logfileBase := filepath.Split(logfilePath)
 
@@ -131,13 +181,14 @@ func OpenRotatingFile(
 		captureStd: captureStd,
 		timeFormat: timeFormat,
 		maxFiles:   maxFiles,
+		compress:   compress,
 	}
 
 	// Try to open existing file for append.
 	if rf.file, err = openForAppend(logfilePath, rf.fileMode); err != nil {
 		if os.IsNotExist(err) {
 			// There is no existing file, go ahead and create a new one.
-			if err := rf.openNew(nil); err == nil {
+			if err := rf.openNew(nil, ""); err == nil {
 				return rf, nil
 			}
 		}
@@ -150,7 +201,7 @@ func OpenRotatingFile(
 	}
 	rf.birthTime = stat.BirthTime()
 	rf.size = stat.Size()
-	rf.afterOpen()
+	rf.afterOpen("")
 	return rf, nil
 }
 
@@ -163,8 +214,9 @@ func (rf *RotatingFile) BirthTime() time.Time {
 	return bt
 }
 
-// Close implements io.Closer
+// Close implements io.Closer. It waits for the compression and removal of rotated files to finish.
 func (rf *RotatingFile) Close() error {
+	rf.cleanups.Wait()
 	return rf.file.Close()
 }
 
@@ -203,7 +255,9 @@ func (rf *RotatingFile) Write(data []byte) (int, error) {
 	return l, nil
 }
 
-func (rf *RotatingFile) afterOpen() {
+// afterOpen is called when a new file has been opened. The rotatedFile is the name of the
+// file that was rotated in order to open it, or empty if no rotation took place.
+func (rf *RotatingFile) afterOpen(rotatedFile string) {
 	if rf.captureStd {
 		if err := dupToStd(rf.file); err != nil {
 			// Dup2 failed
@@ -218,7 +272,61 @@ func (rf *RotatingFile) afterOpen() {
 			}
 		}
 	}
-	go rf.removeOldFiles()
+	rf.cleanups.Add(1)
+	go func() {
+		defer rf.cleanups.Done()
+		if rf.compress && rotatedFile != "" {
+			rf.compressFile(rotatedFile)
+		}
+		rf.removeOldFiles()
+	}()
+}
+
+// compressFile gzips the given file into a file with the same name and an additional ".gz"
+// extension and then removes the original. The original is retained if the compression fails.
+func (rf *RotatingFile) compressFile(fileName string) {
+	// Hold the removeMutex so that removeOldFiles doesn't see the file pair in a transient state.
+	rf.removeMutex.Lock()
+	defer rf.removeMutex.Unlock()
+
+	if err := gzipFile(fileName, rf.fileMode); err == nil {
+		_ = os.Remove(fileName)
+	}
+}
+
+func gzipFile(fileName string, fileMode fs.FileMode) (err error) {
+	src, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Write to a temporary file first so that a partially written archive is never mistaken
+	// for a valid backup.
+	gzName := fileName + compressedExt
+	tmpName := gzName + ".tmp"
+	dst, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileMode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(fileName)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpName, gzName)
 }
 
 func (rf *RotatingFile) fileTime(t time.Time) time.Time {
@@ -230,7 +338,7 @@ func (rf *RotatingFile) fileTime(t time.Time) time.Time {
 	return t
 }
 
-func (rf *RotatingFile) openNew(prevInfo SysInfo) (err error) {
+func (rf *RotatingFile) openNew(prevInfo SysInfo, rotatedFile string) (err error) {
 	fullPath := filepath.Join(rf.dirName, rf.fileName)
 	var newFile *os.File
 	if rf.file == nil {
@@ -278,7 +386,7 @@ func (rf *RotatingFile) openNew(prevInfo SysInfo) (err error) {
 
 	rf.birthTime = rf.fileTime(dtime.Now())
 	rf.size = 0
-	rf.afterOpen()
+	rf.afterOpen(rotatedFile)
 	return nil
 }
 
@@ -288,6 +396,10 @@ func (rf *RotatingFile) openNew(prevInfo SysInfo) (err error) {
 //
 // This function should typically run in it's own goroutine
 func (rf *RotatingFile) removeOldFiles() {
+	if rf.maxFiles == 0 {
+		// Unlimited
+		return
+	}
 	rf.removeMutex.Lock()
 	defer rf.removeMutex.Unlock()
 
@@ -307,13 +419,23 @@ func (rf *RotatingFile) removeOldFiles() {
 	for _, file := range files {
 		fn := file.Name()
 
-		// Skip files that doesn't start with the prefix and end with the suffix.
-		if !(strings.HasPrefix(fn, pfx) && strings.HasSuffix(fn, ext)) {
+		// Skip files that doesn't start with the prefix and end with the suffix, or the suffix
+		// followed by the extension used for compressed files.
+		if !strings.HasPrefix(fn, pfx) {
+			continue
+		}
+		var sfx string
+		switch {
+		case strings.HasSuffix(fn, ext):
+			sfx = ext
+		case strings.HasSuffix(fn, ext+compressedExt):
+			sfx = ext + compressedExt
+		default:
 			continue
 		}
 		// Parse the timestamp from the file name
 		var ts time.Time
-		if ts, err = time.Parse(rf.timeFormat, fn[len(pfx):len(fn)-len(ext)]); err != nil {
+		if ts, err = time.Parse(rf.timeFormat, fn[len(pfx):len(fn)-len(sfx)]); err != nil {
 			continue
 		}
 		key := ts.UnixNano()
@@ -332,6 +454,7 @@ func (rf *RotatingFile) removeOldFiles() {
 
 func (rf *RotatingFile) rotate() error {
 	var prevInfo SysInfo
+	var fname string
 	if rf.maxFiles == 0 || rf.maxFiles > 1 {
 		var err error
 		prevInfo, err = FStat(rf.file)
@@ -343,10 +466,10 @@ func (rf *RotatingFile) rotate() error {
 		ex := filepath.Ext(rf.fileName)
 		sf := fullPath[:len(fullPath)-len(ex)]
 		ts := rf.fileTime(dtime.Now()).Format(rf.timeFormat)
-		fname := fmt.Sprintf("%s-%s%s", sf, ts, ex)
+		fname = fmt.Sprintf("%s-%s%s", sf, ts, ex)
 		if err = os.Rename(fullPath, fname); err != nil {
 			return fmt.Errorf("failed to rename %s to %s: %w", fullPath, fname, err)
 		}
 	}
-	return rf.openNew(prevInfo)
+	return rf.openNew(prevInfo, fname)
 }
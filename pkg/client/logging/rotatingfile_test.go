@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dtime"
+)
+
+func TestRotatingFile_rotateBySize(t *testing.T) {
+	ft := dtime.NewFakeTime()
+	dtime.SetNow(ft.Now)
+	t.Cleanup(func() { dtime.SetNow(time.Now) })
+
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "size.log")
+	rf, err := OpenRotatingFile(logFile, "20060102T150405", false, false, 0600, NewRotateBySize(100), 3, false)
+	require.NoError(t, err)
+	defer rf.Close()
+
+	line := strings.Repeat("x", 39) + "\n"
+	for i := 0; i < 5; i++ {
+		ft.Step(time.Second)
+		_, err = rf.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	// 2 lines fit in each file, so the writes resulted in two rotations
+	require.Equal(t, int64(len(line)), rf.Size())
+	require.Eventually(t, func() bool {
+		files, err := os.ReadDir(logDir)
+		return err == nil && len(files) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A write that is bigger than the max size will not cause rotation of an empty file
+	require.NoError(t, rf.Rotate())
+	big := strings.Repeat("y", 200)
+	_, err = rf.Write([]byte(big))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(big)), rf.Size())
+}
+
+func TestRotatingFile_rotateWithinSecond(t *testing.T) {
+	ft := dtime.NewFakeTime()
+	dtime.SetNow(ft.Now)
+	t.Cleanup(func() { dtime.SetNow(time.Now) })
+
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "fast.log")
+	rf, err := OpenRotatingFile(logFile, backupTimeFormat, false, false, 0600, RotateNever, 0, false)
+	require.NoError(t, err)
+
+	// Each rotation must create a new backup rather than replace the previous one
+	for i := 0; i < 3; i++ {
+		ft.Step(time.Millisecond)
+		_, err = fmt.Fprintf(rf, "message %d\n", i)
+		require.NoError(t, err)
+		require.NoError(t, rf.Rotate())
+	}
+
+	// Closing waits for the removal of old files, which must retain all backups when maxFiles is zero
+	require.NoError(t, rf.Close())
+	files, err := os.ReadDir(logDir)
+	require.NoError(t, err)
+	require.Len(t, files, 4)
+	for i, f := range files[:3] {
+		data, err := os.ReadFile(filepath.Join(logDir, f.Name()))
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("message %d\n", i), string(data))
+	}
+}
+
+func TestRotatingFile_compress(t *testing.T) {
+	ft := dtime.NewFakeTime()
+	dtime.SetNow(ft.Now)
+	t.Cleanup(func() { dtime.SetNow(time.Now) })
+
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "compress.log")
+	const maxFiles = 3
+	rf, err := OpenRotatingFile(logFile, "20060102T150405", false, false, 0600, RotateNever, maxFiles, true)
+	require.NoError(t, err)
+	defer rf.Close()
+
+	var firstBackup string
+	for i := 0; i < maxFiles+2; i++ {
+		ft.Step(time.Second)
+		_, err = fmt.Fprintf(rf, "message %d\n", i)
+		require.NoError(t, err)
+		if i == 0 {
+			firstBackup = filepath.Join(logDir, fmt.Sprintf("compress-%s.log", dtime.Now().UTC().Format("20060102T150405")))
+		}
+		require.NoError(t, rf.Rotate())
+	}
+
+	// Wait until all rotated files are compressed and the excess files have been removed
+	require.Eventually(t, func() bool {
+		files, err := os.ReadDir(logDir)
+		if err != nil || len(files) != maxFiles {
+			return false
+		}
+		for _, f := range files {
+			if f.Name() != "compress.log" && !strings.HasSuffix(f.Name(), ".log.gz") {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The oldest backups were removed
+	require.NoFileExists(t, firstBackup)
+	require.NoFileExists(t, firstBackup+".gz")
+
+	// The newest backup contains the last message
+	files, err := os.ReadDir(logDir)
+	require.NoError(t, err)
+	var newest string
+	for _, f := range files {
+		if f.Name() > newest && f.Name() != "compress.log" {
+			newest = f.Name()
+		}
+	}
+	fh, err := os.Open(filepath.Join(logDir, newest))
+	require.NoError(t, err)
+	defer fh.Close()
+	zr, err := gzip.NewReader(fh)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("message %d\n", maxFiles+1), string(data))
+}
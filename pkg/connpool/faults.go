@@ -0,0 +1,77 @@
+package connpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+type faultyStream struct {
+	BidiStream
+	ctx   context.Context
+	fi    *tunnel.FaultInjector
+	close sync.Once
+}
+
+// NewFaultyMuxTunnel returns a MuxTunnel that injects the given faults into the messages of the given stream.
+// Messages that are sent are delayed, dropped, or reordered, and the stream is reset by closing it and by failing
+// all further calls with tunnel.ErrReset. The given context is used when delaying messages.
+func NewFaultyMuxTunnel(ctx context.Context, stream BidiStream, faults *tunnel.Faults) MuxTunnel {
+	if !faults.Enabled() {
+		return NewMuxTunnel(stream)
+	}
+	return NewMuxTunnel(&faultyStream{BidiStream: stream, ctx: ctx, fi: tunnel.NewFaultInjector(faults)})
+}
+
+func (s *faultyStream) Send(cm *rpc.ConnMessage) error {
+	// The message is sent later, and callers reuse the buffer of the payload.
+	cm = &rpc.ConnMessage{ConnId: cm.ConnId, Payload: append([]byte(nil), cm.Payload...)}
+	err := s.fi.Send(s.ctx, len(cm.Payload), !isControl(cm), func() error { return s.BidiStream.Send(cm) })
+	return s.closeOnReset(err)
+}
+
+func (s *faultyStream) Recv() (*rpc.ConnMessage, error) {
+	if s.fi.IsReset() {
+		return nil, tunnel.ErrReset
+	}
+	cm, err := s.BidiStream.Recv()
+	if err == nil {
+		if err = s.closeOnReset(s.fi.Received()); err != nil {
+			cm = nil
+		}
+	}
+	return cm, err
+}
+
+func (s *faultyStream) CloseSend() error {
+	if s.fi.IsReset() {
+		return nil
+	}
+	if err := s.fi.Flush(); err != nil {
+		return err
+	}
+	if sender, ok := s.BidiStream.(interface{ CloseSend() error }); ok {
+		return sender.CloseSend()
+	}
+	return errors.New("tunnel does not implement CloseSend()")
+}
+
+// closeOnReset closes the sending side of the underlying stream when err is tunnel.ErrReset, so that its peer
+// sees the stream end. Streams that can't be closed, e.g. the server side of a gRPC stream, just stop sending.
+func (s *faultyStream) closeOnReset(err error) error {
+	if errors.Is(err, tunnel.ErrReset) {
+		s.close.Do(func() {
+			if sender, ok := s.BidiStream.(interface{ CloseSend() error }); ok {
+				s.fi.SendReset(s.ctx, sender.CloseSend)
+			}
+		})
+	}
+	return err
+}
+
+func isControl(cm *rpc.ConnMessage) bool {
+	return len(cm.GetConnId()) == 2
+}
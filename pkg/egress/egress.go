@@ -0,0 +1,118 @@
+// Package egress contains functions for the egress rules that route the outbound connections of a
+// client through the traffic-agent of a chosen workload.
+package egress
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+// Parse parses a rule on the form [<subnet>=]<workload>[.<namespace>], where subnet is in CIDR
+// notation. The given namespace is used when the rule doesn't have one.
+func Parse(spec, namespace string) (*manager.EgressRule, error) {
+	rule := &manager.EgressRule{Namespace: namespace}
+	workload := spec
+	if eq := strings.IndexByte(spec, '='); eq >= 0 {
+		_, ipNet, err := net.ParseCIDR(spec[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("invalid egress rule %q: %w", spec, err)
+		}
+		rule.Subnet = ipNet.String()
+		workload = spec[eq+1:]
+	}
+	if dot := strings.LastIndexByte(workload, '.'); dot >= 0 {
+		rule.Namespace = workload[dot+1:]
+		workload = workload[:dot]
+	}
+	rule.Agent = workload
+	if err := Validate(rule); err != nil {
+		return nil, fmt.Errorf("invalid egress rule %q: %w", spec, err)
+	}
+	return rule, nil
+}
+
+// Validate returns an error if the given rule lacks a workload or has an invalid subnet.
+func Validate(rule *manager.EgressRule) error {
+	switch {
+	case rule.Agent == "":
+		return fmt.Errorf("workload must not be empty")
+	case rule.Namespace == "":
+		return fmt.Errorf("namespace must not be empty")
+	case rule.Subnet != "":
+		if _, _, err := net.ParseCIDR(rule.Subnet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the given rule on the form that Parse accepts.
+func String(rule *manager.EgressRule) string {
+	if rule.Subnet == "" {
+		return rule.Agent + "." + rule.Namespace
+	}
+	return rule.Subnet + "=" + rule.Agent + "." + rule.Namespace
+}
+
+// RuleFor returns the rule that applies to the given destination, or nil if no rule applies. Of the
+// rules whose subnet contains the destination, the one with the narrowest subnet applies. A rule
+// without subnet applies when no other rule does.
+func RuleFor(rules []*manager.EgressRule, dest net.IP) *manager.EgressRule {
+	var found *manager.EgressRule
+	foundOnes := -1
+	for _, rule := range rules {
+		if rule.Subnet == "" {
+			if found == nil {
+				found = rule
+			}
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(rule.Subnet)
+		if err != nil || !ipNet.Contains(dest) {
+			continue
+		}
+		if ones, _ := ipNet.Mask.Size(); ones > foundOnes {
+			found = rule
+			foundOnes = ones
+		}
+	}
+	return found
+}
+
+// AgentFor returns the agent of the given agents that connections that the given rule applies to
+// originate from, or nil if none of them belongs to the rule's workload. When several pods of the
+// workload have an agent, the one with the lowest pod IP is returned, so that the connections
+// consistently originate from the same pod.
+func AgentFor(rule *manager.EgressRule, agents []*manager.AgentInfo) *manager.AgentInfo {
+	var found *manager.AgentInfo
+	var foundIP net.IP
+	for _, agent := range agents {
+		if agent.Name != rule.Agent || agent.Namespace != rule.Namespace {
+			continue
+		}
+		ip := iputil.Parse(agent.PodIp)
+		if found == nil || compareIPs(ip, foundIP) < 0 {
+			found = agent
+			foundIP = ip
+		}
+	}
+	return found
+}
+
+// compareIPs compares the 16 byte forms of the given IPs. An IP that can't be parsed sorts last.
+func compareIPs(a, b net.IP) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return bytes.Compare(a.To16(), b.To16())
+}
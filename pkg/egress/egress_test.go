@@ -0,0 +1,58 @@
+package egress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+func TestParse(t *testing.T) {
+	rule, err := Parse("db-proxy", "default")
+	require.NoError(t, err)
+	assert.Equal(t, "", rule.Subnet)
+	assert.Equal(t, "db-proxy", rule.Agent)
+	assert.Equal(t, "default", rule.Namespace)
+	assert.Equal(t, "db-proxy.default", String(rule))
+
+	rule, err = Parse("10.5.1.7/16=db-proxy.data", "default")
+	require.NoError(t, err)
+	assert.Equal(t, "10.5.0.0/16", rule.Subnet)
+	assert.Equal(t, "db-proxy", rule.Agent)
+	assert.Equal(t, "data", rule.Namespace)
+	assert.Equal(t, "10.5.0.0/16=db-proxy.data", String(rule))
+
+	for _, spec := range []string{"", "10.5.0.0=db-proxy", "10.5.0.0/16=", "db-proxy.", ".data"} {
+		_, err = Parse(spec, "default")
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestRuleFor(t *testing.T) {
+	all := &manager.EgressRule{Agent: "all", Namespace: "default"}
+	wide := &manager.EgressRule{Subnet: "10.0.0.0/8", Agent: "wide", Namespace: "default"}
+	narrow := &manager.EgressRule{Subnet: "10.5.0.0/16", Agent: "narrow", Namespace: "default"}
+
+	rules := []*manager.EgressRule{wide, all, narrow}
+	assert.Equal(t, narrow, RuleFor(rules, iputil.Parse("10.5.3.4")))
+	assert.Equal(t, wide, RuleFor(rules, iputil.Parse("10.6.3.4")))
+	assert.Equal(t, all, RuleFor(rules, iputil.Parse("192.168.1.1")))
+	assert.Nil(t, RuleFor(rules[:1], iputil.Parse("192.168.1.1")))
+	assert.Nil(t, RuleFor(nil, iputil.Parse("10.5.3.4")))
+}
+
+func TestAgentFor(t *testing.T) {
+	rule := &manager.EgressRule{Agent: "db-proxy", Namespace: "default"}
+	agents := []*manager.AgentInfo{
+		{Name: "db-proxy", Namespace: "default", PodIp: "10.1.0.12"},
+		{Name: "db-proxy", Namespace: "other", PodIp: "10.1.0.2"},
+		{Name: "db-proxy", Namespace: "default", PodIp: "10.1.0.9"},
+		{Name: "echo", Namespace: "default", PodIp: "10.1.0.1"},
+	}
+	assert.Equal(t, agents[2], AgentFor(rule, agents))
+	assert.Nil(t, AgentFor(rule, agents[1:2]))
+	assert.Nil(t, AgentFor(rule, nil))
+}
@@ -26,6 +26,7 @@ type Forwarder struct {
 
 	tCtx       context.Context
 	tCancel    context.CancelFunc
+	tConns     *sync.WaitGroup // connections that use tCtx
 	targetHost string
 	targetPort int32
 
@@ -105,6 +106,7 @@ func (f *Forwarder) Listen(ctx context.Context) (*net.TCPListener, error) {
 
 	// Set up target lifetime
 	f.tCtx, f.tCancel = context.WithCancel(f.lCtx)
+	f.tConns = &sync.WaitGroup{}
 	listenAddr := f.listenAddr
 
 	f.mu.Unlock()
@@ -153,6 +155,12 @@ func (f *Forwarder) SetIntercepting(intercept *manager.InterceptInfo) {
 		if f.intercept == nil {
 			dlog.Debugf(f.lCtx, "Forward target changed from %s:%d to intercept %s", f.targetHost, f.targetPort, iceptInfo(intercept))
 		} else {
+			if f.intercept.Spec.Name == intercept.Spec.Name &&
+				f.intercept.ClientSession.GetSessionId() != intercept.ClientSession.GetSessionId() {
+				// The intercept was transferred to another client, and re-keyed under its session
+				f.handOver(intercept)
+				return
+			}
 			if f.intercept.Id == intercept.Id {
 				return
 			}
@@ -169,6 +177,7 @@ func (f *Forwarder) SetIntercepting(intercept *manager.InterceptInfo) {
 
 	// Set up new target and lifetime
 	f.tCtx, f.tCancel = context.WithCancel(f.lCtx)
+	f.tConns = &sync.WaitGroup{}
 	if intercept != nil {
 		if f.manager != nil {
 			muxTunnel, err := f.startManagerTunnel(f.tCtx, intercept.ClientSession)
@@ -182,6 +191,39 @@ func (f *Forwarder) SetIntercepting(intercept *manager.InterceptInfo) {
 	f.intercept = intercept
 }
 
+// handOver switches the forwarder to the client that the current intercept was transferred to. New
+// connections are sent to the new client right away. Connections that are already established keep
+// using the previous client until they end, or until the forwarder is closed.
+//
+// Must be called with f.mu locked.
+func (f *Forwarder) handOver(intercept *manager.InterceptInfo) {
+	tCtx, tCancel := context.WithCancel(f.lCtx)
+	var muxTunnel connpool.MuxTunnel
+	if f.manager != nil {
+		var err error
+		if muxTunnel, err = f.startManagerTunnel(tCtx, intercept.ClientSession); err != nil {
+			// Keep using the previous client. The next intercept snapshot will trigger a retry.
+			tCancel()
+			dlog.Error(tCtx, err)
+			return
+		}
+	}
+	dlog.Debugf(f.lCtx, "Intercept '%s' handed over from client session %s to %s",
+		intercept.Spec.Name, f.intercept.ClientSession.GetSessionId(), intercept.ClientSession.GetSessionId())
+
+	oldTunnel, oldCancel, oldConns := f.muxTunnel, f.tCancel, f.tConns
+	go func() {
+		oldConns.Wait()
+		if oldTunnel != nil {
+			_ = oldTunnel.CloseSend()
+		}
+		oldCancel()
+	}()
+	f.tCtx, f.tCancel, f.tConns = tCtx, tCancel, &sync.WaitGroup{}
+	f.muxTunnel = muxTunnel
+	f.intercept = intercept
+}
+
 func (f *Forwarder) forwardConn(clientConn *net.TCPConn) error {
 	f.mu.Lock()
 	ctx := f.tCtx
@@ -189,10 +231,13 @@ func (f *Forwarder) forwardConn(clientConn *net.TCPConn) error {
 	targetPort := f.targetPort
 	intercept := f.intercept
 	muxTunnel := f.muxTunnel
+	conns := f.tConns
+	conns.Add(1)
 	f.mu.Unlock()
 	if intercept != nil {
-		return f.interceptConn(ctx, clientConn, intercept, muxTunnel)
+		return f.interceptConn(ctx, clientConn, intercept, muxTunnel, conns.Done)
 	}
+	defer conns.Done()
 
 	targetAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", targetHost, targetPort))
 	if err != nil {
@@ -248,7 +293,7 @@ func (f *Forwarder) startManagerTunnel(ctx context.Context, clientSession *manag
 		err = fmt.Errorf("call to AgentTunnel() failed: %v", err)
 		return nil, err
 	}
-	muxTunnel := connpool.NewMuxTunnel(agentTunnel)
+	muxTunnel := connpool.NewFaultyMuxTunnel(ctx, agentTunnel, tunnel.GetFaults(ctx))
 	defer func() {
 		if err != nil {
 			_ = muxTunnel.CloseSend()
@@ -323,11 +368,20 @@ func (f *Forwarder) startManagerTunnel(ctx context.Context, clientSession *manag
 	return muxTunnel, nil
 }
 
-func (f *Forwarder) interceptConn(ctx context.Context, conn net.Conn, iCept *manager.InterceptInfo, muxTunnel connpool.MuxTunnel) error {
+// interceptConn sends the given connection to the client of the given intercept. The done function is
+// called when the connection ends.
+func (f *Forwarder) interceptConn(
+	ctx context.Context,
+	conn net.Conn,
+	iCept *manager.InterceptInfo,
+	muxTunnel connpool.MuxTunnel,
+	done func(),
+) error {
 	dlog.Infof(ctx, "Accept got connection from %s", conn.RemoteAddr())
 
 	srcIp, srcPort, err := iputil.SplitToIPPort(conn.RemoteAddr())
 	if err != nil {
+		done()
 		return fmt.Errorf("failed to parse intercept source address %s", conn.RemoteAddr())
 	}
 
@@ -337,8 +391,12 @@ func (f *Forwarder) interceptConn(ctx context.Context, conn net.Conn, iCept *man
 
 	if muxTunnel != nil {
 		_, found, err := tunnel.GetPool(ctx).GetOrCreate(ctx, id, func(ctx context.Context, release func()) (tunnel.Handler, error) {
-			return connpool.HandlerFromConn(id, muxTunnel, release, conn), nil
+			return connpool.HandlerFromConn(id, muxTunnel, func() { release(); done() }, conn), nil
 		})
+		if err != nil || found {
+			// The handler that would call done() when it's released wasn't started
+			done()
+		}
 		if err != nil {
 			return fmt.Errorf("failed to create intercept tunnel connection for %s: %v", id, err)
 		}
@@ -348,6 +406,7 @@ func (f *Forwarder) interceptConn(ctx context.Context, conn net.Conn, iCept *man
 		}
 		return nil
 	}
+	defer done()
 
 	ms, err := f.manager.Tunnel(ctx)
 	if err != nil {
@@ -358,6 +417,7 @@ func (f *Forwarder) interceptConn(ctx context.Context, conn net.Conn, iCept *man
 	if err != nil {
 		return err
 	}
+	s = tunnel.NewFaultyStream(s, tunnel.GetFaults(ctx))
 	if err = s.Send(ctx, tunnel.SessionMessage(iCept.ClientSession.SessionId)); err != nil {
 		return fmt.Errorf("unable to send client session id. Id %s: %v", id, err)
 	}
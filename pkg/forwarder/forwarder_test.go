@@ -0,0 +1,187 @@
+package forwarder_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/connpool"
+	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+// fakeManager is a manager client that serves the tunnels of the forwarder in-process. Each intercepted
+// connection is served by a fake client that answers each line it reads with the session ID of the
+// client that the connection was sent to.
+type fakeManager struct {
+	manager.ManagerClient
+}
+
+// AgentTunnel returns a tunnel that tells the forwarder that the manager doesn't use the multiplexing
+// tunnel for intercepted connections.
+func (m *fakeManager) AgentTunnel(context.Context, ...grpc.CallOption) (manager.Manager_AgentTunnelClient, error) {
+	return &versionTunnel{}, nil
+}
+
+func (m *fakeManager) Tunnel(ctx context.Context, _ ...grpc.CallOption) (manager.Manager_TunnelClient, error) {
+	cToS := make(chan *manager.TunnelMessage, 50)
+	sToC := make(chan *manager.TunnelMessage, 50)
+	go serveClient(ctx, &tunnelEnd{ctx: ctx, recv: cToS, send: sToC})
+	return &tunnelEnd{ctx: ctx, recv: sToC, send: cToS}, nil
+}
+
+func serveClient(ctx context.Context, grpcStream tunnel.GRPCStream) {
+	s, err := tunnel.NewServerStream(ctx, grpcStream)
+	if err != nil {
+		dlog.Error(ctx, err)
+		return
+	}
+	m, err := s.Receive(ctx)
+	if err != nil {
+		dlog.Error(ctx, err)
+		return
+	}
+	sessionID := tunnel.GetSession(m)
+	conn, peer := net.Pipe()
+	tunnel.NewConnEndpoint(s, peer).Start(ctx)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		if _, err := r.ReadString('\n'); err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, sessionID+"\n"); err != nil {
+			return
+		}
+	}
+}
+
+type tunnelEnd struct {
+	grpc.ClientStream
+	ctx  context.Context
+	recv <-chan *manager.TunnelMessage
+	send chan<- *manager.TunnelMessage
+}
+
+func (t *tunnelEnd) Recv() (*manager.TunnelMessage, error) {
+	select {
+	case <-t.ctx.Done():
+		return nil, t.ctx.Err()
+	case m, ok := <-t.recv:
+		if !ok {
+			return nil, io.EOF
+		}
+		return m, nil
+	}
+}
+
+func (t *tunnelEnd) Send(m *manager.TunnelMessage) error {
+	select {
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	case t.send <- m:
+		return nil
+	}
+}
+
+func (t *tunnelEnd) CloseSend() error {
+	close(t.send)
+	return nil
+}
+
+type versionTunnel struct {
+	grpc.ClientStream
+}
+
+func (t *versionTunnel) Recv() (*manager.ConnMessage, error) {
+	return connpool.VersionControl().TunnelMessage(), nil
+}
+
+func (t *versionTunnel) Send(*manager.ConnMessage) error {
+	return nil
+}
+
+func (t *versionTunnel) CloseSend() error {
+	return nil
+}
+
+func interceptInfo(sessionID string) *manager.InterceptInfo {
+	return &manager.InterceptInfo{
+		Spec: &manager.InterceptSpec{
+			Name:       "echo",
+			Client:     sessionID + "@host",
+			Agent:      "echo",
+			Namespace:  "default",
+			TargetHost: "127.0.0.1",
+			TargetPort: 8080,
+		},
+		Id:            sessionID + ":echo",
+		ClientSession: &manager.SessionInfo{SessionId: sessionID},
+		Disposition:   manager.InterceptDispositionType_ACTIVE,
+	}
+}
+
+func TestForwarder_HandOver(t *testing.T) {
+	ctx, cancel := context.WithCancel(dlog.NewTestContext(t, false))
+	defer cancel()
+
+	f := forwarder.NewForwarder(&net.TCPAddr{IP: net.IP{127, 0, 0, 1}}, "127.0.0.1", 8080)
+	listener, err := f.Listen(ctx)
+	require.NoError(t, err)
+	go func() {
+		_ = f.ServeListener(ctx, listener)
+	}()
+	f.SetManager(&manager.SessionInfo{SessionId: "agent"}, &fakeManager{}, semver.MustParse("2.4.9"))
+
+	roundTrip := func(conn net.Conn, r *bufio.Reader) string {
+		t.Helper()
+		require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+		_, err := io.WriteString(conn, "hello\n")
+		require.NoError(t, err)
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		return strings.TrimSpace(line)
+	}
+	dial := func() (net.Conn, *bufio.Reader) {
+		t.Helper()
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		return conn, bufio.NewReader(conn)
+	}
+
+	f.SetIntercepting(interceptInfo("alice"))
+	aliceConn, aliceReader := dial()
+	defer aliceConn.Close()
+	assert.Equal(t, "alice", roundTrip(aliceConn, aliceReader))
+
+	// The intercept is transferred to bob and re-keyed under bob's session. New connections go to bob
+	// while the established connection keeps going to alice until it's closed.
+	f.SetIntercepting(interceptInfo("bob"))
+	bobConn, bobReader := dial()
+	defer bobConn.Close()
+	assert.Equal(t, "bob", roundTrip(bobConn, bobReader))
+	assert.Equal(t, "alice", roundTrip(aliceConn, aliceReader))
+	assert.True(t, f.Intercepting())
+
+	// A different intercept drops the connections of the current client. Connections that were
+	// handed over keep draining.
+	other := interceptInfo("carol")
+	other.Spec.Name = "other"
+	other.Id = "carol:other"
+	f.SetIntercepting(other)
+	require.NoError(t, bobConn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err = bobReader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "alice", roundTrip(aliceConn, aliceReader))
+}
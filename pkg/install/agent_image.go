@@ -0,0 +1,22 @@
+package install
+
+import (
+	"path"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// AgentImageAllowed returns true if the given image matches one of the allowed agent images of the
+// client policy, or if the policy allows all images. The traffic-manager uses it to validate its
+// policy, and the clients use it to enforce the policy.
+func AgentImageAllowed(policy *manager.ClientPolicy, image string) bool {
+	if len(policy.GetAllowedAgentImages()) == 0 {
+		return true
+	}
+	for _, pattern := range policy.AllowedAgentImages {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -11,7 +11,11 @@ const (
 	ManualInjectAnnotation    = DomainPrefix + "manually-injected"
 	ManagerAppName            = "traffic-manager"
 	ManagerPortHTTP           = 8081
+	ManagerPortDirect         = 8082
+	ManagerDirectServiceName  = ManagerAppName + "-direct"
+	ManagerDirectCAName       = ManagerAppName + "-ca"
 	MutatorWebhookPortHTTPS   = 8443
 	MutatorWebhookTLSName     = "mutator-webhook-tls"
 	TelAppMountPoint          = "/tel_app_mounts"
+	AgentTokenMetadataKey     = "x-telepresence-agent-token"
 )
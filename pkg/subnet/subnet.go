@@ -173,3 +173,105 @@ func Covers(a, b *net.IPNet) bool {
 	}
 	return a.Contains(m)
 }
+
+// Overlaps answers the question if network range a and network range b have at least one
+// IP in common.
+func Overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// Overlap describes how a routed subnet overlaps another routed subnet.
+type Overlap int
+
+const (
+	// Disjoint subnets have no IP in common.
+	Disjoint Overlap = iota
+
+	// Masks means that the subnet is at least as specific as the other subnet, so the IPs that they
+	// have in common are routed using the subnet.
+	Masks
+
+	// MaskedBy means that the other subnet is more specific, so the IPs that they have in common are
+	// routed using the other subnet.
+	MaskedBy
+)
+
+// OverlapOf tells how the routed subnet a overlaps the routed subnet b. A routing table prefers the
+// most specific route, so the IPs that a and b have in common are routed using the subnet with the
+// longest mask.
+func OverlapOf(a, b *net.IPNet) Overlap {
+	if !Overlaps(a, b) {
+		return Disjoint
+	}
+	aOnes, _ := a.Mask.Size()
+	bOnes, _ := b.Mask.Size()
+	if bOnes > aOnes {
+		return MaskedBy
+	}
+	return Masks
+}
+
+// FindAvailable returns a subnet with a mask of the given size that is contained in one of the
+// given candidate subnets and doesn't overlap any of the occupied subnets. The bits argument is
+// the total number of bits in the mask, i.e. 32 for IPv4 and 128 for IPv6. Candidates of the other
+// IP family are ignored. The candidates are tried in order. Nil is returned when no such subnet
+// can be found.
+func FindAvailable(ones, bits int, candidates, occupied []*net.IPNet) *net.IPNet {
+	for _, c := range candidates {
+		cOnes, cBits := c.Mask.Size()
+		if cBits != bits || cOnes > ones || ones > bits {
+			continue
+		}
+		// Don't step through more than 2^16 subnets of each candidate
+		stepBits := ones - cOnes
+		if stepBits > 16 {
+			stepBits = 16
+		}
+		mask := net.CIDRMask(ones, bits)
+	next:
+		for i := 0; i < 1<<stepBits; i++ {
+			sn := &net.IPNet{IP: addToIP(c.IP.Mask(c.Mask), i, bits-cOnes-stepBits), Mask: mask}
+			for _, o := range occupied {
+				if Overlaps(sn, o) {
+					continue next
+				}
+			}
+			return sn
+		}
+	}
+	return nil
+}
+
+// Translate returns a copy of the given IP where the network part has been replaced with the network
+// part of the given subnet. This maps an IP in a subnet to the IP that has the same position in another
+// subnet of the same size.
+func Translate(ip net.IP, to *net.IPNet) net.IP {
+	ones, bits := to.Mask.Size()
+	if bits == 8*net.IPv4len {
+		ones += 8 * (net.IPv6len - net.IPv4len)
+	}
+	mask := net.CIDRMask(ones, 8*net.IPv6len)
+	ip16 := ip.To16()
+	to16 := to.IP.To16()
+	r := make(net.IP, net.IPv6len)
+	for i := range r {
+		r[i] = to16[i]&mask[i] | ip16[i]&^mask[i]
+	}
+	if ip.To4() != nil {
+		return r.To4()
+	}
+	return r
+}
+
+// addToIP returns a copy of the given IP with the value n shifted left by shift bits added to it.
+func addToIP(ip net.IP, n, shift int) net.IP {
+	r := make(net.IP, len(ip))
+	copy(r, ip)
+	carry := uint(n) << (shift % 8)
+	for i := len(r) - 1 - shift/8; i >= 0 && carry > 0; i-- {
+		sum := uint(r[i]) + carry&0xff
+		r[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	return r
+}
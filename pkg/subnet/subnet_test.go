@@ -187,3 +187,109 @@ func TestUnique(t *testing.T) {
 		})
 	}
 }
+
+func TestOverlaps(t *testing.T) {
+	_, a, _ := net.ParseCIDR("10.1.0.0/16")
+	_, b, _ := net.ParseCIDR("10.1.2.0/24")
+	assert.True(t, Overlaps(a, b))
+	assert.True(t, Overlaps(b, a))
+
+	_, b, _ = net.ParseCIDR("10.0.0.0/8")
+	assert.True(t, Overlaps(a, b))
+
+	_, b, _ = net.ParseCIDR("10.2.0.0/16")
+	assert.False(t, Overlaps(a, b))
+}
+
+func TestOverlapOf(t *testing.T) {
+	_, a, _ := net.ParseCIDR("10.1.0.0/16")
+	_, b, _ := net.ParseCIDR("10.1.2.0/24")
+	assert.Equal(t, MaskedBy, OverlapOf(a, b))
+	assert.Equal(t, Masks, OverlapOf(b, a))
+	assert.Equal(t, Masks, OverlapOf(a, a))
+
+	_, b, _ = net.ParseCIDR("10.2.0.0/16")
+	assert.Equal(t, Disjoint, OverlapOf(a, b))
+
+	_, b, _ = net.ParseCIDR("fd00::/8")
+	assert.Equal(t, Disjoint, OverlapOf(a, b))
+}
+
+func TestFindAvailable(t *testing.T) {
+	cidrs := func(ss ...string) []*net.IPNet {
+		ns := make([]*net.IPNet, len(ss))
+		for i, s := range ss {
+			_, ns[i], _ = net.ParseCIDR(s)
+		}
+		return ns
+	}
+	tests := []struct {
+		name       string
+		ones       int
+		bits       int
+		candidates []*net.IPNet
+		occupied   []*net.IPNet
+		want       string
+	}{
+		{
+			name:       "first free",
+			ones:       16,
+			bits:       32,
+			candidates: cidrs("10.0.0.0/8"),
+			occupied:   cidrs("10.0.0.0/16", "10.1.0.0/24"),
+			want:       "10.2.0.0/16",
+		},
+		{
+			name:       "next candidate",
+			ones:       16,
+			bits:       32,
+			candidates: cidrs("10.0.0.0/15", "172.16.0.0/12"),
+			occupied:   cidrs("10.0.0.0/15"),
+			want:       "172.16.0.0/16",
+		},
+		{
+			name:       "candidate too small",
+			ones:       12,
+			bits:       32,
+			candidates: cidrs("198.18.0.0/15", "100.64.0.0/10"),
+			occupied:   cidrs("100.64.0.0/12"),
+			want:       "100.80.0.0/12",
+		},
+		{
+			name:       "other family ignored",
+			ones:       64,
+			bits:       128,
+			candidates: cidrs("10.0.0.0/8", "fd00:1::/48"),
+			occupied:   cidrs("fd00:1::/64"),
+			want:       "fd00:1:0:1::/64",
+		},
+		{
+			name:       "none available",
+			ones:       24,
+			bits:       32,
+			candidates: cidrs("192.168.0.0/23"),
+			occupied:   cidrs("192.168.0.0/16"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindAvailable(tt.ones, tt.bits, tt.candidates, tt.occupied)
+			if tt.want == "" {
+				assert.Nil(t, got)
+			} else {
+				require.NotNil(t, got)
+				assert.Equal(t, tt.want, got.String())
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	_, to, _ := net.ParseCIDR("100.64.0.0/16")
+	assert.Equal(t, "100.64.3.4", Translate(net.ParseIP("10.1.3.4"), to).String())
+	assert.Equal(t, "100.64.3.4", Translate(net.IP{10, 1, 3, 4}, to).String())
+
+	_, to, _ = net.ParseCIDR("fd00:1::/64")
+	assert.Equal(t, "fd00:1::1:2", Translate(net.ParseIP("fd00:2::1:2"), to).String())
+}
@@ -32,6 +32,7 @@ func (p *bidiPipe) Start(ctx context.Context) {
 		go doPipe(ctx, p.a, p.b, &wg)
 		go doPipe(ctx, p.b, p.a, &wg)
 		wg.Wait()
+		dlog.Debugf(ctx, "   FWD %s, %s <-> %s", p.a.ID(), p.a.Stats(), p.b.Stats())
 	}()
 }
 
@@ -18,8 +18,10 @@ func NewClientStream(ctx context.Context, grpcStream GRPClientCStream, id ConnID
 	s.roundtripLatency = callDelay
 	s.dialTimeout = dialTimeout
 	s.sessionID = sessionID
+	s.total = getStatsCounter(ctx)
 
-	if err := s.Send(ctx, StreamInfoMessage(id, sessionID, callDelay, dialTimeout)); err != nil {
+	offered := GetCompressions(ctx)
+	if err := s.Send(ctx, StreamInfoMessage(id, sessionID, callDelay, dialTimeout, offered)); err != nil {
 		_ = s.CloseSend(ctx)
 		return nil, err
 	}
@@ -33,6 +35,13 @@ func NewClientStream(ctx context.Context, grpcStream GRPClientCStream, id ConnID
 		return nil, errors.New("initial message was not StreamOK")
 	}
 	s.peerVersion = getVersion(m)
+	if c := getCompression(m); c != NoCompression {
+		if negotiateCompression([]Compression{c}, offered) != c {
+			_ = s.CloseSend(ctx)
+			return nil, fmt.Errorf("peer chose compression %s, which wasn't offered", c)
+		}
+		s.compression = c
+	}
 	return s, nil
 }
 
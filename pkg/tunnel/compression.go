@@ -0,0 +1,242 @@
+package tunnel
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Compression is an algorithm that compresses the payload of Normal messages. The compression of a Stream
+// is negotiated when the stream is established. Peers of Version 2 and below don't negotiate, and their
+// streams are never compressed.
+type Compression byte
+
+const (
+	NoCompression = Compression(iota)
+	Deflate
+)
+
+const (
+	// minCompressSize is the size of the smallest payload that is worth compressing.
+	minCompressSize = 256
+
+	// maxDecompressedSize protects against payloads that claim to decompress into more than any
+	// message that a Dialer ever sends.
+	maxDecompressedSize = 0x400000
+)
+
+var errMalformedCompressed = errors.New("malformed compressed message")
+
+// defaultCompressions are the compressions that a Stream supports unless the context says otherwise.
+var defaultCompressions = []Compression{Deflate}
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Deflate:
+		return "deflate"
+	default:
+		return fmt.Sprintf("** unknown compression: %d **", c)
+	}
+}
+
+// ParseCompressions parses a comma separated list of compressions in order of preference, e.g. "deflate".
+// The list "none" disables compression and the empty string yields the default compressions.
+func ParseCompressions(spec string) ([]Compression, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "":
+		return defaultCompressions, nil
+	case "none":
+		return []Compression{}, nil
+	}
+	var cs []Compression
+	for _, name := range strings.Split(spec, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "deflate":
+			cs = append(cs, Deflate)
+		default:
+			return nil, fmt.Errorf("unknown compression %q", name)
+		}
+	}
+	return cs, nil
+}
+
+type compressionsKey struct{}
+
+// WithCompressions returns a context that makes the streams created with it support the given compressions,
+// in order of preference.
+func WithCompressions(ctx context.Context, cs []Compression) context.Context {
+	return context.WithValue(ctx, compressionsKey{}, cs)
+}
+
+// GetCompressions returns the compressions that streams created with the given context support.
+func GetCompressions(ctx context.Context) []Compression {
+	if cs, ok := ctx.Value(compressionsKey{}).([]Compression); ok {
+		return cs
+	}
+	return defaultCompressions
+}
+
+// negotiateCompression returns the first of the offered compressions that is supported.
+func negotiateCompression(offered, supported []Compression) Compression {
+	for _, o := range offered {
+		for _, s := range supported {
+			if o == s {
+				return o
+			}
+		}
+	}
+	return NoCompression
+}
+
+var flateWriters = sync.Pool{New: func() interface{} {
+	w, _ := flate.NewWriter(nil, flate.BestSpeed)
+	return w
+}}
+
+var flateReaders = sync.Pool{New: func() interface{} {
+	return flate.NewReader(nil)
+}}
+
+// compressMessage returns the compressed form of the given Normal message, or the message itself when it
+// is too small to be worth compressing, or when compression doesn't make it smaller.
+func compressMessage(c Compression, m Message) Message {
+	pl := m.Payload()
+	if c != Deflate || m.Code() != Normal || len(pl) < minCompressSize {
+		return m
+	}
+	b := bytes.Buffer{}
+	b.Grow(len(pl) / 2)
+	b.WriteByte(byte(compressed))
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(len(pl)))
+	b.Write(buf[:n])
+
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&b)
+	if _, err := w.Write(pl); err != nil {
+		return m
+	}
+	if err := w.Close(); err != nil || b.Len() >= len(m.(msg)) {
+		return m
+	}
+	return msg(b.Bytes())
+}
+
+// decompressMessage returns the Normal message that the given compressed message was created from.
+func decompressMessage(c Compression, m Message) (Message, error) {
+	if c != Deflate {
+		return nil, fmt.Errorf("received a compressed message on a stream that uses compression %s", c)
+	}
+	pl := m.Payload()
+	v, n := binary.Uvarint(pl)
+	if n <= 0 || v > maxDecompressedSize {
+		return nil, errMalformedCompressed
+	}
+	r := flateReaders.Get().(io.ReadCloser)
+	defer flateReaders.Put(r)
+	if err := r.(flate.Resetter).Reset(bytes.NewReader(pl[n:]), nil); err != nil {
+		return nil, err
+	}
+	dm := makeMessage(Normal, int(v))
+	if _, err := io.ReadFull(r, dm.Payload()); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformedCompressed, err)
+	}
+	return dm, nil
+}
+
+// Stats are the number of payload bytes in the Normal messages that a Stream has sent and received, before
+// (Sent and Received) and after (SentWire and ReceivedWire) compression.
+type Stats struct {
+	Compression  Compression
+	Sent         uint64
+	SentWire     uint64
+	Received     uint64
+	ReceivedWire uint64
+}
+
+// SendRatio returns the size of the sent payloads after compression relative to their size before compression.
+func (s Stats) SendRatio() float64 {
+	return ratio(s.SentWire, s.Sent)
+}
+
+// ReceiveRatio returns the size of the received payloads after compression relative to their size before
+// compression.
+func (s Stats) ReceiveRatio() float64 {
+	return ratio(s.ReceivedWire, s.Received)
+}
+
+// counters are payload byte counters that are updated atomically. They must be 64-bit aligned.
+type counters struct {
+	sent         uint64
+	sentWire     uint64
+	received     uint64
+	receivedWire uint64
+}
+
+func (c *counters) addSent(n, wire int) {
+	atomic.AddUint64(&c.sent, uint64(n))
+	atomic.AddUint64(&c.sentWire, uint64(wire))
+}
+
+func (c *counters) addReceived(n, wire int) {
+	atomic.AddUint64(&c.received, uint64(n))
+	atomic.AddUint64(&c.receivedWire, uint64(wire))
+}
+
+func (c *counters) stats(compression Compression) Stats {
+	return Stats{
+		Compression:  compression,
+		Sent:         atomic.LoadUint64(&c.sent),
+		SentWire:     atomic.LoadUint64(&c.sentWire),
+		Received:     atomic.LoadUint64(&c.received),
+		ReceivedWire: atomic.LoadUint64(&c.receivedWire),
+	}
+}
+
+// StatsCounter accumulates the payload byte counts of all streams that are created with a context that
+// carries it, so that the compression ratios of those streams can be reported.
+type StatsCounter struct {
+	counters
+}
+
+// Stats returns the accumulated payload byte counts. The Compression of the returned Stats is always
+// NoCompression, because the streams may use different compressions.
+func (c *StatsCounter) Stats() Stats {
+	return c.stats(NoCompression)
+}
+
+type statsCounterKey struct{}
+
+// WithStatsCounter returns a context that makes the streams created with it add their payload byte counts
+// to the given StatsCounter.
+func WithStatsCounter(ctx context.Context, c *StatsCounter) context.Context {
+	return context.WithValue(ctx, statsCounterKey{}, c)
+}
+
+func getStatsCounter(ctx context.Context) *StatsCounter {
+	c, _ := ctx.Value(statsCounterKey{}).(*StatsCounter)
+	return c
+}
+
+func ratio(wire, raw uint64) float64 {
+	if raw == 0 {
+		return 1
+	}
+	return float64(wire) / float64(raw)
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("compression %s, sent %d bytes (ratio %.2f), received %d bytes (ratio %.2f)",
+		s.Compression, s.Sent, s.SendRatio(), s.Received, s.ReceiveRatio())
+}
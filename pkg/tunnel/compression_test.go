@@ -0,0 +1,174 @@
+package tunnel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+func TestParseCompressions(t *testing.T) {
+	cs, err := ParseCompressions("")
+	require.NoError(t, err)
+	assert.Equal(t, []Compression{Deflate}, cs)
+
+	cs, err = ParseCompressions("none")
+	require.NoError(t, err)
+	assert.Empty(t, cs)
+
+	cs, err = ParseCompressions(" deflate ")
+	require.NoError(t, err)
+	assert.Equal(t, []Compression{Deflate}, cs)
+
+	_, err = ParseCompressions("deflate,zip")
+	assert.Error(t, err)
+}
+
+func TestCompressMessage(t *testing.T) {
+	json := bytes.Repeat([]byte(`{"name":"value","list":[1,2,3]},`), 100)
+	m := NewMessage(Normal, json)
+	cm := compressMessage(Deflate, m)
+	assert.Equal(t, compressed, cm.Code())
+	assert.Less(t, len(cm.Payload()), len(json)/10)
+	dm, err := decompressMessage(Deflate, cm)
+	require.NoError(t, err)
+	assert.Equal(t, m, dm)
+
+	// Small and incompressible messages, and messages of uncompressed streams, are sent as is
+	small := NewMessage(Normal, []byte("hello"))
+	assert.Equal(t, small, compressMessage(Deflate, small))
+	random := make([]byte, 1000)
+	for i := range random {
+		random[i] = byte(uuid.New().ID())
+	}
+	rm := NewMessage(Normal, random)
+	assert.Equal(t, rm, compressMessage(Deflate, rm))
+	assert.Equal(t, m, compressMessage(NoCompression, m))
+
+	_, err = decompressMessage(NoCompression, cm)
+	assert.Error(t, err)
+	_, err = decompressMessage(Deflate, NewMessage(compressed, []byte{0x10, 0xff, 0xff}))
+	assert.ErrorIs(t, err, errMalformedCompressed)
+}
+
+func TestStream_Compression(t *testing.T) {
+	ctx, cancel := testContext(t, 5*time.Second)
+	defer cancel()
+
+	id := NewConnID(ipproto.TCP, iputil.Parse("127.0.0.1"), iputil.Parse("192.168.0.1"), 1001, 8080)
+	json := bytes.Repeat([]byte(`{"name":"value","list":[1,2,3]},`), 100)
+
+	// connect establishes a stream between a client and a server that support the given compressions, sends
+	// a message in each direction, and returns the stats of both streams. The client stream adds its payload
+	// byte counts to total.
+	total := &StatsCounter{}
+	connect := func(t *testing.T, clientCs, serverCs []Compression) (Stats, Stats) {
+		tunnel := newBidi(10, ctx.Done())
+		var cStats, sStats Stats
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client, err := NewClientStream(WithStatsCounter(WithCompressions(ctx, clientCs), total), tunnel.clientSide(), id, uuid.New().String(), 0, 0)
+			require.NoError(t, err)
+			require.NoError(t, client.Send(ctx, NewMessage(Normal, json)))
+			m, err := client.Receive(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, json, m.Payload())
+			cStats = client.Stats()
+			assert.NoError(t, client.CloseSend(ctx))
+		}()
+		go func() {
+			defer wg.Done()
+			server, err := NewServerStream(WithCompressions(ctx, serverCs), tunnel.serverSide())
+			require.NoError(t, err)
+			m, err := server.Receive(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, Normal, m.Code())
+			require.NoError(t, server.Send(ctx, m))
+			sStats = server.Stats()
+		}()
+		wg.Wait()
+		return cStats, sStats
+	}
+
+	t.Run("negotiated", func(t *testing.T) {
+		cStats, sStats := connect(t, []Compression{Deflate}, []Compression{Deflate})
+		assert.Equal(t, Deflate, cStats.Compression)
+		assert.Equal(t, Deflate, sStats.Compression)
+		assert.Equal(t, uint64(len(json)), cStats.Sent)
+		assert.Equal(t, uint64(len(json)), sStats.Received)
+		assert.Equal(t, cStats.SentWire, sStats.ReceivedWire)
+		assert.Less(t, cStats.SendRatio(), 0.1)
+		assert.Less(t, sStats.SendRatio(), 0.1)
+	})
+
+	t.Run("accumulated", func(t *testing.T) {
+		*total = StatsCounter{}
+		cStats1, _ := connect(t, []Compression{Deflate}, []Compression{Deflate})
+		cStats2, _ := connect(t, []Compression{Deflate}, []Compression{})
+		ts := total.Stats()
+		assert.Equal(t, NoCompression, ts.Compression)
+		assert.Equal(t, cStats1.Sent+cStats2.Sent, ts.Sent)
+		assert.Equal(t, cStats1.SentWire+cStats2.SentWire, ts.SentWire)
+		assert.Equal(t, cStats1.Received+cStats2.Received, ts.Received)
+		assert.Equal(t, cStats1.ReceivedWire+cStats2.ReceivedWire, ts.ReceivedWire)
+		assert.Less(t, ts.SendRatio(), 0.6)
+		assert.Greater(t, ts.SendRatio(), 0.5)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		for _, cs := range [][]Compression{{}, {Deflate}} {
+			cStats, sStats := connect(t, cs, []Compression{})
+			assert.Equal(t, NoCompression, cStats.Compression)
+			assert.Equal(t, NoCompression, sStats.Compression)
+			assert.Equal(t, 1.0, cStats.SendRatio())
+			assert.Equal(t, 1.0, sStats.ReceiveRatio())
+		}
+	})
+
+	t.Run("old client", func(t *testing.T) {
+		tunnel := newBidi(10, ctx.Done())
+		go func() {
+			// A StreamInfo message from a version 2 client lacks compressions
+			m := StreamInfoMessage(id, "session", 0, 0, nil).(msg)
+			m[1] = 2
+			_ = tunnel.clientSide().Send(msg(m[:len(m)-1]).TunnelMessage())
+		}()
+		server, err := NewServerStream(ctx, tunnel.serverSide())
+		require.NoError(t, err)
+		assert.Equal(t, uint16(2), server.PeerVersion())
+		assert.Equal(t, "session", server.SessionID())
+		assert.Equal(t, NoCompression, server.Stats().Compression)
+	})
+
+	t.Run("old server", func(t *testing.T) {
+		tunnel := newBidi(10, ctx.Done())
+		go func() {
+			// A StreamOK message from a version 2 server lacks the compression
+			ok := makeMessage(streamOK, 1)
+			binary.PutUvarint(ok.Payload(), 2)
+			_ = tunnel.serverSide().Send(ok.TunnelMessage())
+		}()
+		client, err := NewClientStream(ctx, tunnel.clientSide(), id, "session", 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, uint16(2), client.PeerVersion())
+		assert.Equal(t, NoCompression, client.Stats().Compression)
+	})
+}
+
+func TestStats(t *testing.T) {
+	s := Stats{Compression: Deflate, Sent: 1000, SentWire: 250}
+	assert.Equal(t, 0.25, s.SendRatio())
+	assert.Equal(t, 1.0, s.ReceiveRatio())
+	assert.Equal(t, "compression deflate, sent 1000 bytes (ratio 0.25), received 0 bytes (ratio 1.00)", s.String())
+	assert.Equal(t, "none", NoCompression.String())
+}
@@ -111,6 +111,7 @@ func (h *dialer) Start(ctx context.Context) {
 		go h.streamToConnLoop(ctx, &wg)
 		wg.Wait()
 		h.Close(ctx)
+		dlog.Debugf(ctx, "   CONN %s, %s", id, h.stream.Stats())
 	}()
 }
 
@@ -300,7 +301,7 @@ func dialRespond(ctx context.Context, manager rpc.ManagerClient, dr *rpc.DialReq
 		dlog.Error(ctx, err)
 		return
 	}
-	d := NewDialer(s)
+	d := NewDialer(shapeStream(ctx, NewFaultyStream(s, GetFaults(ctx)), dr.InterceptId))
 	d.Start(ctx)
 	<-d.Done()
 }
@@ -0,0 +1,566 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/datawire/dlib/dtime"
+)
+
+// ErrReset is returned by a tunnel that the FaultInjector has reset.
+var ErrReset = errors.New("tunnel reset by fault injection")
+
+const (
+	// maxHoldTime is the maximum time that a reordered message is held back waiting for the next message.
+	maxHoldTime = 50 * time.Millisecond
+
+	// retransmitTimeout is how much a lost message is delayed. It's the minimum retransmission timeout of TCP.
+	retransmitTimeout = 200 * time.Millisecond
+)
+
+// Faults are network faults that are injected into tunnels to simulate a poor connection, e.g. in
+// order to reproduce problems in reconnect paths. The zero value injects no faults.
+//
+// Tunnels assume a reliable transport, so a data message that is dropped is lost for good.
+type Faults struct {
+	// Latency is added to each message that is sent, along with a random duration of up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// Bandwidth is the maximum number of bytes per second that are sent. Zero means unlimited.
+	Bandwidth int64
+
+	// DropRate is the probability that a data message is dropped, and ReorderRate is the probability
+	// that a data message is held back and sent after the next message. Control messages are never
+	// dropped or reordered.
+	DropRate    float64
+	ReorderRate float64
+
+	// ResetRate is the probability that the tunnel is reset when a message is sent or received.
+	ResetRate float64
+
+	// LossRate is the probability that a message is lost and retransmitted. A lost message isn't dropped.
+	// It's delayed by the retransmission timeout of TCP instead.
+	LossRate float64
+
+	// Seed seeds the random numbers, so that a sequence of faults can be repeated. Zero means a random seed.
+	Seed int64
+}
+
+// ParseFaults parses a comma separated list of faults such as "latency=50ms,jitter=10ms,bandwidth=1mbit,drop=1%".
+// The keys are latency, jitter, bandwidth, drop, reorder, reset, loss, and seed. A bandwidth is in bytes per second,
+// unless it has one of the units kb, mb, gb, kbit, mbit, or gbit. A rate is a probability between 0 and 1, or
+// a percentage.
+func ParseFaults(s string) (*Faults, error) {
+	f := &Faults{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return f, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid fault %q, expected <key>=<value>", kv)
+		}
+		k, v := strings.TrimSpace(kv[:eq]), strings.TrimSpace(kv[eq+1:])
+		var err error
+		switch k {
+		case "latency":
+			f.Latency, err = time.ParseDuration(v)
+		case "jitter":
+			f.Jitter, err = time.ParseDuration(v)
+		case "bandwidth":
+			f.Bandwidth, err = parseBandwidth(v)
+		case "drop":
+			f.DropRate, err = parseRate(v)
+		case "reorder":
+			f.ReorderRate, err = parseRate(v)
+		case "reset":
+			f.ResetRate, err = parseRate(v)
+		case "loss":
+			f.LossRate, err = parseRate(v)
+		case "seed":
+			f.Seed, err = strconv.ParseInt(v, 10, 64)
+		default:
+			return nil, fmt.Errorf("unknown fault %q", k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", k, v, err)
+		}
+	}
+	return f, nil
+}
+
+var bandwidthUnits = []struct {
+	suffix string
+	factor float64
+}{
+	// Longest suffixes first
+	{"kbit", 1e3 / 8}, {"mbit", 1e6 / 8}, {"gbit", 1e9 / 8},
+	{"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9},
+}
+
+func parseBandwidth(s string) (int64, error) {
+	factor := 1.0
+	ls := strings.ToLower(s)
+	for _, u := range bandwidthUnits {
+		if strings.HasSuffix(ls, u.suffix) {
+			ls = strings.TrimSuffix(ls, u.suffix)
+			factor = u.factor
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(ls, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		return 0, errors.New("bandwidth cannot be negative")
+	}
+	return int64(v * factor), nil
+}
+
+func parseRate(s string) (float64, error) {
+	var v float64
+	var err error
+	if ps := strings.TrimSuffix(s, "%"); ps != s {
+		v, err = strconv.ParseFloat(ps, 64)
+		v /= 100
+	} else {
+		v, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v > 1 {
+		return 0, errors.New("rate must be between 0 and 1")
+	}
+	return v, nil
+}
+
+// Enabled returns true if any fault is injected.
+func (f *Faults) Enabled() bool {
+	return f != nil && (f.Latency > 0 || f.Jitter > 0 || f.Bandwidth > 0 || f.DropRate > 0 || f.ReorderRate > 0 || f.ResetRate > 0 || f.LossRate > 0)
+}
+
+// String returns the faults in the format that ParseFaults parses.
+func (f *Faults) String() string {
+	if f == nil {
+		return ""
+	}
+	var kvs []string
+	add := func(k string, v interface{}) {
+		kvs = append(kvs, fmt.Sprintf("%s=%v", k, v))
+	}
+	if f.Latency > 0 {
+		add("latency", f.Latency)
+	}
+	if f.Jitter > 0 {
+		add("jitter", f.Jitter)
+	}
+	if f.Bandwidth > 0 {
+		add("bandwidth", f.Bandwidth)
+	}
+	if f.DropRate > 0 {
+		add("drop", f.DropRate)
+	}
+	if f.ReorderRate > 0 {
+		add("reorder", f.ReorderRate)
+	}
+	if f.ResetRate > 0 {
+		add("reset", f.ResetRate)
+	}
+	if f.LossRate > 0 {
+		add("loss", f.LossRate)
+	}
+	if f.Seed != 0 {
+		add("seed", f.Seed)
+	}
+	return strings.Join(kvs, ",")
+}
+
+type faultsKey struct{}
+
+// WithFaults returns a context with the given Faults
+func WithFaults(ctx context.Context, faults *Faults) context.Context {
+	return context.WithValue(ctx, faultsKey{}, faults)
+}
+
+// GetFaults returns the Faults of the given context, or nil if it has none.
+func GetFaults(ctx context.Context) *Faults {
+	faults, ok := ctx.Value(faultsKey{}).(*Faults)
+	if !ok {
+		return nil
+	}
+	return faults
+}
+
+// FaultInjector injects Faults into the messages that are sent in one direction of a tunnel. Each message is
+// scheduled for delivery at the time when it would arrive over the simulated network, and a goroutine of the
+// injector delivers the messages in order, so that the latency delays the messages without limiting the
+// throughput.
+type FaultInjector struct {
+	faults Faults
+	reset  int32 // set atomically when the tunnel has been reset
+
+	mu         sync.Mutex
+	rnd        *rand.Rand
+	nextSlot   time.Time // time when the bandwidth permits the next message to be sent
+	lastDue    time.Time // time when the last queued message is delivered
+	held       *delivery // message that is held back
+	holdTimer  *time.Timer
+	queue      []*delivery
+	delivering bool          // true while a goroutine delivers the queue
+	drained    chan struct{} // closed when the delivering goroutine has emptied the queue
+	err        error         // the first error of a delivery
+}
+
+// delivery is a message that is scheduled for delivery.
+type delivery struct {
+	ctx  context.Context
+	due  time.Time
+	send func() error
+	last bool // the message is delivered although the tunnel has been reset
+}
+
+// NewFaultInjector returns a FaultInjector for the given faults.
+func NewFaultInjector(faults *Faults) *FaultInjector {
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &FaultInjector{faults: *faults, rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Send schedules the given send function for a message of the given size, subject to the faults, and waits
+// for the bandwidth to permit the next message. A data message may be dropped or held back. The send function
+// is called later, by the injector's goroutine, and an error that it returns is returned by the next call
+// to Send or Flush. The injector returns ErrReset when it resets the tunnel, and keeps doing so after that.
+func (fi *FaultInjector) Send(ctx context.Context, size int, data bool, send func() error) error {
+	fi.mu.Lock()
+	if err := fi.checkReset(); err != nil {
+		fi.mu.Unlock()
+		return err
+	}
+	if err := fi.err; err != nil {
+		fi.mu.Unlock()
+		return err
+	}
+	slot, due := fi.schedule(size)
+	d := &delivery{ctx: ctx, due: due, send: send}
+	switch {
+	case data && fi.roll(fi.faults.DropRate):
+	case data && fi.held == nil && fi.roll(fi.faults.ReorderRate):
+		fi.held = d
+		fi.holdTimer = time.AfterFunc(maxHoldTime, fi.release)
+	default:
+		fi.enqueue(d)
+		fi.enqueueHeld()
+	}
+	fi.mu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		dtime.SleepWithContext(ctx, wait)
+	}
+	return ctx.Err()
+}
+
+// SendReset schedules the given send function as the last message of a tunnel that has been reset, e.g. in
+// order to disconnect the tunnel's peer. It's delivered by the injector's goroutine, so it's never sent
+// concurrently with other messages.
+func (fi *FaultInjector) SendReset(ctx context.Context, send func() error) {
+	fi.mu.Lock()
+	fi.enqueue(&delivery{ctx: ctx, due: time.Now(), send: send, last: true})
+	fi.mu.Unlock()
+}
+
+// Received returns ErrReset if the injector resets the tunnel when a message is received, or has reset it before.
+func (fi *FaultInjector) Received() error {
+	if fi.IsReset() {
+		return ErrReset
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.checkReset()
+}
+
+// IsReset returns true if the injector has reset the tunnel.
+func (fi *FaultInjector) IsReset() bool {
+	return atomic.LoadInt32(&fi.reset) != 0
+}
+
+// Flush schedules the message that is held back, if any, and waits until all scheduled messages have been
+// delivered. It must be called before the tunnel is closed.
+func (fi *FaultInjector) Flush() error {
+	fi.mu.Lock()
+	fi.enqueueHeld()
+	drained := fi.drained
+	delivering := fi.delivering
+	fi.mu.Unlock()
+	if delivering {
+		<-drained
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.err
+}
+
+// release schedules the message that is held back when no other message has been sent within the maxHoldTime.
+func (fi *FaultInjector) release() {
+	fi.mu.Lock()
+	fi.enqueueHeld()
+	fi.mu.Unlock()
+}
+
+func (fi *FaultInjector) enqueueHeld() {
+	if d := fi.held; d != nil {
+		fi.held = nil
+		fi.holdTimer.Stop()
+		fi.enqueue(d)
+	}
+}
+
+// enqueue adds the given delivery to the queue, and starts the goroutine that delivers the queue unless it's
+// already running. A message is never delivered before the message that precedes it.
+func (fi *FaultInjector) enqueue(d *delivery) {
+	if d.due.Before(fi.lastDue) {
+		d.due = fi.lastDue
+	}
+	fi.lastDue = d.due
+	fi.queue = append(fi.queue, d)
+	if !fi.delivering {
+		fi.delivering = true
+		fi.drained = make(chan struct{})
+		go fi.deliver()
+	}
+}
+
+// deliver sends each message of the queue when it's due, and returns when the queue is empty.
+func (fi *FaultInjector) deliver() {
+	for {
+		fi.mu.Lock()
+		if len(fi.queue) == 0 {
+			fi.delivering = false
+			close(fi.drained)
+			fi.mu.Unlock()
+			return
+		}
+		d := fi.queue[0]
+		fi.queue = fi.queue[1:]
+		failed := fi.err != nil
+		fi.mu.Unlock()
+
+		if failed || (fi.IsReset() && !d.last) {
+			continue
+		}
+		if wait := time.Until(d.due); wait > 0 {
+			dtime.SleepWithContext(d.ctx, wait)
+		}
+		err := d.ctx.Err()
+		if err == nil {
+			err = d.send()
+		}
+		if err != nil {
+			fi.mu.Lock()
+			if fi.err == nil {
+				fi.err = err
+			}
+			fi.mu.Unlock()
+		}
+	}
+}
+
+// checkReset resets the tunnel at the ResetRate. Messages that are held back or queued are lost when that happens.
+func (fi *FaultInjector) checkReset() error {
+	if !fi.IsReset() && fi.roll(fi.faults.ResetRate) {
+		atomic.StoreInt32(&fi.reset, 1)
+		if fi.held != nil {
+			fi.held = nil
+			fi.holdTimer.Stop()
+		}
+		fi.queue = nil
+	}
+	if fi.IsReset() {
+		return ErrReset
+	}
+	return nil
+}
+
+// schedule returns the time when the bandwidth permits a message of the given size to be sent, and the time
+// when the message is due, i.e. after the latency, jitter, and the retransmission of a lost message.
+func (fi *FaultInjector) schedule(size int) (slot, due time.Time) {
+	slot = time.Now()
+	if bw := fi.faults.Bandwidth; bw > 0 {
+		if fi.nextSlot.After(slot) {
+			slot = fi.nextSlot
+		}
+		fi.nextSlot = slot.Add(time.Duration(int64(size) * int64(time.Second) / bw))
+	}
+	d := fi.faults.Latency
+	if fi.roll(fi.faults.LossRate) {
+		d += retransmitTimeout
+	}
+	if fi.faults.Jitter > 0 {
+		d += time.Duration(fi.rnd.Int63n(int64(fi.faults.Jitter)))
+	}
+	return slot, slot.Add(d)
+}
+
+func (fi *FaultInjector) roll(rate float64) bool {
+	return rate > 0 && fi.rnd.Float64() < rate
+}
+
+type faultyStream struct {
+	Stream
+	fi         *FaultInjector
+	disconnect sync.Once
+}
+
+// NewFaultyStream returns a Stream that injects the given faults into the given Stream. The given Stream is
+// returned when no faults are enabled. When the stream is reset, its peer receives a Disconnect.
+func NewFaultyStream(s Stream, faults *Faults) Stream {
+	if !faults.Enabled() {
+		return s
+	}
+	return &faultyStream{Stream: s, fi: NewFaultInjector(faults)}
+}
+
+func (s *faultyStream) Send(ctx context.Context, m Message) error {
+	err := s.fi.Send(ctx, len(m.Payload()), m.Code() == Normal, func() error { return s.Stream.Send(ctx, m) })
+	return s.disconnectOnReset(ctx, err)
+}
+
+func (s *faultyStream) Receive(ctx context.Context) (Message, error) {
+	if s.fi.IsReset() {
+		return nil, ErrReset
+	}
+	m, err := s.Stream.Receive(ctx)
+	if err == nil {
+		if err = s.disconnectOnReset(ctx, s.fi.Received()); err != nil {
+			m = nil
+		}
+	}
+	return m, err
+}
+
+func (s *faultyStream) CloseSend(ctx context.Context) error {
+	if s.fi.IsReset() {
+		return nil
+	}
+	if err := s.fi.Flush(); err != nil {
+		return err
+	}
+	return s.Stream.CloseSend(ctx)
+}
+
+// disconnectOnReset hard-closes the peer's end of the stream when err is ErrReset.
+func (s *faultyStream) disconnectOnReset(ctx context.Context, err error) error {
+	if errors.Is(err, ErrReset) {
+		s.disconnect.Do(func() {
+			s.fi.SendReset(ctx, func() error { return s.Stream.Send(ctx, NewMessage(Disconnect, nil)) })
+		})
+	}
+	return err
+}
+
+type shapedStream struct {
+	Stream
+	out          *FaultInjector
+	in           *FaultInjector
+	startReceive sync.Once
+	received     chan shapedMessage
+}
+
+// shapedMessage is the result of a receive from the stream that a shapedStream wraps.
+type shapedMessage struct {
+	m   Message
+	err error
+}
+
+// NewShapedStream returns a Stream that simulates the network conditions of the given faults in both
+// directions. Messages are delayed by the latency, jitter, bandwidth, and loss, but never dropped,
+// reordered, or reset. The given Stream is returned when no such conditions are enabled.
+func NewShapedStream(s Stream, faults *Faults) Stream {
+	if faults == nil {
+		return s
+	}
+	shaping := &Faults{Latency: faults.Latency, Jitter: faults.Jitter, Bandwidth: faults.Bandwidth, LossRate: faults.LossRate, Seed: faults.Seed}
+	if !shaping.Enabled() {
+		return s
+	}
+	return &shapedStream{Stream: s, out: NewFaultInjector(shaping), in: NewFaultInjector(shaping), received: make(chan shapedMessage)}
+}
+
+func (s *shapedStream) Send(ctx context.Context, m Message) error {
+	return s.out.Send(ctx, len(m.Payload()), false, func() error { return s.Stream.Send(ctx, m) })
+}
+
+func (s *shapedStream) Receive(ctx context.Context) (Message, error) {
+	s.startReceive.Do(func() { go s.receiveLoop(ctx) })
+	select {
+	case r := <-s.received:
+		return r.m, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *shapedStream) CloseSend(ctx context.Context) error {
+	if err := s.out.Flush(); err != nil {
+		return err
+	}
+	return s.Stream.CloseSend(ctx)
+}
+
+// receiveLoop receives the messages of the wrapped stream as soon as they arrive, and schedules each one,
+// and finally the error that ends the stream, for delivery to Receive when it would arrive over the simulated
+// network.
+func (s *shapedStream) receiveLoop(ctx context.Context) {
+	for {
+		m, err := s.Stream.Receive(ctx)
+		size := 0
+		if err == nil {
+			size = len(m.Payload())
+		}
+		r := shapedMessage{m: m, err: err}
+		err = s.in.Send(ctx, size, false, func() error {
+			select {
+			case s.received <- r:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil || r.err != nil {
+			return
+		}
+	}
+}
+
+// ShapingFunc returns the network conditions to simulate for the connection with the given ID that was
+// intercepted by the intercept with the given ID, or nil. The interceptID is empty when the connection
+// isn't intercepted.
+type ShapingFunc func(id ConnID, interceptID string) *Faults
+
+type shapingKey struct{}
+
+// WithShaping returns a context with the given ShapingFunc
+func WithShaping(ctx context.Context, shaping ShapingFunc) context.Context {
+	return context.WithValue(ctx, shapingKey{}, shaping)
+}
+
+// shapeStream applies the network conditions that the ShapingFunc of the given context returns for the
+// stream's connection.
+func shapeStream(ctx context.Context, s Stream, interceptID string) Stream {
+	if shaping, ok := ctx.Value(shapingKey{}).(ShapingFunc); ok {
+		s = NewShapedStream(s, shaping(s.ID(), interceptID))
+	}
+	return s
+}
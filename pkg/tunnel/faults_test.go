@@ -0,0 +1,269 @@
+package tunnel
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+func TestParseFaults(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    *Faults
+		wantErr bool
+	}{
+		{"", &Faults{}, false},
+		{
+			"latency=50ms, jitter=10ms,bandwidth=1mbit,drop=1%,reorder=0.5,reset=0,seed=42",
+			&Faults{Latency: 50 * time.Millisecond, Jitter: 10 * time.Millisecond, Bandwidth: 125000, DropRate: 0.01, ReorderRate: 0.5, Seed: 42},
+			false,
+		},
+		{"bandwidth=2KB", &Faults{Bandwidth: 2000}, false},
+		{"bandwidth=512", &Faults{Bandwidth: 512}, false},
+		{"latency=100ms,loss=2%", &Faults{Latency: 100 * time.Millisecond, LossRate: 0.02}, false},
+		{"latency", nil, true},
+		{"latency=fast", nil, true},
+		{"drop=150%", nil, true},
+		{"bandwidth=-1", nil, true},
+		{"delay=10ms", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseFaults(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// String produces what ParseFaults parses
+			again, err := ParseFaults(got.String())
+			require.NoError(t, err)
+			assert.Equal(t, got, again)
+		})
+	}
+	var nf *Faults
+	assert.False(t, nf.Enabled())
+	assert.False(t, (&Faults{Seed: 1}).Enabled())
+	assert.True(t, (&Faults{DropRate: 0.1}).Enabled())
+}
+
+func TestFaultInjector(t *testing.T) {
+	ctx, cancel := testContext(t, 5*time.Second)
+	defer cancel()
+
+	var sent []int
+	sender := func(i int) func() error {
+		return func() error {
+			sent = append(sent, i)
+			return nil
+		}
+	}
+
+	fi := NewFaultInjector(&Faults{DropRate: 1})
+	require.NoError(t, fi.Send(ctx, 1, true, sender(1)))
+	require.NoError(t, fi.Send(ctx, 1, false, sender(2)))
+	require.NoError(t, fi.Flush())
+	assert.Equal(t, []int{2}, sent, "data is dropped, control is not")
+
+	sent = nil
+	fi = NewFaultInjector(&Faults{ReorderRate: 1})
+	require.NoError(t, fi.Send(ctx, 1, true, sender(1)))
+	require.NoError(t, fi.Send(ctx, 1, true, sender(2)))
+	require.NoError(t, fi.Send(ctx, 1, true, sender(3)))
+	require.NoError(t, fi.Flush())
+	assert.Equal(t, []int{2, 1, 3}, sent, "one message at a time is held back")
+
+	sent = nil
+	fi = NewFaultInjector(&Faults{ResetRate: 1})
+	assert.ErrorIs(t, fi.Send(ctx, 1, false, sender(1)), ErrReset)
+	assert.ErrorIs(t, fi.Received(), ErrReset)
+	assert.True(t, fi.IsReset())
+	assert.Empty(t, sent)
+
+	// Ten messages of 20kB at 1MB/s take at least 180ms, since the first is sent immediately
+	fi = NewFaultInjector(&Faults{Bandwidth: 1e6})
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, fi.Send(ctx, 20000, true, func() error { return nil }))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+
+	// A message that isn't delivered before its context ends fails the next send
+	fi = NewFaultInjector(&Faults{Latency: time.Hour})
+	cctx, ccancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer ccancel()
+	require.NoError(t, fi.Send(cctx, 1, true, func() error { return nil }))
+	assert.ErrorIs(t, fi.Flush(), context.DeadlineExceeded)
+	assert.ErrorIs(t, fi.Send(ctx, 1, true, func() error { return nil }), context.DeadlineExceeded)
+
+	// A lost message is retransmitted, not dropped
+	sent = nil
+	fi = NewFaultInjector(&Faults{LossRate: 1})
+	start = time.Now()
+	require.NoError(t, fi.Send(ctx, 1, true, sender(1)))
+	require.NoError(t, fi.Flush())
+	assert.Equal(t, []int{1}, sent)
+	assert.GreaterOrEqual(t, time.Since(start), retransmitTimeout)
+
+	// The latency delays each message without limiting the throughput
+	sent = nil
+	var expected []int
+	fi = NewFaultInjector(&Faults{Latency: 100 * time.Millisecond})
+	start = time.Now()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, fi.Send(ctx, 1, true, sender(i)))
+		expected = append(expected, i)
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond, "sending doesn't wait for the latency")
+	require.NoError(t, fi.Flush())
+	assert.Equal(t, expected, sent, "the messages are delivered in order")
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second, "the messages are delayed concurrently")
+}
+
+func TestShapedStream(t *testing.T) {
+	ctx, cancel := testContext(t, 5*time.Second)
+	defer cancel()
+
+	tunnel := newBidi(10, ctx.Done())
+	id := NewConnID(ipproto.TCP, iputil.Parse("127.0.0.1"), iputil.Parse("192.168.0.1"), 1001, 8080)
+	faults := &Faults{Latency: 50 * time.Millisecond, DropRate: 1, ResetRate: 1}
+	shapingCtx := WithShaping(ctx, func(sid ConnID, interceptID string) *Faults {
+		if sid == id && interceptID == "session:echo" {
+			return faults
+		}
+		return nil
+	})
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		client, err := NewClientStream(ctx, tunnel.clientSide(), id, uuid.New().String(), 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, client.Send(ctx, NewMessage(Normal, []byte("hello"))))
+		start := time.Now()
+		m, err := client.Receive(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "HELLO", string(m.Payload()))
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "the latency applies in both directions")
+		assert.NoError(t, client.CloseSend(ctx))
+	}()
+
+	go func() {
+		defer wg.Done()
+		server, err := NewServerStream(ctx, tunnel.serverSide())
+		require.NoError(t, err)
+		server = shapeStream(shapingCtx, server, "session:echo")
+
+		// Messages are neither dropped nor reset by a shaped stream
+		m, err := server.Receive(ctx)
+		require.NoError(t, err)
+		require.NoError(t, server.Send(ctx, NewMessage(Normal, []byte(strings.ToUpper(string(m.Payload()))))))
+	}()
+	wg.Wait()
+
+	// Streams are left alone when there's nothing to simulate
+	s := &shapedStream{}
+	assert.Same(t, Stream(s), shapeStream(ctx, s, ""))
+	assert.Same(t, Stream(s), NewShapedStream(s, nil))
+}
+
+func TestShapedStream_throughput(t *testing.T) {
+	ctx, cancel := testContext(t, 5*time.Second)
+	defer cancel()
+
+	const latency = 200 * time.Millisecond
+	const count = 20
+	tunnel := newBidi(count, ctx.Done())
+	id := NewConnID(ipproto.TCP, iputil.Parse("127.0.0.1"), iputil.Parse("192.168.0.1"), 1001, 8080)
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		client, err := NewClientStream(ctx, tunnel.clientSide(), id, uuid.New().String(), 0, 0)
+		require.NoError(t, err)
+		for i := 0; i < count; i++ {
+			require.NoError(t, client.Send(ctx, NewMessage(Normal, []byte{byte(i)})))
+		}
+		for i := 0; i < count; i++ {
+			m, err := client.Receive(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{byte(i)}, m.Payload())
+		}
+		assert.NoError(t, client.CloseSend(ctx))
+	}()
+
+	go func() {
+		defer wg.Done()
+		server, err := NewServerStream(ctx, tunnel.serverSide())
+		require.NoError(t, err)
+		server = NewShapedStream(server, &Faults{Latency: latency})
+
+		// Messages that arrive back-to-back are delayed by the latency, not by the latency of each preceding message
+		start := time.Now()
+		for i := 0; i < count; i++ {
+			m, err := server.Receive(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{byte(i)}, m.Payload())
+		}
+		assert.GreaterOrEqual(t, time.Since(start), latency)
+		assert.Less(t, time.Since(start), 2*latency, "the latency limits the throughput of received messages")
+
+		// The same goes for messages that are sent back-to-back
+		start = time.Now()
+		for i := 0; i < count; i++ {
+			require.NoError(t, server.Send(ctx, NewMessage(Normal, []byte{byte(i)})))
+		}
+		assert.Less(t, time.Since(start), latency, "sending waits for the latency")
+		require.NoError(t, server.CloseSend(ctx))
+		assert.GreaterOrEqual(t, time.Since(start), latency)
+		assert.Less(t, time.Since(start), 2*latency, "the latency limits the throughput of sent messages")
+	}()
+	wg.Wait()
+}
+
+func TestFaultyStream_Reset(t *testing.T) {
+	ctx, cancel := testContext(t, 5*time.Second)
+	defer cancel()
+
+	tunnel := newBidi(10, ctx.Done())
+	id := NewConnID(ipproto.TCP, iputil.Parse("127.0.0.1"), iputil.Parse("192.168.0.1"), 1001, 8080)
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		client, err := NewClientStream(ctx, tunnel.clientSide(), id, uuid.New().String(), 0, 0)
+		require.NoError(t, err)
+		client = NewFaultyStream(client, &Faults{Latency: time.Millisecond, ResetRate: 1})
+		assert.ErrorIs(t, client.Send(ctx, NewMessage(Normal, []byte("hello"))), ErrReset)
+		_, err = client.Receive(ctx)
+		assert.ErrorIs(t, err, ErrReset)
+		assert.NoError(t, client.CloseSend(ctx))
+	}()
+
+	go func() {
+		defer wg.Done()
+		server, err := NewServerStream(ctx, tunnel.serverSide())
+		require.NoError(t, err)
+
+		// The peer of a reset stream is disconnected
+		m, err := server.Receive(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, Disconnect, m.Code())
+	}()
+	wg.Wait()
+}
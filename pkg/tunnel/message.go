@@ -22,6 +22,11 @@ const (
 	Disconnect
 	KeepAlive
 	Session
+	compressed
+	muxOpen
+	muxFrame
+	muxWindow
+	muxReset
 )
 
 func (c MessageCode) String() string {
@@ -42,6 +47,16 @@ func (c MessageCode) String() string {
 		return "KEEP_ALIVE"
 	case Session:
 		return "SESSION"
+	case compressed:
+		return "COMPRESSED"
+	case muxOpen:
+		return "MUX_OPEN"
+	case muxFrame:
+		return "MUX_FRAME"
+	case muxWindow:
+		return "MUX_WINDOW"
+	case muxReset:
+		return "MUX_RESET"
 	default:
 		return fmt.Sprintf("** unknown control code: %d **", c)
 	}
@@ -84,7 +99,9 @@ func NewMessage(code MessageCode, payload []byte) Message {
 	return msg{byte(code)}
 }
 
-func StreamInfoMessage(id ConnID, sessionID string, callDelay, dialTimeout time.Duration) Message {
+// StreamInfoMessage returns the message that a client Stream starts with. The given compressions are offered
+// to the server in order of preference.
+func StreamInfoMessage(id ConnID, sessionID string, callDelay, dialTimeout time.Duration, compressions []Compression) Message {
 	b := bytes.Buffer{}
 	b.WriteByte(byte(streamInfo))
 
@@ -107,13 +124,23 @@ func StreamInfoMessage(id ConnID, sessionID string, callDelay, dialTimeout time.
 	n = binary.PutUvarint(buf, uint64(len(sb)))
 	b.Write(buf[:n])
 	b.Write(sb)
+
+	// Peers of version 2 and below ignore the compressions
+	n = binary.PutUvarint(buf, uint64(len(compressions)))
+	b.Write(buf[:n])
+	for _, c := range compressions {
+		b.WriteByte(byte(c))
+	}
 	return msg(b.Bytes())
 }
 
-func StreamOKMessage() Message {
-	m := makeMessage(streamOK, 4)
+// StreamOKMessage returns the message that a server Stream responds to a StreamInfoMessage with. It
+// contains the compression that the server chose among the ones that the client offered.
+func StreamOKMessage(c Compression) Message {
+	m := makeMessage(streamOK, 5)
 	n := binary.PutUvarint(m.Payload(), uint64(Version))
-	return m[:n+1]
+	m[n+1] = byte(c)
+	return m[:n+2]
 }
 
 func SessionMessage(sessionID string) Message {
@@ -136,36 +163,47 @@ func getVersion(m Message) uint16 {
 	return uint16(v)
 }
 
+// getCompression returns the compression that this StreamOK message represents. Peers of version 2 and
+// below don't send one.
+func getCompression(m Message) Compression {
+	pl := m.Payload()
+	if _, n := binary.Uvarint(pl); n > 0 && n < len(pl) {
+		return Compression(pl[n])
+	}
+	return NoCompression
+}
+
 var errMalformedConnect = errors.New("malformed Connect message")
 
-// connectInfo returns the connectInfo that this Message represents
-func setConnectInfo(m Message, s *stream) error {
+// setConnectInfo assigns the connect info that this Message represents to the given stream and returns the
+// compressions that the peer offers.
+func setConnectInfo(m Message, s *stream) ([]Compression, error) {
 	pl := m.Payload()
 
 	v, n := binary.Uvarint(pl)
 	if n <= 0 {
-		return errMalformedConnect
+		return nil, errMalformedConnect
 	}
 	s.peerVersion = uint16(v)
 	pl = pl[n:]
 
 	v, n = binary.Uvarint(pl)
 	if n <= 0 {
-		return errMalformedConnect
+		return nil, errMalformedConnect
 	}
 	s.roundtripLatency = time.Duration(v)
 	pl = pl[n:]
 
 	v, n = binary.Uvarint(pl)
 	if n <= 0 {
-		return errMalformedConnect
+		return nil, errMalformedConnect
 	}
 	s.dialTimeout = time.Duration(v)
 	pl = pl[n:]
 
 	v, n = binary.Uvarint(pl)
 	if n <= 0 || v > uint64(len(pl)) {
-		return errMalformedConnect
+		return nil, errMalformedConnect
 	}
 	pl = pl[n:]
 	s.id = ConnID(pl[:v])
@@ -173,9 +211,23 @@ func setConnectInfo(m Message, s *stream) error {
 
 	v, n = binary.Uvarint(pl)
 	if n <= 0 || v > uint64(len(pl)) {
-		return errMalformedConnect
+		return nil, errMalformedConnect
 	}
 	pl = pl[n:]
 	s.sessionID = string(pl[:v])
-	return nil
+	pl = pl[v:]
+
+	if s.peerVersion < 3 || len(pl) == 0 {
+		return nil, nil
+	}
+	v, n = binary.Uvarint(pl)
+	if n <= 0 || v > uint64(len(pl)-n) {
+		return nil, errMalformedConnect
+	}
+	pl = pl[n:]
+	cs := make([]Compression, v)
+	for i := range cs {
+		cs[i] = Compression(pl[i])
+	}
+	return cs, nil
 }
@@ -0,0 +1,532 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// muxWindowSize is the number of payload bytes that a sub-stream may send before its peer grants more.
+const muxWindowSize = 0x80000
+
+var (
+	errMuxSessionClosed = fmt.Errorf("%w: multiplexed session ended", net.ErrClosed)
+	errSubStreamReset   = fmt.Errorf("%w: sub-stream was reset", net.ErrClosed)
+	errMalformedMux     = errors.New("malformed multiplexed session message")
+)
+
+// A MuxSession is a long-lived session that multiplexes any number of sub-streams over one gRPC Tunnel stream.
+// Opening a sub-stream costs no round trip, because the client doesn't await a StreamOK, and each sub-stream
+// has a flow control window of its own, so that a slow reader doesn't stall the other sub-streams. Peers of
+// version 3 and below don't support multiplexed sessions.
+type MuxSession struct {
+	grpcStream  GRPCStream
+	tag         string
+	peerVersion uint16
+	compression Compression
+
+	// sendLock serializes the sends on the gRPC stream
+	sendLock sync.Mutex
+
+	lock       sync.Mutex
+	subStreams map[uint32]*subStream
+	nextID     uint32
+	err        error
+	done       chan struct{}
+}
+
+func newMuxSession(tag string, grpcStream GRPCStream) *MuxSession {
+	return &MuxSession{
+		grpcStream: grpcStream,
+		tag:        tag,
+		subStreams: make(map[uint32]*subStream),
+		done:       make(chan struct{}),
+	}
+}
+
+// NewMuxSession opens a multiplexed session on the given gRPC Tunnel stream. The session ends when the
+// stream ends, which, for a gRPC stream, is when the context of its call is cancelled.
+func NewMuxSession(ctx context.Context, grpcStream GRPClientCStream) (*MuxSession, error) {
+	ms := newMuxSession("CLI", grpcStream)
+	offered := GetCompressions(ctx)
+	if err := grpcStream.Send(muxOpenMessage(offered).TunnelMessage()); err != nil {
+		_ = grpcStream.CloseSend()
+		return nil, err
+	}
+	tm, err := grpcStream.Recv()
+	if err != nil {
+		_ = grpcStream.CloseSend()
+		return nil, fmt.Errorf("failed to read initial multiplexed session message: %w", err)
+	}
+	m := msg(tm.Payload)
+	if m.Code() != muxOpen {
+		_ = grpcStream.CloseSend()
+		return nil, errors.New("initial message was not a multiplexed session acknowledgement")
+	}
+	ms.peerVersion = getVersion(m)
+	if c := getCompression(m); c != NoCompression {
+		if negotiateCompression([]Compression{c}, offered) != c {
+			_ = grpcStream.CloseSend()
+			return nil, fmt.Errorf("peer chose compression %s, which wasn't offered", c)
+		}
+		ms.compression = c
+	}
+	dlog.Debugf(ctx, "   %s, multiplexed session established, compression %s", ms.tag, ms.compression)
+	go func() {
+		if err := ms.readLoop(ctx, nil); err != nil {
+			dlog.Errorf(ctx, "!! %s, multiplexed session ended: %v", ms.tag, err)
+		}
+	}()
+	return ms, nil
+}
+
+// AcceptTunnel reads the initial message of a gRPC Tunnel stream. It returns a Stream when the client opened
+// the stream with NewClientStream, and a MuxSession that must be served when the client opened it with
+// NewMuxSession.
+func AcceptTunnel(ctx context.Context, grpcStream GRPCStream) (Stream, *MuxSession, error) {
+	s := &stream{tag: "SRV", grpcStream: grpcStream, syncRatio: 8, ackWindow: 1}
+	m, err := s.Receive(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read initial StreamInfo message: %w", err)
+	}
+	switch m.Code() {
+	case streamInfo:
+		if err = s.accept(ctx, m); err != nil {
+			return nil, nil, err
+		}
+		return s, nil, nil
+	case muxOpen:
+		ms := newMuxSession("SRV", grpcStream)
+		offered, err := getMuxOpenInfo(m, ms)
+		if err != nil {
+			return nil, nil, err
+		}
+		ms.compression = negotiateCompression(offered, GetCompressions(ctx))
+		if err = grpcStream.Send(muxOKMessage(ms.compression).TunnelMessage()); err != nil {
+			return nil, nil, err
+		}
+		dlog.Debugf(ctx, "   %s, multiplexed session established, compression %s", ms.tag, ms.compression)
+		return nil, ms, nil
+	default:
+		return nil, nil, errors.New("initial message was not StreamInfo")
+	}
+}
+
+// Serve dispatches the messages of a session that was returned by AcceptTunnel. The given handler is called
+// in a goroutine of its own for each sub-stream that the client opens, and the sub-stream is reset if it's
+// still open when the handler returns. Serve returns when the session ends.
+func (ms *MuxSession) Serve(ctx context.Context, handler func(context.Context, Stream) error) error {
+	return ms.readLoop(ctx, handler)
+}
+
+// Open opens a sub-stream for the given connection. The sub-stream is reset when the given context is
+// cancelled.
+func (ms *MuxSession) Open(ctx context.Context, id ConnID, sessionID string, callDelay, dialTimeout time.Duration) (Stream, error) {
+	ms.lock.Lock()
+	if ms.err != nil {
+		ms.lock.Unlock()
+		return nil, ms.err
+	}
+	ms.nextID++
+	ss := ms.addSubStream(ms.nextID)
+	ms.lock.Unlock()
+
+	s := &stream{
+		tag:              "MUX",
+		grpcStream:       ss,
+		id:               id,
+		dialTimeout:      dialTimeout,
+		roundtripLatency: callDelay,
+		sessionID:        sessionID,
+		syncRatio:        8,
+		ackWindow:        1,
+		peerVersion:      ms.peerVersion,
+		compression:      ms.compression,
+		total:            getStatsCounter(ctx),
+	}
+	if err := s.Send(ctx, StreamInfoMessage(id, sessionID, callDelay, dialTimeout, nil)); err != nil {
+		ms.reset(ss)
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			ms.reset(ss)
+		case <-ss.done:
+		}
+	}()
+	return s, nil
+}
+
+// Done returns a channel that is closed when the session ends.
+func (ms *MuxSession) Done() <-chan struct{} {
+	return ms.done
+}
+
+// PeerVersion returns the version of the session's peer.
+func (ms *MuxSession) PeerVersion() uint16 {
+	return ms.peerVersion
+}
+
+// addSubStream adds a sub-stream with the given id. Must be called with ms.lock held.
+func (ms *MuxSession) addSubStream(id uint32) *subStream {
+	ss := &subStream{
+		ms:     ms,
+		id:     id,
+		window: muxWindowSize,
+		ready:  make(chan struct{}, 1),
+		credit: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	ms.subStreams[id] = ss
+	return ss
+}
+
+func (ms *MuxSession) getSubStream(id uint32) *subStream {
+	ms.lock.Lock()
+	ss := ms.subStreams[id]
+	ms.lock.Unlock()
+	return ss
+}
+
+func (ms *MuxSession) readLoop(ctx context.Context, handler func(context.Context, Stream) error) error {
+	var err error
+	for {
+		var tm *rpc.TunnelMessage
+		if tm, err = ms.grpcStream.Recv(); err != nil {
+			break
+		}
+		var id uint32
+		var m msg
+		if id, m, err = parseMuxFrame(tm.Payload); err != nil {
+			break
+		}
+		switch m.Code() {
+		case muxWindow:
+			if ss := ms.getSubStream(id); ss != nil {
+				ss.grant(m)
+			}
+		case muxReset:
+			if ss := ms.getSubStream(id); ss != nil {
+				ms.release(ss, errSubStreamReset)
+			}
+		case streamInfo:
+			if handler == nil {
+				// Only clients open sub-streams
+				continue
+			}
+			ms.lock.Lock()
+			ss, ok := ms.subStreams[id]
+			if !ok {
+				ss = ms.addSubStream(id)
+			}
+			ms.lock.Unlock()
+			if !ok {
+				ss.enqueue(m)
+				go ms.serveSubStream(ctx, ss, handler)
+			}
+		default:
+			if ss := ms.getSubStream(id); ss != nil {
+				ss.enqueue(m)
+			}
+		}
+	}
+	ms.close(err)
+	if ctx.Err() != nil || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (ms *MuxSession) serveSubStream(ctx context.Context, ss *subStream, handler func(context.Context, Stream) error) {
+	defer ms.reset(ss)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-ss.done:
+			cancel()
+		}
+	}()
+
+	s := &stream{tag: "SRV", grpcStream: ss, syncRatio: 8, ackWindow: 1, total: getStatsCounter(ctx)}
+	m, err := s.Receive(ctx)
+	if err == nil {
+		_, err = setConnectInfo(m, s)
+	}
+	if err != nil {
+		dlog.Errorf(ctx, "!! %s, failed to parse StreamInfo of sub-stream %d: %v", ms.tag, ss.id, err)
+		return
+	}
+	s.compression = ms.compression
+	if err = handler(ctx, s); err != nil {
+		dlog.Errorf(ctx, "!! %s %s, %v", ms.tag, s.id, err)
+	}
+}
+
+func (ms *MuxSession) sendFrame(id uint32, m msg) error {
+	b := make([]byte, 1+binary.MaxVarintLen32+len(m))
+	b[0] = byte(muxFrame)
+	n := 1 + binary.PutUvarint(b[1:], uint64(id))
+	n += copy(b[n:], m)
+
+	ms.sendLock.Lock()
+	defer ms.sendLock.Unlock()
+	select {
+	case <-ms.done:
+		return ms.err
+	default:
+		return ms.grpcStream.Send(&rpc.TunnelMessage{Payload: b[:n]})
+	}
+}
+
+// reset releases the given sub-stream and tells the peer to do the same, unless it is already released.
+func (ms *MuxSession) reset(ss *subStream) {
+	if ms.release(ss, errSubStreamReset) {
+		_ = ms.sendFrame(ss.id, msg{byte(muxReset)})
+	}
+}
+
+// release removes the sub-stream from the session and makes it return the given error once its queue is
+// drained. It returns false if the sub-stream was already released.
+func (ms *MuxSession) release(ss *subStream, err error) bool {
+	ms.lock.Lock()
+	_, ok := ms.subStreams[ss.id]
+	if ok {
+		delete(ms.subStreams, ss.id)
+	}
+	ms.lock.Unlock()
+	if ok {
+		ss.release(err)
+	}
+	return ok
+}
+
+func (ms *MuxSession) close(err error) {
+	ms.lock.Lock()
+	if ms.err != nil {
+		ms.lock.Unlock()
+		return
+	}
+	ms.err = errMuxSessionClosed
+	if err != nil && !errors.Is(err, io.EOF) {
+		ms.err = fmt.Errorf("%w: %v", errMuxSessionClosed, err)
+	}
+	subStreams := ms.subStreams
+	ms.subStreams = nil
+	ms.lock.Unlock()
+
+	ms.sendLock.Lock()
+	close(ms.done)
+	ms.sendLock.Unlock()
+	for _, ss := range subStreams {
+		ss.release(ms.err)
+	}
+	if cs, ok := ms.grpcStream.(GRPClientCStream); ok {
+		_ = cs.CloseSend()
+	}
+}
+
+// subStream is the GRPCStream of a Stream that is multiplexed over a MuxSession.
+type subStream struct {
+	ms     *MuxSession
+	id     uint32
+	ready  chan struct{} // signalled when a message is queued
+	credit chan struct{} // signalled when the window grows
+	done   chan struct{} // closed when the sub-stream is released
+
+	lock      sync.Mutex
+	queue     []msg
+	err       error
+	window    int64
+	consumed  int64
+	sentClose bool
+	recvClose bool
+}
+
+func isData(m msg) bool {
+	c := m.Code()
+	return c == Normal || c == compressed
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (ss *subStream) enqueue(m msg) {
+	ss.lock.Lock()
+	ss.queue = append(ss.queue, m)
+	bothClosed := false
+	if m.Code() == closeSend {
+		ss.recvClose = true
+		bothClosed = ss.sentClose
+	}
+	ss.lock.Unlock()
+	signal(ss.ready)
+	if bothClosed {
+		ss.ms.release(ss, io.EOF)
+	}
+}
+
+func (ss *subStream) grant(m msg) {
+	v, n := binary.Uvarint(m.Payload())
+	if n <= 0 {
+		return
+	}
+	ss.lock.Lock()
+	ss.window += int64(v)
+	ss.lock.Unlock()
+	signal(ss.credit)
+}
+
+func (ss *subStream) release(err error) {
+	ss.lock.Lock()
+	if ss.err == nil {
+		ss.err = err
+		close(ss.done)
+	}
+	ss.lock.Unlock()
+}
+
+func (ss *subStream) Recv() (*rpc.TunnelMessage, error) {
+	for {
+		ss.lock.Lock()
+		if len(ss.queue) > 0 {
+			m := ss.queue[0]
+			ss.queue[0] = nil
+			ss.queue = ss.queue[1:]
+			var grant int64
+			if isData(m) {
+				ss.consumed += int64(len(m.Payload()))
+				if ss.consumed >= muxWindowSize/2 {
+					grant = ss.consumed
+					ss.consumed = 0
+				}
+			}
+			ss.lock.Unlock()
+			if grant > 0 {
+				_ = ss.ms.sendFrame(ss.id, windowMessage(grant))
+			}
+			return m.TunnelMessage(), nil
+		}
+		err := ss.err
+		ss.lock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case <-ss.ready:
+		case <-ss.done:
+		}
+	}
+}
+
+func (ss *subStream) Send(tm *rpc.TunnelMessage) error {
+	m := msg(tm.Payload)
+	for {
+		ss.lock.Lock()
+		if ss.err != nil {
+			err := ss.err
+			ss.lock.Unlock()
+			return err
+		}
+		if !isData(m) || ss.window > 0 {
+			if isData(m) {
+				ss.window -= int64(len(m.Payload()))
+			}
+			ss.lock.Unlock()
+			break
+		}
+		ss.lock.Unlock()
+		select {
+		case <-ss.credit:
+		case <-ss.done:
+		}
+	}
+	if err := ss.ms.sendFrame(ss.id, m); err != nil {
+		return err
+	}
+	if m.Code() == closeSend {
+		ss.lock.Lock()
+		ss.sentClose = true
+		bothClosed := ss.recvClose
+		ss.lock.Unlock()
+		if bothClosed {
+			ss.ms.release(ss, io.EOF)
+		}
+	}
+	return nil
+}
+
+func muxOpenMessage(compressions []Compression) msg {
+	b := bytes.Buffer{}
+	b.WriteByte(byte(muxOpen))
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(Version))
+	b.Write(buf[:n])
+	n = binary.PutUvarint(buf, uint64(len(compressions)))
+	b.Write(buf[:n])
+	for _, c := range compressions {
+		b.WriteByte(byte(c))
+	}
+	return b.Bytes()
+}
+
+// muxOKMessage has the same layout as a StreamOK message.
+func muxOKMessage(c Compression) msg {
+	m := StreamOKMessage(c).(msg)
+	m[0] = byte(muxOpen)
+	return m
+}
+
+// getMuxOpenInfo assigns the peer version of the given muxOpen message to the session and returns the
+// compressions that the peer offers.
+func getMuxOpenInfo(m Message, ms *MuxSession) ([]Compression, error) {
+	pl := m.Payload()
+	v, n := binary.Uvarint(pl)
+	if n <= 0 {
+		return nil, errMalformedMux
+	}
+	ms.peerVersion = uint16(v)
+	pl = pl[n:]
+	v, n = binary.Uvarint(pl)
+	if n <= 0 || v > uint64(len(pl)-n) {
+		return nil, errMalformedMux
+	}
+	pl = pl[n:]
+	cs := make([]Compression, v)
+	for i := range cs {
+		cs[i] = Compression(pl[i])
+	}
+	return cs, nil
+}
+
+func windowMessage(grant int64) msg {
+	m := makeMessage(muxWindow, binary.MaxVarintLen64)
+	n := binary.PutUvarint(m.Payload(), uint64(grant))
+	return m[:n+1]
+}
+
+func parseMuxFrame(b []byte) (uint32, msg, error) {
+	if len(b) < 3 || MessageCode(b[0]) != muxFrame {
+		return 0, nil, errMalformedMux
+	}
+	v, n := binary.Uvarint(b[1:])
+	if n <= 0 || v > 0xffffffff || 1+n >= len(b) {
+		return 0, nil, errMalformedMux
+	}
+	return uint32(v), msg(b[1+n:]), nil
+}
@@ -0,0 +1,194 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+func muxConnID(port uint16) ConnID {
+	return NewConnID(ipproto.TCP, iputil.Parse("127.0.0.1"), iputil.Parse("192.168.0.1"), port, 8080)
+}
+
+// startMuxSession establishes a multiplexed session and serves it with the given handler.
+func startMuxSession(ctx context.Context, t *testing.T, handler func(context.Context, Stream) error) *MuxSession {
+	tunnel := newBidi(100, ctx.Done())
+	go func() {
+		s, ms, err := AcceptTunnel(ctx, tunnel.serverSide())
+		if !assert.NoError(t, err) || !assert.Nil(t, s) {
+			return
+		}
+		assert.NoError(t, ms.Serve(ctx, handler))
+	}()
+	ms, err := NewMuxSession(ctx, tunnel.clientSide())
+	require.NoError(t, err)
+	assert.Equal(t, Version, ms.PeerVersion())
+	return ms
+}
+
+// echo replies to each message with the same message.
+func echo(ctx context.Context, s Stream) error {
+	for {
+		m, err := s.Receive(ctx)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return s.CloseSend(ctx)
+			}
+			return err
+		}
+		if err = s.Send(ctx, m); err != nil {
+			return err
+		}
+	}
+}
+
+func TestMuxSession(t *testing.T) {
+	ctx, cancel := testContext(t, 10*time.Second)
+	defer cancel()
+	ms := startMuxSession(ctx, t, echo)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := muxConnID(uint16(1000 + i))
+			s, err := ms.Open(ctx, id, "session", 0, 0)
+			if !assert.NoError(t, err) {
+				return
+			}
+			payload := bytes.Repeat([]byte{byte(i)}, 100*i)
+			for j := 0; j < 5; j++ {
+				if !assert.NoError(t, s.Send(ctx, NewMessage(Normal, payload))) {
+					return
+				}
+				m, err := s.Receive(ctx)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, payload, m.Payload())
+			}
+			if !assert.NoError(t, s.CloseSend(ctx)) {
+				return
+			}
+			_, err = s.Receive(ctx)
+			assert.ErrorIs(t, err, net.ErrClosed)
+		}(i)
+	}
+	wg.Wait()
+
+	// Fully closed sub-streams are removed from the session
+	assert.Eventually(t, func() bool {
+		ms.lock.Lock()
+		defer ms.lock.Unlock()
+		return len(ms.subStreams) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMuxSession_FlowControl(t *testing.T) {
+	ctx, cancel := testContext(t, 10*time.Second)
+	defer cancel()
+
+	blocked := muxConnID(1001)
+	release := make(chan struct{})
+	ms := startMuxSession(ctx, t, func(ctx context.Context, s Stream) error {
+		if s.ID() == blocked {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-release:
+			}
+		}
+		return echo(ctx, s)
+	})
+
+	s1, err := ms.Open(ctx, blocked, "session", 0, 0)
+	require.NoError(t, err)
+
+	// The chunks are random, so that compression doesn't shrink them
+	chunk := make([]byte, muxWindowSize/8)
+	rand.Read(chunk)
+	for i := 0; i < 8; i++ {
+		require.NoError(t, s1.Send(ctx, NewMessage(Normal, chunk)))
+	}
+	sent := make(chan error, 1)
+	go func() { sent <- s1.Send(ctx, NewMessage(Normal, chunk)) }()
+
+	// The window of the first stream is exhausted, but other streams aren't affected
+	s2, err := ms.Open(ctx, muxConnID(1002), "session", 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, s2.Send(ctx, NewMessage(Normal, []byte("hello"))))
+	m, err := s2.Receive(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(m.Payload()))
+	select {
+	case err = <-sent:
+		t.Fatalf("send didn't block on exhausted window: %v", err)
+	default:
+	}
+
+	// Reading grants a new window
+	close(release)
+	for i := 0; i < 9; i++ {
+		_, err = s1.Receive(ctx)
+		require.NoError(t, err)
+		if i == 0 {
+			require.NoError(t, <-sent)
+		}
+	}
+}
+
+func TestMuxSession_Reset(t *testing.T) {
+	ctx, cancel := testContext(t, 10*time.Second)
+	defer cancel()
+
+	received := make(chan error, 1)
+	ms := startMuxSession(ctx, t, func(ctx context.Context, s Stream) error {
+		_, err := s.Receive(ctx)
+		received <- err
+		return nil
+	})
+
+	// Cancelling the context of a sub-stream resets it on both ends
+	sctx, scancel := context.WithCancel(ctx)
+	s, err := ms.Open(sctx, muxConnID(1001), "session", 0, 0)
+	require.NoError(t, err)
+	scancel()
+	assert.ErrorIs(t, <-received, net.ErrClosed)
+	_, err = s.Receive(ctx)
+	assert.ErrorIs(t, err, net.ErrClosed)
+
+	// A sub-stream is reset when the handler returns
+	s, err = ms.Open(ctx, muxConnID(1002), "session", 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Send(ctx, NewMessage(Normal, []byte("hello"))))
+	require.NoError(t, <-received)
+	_, err = s.Receive(ctx)
+	assert.ErrorIs(t, err, errSubStreamReset)
+}
+
+func TestMuxSession_OldPeer(t *testing.T) {
+	ctx, cancel := testContext(t, 5*time.Second)
+	defer cancel()
+
+	// A peer of version 3 and below doesn't accept the session, and its Tunnel call ends
+	tunnel := newBidi(10, ctx.Done())
+	go func() {
+		_, err := NewServerStream(ctx, tunnel.serverSide())
+		assert.Error(t, err)
+		_ = tunnel.sToC.close()
+	}()
+	_, err := NewMuxSession(ctx, tunnel.clientSide())
+	assert.Error(t, err)
+}
@@ -7,7 +7,7 @@ import (
 )
 
 func NewServerStream(ctx context.Context, grpcStream GRPCStream) (Stream, error) {
-	s := &stream{tag: "SRV", grpcStream: grpcStream, syncRatio: 8, ackWindow: 1}
+	s := &stream{tag: "SRV", grpcStream: grpcStream, syncRatio: 8, ackWindow: 1, total: getStatsCounter(ctx)}
 	m, err := s.Receive(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read initial StreamInfo message: %w", err)
@@ -15,11 +15,18 @@ func NewServerStream(ctx context.Context, grpcStream GRPCStream) (Stream, error)
 	if m.Code() != streamInfo {
 		return nil, errors.New("initial message was not StreamInfo")
 	}
-	if err = setConnectInfo(m, s); err != nil {
-		return nil, fmt.Errorf("failed to parse StreamInfo message: %w", err)
-	}
-	if err = s.Send(ctx, StreamOKMessage()); err != nil {
+	if err = s.accept(ctx, m); err != nil {
 		return nil, err
 	}
 	return s, nil
 }
+
+// accept parses the given StreamInfo message and responds with a StreamOK message.
+func (s *stream) accept(ctx context.Context, m Message) error {
+	offered, err := setConnectInfo(m, s)
+	if err != nil {
+		return fmt.Errorf("failed to parse StreamInfo message: %w", err)
+	}
+	s.compression = negotiateCompression(offered, GetCompressions(ctx))
+	return s.Send(ctx, StreamOKMessage(s.compression))
+}
@@ -15,7 +15,9 @@ import (
 // Version
 //   0 which didn't report versions and didn't do synchronization
 //   1 used MuxTunnel instead of one tunnel per connection.
-const Version = uint16(2)
+//   2 didn't negotiate compression.
+//   3 didn't support multiplexed sessions.
+const Version = uint16(4)
 
 // Endpoint is an endpoint for a Stream such as a Dialer or a bidirectional pipe.
 type Endpoint interface {
@@ -61,6 +63,7 @@ type Stream interface {
 	SessionID() string
 	DialTimeout() time.Duration
 	RoundtripLatency() time.Duration
+	Stats() Stats
 }
 
 // ReadLoop reads from the Stream and dispatches messages and error to the give channels. There
@@ -125,6 +128,12 @@ func WriteLoop(ctx context.Context, s Stream, msgCh <-chan Message) {
 }
 
 type stream struct {
+	// payload byte counters, accessed atomically and therefore first in the struct to ensure 64-bit alignment
+	counters
+
+	// total, when not nil, accumulates the payload byte counts of this stream and others
+	total *StatsCounter
+
 	grpcStream       GRPCStream
 	id               ConnID
 	dialTimeout      time.Duration
@@ -134,6 +143,7 @@ type stream struct {
 	syncRatio        uint32 // send and check sync after each syncRatio message
 	ackWindow        uint32 // maximum permitted difference between sent and received ack
 	peerVersion      uint16
+	compression      Compression
 }
 
 func newStream(tag string, grpcStream GRPCStream) stream {
@@ -164,13 +174,42 @@ func (s *stream) SessionID() string {
 	return s.sessionID
 }
 
+func (s *stream) Stats() Stats {
+	return s.stats(s.compression)
+}
+
+func (s *stream) addSent(n, wire int) {
+	s.counters.addSent(n, wire)
+	if s.total != nil {
+		s.total.addSent(n, wire)
+	}
+}
+
+func (s *stream) addReceived(n, wire int) {
+	s.counters.addReceived(n, wire)
+	if s.total != nil {
+		s.total.addReceived(n, wire)
+	}
+}
+
 func (s *stream) Receive(ctx context.Context) (Message, error) {
 	cm, err := s.grpcStream.Recv()
 	if err != nil {
 		return nil, err
 	}
-	m := msg(cm.Payload)
+	var m Message = msg(cm.Payload)
 	switch m.Code() {
+	case Normal:
+		n := len(m.Payload())
+		s.addReceived(n, n)
+		dlog.Tracef(ctx, "<- %s %s, %s", s.tag, s.id, m)
+	case compressed:
+		wire := len(m.Payload())
+		if m, err = decompressMessage(s.compression, m); err != nil {
+			return nil, err
+		}
+		s.addReceived(len(m.Payload()), wire)
+		dlog.Tracef(ctx, "<- %s %s, %s, compressed", s.tag, s.id, m)
 	case closeSend:
 		dlog.Tracef(ctx, "<- %s %s, close send", s.tag, s.id)
 		return nil, net.ErrClosed
@@ -183,13 +222,20 @@ func (s *stream) Receive(ctx context.Context) (Message, error) {
 }
 
 func (s *stream) Send(ctx context.Context, m Message) error {
-	if err := s.grpcStream.Send(m.TunnelMessage()); err != nil {
+	wm := m
+	if m.Code() == Normal {
+		wm = compressMessage(s.compression, m)
+	}
+	if err := s.grpcStream.Send(wm.TunnelMessage()); err != nil {
 		if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
 			dlog.Errorf(ctx, "!! %s %s, Send failed: %v", s.tag, s.id, err)
 		}
 		return err
 	}
-	dlog.Tracef(ctx, "-> %s %s, %s", s.tag, s.id, m)
+	if m.Code() == Normal {
+		s.addSent(len(m.Payload()), len(wm.Payload()))
+	}
+	dlog.Tracef(ctx, "-> %s %s, %s", s.tag, s.id, wm)
 	return nil
 }
 
@@ -37,7 +37,15 @@ func openTun(ctx context.Context) (td *Device, err error) {
 			}
 		}
 	}()
+	// Use the first free name in the tel0, tel1, ... sequence. The daemon creates one device
+	// per connection.
 	interfaceName := "tel0"
+	for i := 1; ; i++ {
+		if _, err := net.InterfaceByName(interfaceName); err != nil {
+			break
+		}
+		interfaceName = fmt.Sprintf("tel%d", i)
+	}
 	td = &Device{}
 	if td.Device, err = tun.CreateTUN(interfaceName, 0); err != nil {
 		return nil, fmt.Errorf("failed to create TUN device: %w", err)
@@ -296,6 +296,7 @@ func (h *handler) Start(ctx context.Context) {
 				_ = h.sendConnControl(ctx, connpool.Disconnect)
 			} else if h.stream != nil {
 				_ = h.stream.CloseSend(ctx)
+				dlog.Debugf(ctx, "   CON %s, %s", h.id, h.stream.Stats())
 			}
 		}()
 		h.processPackets(ctx)
@@ -33,12 +33,18 @@ type ConnectorClient interface {
 	// Status is much like Connect, except that it doesn't actually do
 	// anything.  It's a dry-run.
 	Status(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectInfo, error)
+	// Returns the status of all connections. Each connection is reported
+	// in the same way as Status reports it.
+	ListConnections(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ConnectionList, error)
 	// Adds an intercept to a workload.  Requires having already called
 	// Connect.
 	CreateIntercept(ctx context.Context, in *CreateInterceptRequest, opts ...grpc.CallOption) (*InterceptResult, error)
 	// Deactivates and removes an existent workload intercept.
 	// Requires having already called Connect.
 	RemoveIntercept(ctx context.Context, in *manager.RemoveInterceptRequest2, opts ...grpc.CallOption) (*InterceptResult, error)
+	// Hands an existent workload intercept over to another client.
+	// Requires having already called Connect.
+	TransferIntercept(ctx context.Context, in *manager.TransferInterceptRequest, opts ...grpc.CallOption) (*InterceptResult, error)
 	// Uninstalls traffic-agents and traffic-manager from the cluster.
 	// Requires having already called Connect.
 	Uninstall(ctx context.Context, in *UninstallRequest, opts ...grpc.CallOption) (*UninstallResult, error)
@@ -95,6 +101,15 @@ func (c *connectorClient) Status(ctx context.Context, in *ConnectRequest, opts .
 	return out, nil
 }
 
+func (c *connectorClient) ListConnections(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ConnectionList, error) {
+	out := new(ConnectionList)
+	err := c.cc.Invoke(ctx, "/telepresence.connector.Connector/ListConnections", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *connectorClient) CreateIntercept(ctx context.Context, in *CreateInterceptRequest, opts ...grpc.CallOption) (*InterceptResult, error) {
 	out := new(InterceptResult)
 	err := c.cc.Invoke(ctx, "/telepresence.connector.Connector/CreateIntercept", in, out, opts...)
@@ -113,6 +128,15 @@ func (c *connectorClient) RemoveIntercept(ctx context.Context, in *manager.Remov
 	return out, nil
 }
 
+func (c *connectorClient) TransferIntercept(ctx context.Context, in *manager.TransferInterceptRequest, opts ...grpc.CallOption) (*InterceptResult, error) {
+	out := new(InterceptResult)
+	err := c.cc.Invoke(ctx, "/telepresence.connector.Connector/TransferIntercept", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *connectorClient) Uninstall(ctx context.Context, in *UninstallRequest, opts ...grpc.CallOption) (*UninstallResult, error) {
 	out := new(UninstallResult)
 	err := c.cc.Invoke(ctx, "/telepresence.connector.Connector/Uninstall", in, out, opts...)
@@ -242,12 +266,18 @@ type ConnectorServer interface {
 	// Status is much like Connect, except that it doesn't actually do
 	// anything.  It's a dry-run.
 	Status(context.Context, *ConnectRequest) (*ConnectInfo, error)
+	// Returns the status of all connections. Each connection is reported
+	// in the same way as Status reports it.
+	ListConnections(context.Context, *emptypb.Empty) (*ConnectionList, error)
 	// Adds an intercept to a workload.  Requires having already called
 	// Connect.
 	CreateIntercept(context.Context, *CreateInterceptRequest) (*InterceptResult, error)
 	// Deactivates and removes an existent workload intercept.
 	// Requires having already called Connect.
 	RemoveIntercept(context.Context, *manager.RemoveInterceptRequest2) (*InterceptResult, error)
+	// Hands an existent workload intercept over to another client.
+	// Requires having already called Connect.
+	TransferIntercept(context.Context, *manager.TransferInterceptRequest) (*InterceptResult, error)
 	// Uninstalls traffic-agents and traffic-manager from the cluster.
 	// Requires having already called Connect.
 	Uninstall(context.Context, *UninstallRequest) (*UninstallResult, error)
@@ -283,12 +313,18 @@ func (UnimplementedConnectorServer) Connect(context.Context, *ConnectRequest) (*
 func (UnimplementedConnectorServer) Status(context.Context, *ConnectRequest) (*ConnectInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
 }
+func (UnimplementedConnectorServer) ListConnections(context.Context, *emptypb.Empty) (*ConnectionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConnections not implemented")
+}
 func (UnimplementedConnectorServer) CreateIntercept(context.Context, *CreateInterceptRequest) (*InterceptResult, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateIntercept not implemented")
 }
 func (UnimplementedConnectorServer) RemoveIntercept(context.Context, *manager.RemoveInterceptRequest2) (*InterceptResult, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveIntercept not implemented")
 }
+func (UnimplementedConnectorServer) TransferIntercept(context.Context, *manager.TransferInterceptRequest) (*InterceptResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransferIntercept not implemented")
+}
 func (UnimplementedConnectorServer) Uninstall(context.Context, *UninstallRequest) (*UninstallResult, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Uninstall not implemented")
 }
@@ -386,6 +422,24 @@ func _Connector_Status_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_ListConnections_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).ListConnections(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.connector.Connector/ListConnections",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).ListConnections(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Connector_CreateIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateInterceptRequest)
 	if err := dec(in); err != nil {
@@ -422,6 +476,24 @@ func _Connector_RemoveIntercept_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_TransferIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(manager.TransferInterceptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).TransferIntercept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.connector.Connector/TransferIntercept",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).TransferIntercept(ctx, req.(*manager.TransferInterceptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Connector_Uninstall_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UninstallRequest)
 	if err := dec(in); err != nil {
@@ -624,6 +696,10 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Status",
 			Handler:    _Connector_Status_Handler,
 		},
+		{
+			MethodName: "ListConnections",
+			Handler:    _Connector_ListConnections_Handler,
+		},
 		{
 			MethodName: "CreateIntercept",
 			Handler:    _Connector_CreateIntercept_Handler,
@@ -632,6 +708,10 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveIntercept",
 			Handler:    _Connector_RemoveIntercept_Handler,
 		},
+		{
+			MethodName: "TransferIntercept",
+			Handler:    _Connector_TransferIntercept_Handler,
+		},
 		{
 			MethodName: "Uninstall",
 			Handler:    _Connector_Uninstall_Handler,
@@ -35,6 +35,18 @@ type DaemonClient interface {
 	SetDnsSearchPath(ctx context.Context, in *Paths, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// SetLogLevel will temporarily set the log-level for the daemon for a duration that is determined b the request.
 	SetLogLevel(ctx context.Context, in *manager.LogLevelRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// RunDiagnostics performs the daemon's self-checks, such as verifying that the
+	// cluster subnets don't conflict with local routes, and returns the results.
+	RunDiagnostics(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*manager.DiagnosticResults, error)
+	// WatchNotifications streams messages that the daemon wants to convey to the user, such as
+	// warnings about cluster subnets that conflict with subnets that are routed locally.
+	WatchNotifications(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (Daemon_WatchNotificationsClient, error)
+	// SetNetworkShaping sets the network conditions that are simulated for the outbound
+	// traffic to the cluster.
+	SetNetworkShaping(ctx context.Context, in *NetworkShaping, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// RemoveConnection releases the router of the connection that is named in the metadata of
+	// the call, along with the routes and the TUN device that the router uses.
+	RemoveConnection(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type daemonClient struct {
@@ -108,6 +120,65 @@ func (c *daemonClient) SetLogLevel(ctx context.Context, in *manager.LogLevelRequ
 	return out, nil
 }
 
+func (c *daemonClient) RunDiagnostics(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*manager.DiagnosticResults, error) {
+	out := new(manager.DiagnosticResults)
+	err := c.cc.Invoke(ctx, "/telepresence.daemon.Daemon/RunDiagnostics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *daemonClient) WatchNotifications(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (Daemon_WatchNotificationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Daemon_ServiceDesc.Streams[0], "/telepresence.daemon.Daemon/WatchNotifications", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &daemonWatchNotificationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Daemon_WatchNotificationsClient interface {
+	Recv() (*Notification, error)
+	grpc.ClientStream
+}
+
+type daemonWatchNotificationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *daemonWatchNotificationsClient) Recv() (*Notification, error) {
+	m := new(Notification)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *daemonClient) SetNetworkShaping(ctx context.Context, in *NetworkShaping, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/telepresence.daemon.Daemon/SetNetworkShaping", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *daemonClient) RemoveConnection(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/telepresence.daemon.Daemon/RemoveConnection", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DaemonServer is the server API for Daemon service.
 // All implementations must embed UnimplementedDaemonServer
 // for forward compatibility
@@ -126,6 +197,18 @@ type DaemonServer interface {
 	SetDnsSearchPath(context.Context, *Paths) (*emptypb.Empty, error)
 	// SetLogLevel will temporarily set the log-level for the daemon for a duration that is determined b the request.
 	SetLogLevel(context.Context, *manager.LogLevelRequest) (*emptypb.Empty, error)
+	// RunDiagnostics performs the daemon's self-checks, such as verifying that the
+	// cluster subnets don't conflict with local routes, and returns the results.
+	RunDiagnostics(context.Context, *emptypb.Empty) (*manager.DiagnosticResults, error)
+	// WatchNotifications streams messages that the daemon wants to convey to the user, such as
+	// warnings about cluster subnets that conflict with subnets that are routed locally.
+	WatchNotifications(*emptypb.Empty, Daemon_WatchNotificationsServer) error
+	// SetNetworkShaping sets the network conditions that are simulated for the outbound
+	// traffic to the cluster.
+	SetNetworkShaping(context.Context, *NetworkShaping) (*emptypb.Empty, error)
+	// RemoveConnection releases the router of the connection that is named in the metadata of
+	// the call, along with the routes and the TUN device that the router uses.
+	RemoveConnection(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
 	mustEmbedUnimplementedDaemonServer()
 }
 
@@ -154,6 +237,18 @@ func (UnimplementedDaemonServer) SetDnsSearchPath(context.Context, *Paths) (*emp
 func (UnimplementedDaemonServer) SetLogLevel(context.Context, *manager.LogLevelRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
 }
+func (UnimplementedDaemonServer) RunDiagnostics(context.Context, *emptypb.Empty) (*manager.DiagnosticResults, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunDiagnostics not implemented")
+}
+func (UnimplementedDaemonServer) WatchNotifications(*emptypb.Empty, Daemon_WatchNotificationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchNotifications not implemented")
+}
+func (UnimplementedDaemonServer) SetNetworkShaping(context.Context, *NetworkShaping) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetNetworkShaping not implemented")
+}
+func (UnimplementedDaemonServer) RemoveConnection(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveConnection not implemented")
+}
 func (UnimplementedDaemonServer) mustEmbedUnimplementedDaemonServer() {}
 
 // UnsafeDaemonServer may be embedded to opt out of forward compatibility for this service.
@@ -293,6 +388,81 @@ func _Daemon_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Daemon_RunDiagnostics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DaemonServer).RunDiagnostics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.daemon.Daemon/RunDiagnostics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DaemonServer).RunDiagnostics(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Daemon_WatchNotifications_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(emptypb.Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DaemonServer).WatchNotifications(m, &daemonWatchNotificationsServer{stream})
+}
+
+type Daemon_WatchNotificationsServer interface {
+	Send(*Notification) error
+	grpc.ServerStream
+}
+
+type daemonWatchNotificationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *daemonWatchNotificationsServer) Send(m *Notification) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Daemon_SetNetworkShaping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NetworkShaping)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DaemonServer).SetNetworkShaping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.daemon.Daemon/SetNetworkShaping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DaemonServer).SetNetworkShaping(ctx, req.(*NetworkShaping))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Daemon_RemoveConnection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DaemonServer).RemoveConnection(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.daemon.Daemon/RemoveConnection",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DaemonServer).RemoveConnection(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Daemon_ServiceDesc is the grpc.ServiceDesc for Daemon service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -328,7 +498,25 @@ var Daemon_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SetLogLevel",
 			Handler:    _Daemon_SetLogLevel_Handler,
 		},
+		{
+			MethodName: "RunDiagnostics",
+			Handler:    _Daemon_RunDiagnostics_Handler,
+		},
+		{
+			MethodName: "SetNetworkShaping",
+			Handler:    _Daemon_SetNetworkShaping_Handler,
+		},
+		{
+			MethodName: "RemoveConnection",
+			Handler:    _Daemon_RemoveConnection_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchNotifications",
+			Handler:       _Daemon_WatchNotifications_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "rpc/daemon/daemon.proto",
 }
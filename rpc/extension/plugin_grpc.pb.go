@@ -0,0 +1,192 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package extension
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// MechanismPluginClient is the client API for MechanismPlugin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MechanismPluginClient interface {
+	// ValidateArgs validates the mechanism args of an intercept, and
+	// returns them normalized along with a human-friendly description.
+	// An InvalidArgument status means that the args are invalid.
+	ValidateArgs(ctx context.Context, in *MechanismArgs, opts ...grpc.CallOption) (*ValidatedArgs, error)
+	// StartIntercept is called before the intercept is created, so
+	// that the plugin can start client-side services, such as a local
+	// proxy, that must be in place before the intercept becomes
+	// ACTIVE.
+	StartIntercept(ctx context.Context, in *StartInterceptRequest, opts ...grpc.CallOption) (*StartInterceptResponse, error)
+	// StopIntercept is called when the intercept ends, or when it
+	// couldn't be created.
+	StopIntercept(ctx context.Context, in *StopInterceptRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type mechanismPluginClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMechanismPluginClient(cc grpc.ClientConnInterface) MechanismPluginClient {
+	return &mechanismPluginClient{cc}
+}
+
+func (c *mechanismPluginClient) ValidateArgs(ctx context.Context, in *MechanismArgs, opts ...grpc.CallOption) (*ValidatedArgs, error) {
+	out := new(ValidatedArgs)
+	err := c.cc.Invoke(ctx, "/telepresence.extension.MechanismPlugin/ValidateArgs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mechanismPluginClient) StartIntercept(ctx context.Context, in *StartInterceptRequest, opts ...grpc.CallOption) (*StartInterceptResponse, error) {
+	out := new(StartInterceptResponse)
+	err := c.cc.Invoke(ctx, "/telepresence.extension.MechanismPlugin/StartIntercept", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mechanismPluginClient) StopIntercept(ctx context.Context, in *StopInterceptRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/telepresence.extension.MechanismPlugin/StopIntercept", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MechanismPluginServer is the server API for MechanismPlugin service.
+// All implementations must embed UnimplementedMechanismPluginServer
+// for forward compatibility
+type MechanismPluginServer interface {
+	// ValidateArgs validates the mechanism args of an intercept, and
+	// returns them normalized along with a human-friendly description.
+	// An InvalidArgument status means that the args are invalid.
+	ValidateArgs(context.Context, *MechanismArgs) (*ValidatedArgs, error)
+	// StartIntercept is called before the intercept is created, so
+	// that the plugin can start client-side services, such as a local
+	// proxy, that must be in place before the intercept becomes
+	// ACTIVE.
+	StartIntercept(context.Context, *StartInterceptRequest) (*StartInterceptResponse, error)
+	// StopIntercept is called when the intercept ends, or when it
+	// couldn't be created.
+	StopIntercept(context.Context, *StopInterceptRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedMechanismPluginServer()
+}
+
+// UnimplementedMechanismPluginServer must be embedded to have forward compatible implementations.
+type UnimplementedMechanismPluginServer struct {
+}
+
+func (UnimplementedMechanismPluginServer) ValidateArgs(context.Context, *MechanismArgs) (*ValidatedArgs, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateArgs not implemented")
+}
+func (UnimplementedMechanismPluginServer) StartIntercept(context.Context, *StartInterceptRequest) (*StartInterceptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartIntercept not implemented")
+}
+func (UnimplementedMechanismPluginServer) StopIntercept(context.Context, *StopInterceptRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopIntercept not implemented")
+}
+func (UnimplementedMechanismPluginServer) mustEmbedUnimplementedMechanismPluginServer() {}
+
+// UnsafeMechanismPluginServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MechanismPluginServer will
+// result in compilation errors.
+type UnsafeMechanismPluginServer interface {
+	mustEmbedUnimplementedMechanismPluginServer()
+}
+
+func RegisterMechanismPluginServer(s grpc.ServiceRegistrar, srv MechanismPluginServer) {
+	s.RegisterService(&MechanismPlugin_ServiceDesc, srv)
+}
+
+func _MechanismPlugin_ValidateArgs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MechanismArgs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MechanismPluginServer).ValidateArgs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.extension.MechanismPlugin/ValidateArgs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MechanismPluginServer).ValidateArgs(ctx, req.(*MechanismArgs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MechanismPlugin_StartIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartInterceptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MechanismPluginServer).StartIntercept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.extension.MechanismPlugin/StartIntercept",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MechanismPluginServer).StartIntercept(ctx, req.(*StartInterceptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MechanismPlugin_StopIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopInterceptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MechanismPluginServer).StopIntercept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.extension.MechanismPlugin/StopIntercept",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MechanismPluginServer).StopIntercept(ctx, req.(*StopInterceptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MechanismPlugin_ServiceDesc is the grpc.ServiceDesc for MechanismPlugin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MechanismPlugin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "telepresence.extension.MechanismPlugin",
+	HandlerType: (*MechanismPluginServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ValidateArgs",
+			Handler:    _MechanismPlugin_ValidateArgs_Handler,
+		},
+		{
+			MethodName: "StartIntercept",
+			Handler:    _MechanismPlugin_StartIntercept_Handler,
+		},
+		{
+			MethodName: "StopIntercept",
+			Handler:    _MechanismPlugin_StopIntercept_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "rpc/extension/plugin.proto",
+}
@@ -32,6 +32,10 @@ type ManagerClient interface {
 	GetCloudConfig(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*AmbassadorCloudConfig, error)
 	// GetTelepresenceAPI returns information about the TelepresenceAPI server
 	GetTelepresenceAPI(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*TelepresenceAPIInfo, error)
+	// GetPreviewGateway returns information about the preview gateway of the traffic-manager
+	GetPreviewGateway(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PreviewGateway, error)
+	// GetClientPolicy returns the cluster-wide client policy
+	GetClientPolicy(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ClientPolicy, error)
 	// ArriveAsClient establishes a session between a client and the Manager.
 	ArriveAsClient(ctx context.Context, in *ClientInfo, opts ...grpc.CallOption) (*SessionInfo, error)
 	// ArriveAsAgent establishes a session between an agent and the Manager.
@@ -47,6 +51,26 @@ type ManagerClient interface {
 	// GetLogs will acquire logs for the various Telepresence components in kubernetes
 	// (pending the request) and return them to the caller
 	GetLogs(ctx context.Context, in *GetLogsRequest, opts ...grpc.CallOption) (*LogsResponse, error)
+	// WatchLogs streams the logs of the various Telepresence components in kubernetes
+	// (pending the request), one line at a time. Lines from different pods are
+	// interleaved in the order that they arrive.
+	WatchLogs(ctx context.Context, in *WatchLogsRequest, opts ...grpc.CallOption) (Manager_WatchLogsClient, error)
+	// RunDiagnostics performs the traffic-manager's self-checks, such as verifying
+	// that the Kubernetes API server can be reached, and returns the results.
+	RunDiagnostics(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DiagnosticResults, error)
+	// AdminListSessions returns all client and agent sessions. Requires
+	// admin permission.
+	AdminListSessions(ctx context.Context, in *AdminRequest, opts ...grpc.CallOption) (*AdminSessionList, error)
+	// AdminRemoveIntercept removes an intercept regardless of what client
+	// session it belongs to. The client is notified. Requires admin permission.
+	AdminRemoveIntercept(ctx context.Context, in *AdminRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// AdminExpireSession removes a client or agent session along with its
+	// intercepts. A client is notified. Requires admin permission.
+	AdminExpireSession(ctx context.Context, in *AdminRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// WatchNotifications streams messages that are intended for the user of
+	// the given client session, such as notices about intercepts that an admin
+	// removed. The stream ends when the session ends.
+	WatchNotifications(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchNotificationsClient, error)
 	// WatchAgents notifies a client of the set of known Agents.
 	//
 	// A session ID is required; if no session ID is given then the call
@@ -62,6 +86,9 @@ type ManagerClient interface {
 	// WatchClusterInfo returns information needed when establishing
 	// connectivity to the cluster.
 	WatchClusterInfo(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchClusterInfoClient, error)
+	// GetEgressStatus returns the egress rules of the given client session
+	// together with the traffic-agents that the rules currently select.
+	GetEgressStatus(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (*EgressRuleStatusList, error)
 	// CreateIntercept lets a client create an intercept.  It will be
 	// created in the "WATING" disposition, and it will remain in that
 	// state until the Agent (the app-sidecar) calls ReviewIntercept()
@@ -70,6 +97,9 @@ type ManagerClient interface {
 	CreateIntercept(ctx context.Context, in *CreateInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
 	// RemoveIntercept lets a client remove an intercept.
 	RemoveIntercept(ctx context.Context, in *RemoveInterceptRequest2, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// TransferIntercept lets a client hand one of its intercepts over to
+	// another client without interrupting the intercepted traffic.
+	TransferIntercept(ctx context.Context, in *TransferInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
 	UpdateIntercept(ctx context.Context, in *UpdateInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
 	// GetIntercept gets info from intercept name
 	GetIntercept(ctx context.Context, in *GetInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
@@ -165,6 +195,24 @@ func (c *managerClient) GetTelepresenceAPI(ctx context.Context, in *emptypb.Empt
 	return out, nil
 }
 
+func (c *managerClient) GetPreviewGateway(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PreviewGateway, error) {
+	out := new(PreviewGateway)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/GetPreviewGateway", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) GetClientPolicy(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ClientPolicy, error) {
+	out := new(ClientPolicy)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/GetClientPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *managerClient) ArriveAsClient(ctx context.Context, in *ClientInfo, opts ...grpc.CallOption) (*SessionInfo, error) {
 	out := new(SessionInfo)
 	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/ArriveAsClient", in, out, opts...)
@@ -219,8 +267,108 @@ func (c *managerClient) GetLogs(ctx context.Context, in *GetLogsRequest, opts ..
 	return out, nil
 }
 
+func (c *managerClient) WatchLogs(ctx context.Context, in *WatchLogsRequest, opts ...grpc.CallOption) (Manager_WatchLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[0], "/telepresence.manager.Manager/WatchLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managerWatchLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Manager_WatchLogsClient interface {
+	Recv() (*LogLine, error)
+	grpc.ClientStream
+}
+
+type managerWatchLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *managerWatchLogsClient) Recv() (*LogLine, error) {
+	m := new(LogLine)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *managerClient) RunDiagnostics(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DiagnosticResults, error) {
+	out := new(DiagnosticResults)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/RunDiagnostics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) AdminListSessions(ctx context.Context, in *AdminRequest, opts ...grpc.CallOption) (*AdminSessionList, error) {
+	out := new(AdminSessionList)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/AdminListSessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) AdminRemoveIntercept(ctx context.Context, in *AdminRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/AdminRemoveIntercept", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) AdminExpireSession(ctx context.Context, in *AdminRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/AdminExpireSession", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) WatchNotifications(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchNotificationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[1], "/telepresence.manager.Manager/WatchNotifications", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managerWatchNotificationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Manager_WatchNotificationsClient interface {
+	Recv() (*Notification, error)
+	grpc.ClientStream
+}
+
+type managerWatchNotificationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *managerWatchNotificationsClient) Recv() (*Notification, error) {
+	m := new(Notification)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *managerClient) WatchAgents(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchAgentsClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[0], "/telepresence.manager.Manager/WatchAgents", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[2], "/telepresence.manager.Manager/WatchAgents", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -252,7 +400,7 @@ func (x *managerWatchAgentsClient) Recv() (*AgentInfoSnapshot, error) {
 }
 
 func (c *managerClient) WatchIntercepts(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchInterceptsClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[1], "/telepresence.manager.Manager/WatchIntercepts", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[3], "/telepresence.manager.Manager/WatchIntercepts", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -284,7 +432,7 @@ func (x *managerWatchInterceptsClient) Recv() (*InterceptInfoSnapshot, error) {
 }
 
 func (c *managerClient) WatchClusterInfo(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchClusterInfoClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[2], "/telepresence.manager.Manager/WatchClusterInfo", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[4], "/telepresence.manager.Manager/WatchClusterInfo", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -315,6 +463,15 @@ func (x *managerWatchClusterInfoClient) Recv() (*ClusterInfo, error) {
 	return m, nil
 }
 
+func (c *managerClient) GetEgressStatus(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (*EgressRuleStatusList, error) {
+	out := new(EgressRuleStatusList)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/GetEgressStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *managerClient) CreateIntercept(ctx context.Context, in *CreateInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error) {
 	out := new(InterceptInfo)
 	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/CreateIntercept", in, out, opts...)
@@ -333,6 +490,15 @@ func (c *managerClient) RemoveIntercept(ctx context.Context, in *RemoveIntercept
 	return out, nil
 }
 
+func (c *managerClient) TransferIntercept(ctx context.Context, in *TransferInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error) {
+	out := new(InterceptInfo)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/TransferIntercept", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *managerClient) UpdateIntercept(ctx context.Context, in *UpdateInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error) {
 	out := new(InterceptInfo)
 	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/UpdateIntercept", in, out, opts...)
@@ -361,7 +527,7 @@ func (c *managerClient) ReviewIntercept(ctx context.Context, in *ReviewIntercept
 }
 
 func (c *managerClient) ClientTunnel(ctx context.Context, opts ...grpc.CallOption) (Manager_ClientTunnelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[3], "/telepresence.manager.Manager/ClientTunnel", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[5], "/telepresence.manager.Manager/ClientTunnel", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -392,7 +558,7 @@ func (x *managerClientTunnelClient) Recv() (*ConnMessage, error) {
 }
 
 func (c *managerClient) AgentTunnel(ctx context.Context, opts ...grpc.CallOption) (Manager_AgentTunnelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[4], "/telepresence.manager.Manager/AgentTunnel", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[6], "/telepresence.manager.Manager/AgentTunnel", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -441,7 +607,7 @@ func (c *managerClient) AgentLookupHostResponse(ctx context.Context, in *LookupH
 }
 
 func (c *managerClient) WatchLookupHost(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchLookupHostClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[5], "/telepresence.manager.Manager/WatchLookupHost", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[7], "/telepresence.manager.Manager/WatchLookupHost", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -473,7 +639,7 @@ func (x *managerWatchLookupHostClient) Recv() (*LookupHostRequest, error) {
 }
 
 func (c *managerClient) WatchLogLevel(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (Manager_WatchLogLevelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[6], "/telepresence.manager.Manager/WatchLogLevel", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[8], "/telepresence.manager.Manager/WatchLogLevel", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -505,7 +671,7 @@ func (x *managerWatchLogLevelClient) Recv() (*LogLevelRequest, error) {
 }
 
 func (c *managerClient) Tunnel(ctx context.Context, opts ...grpc.CallOption) (Manager_TunnelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[7], "/telepresence.manager.Manager/Tunnel", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[9], "/telepresence.manager.Manager/Tunnel", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -536,7 +702,7 @@ func (x *managerTunnelClient) Recv() (*TunnelMessage, error) {
 }
 
 func (c *managerClient) WatchDial(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchDialClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[8], "/telepresence.manager.Manager/WatchDial", opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[10], "/telepresence.manager.Manager/WatchDial", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -584,6 +750,10 @@ type ManagerServer interface {
 	GetCloudConfig(context.Context, *emptypb.Empty) (*AmbassadorCloudConfig, error)
 	// GetTelepresenceAPI returns information about the TelepresenceAPI server
 	GetTelepresenceAPI(context.Context, *emptypb.Empty) (*TelepresenceAPIInfo, error)
+	// GetPreviewGateway returns information about the preview gateway of the traffic-manager
+	GetPreviewGateway(context.Context, *emptypb.Empty) (*PreviewGateway, error)
+	// GetClientPolicy returns the cluster-wide client policy
+	GetClientPolicy(context.Context, *emptypb.Empty) (*ClientPolicy, error)
 	// ArriveAsClient establishes a session between a client and the Manager.
 	ArriveAsClient(context.Context, *ClientInfo) (*SessionInfo, error)
 	// ArriveAsAgent establishes a session between an agent and the Manager.
@@ -599,6 +769,26 @@ type ManagerServer interface {
 	// GetLogs will acquire logs for the various Telepresence components in kubernetes
 	// (pending the request) and return them to the caller
 	GetLogs(context.Context, *GetLogsRequest) (*LogsResponse, error)
+	// WatchLogs streams the logs of the various Telepresence components in kubernetes
+	// (pending the request), one line at a time. Lines from different pods are
+	// interleaved in the order that they arrive.
+	WatchLogs(*WatchLogsRequest, Manager_WatchLogsServer) error
+	// RunDiagnostics performs the traffic-manager's self-checks, such as verifying
+	// that the Kubernetes API server can be reached, and returns the results.
+	RunDiagnostics(context.Context, *emptypb.Empty) (*DiagnosticResults, error)
+	// AdminListSessions returns all client and agent sessions. Requires
+	// admin permission.
+	AdminListSessions(context.Context, *AdminRequest) (*AdminSessionList, error)
+	// AdminRemoveIntercept removes an intercept regardless of what client
+	// session it belongs to. The client is notified. Requires admin permission.
+	AdminRemoveIntercept(context.Context, *AdminRequest) (*emptypb.Empty, error)
+	// AdminExpireSession removes a client or agent session along with its
+	// intercepts. A client is notified. Requires admin permission.
+	AdminExpireSession(context.Context, *AdminRequest) (*emptypb.Empty, error)
+	// WatchNotifications streams messages that are intended for the user of
+	// the given client session, such as notices about intercepts that an admin
+	// removed. The stream ends when the session ends.
+	WatchNotifications(*SessionInfo, Manager_WatchNotificationsServer) error
 	// WatchAgents notifies a client of the set of known Agents.
 	//
 	// A session ID is required; if no session ID is given then the call
@@ -614,6 +804,9 @@ type ManagerServer interface {
 	// WatchClusterInfo returns information needed when establishing
 	// connectivity to the cluster.
 	WatchClusterInfo(*SessionInfo, Manager_WatchClusterInfoServer) error
+	// GetEgressStatus returns the egress rules of the given client session
+	// together with the traffic-agents that the rules currently select.
+	GetEgressStatus(context.Context, *SessionInfo) (*EgressRuleStatusList, error)
 	// CreateIntercept lets a client create an intercept.  It will be
 	// created in the "WATING" disposition, and it will remain in that
 	// state until the Agent (the app-sidecar) calls ReviewIntercept()
@@ -622,6 +815,9 @@ type ManagerServer interface {
 	CreateIntercept(context.Context, *CreateInterceptRequest) (*InterceptInfo, error)
 	// RemoveIntercept lets a client remove an intercept.
 	RemoveIntercept(context.Context, *RemoveInterceptRequest2) (*emptypb.Empty, error)
+	// TransferIntercept lets a client hand one of its intercepts over to
+	// another client without interrupting the intercepted traffic.
+	TransferIntercept(context.Context, *TransferInterceptRequest) (*InterceptInfo, error)
 	UpdateIntercept(context.Context, *UpdateInterceptRequest) (*InterceptInfo, error)
 	// GetIntercept gets info from intercept name
 	GetIntercept(context.Context, *GetInterceptRequest) (*InterceptInfo, error)
@@ -684,6 +880,12 @@ func (UnimplementedManagerServer) GetCloudConfig(context.Context, *emptypb.Empty
 func (UnimplementedManagerServer) GetTelepresenceAPI(context.Context, *emptypb.Empty) (*TelepresenceAPIInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetTelepresenceAPI not implemented")
 }
+func (UnimplementedManagerServer) GetPreviewGateway(context.Context, *emptypb.Empty) (*PreviewGateway, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPreviewGateway not implemented")
+}
+func (UnimplementedManagerServer) GetClientPolicy(context.Context, *emptypb.Empty) (*ClientPolicy, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClientPolicy not implemented")
+}
 func (UnimplementedManagerServer) ArriveAsClient(context.Context, *ClientInfo) (*SessionInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ArriveAsClient not implemented")
 }
@@ -702,6 +904,24 @@ func (UnimplementedManagerServer) SetLogLevel(context.Context, *LogLevelRequest)
 func (UnimplementedManagerServer) GetLogs(context.Context, *GetLogsRequest) (*LogsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetLogs not implemented")
 }
+func (UnimplementedManagerServer) WatchLogs(*WatchLogsRequest, Manager_WatchLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchLogs not implemented")
+}
+func (UnimplementedManagerServer) RunDiagnostics(context.Context, *emptypb.Empty) (*DiagnosticResults, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunDiagnostics not implemented")
+}
+func (UnimplementedManagerServer) AdminListSessions(context.Context, *AdminRequest) (*AdminSessionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdminListSessions not implemented")
+}
+func (UnimplementedManagerServer) AdminRemoveIntercept(context.Context, *AdminRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdminRemoveIntercept not implemented")
+}
+func (UnimplementedManagerServer) AdminExpireSession(context.Context, *AdminRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdminExpireSession not implemented")
+}
+func (UnimplementedManagerServer) WatchNotifications(*SessionInfo, Manager_WatchNotificationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchNotifications not implemented")
+}
 func (UnimplementedManagerServer) WatchAgents(*SessionInfo, Manager_WatchAgentsServer) error {
 	return status.Errorf(codes.Unimplemented, "method WatchAgents not implemented")
 }
@@ -711,12 +931,18 @@ func (UnimplementedManagerServer) WatchIntercepts(*SessionInfo, Manager_WatchInt
 func (UnimplementedManagerServer) WatchClusterInfo(*SessionInfo, Manager_WatchClusterInfoServer) error {
 	return status.Errorf(codes.Unimplemented, "method WatchClusterInfo not implemented")
 }
+func (UnimplementedManagerServer) GetEgressStatus(context.Context, *SessionInfo) (*EgressRuleStatusList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEgressStatus not implemented")
+}
 func (UnimplementedManagerServer) CreateIntercept(context.Context, *CreateInterceptRequest) (*InterceptInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateIntercept not implemented")
 }
 func (UnimplementedManagerServer) RemoveIntercept(context.Context, *RemoveInterceptRequest2) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveIntercept not implemented")
 }
+func (UnimplementedManagerServer) TransferIntercept(context.Context, *TransferInterceptRequest) (*InterceptInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransferIntercept not implemented")
+}
 func (UnimplementedManagerServer) UpdateIntercept(context.Context, *UpdateInterceptRequest) (*InterceptInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateIntercept not implemented")
 }
@@ -853,6 +1079,42 @@ func _Manager_GetTelepresenceAPI_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Manager_GetPreviewGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).GetPreviewGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/GetPreviewGateway",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).GetPreviewGateway(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_GetClientPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).GetClientPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/GetClientPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).GetClientPolicy(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Manager_ArriveAsClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ClientInfo)
 	if err := dec(in); err != nil {
@@ -961,6 +1223,120 @@ func _Manager_GetLogs_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Manager_WatchLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagerServer).WatchLogs(m, &managerWatchLogsServer{stream})
+}
+
+type Manager_WatchLogsServer interface {
+	Send(*LogLine) error
+	grpc.ServerStream
+}
+
+type managerWatchLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *managerWatchLogsServer) Send(m *LogLine) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Manager_RunDiagnostics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).RunDiagnostics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/RunDiagnostics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).RunDiagnostics(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_AdminListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).AdminListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/AdminListSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).AdminListSessions(ctx, req.(*AdminRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_AdminRemoveIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).AdminRemoveIntercept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/AdminRemoveIntercept",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).AdminRemoveIntercept(ctx, req.(*AdminRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_AdminExpireSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).AdminExpireSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/AdminExpireSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).AdminExpireSession(ctx, req.(*AdminRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_WatchNotifications_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SessionInfo)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagerServer).WatchNotifications(m, &managerWatchNotificationsServer{stream})
+}
+
+type Manager_WatchNotificationsServer interface {
+	Send(*Notification) error
+	grpc.ServerStream
+}
+
+type managerWatchNotificationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *managerWatchNotificationsServer) Send(m *Notification) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Manager_WatchAgents_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(SessionInfo)
 	if err := stream.RecvMsg(m); err != nil {
@@ -1024,6 +1400,24 @@ func (x *managerWatchClusterInfoServer) Send(m *ClusterInfo) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Manager_GetEgressStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionInfo)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).GetEgressStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/GetEgressStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).GetEgressStatus(ctx, req.(*SessionInfo))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Manager_CreateIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateInterceptRequest)
 	if err := dec(in); err != nil {
@@ -1060,6 +1454,24 @@ func _Manager_RemoveIntercept_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Manager_TransferIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferInterceptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).TransferIntercept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/TransferIntercept",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).TransferIntercept(ctx, req.(*TransferInterceptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Manager_UpdateIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateInterceptRequest)
 	if err := dec(in); err != nil {
@@ -1318,6 +1730,14 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTelepresenceAPI",
 			Handler:    _Manager_GetTelepresenceAPI_Handler,
 		},
+		{
+			MethodName: "GetPreviewGateway",
+			Handler:    _Manager_GetPreviewGateway_Handler,
+		},
+		{
+			MethodName: "GetClientPolicy",
+			Handler:    _Manager_GetClientPolicy_Handler,
+		},
 		{
 			MethodName: "ArriveAsClient",
 			Handler:    _Manager_ArriveAsClient_Handler,
@@ -1342,6 +1762,26 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetLogs",
 			Handler:    _Manager_GetLogs_Handler,
 		},
+		{
+			MethodName: "RunDiagnostics",
+			Handler:    _Manager_RunDiagnostics_Handler,
+		},
+		{
+			MethodName: "AdminListSessions",
+			Handler:    _Manager_AdminListSessions_Handler,
+		},
+		{
+			MethodName: "AdminRemoveIntercept",
+			Handler:    _Manager_AdminRemoveIntercept_Handler,
+		},
+		{
+			MethodName: "AdminExpireSession",
+			Handler:    _Manager_AdminExpireSession_Handler,
+		},
+		{
+			MethodName: "GetEgressStatus",
+			Handler:    _Manager_GetEgressStatus_Handler,
+		},
 		{
 			MethodName: "CreateIntercept",
 			Handler:    _Manager_CreateIntercept_Handler,
@@ -1350,6 +1790,10 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveIntercept",
 			Handler:    _Manager_RemoveIntercept_Handler,
 		},
+		{
+			MethodName: "TransferIntercept",
+			Handler:    _Manager_TransferIntercept_Handler,
+		},
 		{
 			MethodName: "UpdateIntercept",
 			Handler:    _Manager_UpdateIntercept_Handler,
@@ -1372,6 +1816,16 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchLogs",
+			Handler:       _Manager_WatchLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchNotifications",
+			Handler:       _Manager_WatchNotifications_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "WatchAgents",
 			Handler:       _Manager_WatchAgents_Handler,